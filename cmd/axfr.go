@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var (
+	axfrServers []string
+	axfrSave    string
+)
+
+var dnsAxfrCmd = &cobra.Command{
+	Use:   "axfr [domain]",
+	Short: color.RedString("Zone Transfer - Check nameservers for AXFR/IXFR exposure"),
+	Long: `Attempt full (AXFR) and incremental (IXFR) zone transfers against every
+authoritative nameserver of a domain and report the servers that leak
+the zone:
+
+Features:
+  ▸ Finds the zone from its SOA record and every NS address (IPv4 and IPv6)
+  ▸ AXFR and IXFR attempts per nameserver address
+  ▸ Transferred records saved in zone file format
+
+Flags:
+      --server     Nameserver to try instead of the zone's NS records,
+                   host or host:port, or tls://host[:port] for a
+                   transfer over TLS (repeatable)
+      --save       Write the transferred records to this file
+  -v, --verbose    List the transferred records
+  -t, --timeout    Seconds to wait for each response (default: 5)
+      --resolver   Resolver used to find the nameservers (repeatable)
+      --retries    Extra rounds over the resolvers on failure (default: 2)
+
+Examples:
+  afsa dns axfr example.com
+  afsa dns axfr example.com --save example.com.zone
+  afsa dns axfr example.com --server 127.0.0.1:5353
+  afsa dns axfr example.com --server tls://ns1.example.com
+  afsa dns axfr example.com -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		return runReport("dns-axfr", domain, func() (report, error) {
+			res, err := dns.ZoneTransfer(cmd.Context(), domain, dns.TransferOptions{
+				ResolverOptions: dnsResolverOptions(),
+				Servers:         axfrServers,
+			})
+			if err != nil {
+				return (*DNSTransferReport)(res), err
+			}
+			if axfrSave != "" && len(res.Leaking) > 0 {
+				if err := saveZone(res, axfrSave); err != nil {
+					return nil, err
+				}
+				res.File = axfrSave
+			}
+			return (*DNSTransferReport)(res), nil
+		})
+	},
+}
+
+func init() {
+	dnsAxfrCmd.Flags().StringSliceVar(&axfrServers, "server", nil, "Nameserver to try instead of the zone's NS records (repeatable)")
+	dnsAxfrCmd.Flags().StringVar(&axfrSave, "save", "", "Write the transferred records to this file")
+	dnsCmd.AddCommand(dnsAxfrCmd)
+}
+
+func saveZone(res *dns.TransferResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := res.WriteZone(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DNSTransferReport renders a dns.TransferResult.
+type DNSTransferReport dns.TransferResult
+
+func (r *DNSTransferReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          ZONE TRANSFER EXPOSURE CHECK                  ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Zone: %s\n", r.Zone)
+	color.Cyan("  Nameserver Addresses: %d\n\n", len(r.Servers))
+
+	color.Red("  ▸ Transfer Attempts:\n")
+	for i, st := range r.Servers {
+		branch, indent := "├─", "│  "
+		if i == len(r.Servers)-1 {
+			branch, indent = "└─", "   "
+		}
+		name := st.Server
+		if st.Nameserver != "" {
+			name = fmt.Sprintf("%s (%s)", st.Nameserver, orPlaceholder(st.Server, "no address"))
+		}
+		fmt.Printf("    %s %s\n", branch, color.CyanString(name))
+		if st.Error != "" {
+			fmt.Printf("    %s└─ %s\n", indent, color.YellowString(st.Error))
+			continue
+		}
+		fmt.Printf("    %s├─ AXFR: %s\n", indent, transferLabel(st.AXFR))
+		fmt.Printf("    %s└─ IXFR: %s\n", indent, transferLabel(st.IXFR))
+	}
+
+	if dnsVerbose {
+		for _, st := range r.Servers {
+			if len(st.Records) == 0 {
+				continue
+			}
+			color.Red("\n  ▸ Records from %s:\n", st.Server)
+			for i, rec := range st.Records {
+				prefix := "├─"
+				if i == len(st.Records)-1 {
+					prefix = "└─"
+				}
+				fmt.Printf("    %s %s %s %s\n", prefix, rec.Name, color.YellowString(rec.Type), rec.Data)
+			}
+		}
+	}
+
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Nameserver Addresses: %d\n", len(r.Servers))
+	if len(r.Leaking) > 0 {
+		fmt.Printf("    ├─ Leaking the Zone: %s\n", color.RedString("%d (%v)", len(r.Leaking), r.Leaking))
+	} else {
+		fmt.Printf("    ├─ Leaking the Zone: %s\n", color.GreenString("0"))
+	}
+	fmt.Printf("    └─ Saved To: %s\n", orPlaceholder(r.File, "-"))
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Zone Transfer Check Completed                   ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func transferLabel(a dns.TransferAttempt) string {
+	if a.Allowed {
+		label := color.RedString("ALLOWED ✗ (%d records)", a.Records)
+		if a.Error != "" {
+			label += color.YellowString(" interrupted: %s", a.Error)
+		}
+		return label
+	}
+	if a.Refused {
+		return color.GreenString("refused ✓ (%s)", a.Error)
+	}
+	if a.Error != "" {
+		return color.YellowString("failed (%s)", a.Error)
+	}
+	return color.GreenString("nothing disclosed ✓")
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var dnsDelegationCmd = &cobra.Command{
+	Use:   "delegation [domain]",
+	Short: color.RedString("Delegation Health - Nameserver consistency and redundancy audit"),
+	Long: `Audit the delegation of a domain's zone and the nameservers serving it:
+
+Features:
+  ▸ Parent-zone NS records and glue against the zone's own NS set
+  ▸ SOA serials compared across every nameserver address
+  ▸ Lame delegation: servers that don't answer authoritatively
+  ▸ IPv6 addresses and reachability of each nameserver
+  ▸ Network diversity: origin ASN and /24 of every address
+  ▸ Open recursion on the authoritative servers
+
+Flags:
+  -v, --verbose    Show the network and response time of each address
+  -t, --timeout    Seconds to wait for each response (default: 5)
+      --resolver   Resolver to query, host or host:port (repeatable)
+
+Examples:
+  afsa dns delegation example.com
+  afsa dns delegation example.com -v
+  afsa dns delegation example.com -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		return runReport("dns-delegation", domain, func() (report, error) {
+			res, err := dns.CheckDelegation(cmd.Context(), domain, dns.DelegationOptions{
+				ResolverOptions: dnsResolverOptions(),
+			})
+			return (*DelegationReport)(res), err
+		})
+	},
+}
+
+func init() {
+	dnsCmd.AddCommand(dnsDelegationCmd)
+}
+
+// DelegationReport renders a dns.DelegationResult.
+type DelegationReport dns.DelegationResult
+
+func (r *DelegationReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          DELEGATION HEALTH REPORT                      ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Zone: %s\n", strings.TrimSuffix(r.Zone, "."))
+	color.Cyan("  Parent Zone: %s\n\n", r.Parent)
+
+	color.Red("  ▸ NS Records:\n")
+	fmt.Printf("    ├─ Parent: %s\n", orPlaceholder(strings.Join(r.ParentNS, ", "), "none"))
+	fmt.Printf("    └─ Child: %s\n", orPlaceholder(strings.Join(r.ChildNS, ", "), "none"))
+
+	color.Red("\n  ▸ Nameservers:\n")
+	for i, h := range r.Nameservers {
+		branch, indent := "├─", "│  "
+		if i == len(r.Nameservers)-1 {
+			branch, indent = "└─", "   "
+		}
+		var listed []string
+		if h.InParent {
+			listed = append(listed, "parent")
+		}
+		if h.InChild {
+			listed = append(listed, "child")
+		}
+		fmt.Printf("    %s %s (in %s)\n", branch, color.CyanString(h.Host), orPlaceholder(strings.Join(listed, " and "), "neither"))
+		if len(h.Addresses) == 0 {
+			fmt.Printf("    %s└─ %s\n", indent, color.RedString("no addresses"))
+		}
+		for j, a := range h.Addresses {
+			prefix := "├─"
+			if j == len(h.Addresses)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s%s %s %s\n", indent, prefix, a.Address, nameserverStatus(a))
+			if dnsVerbose && a.ASN != 0 {
+				sub := "│  "
+				if j == len(h.Addresses)-1 {
+					sub = "   "
+				}
+				fmt.Printf("    %s%s   AS%d %s, %s %s\n", indent, sub, a.ASN, a.ASName, a.Prefix, a.Country)
+			}
+		}
+	}
+
+	color.Red("\n  ▸ Findings:\n")
+	if len(r.Findings) == 0 {
+		fmt.Printf("    └─ %s\n", color.GreenString("no issues found"))
+	}
+	for i, f := range r.Findings {
+		prefix := "├─"
+		if i == len(r.Findings)-1 {
+			prefix = "└─"
+		}
+		fmt.Printf("    %s %s %s %s\n", prefix, severityLabel(f.Severity), color.CyanString("[%s]", f.Check), f.Message)
+	}
+
+	if len(r.Errors) > 0 && dnsVerbose {
+		color.Red("\n  ▸ Query Errors:\n")
+		for i, e := range r.Errors {
+			prefix := "├─"
+			if i == len(r.Errors)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, color.RedString(e))
+		}
+	}
+
+	counts := map[string]int{}
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+	}
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Nameservers: %d\n", len(r.Nameservers))
+	fmt.Printf("    ├─ High: %d\n", counts[dns.SeverityHigh])
+	fmt.Printf("    ├─ Medium: %d\n", counts[dns.SeverityMedium])
+	fmt.Printf("    └─ Low: %d\n", counts[dns.SeverityLow])
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Delegation Check Completed                      ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func nameserverStatus(a dns.NameserverAddress) string {
+	var status string
+	switch {
+	case a.Authoritative:
+		status = color.GreenString("authoritative, serial %d", a.Serial)
+		if dnsVerbose {
+			status += fmt.Sprintf(" (%dms)", a.RTTMS)
+		}
+	case a.Responded:
+		status = color.RedString("lame: %s", a.Error)
+	case a.Untested:
+		status = color.YellowString("untested: no IPv6 connectivity here")
+	default:
+		status = color.RedString("no response: %s", a.Error)
+	}
+	if a.OpenRecursion {
+		status += color.RedString(", open recursion")
+	}
+	return status
+}
@@ -1,17 +1,33 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"net"
-	"sort"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
 )
 
 var (
-	dnsVerbose bool
-	dnsTimeout int
+	dnsVerbose       bool
+	dnsTimeout       int
+	dnsResolvers     []string
+	dnsTransport     string
+	dnsUDPSize       uint16
+	dnsRetries       int
+	dnsAuthoritative bool
+	dnsCompare       bool
+	dnsTypes         []string
+	dnsDNSSEC        bool
+	dnsTrustAnchors  []string
+	dnsTLSCA         string
+	dnsTamper        bool
+	dnsEncrypted     []string
 )
 
 var dnsCmd = &cobra.Command{
@@ -27,160 +43,412 @@ Features:
   ▸ CNAME Records (Canonical names)
   ▸ TXT Records (Text records, SPF, DMARC, etc.)
   ▸ SOA Records (Start of Authority)
+  ▸ CAA Records (Certificate authorities allowed to issue)
+  ▸ SRV Records (common services such as _sip._tcp, _ldap._tcp)
+  ▸ PTR Records (reverse DNS for an IP address)
+  ▸ DNSKEY and DS Records (DNSSEC keys and delegation)
+  ▸ TLSA Records (DANE, for common TLS ports)
+  ▸ HTTPS, SVCB and NAPTR Records
+  Every record is shown with its TTL.
+  ▸ DNSSEC chain-of-trust validation from the root (--dnssec)
+  ▸ DNS over TLS and DNS over HTTPS resolvers
+  ▸ Plain vs encrypted DNS comparison to detect tampering (--tamper)
 
 Flags:
-  -v, --verbose     Show detailed information
-  -t, --timeout     Query timeout in seconds (default: 10)
+  -v, --verbose        Show detailed information
+      --type           Record types to query, comma separated or ALL
+                       (default: A,AAAA,CNAME,MX,NS,TXT,SOA,CAA; PTR for an IP)
+  -t, --timeout        Seconds to wait for each response (default: 5)
+      --resolver       Resolver to query, host or host:port, tls://host[:port]
+                       or an https:// DoH URL (repeatable; default: system
+                       resolvers)
+      --transport      udp, tcp, tls or https for resolvers given as a host
+                       (default: udp, tcp for truncated answers)
+      --tls-ca         PEM file of CA certificates to trust for tls/https
+                       resolvers instead of the system roots
+      --edns-size      EDNS0 UDP buffer size to advertise (default: 1232)
+      --retries        Extra rounds over all resolvers on failure (default: 2)
+      --authoritative  Query the domain's authoritative nameservers directly
+      --compare        Query every resolver and report differing answers
+      --dnssec         Validate the DNSSEC chain of trust from the root
+      --trust-anchor   Root DS record to trust instead of the IANA root keys
+                       (repeatable)
+      --tamper         Compare plain and encrypted DNS answers to detect
+                       interception and tampering
+      --encrypted-resolver
+                       tls:// or https:// resolver --tamper compares against
+                       (repeatable; default: Cloudflare and Google DoH)
 
 Examples:
   afsa dns example.com
   afsa dns google.com -v
-  afsa dns example.com --timeout=15`,
+  afsa dns example.com --type SOA,CAA
+  afsa dns example.com --type ALL
+  afsa dns 8.8.8.8
+  afsa dns example.com --timeout=15
+  afsa dns example.com --resolver 1.1.1.1:53
+  afsa dns example.com --resolver 1.1.1.1 --resolver 8.8.8.8 --compare
+  afsa dns example.com --authoritative --compare
+  afsa dns example.com --transport tcp
+  afsa dns example.com --resolver https://cloudflare-dns.com/dns-query
+  afsa dns example.com --resolver 9.9.9.9 --transport tls
+  afsa dns example.com --tamper
+  afsa dns example.com --tamper --encrypted-resolver tls://dns.quad9.net
+  afsa dns --dnssec example.com
+  afsa dns example.com -o json`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		performAdvancedDNSLookup(domain)
+		return runReport("dns", domain, func() (report, error) {
+			resolver := dnsResolverOptions()
+			var err error
+			if resolver.TLSConfig, err = dnsTLSConfig(); err != nil {
+				return nil, err
+			}
+			res, err := dns.Lookup(cmd.Context(), domain, dns.Options{
+				ResolverOptions:    resolver,
+				Transport:          dnsTransport,
+				UDPSize:            dnsUDPSize,
+				Authoritative:      dnsAuthoritative,
+				Compare:            dnsCompare,
+				Types:              dnsTypes,
+				DNSSEC:             dnsDNSSEC,
+				TrustAnchors:       dnsTrustAnchors,
+				Tamper:             dnsTamper,
+				EncryptedResolvers: dnsEncrypted,
+			})
+			return (*DNSReport)(res), err
+		})
 	},
 }
 
 func init() {
-	dnsCmd.Flags().BoolVarP(&dnsVerbose, "verbose", "v", false, "Verbose output")
-	dnsCmd.Flags().IntVarP(&dnsTimeout, "timeout", "t", 10, "Query timeout in seconds")
+	dnsCmd.PersistentFlags().BoolVarP(&dnsVerbose, "verbose", "v", false, "Verbose output")
+	dnsCmd.PersistentFlags().IntVarP(&dnsTimeout, "timeout", "t", 5, "Seconds to wait for each response")
+	dnsCmd.PersistentFlags().StringSliceVar(&dnsResolvers, "resolver", nil, "Resolver to query, host or host:port (repeatable)")
+	dnsCmd.Flags().StringVar(&dnsTransport, "transport", "", "Query transport: udp, tcp, tls or https (default: udp with tcp fallback)")
+	dnsCmd.Flags().StringVar(&dnsTLSCA, "tls-ca", "", "PEM file of CA certificates to trust for tls/https resolvers")
+	dnsCmd.Flags().Uint16Var(&dnsUDPSize, "edns-size", dns.DefaultUDPSize, "EDNS0 UDP buffer size to advertise")
+	dnsCmd.PersistentFlags().IntVar(&dnsRetries, "retries", dns.DefaultRetries, "Extra rounds over all resolvers on failure")
+	dnsCmd.Flags().BoolVar(&dnsAuthoritative, "authoritative", false, "Query the domain's authoritative nameservers directly")
+	dnsCmd.Flags().BoolVar(&dnsCompare, "compare", false, "Query every resolver and report differing answers")
+	dnsCmd.Flags().BoolVar(&dnsDNSSEC, "dnssec", false, "Validate the DNSSEC chain of trust from the root")
+	dnsCmd.Flags().StringArrayVar(&dnsTrustAnchors, "trust-anchor", nil, "Root DS record to trust instead of the IANA root keys (repeatable)")
+	dnsCmd.Flags().StringSliceVar(&dnsTypes, "type", nil, "Record types to query, comma separated or ALL")
+	dnsCmd.Flags().BoolVar(&dnsTamper, "tamper", false, "Compare plain and encrypted DNS answers to detect tampering")
+	dnsCmd.Flags().StringSliceVar(&dnsEncrypted, "encrypted-resolver", nil, "tls:// or https:// resolver --tamper compares against (repeatable)")
 }
 
-func performAdvancedDNSLookup(domain string) {
+// dnsTLSConfig returns the TLS settings for encrypted resolvers, trusting
+// only the --tls-ca certificates when it is given.
+func dnsTLSConfig() (*tls.Config, error) {
+	if dnsTLSCA == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(dnsTLSCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", dnsTLSCA)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// dnsRetryCount maps --retries to dns.ResolverOptions.Retries, where zero
+// selects the default and a negative value disables retries.
+func dnsRetryCount() int {
+	if dnsRetries <= 0 {
+		return -1
+	}
+	return dnsRetries
+}
+
+// dnsResolverOptions returns the resolver settings given to the dns
+// commands by --timeout, --resolver and --retries.
+func dnsResolverOptions() dns.ResolverOptions {
+	return dns.ResolverOptions{
+		Timeout:   time.Duration(dnsTimeout) * time.Second,
+		Resolvers: dnsResolvers,
+		Retries:   dnsRetryCount(),
+	}
+}
+
+// DNSReport renders a dns.Result.
+type DNSReport dns.Result
+
+func (r *DNSReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║             DNS RECONNAISSANCE REPORT                   ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  Domain: %s\n\n", domain)
-
-	// Validate domain
-	if err := validateDomain(domain); err != nil {
-		color.Red("  [✗] Invalid domain: %v\n\n", err)
-		return
-	}
-
-	// A Records (IPv4)
-	aRecords, err := net.LookupHost(domain)
-	if err == nil && len(aRecords) > 0 {
-		color.Red("  ▸ A Records (IPv4 Addresses):\n")
-		ipv4s := filterIPv4(aRecords)
-		if len(ipv4s) > 0 {
-			for i, ip := range ipv4s {
-				if i == len(ipv4s)-1 {
-					fmt.Printf("    └─ %s\n", color.GreenString(ip))
-				} else {
-					fmt.Printf("    ├─ %s\n", color.GreenString(ip))
-				}
-			}
-		}
+	color.Cyan("  Domain: %s\n", r.Domain)
+	if r.Zone != "" {
+		color.Cyan("  Authoritative for zone: %s\n", r.Zone)
+	}
+	color.Cyan("  Resolvers: %s (%s)\n", strings.Join(r.Resolvers, ", "), r.Transport)
+	color.Cyan("  Record Types: %s\n\n", strings.Join(r.Types, ", "))
+	if r.NXDomain {
+		color.Yellow("  ⚠  %s does not exist (NXDOMAIN)\n\n", r.Domain)
 	}
 
-	// AAAA Records (IPv6)
-	aaaaRecords := filterIPv6(aRecords)
-	if len(aaaaRecords) > 0 {
-		color.Red("  ▸ AAAA Records (IPv6 Addresses):\n")
-		for i, ip := range aaaaRecords {
-			if i == len(aaaaRecords)-1 {
-				fmt.Printf("    └─ %s\n", color.CyanString(ip))
-			} else {
-				fmt.Printf("    ├─ %s\n", color.CyanString(ip))
+	res := (*dns.Result)(r)
+	for _, typ := range r.Types {
+		records := res.RecordsOf(typ)
+		if len(records) == 0 {
+			continue
+		}
+		color.Red("  ▸ %s:\n", dnsSectionTitles[typ])
+		for i, rec := range records {
+			prefix := "├─"
+			if i == len(records)-1 {
+				prefix = "└─"
+			}
+			data := rec.Data
+			if (typ == "TXT" || typ == "DNSKEY") && len(data) > 60 && !dnsVerbose {
+				data = data[:57] + "..."
+			}
+			value := dnsRecordColor(typ)(data)
+			if !strings.EqualFold(strings.TrimSuffix(rec.Name, "."), strings.TrimSuffix(r.Domain, ".")) {
+				// Enumerated names (SRV, TLSA) and reverse lookups
+				value = rec.Name + " → " + value
 			}
+			fmt.Printf("    %s %s %s\n", prefix, value, color.HiBlackString("(TTL %ds)", rec.TTL))
 		}
 	}
 
-	// MX Records
-	mxRecords, err := net.LookupMX(domain)
-	if err == nil && len(mxRecords) > 0 {
-		color.Red("  ▸ MX Records (Mail Servers):\n")
-		sort.Slice(mxRecords, func(i, j int) bool {
-			return mxRecords[i].Pref < mxRecords[j].Pref
-		})
-		for i, mx := range mxRecords {
-			if i == len(mxRecords)-1 {
-				fmt.Printf("    └─ %s (Priority: %d)\n", color.YellowString(mx.Host), mx.Pref)
-			} else {
-				fmt.Printf("    ├─ %s (Priority: %d)\n", color.YellowString(mx.Host), mx.Pref)
+	if len(r.Discrepancies) > 0 {
+		color.Red("  ▸ Resolver Discrepancies:\n")
+		for i, d := range r.Discrepancies {
+			last := i == len(r.Discrepancies)-1
+			branch, indent := "├─", "│  "
+			if last {
+				branch, indent = "└─", "   "
+			}
+			fmt.Printf("    %s %s %s\n", branch, color.YellowString(d.Type), d.Name)
+			for j, a := range d.Answers {
+				sub := "├─"
+				if j == len(d.Answers)-1 {
+					sub = "└─"
+				}
+				answer := strings.Join(a.Records, ", ")
+				if a.Error != "" {
+					answer = color.RedString(a.Error)
+				} else if answer == "" {
+					answer = "(no records)"
+				}
+				fmt.Printf("    %s%s %s: %s\n", indent, sub, a.Server, answer)
 			}
 		}
 	}
 
-	// NS Records
-	nsRecords, err := net.LookupNS(domain)
-	if err == nil && len(nsRecords) > 0 {
-		color.Red("  ▸ NS Records (Nameservers):\n")
-		for i, ns := range nsRecords {
-			if i == len(nsRecords)-1 {
-				fmt.Printf("    └─ %s\n", color.MagentaString(ns.Host))
-			} else {
-				fmt.Printf("    ├─ %s\n", color.MagentaString(ns.Host))
-			}
-		}
+	if r.DNSSEC != nil {
+		printDNSSEC(r.DNSSEC)
 	}
 
-	// CNAME Records
-	cname, err := net.LookupCNAME(domain)
-	if err == nil && cname != domain {
-		color.Red("  ▸ CNAME Record:\n")
-		fmt.Printf("    └─ %s\n", color.BlueString(cname))
+	if r.Tampering != nil {
+		printTampering(r.Tampering)
 	}
 
-	// TXT Records
-	txtRecords, err := net.LookupTXT(domain)
-	if err == nil && len(txtRecords) > 0 {
-		color.Red("  ▸ TXT Records:\n")
-		for i, txt := range txtRecords {
-			prefix := "├─ "
-			if i == len(txtRecords)-1 {
-				prefix = "└─ "
+	if len(r.Errors) > 0 && dnsVerbose {
+		color.Red("  ▸ Query Errors:\n")
+		for i, e := range r.Errors {
+			prefix := "├─"
+			if i == len(r.Errors)-1 {
+				prefix = "└─"
 			}
-			displayTxt := txt
-			if len(txt) > 60 && !dnsVerbose {
-				displayTxt = txt[:57] + "..."
-			}
-			fmt.Printf("    %s %s\n", prefix, color.WhiteString(displayTxt))
+			fmt.Printf("    %s %s\n", prefix, color.RedString(e))
 		}
 	}
 
 	// Summary statistics
 	color.Red("\n  ▸ Summary:\n")
-	fmt.Printf("    ├─ Total IPv4 Records: %d\n", len(filterIPv4(aRecords)))
-	fmt.Printf("    ├─ Total IPv6 Records: %d\n", len(aaaaRecords))
-	fmt.Printf("    ├─ Mail Servers: %d\n", len(mxRecords))
-	fmt.Printf("    └─ Nameservers: %d\n", len(nsRecords))
+	fmt.Printf("    ├─ Total IPv4 Records: %d\n", len(res.RecordsOf("A")))
+	fmt.Printf("    ├─ Total IPv6 Records: %d\n", len(res.RecordsOf("AAAA")))
+	fmt.Printf("    ├─ Mail Servers: %d\n", len(res.RecordsOf("MX")))
+	fmt.Printf("    ├─ Nameservers: %d\n", len(res.RecordsOf("NS")))
+	fmt.Printf("    ├─ Total Records: %d\n", len(r.Records))
+	if len(r.Resolvers) > 1 && dnsCompare {
+		fmt.Printf("    ├─ Discrepancies: %d\n", len(r.Discrepancies))
+	}
+	if r.DNSSEC != nil {
+		fmt.Printf("    ├─ DNSSEC: %s\n", dnssecStatusColor(r.DNSSEC.Status)(r.DNSSEC.Status))
+	}
+	if r.Tampering != nil {
+		fmt.Printf("    ├─ Tampering: %s\n", tamperVerdictColor(r.Tampering.Verdict)(r.Tampering.Verdict))
+	}
+	fmt.Printf("    └─ Failed Queries: %d\n", len(r.Errors))
 
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║     [✓] DNS Reconnaissance Completed Successfully      ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
 
-func validateDomain(domain string) error {
-	if domain == "" {
-		return fmt.Errorf("domain cannot be empty")
-	}
-	if len(domain) > 253 {
-		return fmt.Errorf("domain name too long")
+var dnsSectionTitles = map[string]string{
+	"A":      "A Records (IPv4 Addresses)",
+	"AAAA":   "AAAA Records (IPv6 Addresses)",
+	"CNAME":  "CNAME Record",
+	"MX":     "MX Records (Mail Servers)",
+	"NS":     "NS Records (Nameservers)",
+	"TXT":    "TXT Records",
+	"SOA":    "SOA Record (Start of Authority)",
+	"CAA":    "CAA Records (Certificate Authorities)",
+	"SRV":    "SRV Records (Services)",
+	"PTR":    "PTR Records (Reverse DNS)",
+	"DNSKEY": "DNSKEY Records (Zone Keys)",
+	"DS":     "DS Records (Delegation Signer)",
+	"TLSA":   "TLSA Records (DANE)",
+	"HTTPS":  "HTTPS Records (Service Binding)",
+	"SVCB":   "SVCB Records (Service Binding)",
+	"NAPTR":  "NAPTR Records (Naming Authority Pointer)",
+}
+
+func dnsRecordColor(typ string) func(format string, a ...interface{}) string {
+	switch typ {
+	case "A":
+		return color.GreenString
+	case "AAAA":
+		return color.CyanString
+	case "MX":
+		return color.YellowString
+	case "NS":
+		return color.MagentaString
+	case "CNAME", "PTR":
+		return color.BlueString
 	}
-	return nil
+	return color.WhiteString
 }
 
-func filterIPv4(ips []string) []string {
-	var result []string
-	for _, ip := range ips {
-		if net.ParseIP(ip).To4() != nil {
-			result = append(result, ip)
+func printDNSSEC(d *dns.DNSSECResult) {
+	color.Red("  ▸ DNSSEC Chain of Trust:\n")
+	fmt.Printf("    ├─ Status: %s\n", dnssecStatusColor(d.Status)(strings.ToUpper(d.Status)))
+	fmt.Printf("    ├─ %s\n", d.Reason)
+	for i, z := range d.Zones {
+		branch, indent := "├─", "│  "
+		if i == len(d.Zones)-1 {
+			branch, indent = "└─", "   "
+		}
+		fmt.Printf("    %s %s %s\n", branch, color.CyanString(z.Zone), dnssecStatusColor(z.Status)(z.Status))
+
+		var lines []string
+		for _, ds := range z.DS {
+			match := color.RedString("✗ no matching key")
+			if ds.Matched {
+				match = color.GreenString("✓")
+			}
+			lines = append(lines, fmt.Sprintf("DS %d %s/%s %s", ds.KeyTag, ds.Algorithm, ds.DigestType, match))
+		}
+		for _, k := range z.Keys {
+			line := fmt.Sprintf("%s %d %s", k.Role, k.KeyTag, k.Algorithm)
+			if k.Bits > 0 {
+				line += fmt.Sprintf(" (%d bits)", k.Bits)
+			}
+			lines = append(lines, line)
+		}
+		if dnsVerbose {
+			for _, s := range z.Signatures {
+				lines = append(lines, signatureLine(s))
+			}
+		} else {
+			for _, s := range z.Signatures {
+				if !s.Valid {
+					lines = append(lines, signatureLine(s))
+				}
+			}
+		}
+		switch {
+		case z.NSEC3 != nil:
+			optOut := ""
+			if z.NSEC3.OptOut {
+				optOut = ", opt-out"
+			}
+			lines = append(lines, fmt.Sprintf("Denial: NSEC3 (%d iterations, %d-byte salt%s)", z.NSEC3.Iterations, z.NSEC3.SaltLength, optOut))
+		case z.Denial != "":
+			lines = append(lines, "Denial: "+z.Denial)
+		}
+		for j, line := range lines {
+			sub := "├─"
+			if j == len(lines)-1 {
+				sub = "└─"
+			}
+			fmt.Printf("    %s%s %s\n", indent, sub, line)
 		}
 	}
-	return result
+
+	if dnsVerbose && len(d.Answer) > 0 {
+		color.Red("  ▸ DNSSEC Answer Signatures (%s):\n", d.Name)
+		for i, s := range d.Answer {
+			prefix := "├─"
+			if i == len(d.Answer)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, signatureLine(s))
+		}
+	}
+
+	if len(d.Warnings) > 0 {
+		color.Red("  ▸ DNSSEC Warnings:\n")
+		for i, w := range d.Warnings {
+			prefix := "├─"
+			if i == len(d.Warnings)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, color.YellowString(w))
+		}
+	}
+}
+
+func signatureLine(s dns.Signature) string {
+	line := fmt.Sprintf("RRSIG %s by %d %s, expires %s", s.Covers, s.KeyTag, s.Algorithm, s.Expiration.Format("2006-01-02"))
+	if !s.Valid {
+		return line + " " + color.RedString("✗ "+s.Error)
+	}
+	return line + " " + color.GreenString("✓")
 }
 
-func filterIPv6(ips []string) []string {
-	var result []string
-	for _, ip := range ips {
-		if net.ParseIP(ip).To4() == nil && net.ParseIP(ip) != nil {
-			result = append(result, ip)
+func dnssecStatusColor(status string) func(format string, a ...interface{}) string {
+	switch status {
+	case dns.StatusSecure:
+		return color.GreenString
+	case dns.StatusInsecure:
+		return color.YellowString
+	case dns.StatusBogus:
+		return color.RedString
+	}
+	return color.MagentaString
+}
+
+func printTampering(t *dns.TamperResult) {
+	color.Red("  ▸ Tampering Check (plain vs encrypted DNS):\n")
+	fmt.Printf("    ├─ Plain: %s\n", strings.Join(t.Plain, ", "))
+	fmt.Printf("    ├─ Encrypted: %s\n", strings.Join(t.Encrypted, ", "))
+	if t.Intercepted {
+		fmt.Printf("    ├─ Port 53: %s\n", color.RedString("intercepted ✗ (an address without a DNS server answered)"))
+	} else {
+		fmt.Printf("    ├─ Port 53: %s\n", color.GreenString("not intercepted ✓"))
+	}
+	if len(t.Differences) == 0 {
+		fmt.Printf("    ├─ Differences: %s\n", color.GreenString("none"))
+	}
+	for _, d := range t.Differences {
+		mark := color.YellowString("≠")
+		if d.Suspicious {
+			mark = color.RedString("✗")
 		}
+		fmt.Printf("    ├─ %s %s %s: %s\n", mark, color.YellowString(d.Type), d.Name, d.Reason)
+		fmt.Printf("    │  ├─ plain: %s\n", strings.Join(d.Plain, ", "))
+		fmt.Printf("    │  └─ encrypted: %s\n", strings.Join(d.Encrypted, ", "))
+	}
+	fmt.Printf("    └─ Verdict: %s\n", tamperVerdictColor(t.Verdict)(t.Verdict))
+}
+
+func tamperVerdictColor(verdict string) func(format string, a ...interface{}) string {
+	switch verdict {
+	case dns.TamperClean:
+		return color.GreenString
+	case dns.TamperSuspicious:
+		return color.YellowString
 	}
-	return result
+	return color.RedString
 }
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var emailSelectors []string
+
+var dnsEmailCmd = &cobra.Command{
+	Use:   "email [domain]",
+	Short: color.RedString("Email Security - SPF, DMARC, DKIM, MTA-STS, TLS-RPT and BIMI posture"),
+	Long: `Check the DNS records that protect a domain's mail and grade what is found:
+
+Features:
+  ▸ SPF parsing with include/redirect expanded against the 10-lookup limit
+  ▸ DMARC policy, subdomain policy, alignment and report addresses
+  ▸ DKIM keys at common selectors, with RSA key sizes
+  ▸ MTA-STS record and policy file (https://mta-sts.<domain>/.well-known/mta-sts.txt)
+  ▸ TLS-RPT reporting and BIMI brand indicator records
+  ▸ Findings graded high, medium, low and info
+
+Flags:
+      --selector   DKIM selector to probe instead of the built-in list
+                   (repeatable)
+  -v, --verbose    Show every expanded SPF record and informational findings
+  -t, --timeout    Seconds to wait for each response (default: 5)
+      --resolver   Resolver to query, host or host:port (repeatable)
+
+Examples:
+  afsa dns email example.com
+  afsa dns email example.com --selector s2048 --selector google
+  afsa dns email example.com -v -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		return runReport("dns-email", domain, func() (report, error) {
+			res, err := dns.EmailPosture(cmd.Context(), domain, dns.EmailOptions{
+				ResolverOptions: dnsResolverOptions(),
+				Selectors:       emailSelectors,
+			})
+			return (*EmailReport)(res), err
+		})
+	},
+}
+
+func init() {
+	dnsEmailCmd.Flags().StringSliceVar(&emailSelectors, "selector", nil, "DKIM selector to probe instead of the built-in list (repeatable)")
+	dnsCmd.AddCommand(dnsEmailCmd)
+}
+
+// EmailReport renders a dns.EmailResult.
+type EmailReport dns.EmailResult
+
+func (r *EmailReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          EMAIL SECURITY POSTURE REPORT                 ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Domain: %s\n", r.Domain)
+	color.Cyan("  Mail Exchangers: %s\n\n", orPlaceholder(strings.Join(r.MX, ", "), "none"))
+
+	color.Red("  ▸ SPF:\n")
+	if r.SPF == nil {
+		fmt.Printf("    └─ %s\n", color.RedString("not found"))
+	} else {
+		fmt.Printf("    ├─ Record: %s\n", color.WhiteString(r.SPF.Record))
+		fmt.Printf("    ├─ Ends With: %s\n", color.YellowString(orPlaceholder(r.SPF.All, "(no all mechanism)")))
+		lookups := color.GreenString("%d/%d", r.SPF.Lookups, dns.SPFLookupLimit)
+		if r.SPF.Lookups > dns.SPFLookupLimit {
+			lookups = color.RedString("%d/%d", r.SPF.Lookups, dns.SPFLookupLimit)
+		}
+		if dnsVerbose {
+			fmt.Printf("    ├─ DNS Lookups: %s\n", lookups)
+			fmt.Printf("    └─ Expansion:\n")
+			for _, rec := range r.SPF.Records {
+				line := rec.Record
+				if rec.Error != "" {
+					line = color.RedString(rec.Error)
+				}
+				via := ""
+				if rec.Via != "" {
+					via = rec.Via + ":"
+				}
+				fmt.Printf("       %s%s%s %s\n", strings.Repeat("  ", rec.Depth), color.CyanString(via), color.CyanString(rec.Domain), line)
+			}
+		} else {
+			fmt.Printf("    └─ DNS Lookups: %s\n", lookups)
+		}
+	}
+
+	color.Red("  ▸ DMARC:\n")
+	if r.DMARC == nil {
+		fmt.Printf("    └─ %s\n", color.RedString("not found"))
+	} else {
+		fmt.Printf("    ├─ Record: %s\n", color.WhiteString(r.DMARC.Record))
+		if r.DMARC.OrganizationalDomain != "" {
+			fmt.Printf("    ├─ Inherited From: %s (its subdomain policy applies)\n", r.DMARC.OrganizationalDomain)
+		}
+		fmt.Printf("    ├─ Policy: %s (subdomains: %s, %d%%)\n", color.YellowString(r.DMARC.Policy), r.DMARC.SubdomainPolicy, r.DMARC.Percent)
+		fmt.Printf("    ├─ Alignment: DKIM %s, SPF %s\n", r.DMARC.DKIMAlignment, r.DMARC.SPFAlignment)
+		fmt.Printf("    ├─ Aggregate Reports: %s\n", orPlaceholder(strings.Join(r.DMARC.AggregateReports, ", "), "none"))
+		fmt.Printf("    └─ Forensic Reports: %s\n", orPlaceholder(strings.Join(r.DMARC.ForensicReports, ", "), "none"))
+	}
+
+	color.Red("  ▸ DKIM:\n")
+	if len(r.DKIM) == 0 {
+		fmt.Printf("    └─ %s\n", color.YellowString("no key at the selectors probed"))
+	}
+	for i, k := range r.DKIM {
+		prefix := "├─"
+		if i == len(r.DKIM)-1 {
+			prefix = "└─"
+		}
+		key := strings.ToUpper(k.KeyType)
+		switch {
+		case k.Revoked:
+			key = "revoked"
+		case k.Bits > 0:
+			key = fmt.Sprintf("%s %d bits", key, k.Bits)
+		}
+		fmt.Printf("    %s %s: %s\n", prefix, color.CyanString(k.Selector), key)
+	}
+
+	color.Red("  ▸ MTA-STS:\n")
+	switch {
+	case r.MTASTS == nil:
+		fmt.Printf("    └─ %s\n", color.YellowString("not found"))
+	case r.MTASTS.Error != "":
+		fmt.Printf("    ├─ Record: %s\n", color.WhiteString(r.MTASTS.Record))
+		fmt.Printf("    └─ Policy: %s\n", color.RedString(r.MTASTS.Error))
+	default:
+		fmt.Printf("    ├─ Record: %s\n", color.WhiteString(r.MTASTS.Record))
+		fmt.Printf("    ├─ Mode: %s (max_age %d)\n", color.YellowString(r.MTASTS.Mode), r.MTASTS.MaxAge)
+		fmt.Printf("    └─ MX Patterns: %s\n", strings.Join(r.MTASTS.MX, ", "))
+	}
+
+	color.Red("  ▸ TLS-RPT:\n")
+	if r.TLSRPT == nil {
+		fmt.Printf("    └─ %s\n", color.YellowString("not found"))
+	} else {
+		fmt.Printf("    └─ Reports: %s\n", orPlaceholder(strings.Join(r.TLSRPT.Reports, ", "), "none"))
+	}
+
+	color.Red("  ▸ BIMI:\n")
+	if r.BIMI == nil {
+		fmt.Printf("    └─ %s\n", color.YellowString("not found"))
+	} else {
+		fmt.Printf("    ├─ Logo: %s\n", orPlaceholder(r.BIMI.Logo, "none"))
+		fmt.Printf("    └─ Certificate: %s\n", orPlaceholder(r.BIMI.Certificate, "none"))
+	}
+
+	var findings []dns.Finding
+	for _, f := range r.Findings {
+		if f.Severity != dns.SeverityInfo || dnsVerbose {
+			findings = append(findings, f)
+		}
+	}
+	color.Red("\n  ▸ Findings:\n")
+	if len(findings) == 0 {
+		fmt.Printf("    └─ %s\n", color.GreenString("no issues found"))
+	}
+	for i, f := range findings {
+		prefix := "├─"
+		if i == len(findings)-1 {
+			prefix = "└─"
+		}
+		fmt.Printf("    %s %s %s %s\n", prefix, severityLabel(f.Severity), color.CyanString("[%s]", f.Check), f.Message)
+	}
+
+	if len(r.Errors) > 0 && dnsVerbose {
+		color.Red("  ▸ Query Errors:\n")
+		for i, e := range r.Errors {
+			prefix := "├─"
+			if i == len(r.Errors)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, color.RedString(e))
+		}
+	}
+
+	counts := map[string]int{}
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+	}
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ High: %d\n", counts[dns.SeverityHigh])
+	fmt.Printf("    ├─ Medium: %d\n", counts[dns.SeverityMedium])
+	fmt.Printf("    ├─ Low: %d\n", counts[dns.SeverityLow])
+	fmt.Printf("    └─ Failed Queries: %d\n", len(r.Errors))
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Email Security Check Completed                  ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func severityLabel(severity string) string {
+	label := fmt.Sprintf("%-6s", strings.ToUpper(severity))
+	switch severity {
+	case dns.SeverityHigh:
+		return color.RedString(label)
+	case dns.SeverityMedium:
+		return color.YellowString(label)
+	case dns.SeverityLow:
+		return color.CyanString(label)
+	}
+	return color.HiBlackString(label)
+}
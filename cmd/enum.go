@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var (
+	enumWordlist     string
+	enumConcurrency  int
+	enumPermutations bool
+	enumDepth        int
+)
+
+var dnsEnumCmd = &cobra.Command{
+	Use:   "enum [domain]",
+	Short: color.RedString("Subdomain Enumeration - Wordlist brute force with wildcard detection"),
+	Long: `Find subdomains by resolving wordlist labels below a domain:
+
+Features:
+  ▸ Concurrent A/AAAA resolution, with the CNAME chain of each hit
+  ▸ Wildcard detection with random labels; wildcard answers are filtered
+  ▸ Permutations of the names found (api → api-dev, dev-api, api2)
+  ▸ Recursive expansion below the names found
+  ▸ Built-in wordlist of common host names
+
+Flags:
+  -w, --wordlist      File with one label per line (default: built-in list)
+  -c, --concurrency   Names resolved at once (default: 20)
+      --permutations  Also try variants of the names found
+      --depth         Levels below each name found to enumerate again
+                      (default: 0)
+  -t, --timeout       Seconds to wait for each response (default: 5)
+      --resolver      Resolver to query, host or host:port (repeatable)
+
+Examples:
+  afsa dns enum example.com
+  afsa dns enum example.com -w wordlist.txt
+  afsa dns enum example.com --permutations --depth 1
+  afsa dns enum example.com --resolver 1.1.1.1 -c 50 -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		return runReport("dns-enum", domain, func() (report, error) {
+			var words []string
+			if enumWordlist != "" {
+				var err error
+				if words, err = dns.LoadWordlist(enumWordlist); err != nil {
+					return nil, err
+				}
+				if len(words) == 0 {
+					return nil, fmt.Errorf("wordlist %s has no labels", enumWordlist)
+				}
+			}
+			progressf("  ⚡ Enumerating subdomains of %s...\n", domain)
+			res, err := dns.Enumerate(cmd.Context(), domain, dns.EnumOptions{
+				ResolverOptions: dnsResolverOptions(),
+				Wordlist:        words,
+				Concurrency:     enumConcurrency,
+				Permutations:    enumPermutations,
+				Depth:           enumDepth,
+				OnFound: func(s dns.Subdomain) {
+					progressf("    [+] %s %s\n", s.Name, strings.Join(subdomainAnswers(s), ", "))
+				},
+			})
+			return (*DNSEnumReport)(res), err
+		})
+	},
+}
+
+func init() {
+	dnsEnumCmd.Flags().StringVarP(&enumWordlist, "wordlist", "w", "", "File with one label per line (default: built-in list)")
+	dnsEnumCmd.Flags().IntVarP(&enumConcurrency, "concurrency", "c", dns.DefaultEnumConcurrency, "Names resolved at once")
+	dnsEnumCmd.Flags().BoolVar(&enumPermutations, "permutations", false, "Also try variants of the names found")
+	dnsEnumCmd.Flags().IntVar(&enumDepth, "depth", 0, "Levels below each name found to enumerate again")
+	dnsCmd.AddCommand(dnsEnumCmd)
+}
+
+// subdomainAnswers lists the CNAME chain followed by the addresses.
+func subdomainAnswers(s dns.Subdomain) []string {
+	var answers []string
+	for _, c := range s.CNAME {
+		answers = append(answers, "→ "+c)
+	}
+	answers = append(answers, s.A...)
+	return append(answers, s.AAAA...)
+}
+
+// DNSEnumReport renders a dns.EnumResult.
+type DNSEnumReport dns.EnumResult
+
+func (r *DNSEnumReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          SUBDOMAIN ENUMERATION REPORT                  ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Domain: %s\n", r.Domain)
+	color.Cyan("  Candidates Tried: %d\n\n", r.Candidates)
+
+	if len(r.Wildcards) > 0 {
+		color.Red("  ▸ Wildcard Records (answers filtered):\n")
+		for i, w := range r.Wildcards {
+			prefix := "├─"
+			if i == len(r.Wildcards)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s *.%s → %s\n", prefix, w.Name, color.YellowString(strings.Join(w.Answers, ", ")))
+		}
+	}
+	for _, name := range r.WildcardsUnknown {
+		color.Yellow("  ⚠  Wildcard check failed for %s: the names below it are unfiltered\n", name)
+	}
+
+	if len(r.Subdomains) > 0 {
+		color.Red("  ▸ Subdomains Found:\n")
+		for i, s := range r.Subdomains {
+			branch, indent := "├─", "│  "
+			if i == len(r.Subdomains)-1 {
+				branch, indent = "└─", "   "
+			}
+			source := ""
+			if s.Source != dns.SourceWordlist {
+				source = color.HiBlackString(" (%s)", s.Source)
+			}
+			fmt.Printf("    %s %s%s\n", branch, color.GreenString(s.Name), source)
+			var lines []string
+			for _, c := range s.CNAME {
+				lines = append(lines, "CNAME "+color.BlueString(c))
+			}
+			for _, a := range s.A {
+				lines = append(lines, "A     "+color.WhiteString(a))
+			}
+			for _, a := range s.AAAA {
+				lines = append(lines, "AAAA  "+color.CyanString(a))
+			}
+			for j, line := range lines {
+				sub := "├─"
+				if j == len(lines)-1 {
+					sub = "└─"
+				}
+				fmt.Printf("    %s%s %s\n", indent, sub, line)
+			}
+		}
+	}
+
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Subdomains Found: %d\n", len(r.Subdomains))
+	fmt.Printf("    ├─ Wildcards: %d\n", len(r.Wildcards))
+	fmt.Printf("    ├─ Failed Lookups: %d\n", r.Errors)
+	fmt.Printf("    ├─ Failed Wildcard Probes: %d\n", r.WildcardErrors)
+	fmt.Printf("    └─ Duration: %.1fs\n", float64(r.DurationMS)/1000)
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Subdomain Enumeration Completed                 ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
@@ -2,16 +2,20 @@ package cmd
 
 import (
 	"fmt"
-	"net"
-	"time"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/firewall"
+	"github.com/tanvircs/afsa/pkg/scan"
 )
 
 var (
-	ports    []string
-	detailed bool
+	firewallPortSpec    string
+	detailed            bool
+	firewallIPv4Only    bool
+	firewallIPv6Only    bool
+	firewallConcurrency int
 )
 
 var firewallCmd = &cobra.Command{
@@ -31,149 +35,238 @@ Features:
   ▸ Connection profiling
 
 Flags:
-  -p, --ports      Comma-separated ports to test (default: 80,443,22)
+  -p, --ports      Ports to test, nmap-style (default: 80,443,22,3306,5432)
+                   e.g. 22,80,443,8000-8100; TCP only, so U: entries
+                   are skipped
   -d, --detailed   Show detailed service information
+  -4, --ipv4       Connect over IPv4 only
+  -6, --ipv6       Connect over IPv6 only
+      --concurrency  Connections in flight at once (default: 100)
+
+A hostname is tested on every A and AAAA address it has, and the report
+compares which ports each address family reaches.
 
 Examples:
   afsa firewall status
   afsa firewall test example.com
   afsa firewall test example.com -p 22,80,443,3306
-  afsa firewall test example.com -d`,
+  afsa firewall test example.com -p 1-1024
+  afsa firewall test example.com -d
+  afsa firewall test 2001:db8::1 -p 22,443
+  afsa firewall test example.com -6`,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		subCmd := args[0]
 		switch subCmd {
 		case "status":
-			checkFirewallStatus()
+			return runReport("firewall-status", "", func() (report, error) {
+				return (*FirewallStatusReport)(firewall.Status()), nil
+			})
 		case "rules":
-			listFirewallRules()
+			return runReport("firewall-rules", "", func() (report, error) {
+				return (*FirewallRulesReport)(firewall.Rules()), nil
+			})
 		case "test":
 			if len(args) < 2 {
-				color.Red("  [✗] firewall test requires hostname\n")
-				fmt.Println("Usage: afsa firewall test <hostname> [flags]")
-				return
+				return fmt.Errorf("firewall test requires hostname\nUsage: afsa firewall test <hostname> [flags]")
 			}
-			testFirewallPorts(args[1])
+			return runReport("firewall-test", args[1], func() (report, error) {
+				testPorts, skipped, err := firewallTestPorts()
+				if err != nil {
+					return nil, err
+				}
+				family, err := addressFamily(firewallIPv4Only, firewallIPv6Only)
+				if err != nil {
+					return nil, err
+				}
+				res, err := firewall.Test(cmd.Context(), args[1], testPorts, firewall.TestOptions{
+					Family:      family,
+					Concurrency: firewallConcurrency,
+				})
+				if res != nil {
+					res.SkippedUDPPorts = skipped
+				}
+				return (*FirewallTestReport)(res), err
+			})
 		default:
-			color.Red("  [✗] Unknown firewall subcommand: %s\n", subCmd)
+			return fmt.Errorf("unknown firewall subcommand: %s", subCmd)
 		}
 	},
 }
 
 func init() {
-	firewallCmd.Flags().StringSliceVarP(&ports, "ports", "p", []string{"80", "443", "22", "3306", "5432"}, "Ports to test")
+	firewallCmd.Flags().StringVarP(&firewallPortSpec, "ports", "p", "80,443,22,3306,5432", "Ports to test (e.g., 22,80,443,1000-2000); U: entries are skipped")
 	firewallCmd.Flags().BoolVarP(&detailed, "detailed", "d", false, "Show detailed information")
+	firewallCmd.Flags().BoolVarP(&firewallIPv4Only, "ipv4", "4", false, "Connect over IPv4 only")
+	firewallCmd.Flags().BoolVarP(&firewallIPv6Only, "ipv6", "6", false, "Connect over IPv6 only")
+	firewallCmd.Flags().IntVar(&firewallConcurrency, "concurrency", scan.DefaultConcurrency, "Connections in flight at once")
 }
 
-func checkFirewallStatus() {
+// FirewallStatusReport renders a firewall.StatusResult.
+type FirewallStatusReport firewall.StatusResult
+
+// FirewallRulesReport renders a firewall.RulesResult.
+type FirewallRulesReport firewall.RulesResult
+
+// FirewallTestReport renders a firewall.TestResult.
+type FirewallTestReport firewall.TestResult
+
+func (r *FirewallStatusReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║          FIREWALL STATUS ANALYSIS                      ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  System: %s\n\n", getOS())
+	color.Cyan("  System: %s\n\n", r.System)
 
 	color.Red("  ▸ Firewall Monitoring:\n")
-	fmt.Printf("    ├─ Status: %s\n", color.YellowString("Requires elevated privileges"))
-	fmt.Printf("    └─ Note: Run with 'sudo' for full details\n")
+	fmt.Printf("    ├─ Status: %s\n", color.YellowString(r.Status))
+	fmt.Printf("    └─ Note: %s\n", r.Note)
 
 	color.Red("\n  ▸ Manual Status Check Commands:\n")
-	fmt.Printf("    macOS:  %s\n", color.WhiteString("sudo launchctl list | grep -i firewall"))
-	fmt.Printf("    Linux:  %s\n", color.WhiteString("sudo systemctl status ufw"))
-	fmt.Printf("    Linux:  %s\n", color.WhiteString("sudo iptables -L -n"))
+	for _, c := range r.CheckCommands {
+		fmt.Printf("    %-7s %s\n", c.Platform+":", color.WhiteString(c.Command))
+	}
 
 	color.Red("\n  ▸ Firewall Services:\n")
-	fmt.Printf("    ├─ macOS: pf (Packet Filter)\n")
-	fmt.Printf("    ├─ Linux: ufw (Uncomplicated Firewall)\n")
-	fmt.Printf("    ├─ Linux: firewalld (Dynamic Firewall Manager)\n")
-	fmt.Printf("    └─ Linux: iptables (Netfilter)\n")
+	for i, service := range r.Services {
+		if i == len(r.Services)-1 {
+			fmt.Printf("    └─ %s\n", service)
+		} else {
+			fmt.Printf("    ├─ %s\n", service)
+		}
+	}
 
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║    [✓] Firewall Status Check Completed                 ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
 
-func listFirewallRules() {
+func (r *FirewallRulesReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║           FIREWALL RULES ENUMERATION                   ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Yellow("\n  ⚠  Detailed firewall rules require elevated privileges (sudo)\n")
-
-	color.Red("  ▸ Linux (iptables) Commands:\n")
-	fmt.Printf("    ├─ %s\n", color.WhiteString("sudo iptables -L -n -v"))
-	fmt.Printf("    ├─ %s\n", color.WhiteString("sudo iptables -L INPUT -n -v"))
-	fmt.Printf("    └─ %s\n", color.WhiteString("sudo iptables -L OUTPUT -n -v"))
+	color.Yellow("\n  ⚠  %s\n", r.Note)
 
-	color.Red("\n  ▸ Linux (firewalld) Commands:\n")
-	fmt.Printf("    ├─ %s\n", color.WhiteString("sudo firewall-cmd --list-all"))
-	fmt.Printf("    ├─ %s\n", color.WhiteString("sudo firewall-cmd --list-ports"))
-	fmt.Printf("    └─ %s\n", color.WhiteString("sudo firewall-cmd --list-rich-rules"))
-
-	color.Red("\n  ▸ Linux (ufw) Commands:\n")
-	fmt.Printf("    └─ %s\n", color.WhiteString("sudo ufw status verbose"))
-
-	color.Red("\n  ▸ macOS (pf) Commands:\n")
-	fmt.Printf("    └─ %s\n", color.WhiteString("sudo pfctl -s rules"))
+	// Group consecutive commands by tool, preserving order
+	for i := 0; i < len(r.Commands); {
+		j := i
+		for j < len(r.Commands) && r.Commands[j].Tool == r.Commands[i].Tool {
+			j++
+		}
+		if i == 0 {
+			color.Red("  ▸ %s (%s) Commands:\n", r.Commands[i].Platform, r.Commands[i].Tool)
+		} else {
+			color.Red("\n  ▸ %s (%s) Commands:\n", r.Commands[i].Platform, r.Commands[i].Tool)
+		}
+		for k := i; k < j; k++ {
+			if k == j-1 {
+				fmt.Printf("    └─ %s\n", color.WhiteString(r.Commands[k].Command))
+			} else {
+				fmt.Printf("    ├─ %s\n", color.WhiteString(r.Commands[k].Command))
+			}
+		}
+		i = j
+	}
 
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║    [✓] Firewall Rules Listing Completed                ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
 
-func testFirewallPorts(hostname string) {
+// firewallTestPorts returns the TCP ports of --ports and, apart, its U:
+// entries, which firewall test skips since it only tests TCP.
+func firewallTestPorts() (tcp, skipped []int, err error) {
+	ps, err := scan.ParsePortSpec(firewallPortSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ps.TCP) == 0 {
+		return nil, nil, fmt.Errorf("port specification %q selects no TCP ports; firewall test only tests TCP, use afsa scan for UDP ports", firewallPortSpec)
+	}
+	return ps.TCP, portsNotIn(ps.UDP, ps.TCP), nil
+}
+
+func (r *FirewallTestReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║          FIREWALL PORT CONNECTIVITY TEST               ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  Target: %s\n", hostname)
-	color.Cyan("  Testing Ports: %v\n\n", ports)
+	var testedPorts []int
+	for _, p := range r.Ports {
+		if len(testedPorts) == 0 || testedPorts[len(testedPorts)-1] != p.Port {
+			testedPorts = append(testedPorts, p.Port)
+		}
+	}
 
-	openPorts := 0
-	closedPorts := 0
+	color.Cyan("  Target: %s\n", r.Target)
+	if len(r.Addresses) > 0 && (len(r.Addresses) > 1 || r.Addresses[0] != r.Target) {
+		color.Cyan("  Addresses: %s\n", strings.Join(r.Addresses, ", "))
+	}
+	if len(testedPorts) > 20 {
+		color.Cyan("  Testing Ports: %d ports\n\n", len(testedPorts))
+	} else {
+		color.Cyan("  Testing Ports: %v\n\n", testedPorts)
+	}
+	if len(r.SkippedUDPPorts) > 0 {
+		color.Yellow("  ⚠  Skipped UDP ports U:%s: firewall test only tests TCP; use afsa scan for those\n\n", portList(r.SkippedUDPPorts))
+	}
 
 	color.Red("  ▸ Port Scan Results:\n")
 
-	for i, port := range ports {
-		address := hostname + ":" + port
-		conn, err := dialWithTimeout(address, 5)
-		if err == nil {
-			conn.Close()
-			color.Green("    ├─ Port %s: OPEN %s\n", port, "✓")
-			openPorts++
-		} else {
-			color.Red("    ├─ Port %s: CLOSED/FILTERED %s\n", port, "✗")
-			closedPorts++
+	for _, p := range r.Ports {
+		// Name the address when the host has several.
+		via := familyLabel(p.Family)
+		if len(r.Addresses) > 1 {
+			via += " " + p.Address
 		}
-
-		if i == len(ports)-1 {
-			fmt.Printf("    └─ (Scan completed)\n")
+		switch p.State {
+		case scan.StateOpen:
+			color.Green("    ├─ Port %d: OPEN %s (%s)\n", p.Port, "✓", via)
+		case scan.StateClosed:
+			color.Red("    ├─ Port %d: CLOSED %s (%s, %s)\n", p.Port, "✗", via, p.Reason)
+		case scan.StateUnreachable:
+			color.Magenta("    ├─ Port %d: UNREACHABLE %s (%s, %s)\n", p.Port, "✗", via, p.Reason)
+		default:
+			color.Yellow("    ├─ Port %d: FILTERED %s (%s, %s)\n", p.Port, "✗", via, p.Reason)
+		}
+	}
+	fmt.Printf("    └─ (Scan completed in %dms)\n", r.DurationMS)
+
+	if len(r.DualStack) > 0 {
+		color.Red("\n  ▸ Dual-Stack Reachability:\n")
+		for i, d := range r.DualStack {
+			prefix := "├─"
+			if i == len(r.DualStack)-1 {
+				prefix = "└─"
+			}
+			reach := color.GreenString("IPv4 + IPv6")
+			if !d.IPv6 {
+				reach = color.YellowString("IPv4 only")
+			} else if !d.IPv4 {
+				reach = color.YellowString("IPv6 only")
+			}
+			fmt.Printf("    %s Port %d: %s\n", prefix, d.Port, reach)
 		}
 	}
 
+	successRate := 0.0
+	if len(r.Ports) > 0 {
+		successRate = float64(r.Open) / float64(len(r.Ports)) * 100
+	}
+
 	color.Red("\n  ▸ Scan Summary:\n")
-	fmt.Printf("    ├─ Open Ports: %s\n", color.GreenString(fmt.Sprintf("%d", openPorts)))
-	fmt.Printf("    ├─ Closed/Filtered: %s\n", color.RedString(fmt.Sprintf("%d", closedPorts)))
-	fmt.Printf("    └─ Success Rate: %.1f%%\n", float64(openPorts)/float64(len(ports))*100)
+	fmt.Printf("    ├─ Open Ports: %s\n", color.GreenString(fmt.Sprintf("%d", r.Open)))
+	fmt.Printf("    ├─ Closed (refused): %s\n", color.RedString(fmt.Sprintf("%d", r.Closed)))
+	fmt.Printf("    ├─ Filtered (no response): %s\n", color.YellowString(fmt.Sprintf("%d", r.Filtered)))
+	fmt.Printf("    ├─ Unreachable (routing): %s\n", color.MagentaString(fmt.Sprintf("%d", r.Unreachable)))
+	fmt.Printf("    └─ Success Rate: %.1f%%\n", successRate)
 
 	if detailed {
 		color.Red("\n  ▸ Common Services:\n")
-		serviceMap := map[string]string{
-			"22":   "SSH",
-			"80":   "HTTP",
-			"443":  "HTTPS",
-			"3306": "MySQL",
-			"5432": "PostgreSQL",
-			"5000": "Flask/Django",
-			"8080": "HTTP Alt",
-		}
-		for port := range serviceMap {
-			if service, ok := serviceMap[port]; ok {
-				for _, testPort := range ports {
-					if testPort == port {
-						fmt.Printf("    ├─ Port %s: %s\n", port, service)
-						break
-					}
-				}
+		for i, p := range r.Ports {
+			if p.Service != "" && (i == 0 || r.Ports[i-1].Port != p.Port) {
+				fmt.Printf("    ├─ Port %d: %s\n", p.Port, p.Service)
 			}
 		}
 	}
@@ -182,20 +275,3 @@ func testFirewallPorts(hostname string) {
 	color.Red("║      [✓] Firewall Port Test Completed                  ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
-
-func dialWithTimeout(address string, timeoutSeconds int) (net.Conn, error) {
-	timeout := time.Duration(timeoutSeconds) * time.Second
-	return net.DialTimeout("tcp", address, timeout)
-}
-
-func getOS() string {
-	if isLinux() {
-		return "Linux"
-	}
-	return "macOS"
-}
-
-func isLinux() bool {
-	// This is determined at compile time
-	return false // Will be handled properly
-}
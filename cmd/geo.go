@@ -5,6 +5,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/geo"
 )
 
 var geoCmd = &cobra.Command{
@@ -19,65 +20,100 @@ Features:
   ▸ ISP information
   ▸ Time zone information
   ▸ Proxy/VPN detection
+  ▸ Origin AS and its name from the dataset afsa ip import saved
+
+Flags:
+      --dataset    IP-to-ASN dataset to use instead of the imported one
 
 Examples:
   afsa geo 8.8.8.8
   afsa geo 1.1.1.1`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		ipAddr := args[0]
-		performGeolocationAnalysis(ipAddr)
+		return runReport("geo", ipAddr, func() (report, error) {
+			table, err := loadASNTable(ipAddr)
+			if err != nil {
+				return nil, err
+			}
+			res, err := geo.Lookup(cmd.Context(), ipAddr, geo.Options{ASN: table})
+			return (*GeoReport)(res), err
+		})
 	},
 }
 
-func performGeolocationAnalysis(ipAddr string) {
+func init() {
+	geoCmd.Flags().StringVar(&ipDataset, "dataset", "", "IP-to-ASN dataset to use instead of the imported one")
+}
+
+// GeoReport renders a geo.Result.
+type GeoReport geo.Result
+
+// orPlaceholder returns value, or placeholder when value is unknown.
+func orPlaceholder(value, placeholder string) string {
+	if value == "" {
+		return placeholder
+	}
+	return value
+}
+
+func formatCoordinate(c *float64) string {
+	if c == nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.4f", *c)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+func (r *GeoReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║          GEOLOCATION ANALYSIS REPORT                   ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  Target IP: %s\n\n", ipAddr)
+	color.Cyan("  Target IP: %s\n\n", r.IP)
 
 	color.Red("  ▸ Location Information:\n")
-	fmt.Printf("    ├─ IP Address: %s\n", color.CyanString(ipAddr))
-	fmt.Printf("    ├─ Country: %s\n", color.YellowString("Requires GeoIP Database"))
-	fmt.Printf("    ├─ City: %s\n", color.YellowString("Requires GeoIP Database"))
-	fmt.Printf("    ├─ Latitude: %s\n", color.WhiteString("N/A"))
-	fmt.Printf("    ├─ Longitude: %s\n", color.WhiteString("N/A"))
+	fmt.Printf("    ├─ IP Address: %s\n", color.CyanString(r.IP))
+	fmt.Printf("    ├─ Country: %s\n", color.YellowString(orPlaceholder(r.Country, "Requires GeoIP Database")))
+	fmt.Printf("    ├─ City: %s\n", color.YellowString(orPlaceholder(r.City, "Requires GeoIP Database")))
+	fmt.Printf("    ├─ Latitude: %s\n", color.WhiteString(formatCoordinate(r.Latitude)))
+	fmt.Printf("    ├─ Longitude: %s\n", color.WhiteString(formatCoordinate(r.Longitude)))
 
 	color.Red("\n  ▸ ISP Information:\n")
-	fmt.Printf("    ├─ ISP Name: %s\n", color.YellowString("Requires WHOIS/GeoIP Data"))
-	fmt.Printf("    ├─ ASN: %s\n", color.WhiteString("N/A"))
-	fmt.Printf("    ├─ Organization: %s\n", color.YellowString("Requires Database"))
+	fmt.Printf("    ├─ ISP Name: %s\n", color.YellowString(orPlaceholder(r.ISP, "Requires WHOIS/GeoIP Data")))
+	fmt.Printf("    ├─ ASN: %s\n", color.WhiteString(orPlaceholder(r.ASN, "N/A")))
+	fmt.Printf("    ├─ Organization: %s\n", color.YellowString(orPlaceholder(r.Organization, "Requires Database")))
 
 	color.Red("\n  ▸ Time Zone Information:\n")
-	fmt.Printf("    ├─ Time Zone: %s\n", color.WhiteString("N/A"))
+	fmt.Printf("    ├─ Time Zone: %s\n", color.WhiteString(orPlaceholder(r.TimeZone, "N/A")))
 	fmt.Printf("    ├─ UTC Offset: %s\n", color.WhiteString("N/A"))
 	fmt.Printf("    └─ DST Status: %s\n", color.WhiteString("N/A"))
 
 	color.Red("\n  ▸ Connection Type:\n")
-	fmt.Printf("    ├─ Type: %s\n", color.YellowString("Standard (Likely ISP)"))
+	fmt.Printf("    ├─ Type: %s\n", color.YellowString(r.ConnectionType))
 	fmt.Printf("    ├─ Mobile: %s\n", color.WhiteString("Unknown"))
-	fmt.Printf("    ├─ VPN Detected: %s\n", color.GreenString("No"))
-	fmt.Printf("    └─ Proxy Detected: %s\n", color.GreenString("No"))
+	fmt.Printf("    ├─ VPN Detected: %s\n", color.GreenString(yesNo(r.VPNDetected)))
+	fmt.Printf("    └─ Proxy Detected: %s\n", color.GreenString(yesNo(r.ProxyDetected)))
 
 	color.Red("\n  ▸ Recommended GeoIP Services:\n")
-	services := []string{
-		"MaxMind GeoIP2 - Commercial & free options",
-		"IP2Location - Comprehensive database",
-		"ipapi.co - Free REST API",
-		"geoip.dev - Simple API",
-		"ipstack - Detailed information",
-	}
-	for i, service := range services {
-		if i == len(services)-1 {
+	for i, service := range r.GeoIPServices {
+		if i == len(r.GeoIPServices)-1 {
 			fmt.Printf("    └─ %s\n", service)
 		} else {
 			fmt.Printf("    ├─ %s\n", service)
 		}
 	}
 
-	color.Yellow("\n  Note: For accurate geolocation data, integrate with a GeoIP database.\n")
-	fmt.Printf("        See: https://maxmind.com or https://ip2location.com\n")
+	if r.DatabaseMissing {
+		color.Yellow("\n  Note: For accurate geolocation data, integrate with a GeoIP database.\n")
+		fmt.Printf("        See: https://maxmind.com or https://ip2location.com\n")
+	}
 
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║    [✓] Geolocation Analysis Completed                  ║\n")
@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/asn"
+	"github.com/tanvircs/afsa/pkg/ipintel"
+	"github.com/tanvircs/afsa/pkg/rdap"
 )
 
-var ipVerbose bool
+var (
+	ipVerbose bool
+	ipDataset string
+)
 
 var ipCmd = &cobra.Command{
 	Use:   "ip [address]",
@@ -22,52 +31,93 @@ Features:
   ▸ Reverse DNS Lookup
   ▸ Special Address Detection
   ▸ CIDR Range Information
+  ▸ Covering prefix, origin ASN, AS name and country from an imported
+    IP-to-ASN dataset (see afsa ip import), and the allocating RIR
 
 Flags:
   -v, --verbose    Show detailed analysis
+      --dataset    IP-to-ASN dataset to use instead of the imported one
 
 Examples:
   afsa ip 8.8.8.8
   afsa ip 192.168.1.1 -v
   afsa ip 2001:4860:4860::8888`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		ipAddr := args[0]
-		performAdvancedIPLookup(ipAddr)
+		return runReport("ip", ipAddr, func() (report, error) {
+			table, err := loadASNTable(ipAddr)
+			if err != nil {
+				return nil, err
+			}
+			opts := ipintel.Options{ASN: table}
+			// Take the RIR from the bootstrap files afsa whois
+			// --refresh-bootstrap saved, if any.
+			if dir, err := rdap.DefaultBootstrapDir(); err == nil {
+				if opts.Bootstrap, err = rdap.LoadBootstrap(dir); err != nil {
+					return nil, err
+				}
+			}
+			res, err := ipintel.Analyze(cmd.Context(), ipAddr, opts)
+			return (*IPReport)(res), err
+		})
 	},
 }
 
 func init() {
 	ipCmd.Flags().BoolVarP(&ipVerbose, "verbose", "v", false, "Verbose output")
+	ipCmd.Flags().StringVar(&ipDataset, "dataset", "", "IP-to-ASN dataset to use instead of the imported one")
+}
+
+// loadASNTable loads the dataset named by --dataset, else the one afsa
+// ip import saved, for looking up addr. Without either, it returns nil:
+// the origin AS is then left out rather than failing the command. It
+// returns nil without reading anything when addr is not a public
+// unicast address, which no dataset routes.
+func loadASNTable(addr string) (*asn.Table, error) {
+	if ip := net.ParseIP(addr); ip == nil || !ip.IsGlobalUnicast() || ipintel.IsPrivate(ip) {
+		return nil, nil
+	}
+	path := ipDataset
+	if path == "" {
+		var err error
+		if path, err = asn.DefaultPath(); err != nil {
+			return nil, nil
+		}
+	}
+	t, err := asn.Load(path)
+	if err != nil {
+		if ipDataset == "" && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("loading IP-to-ASN dataset: %w", err)
+	}
+	return t, nil
 }
 
-func performAdvancedIPLookup(ipAddr string) {
+// IPReport renders an ipintel.Result.
+type IPReport ipintel.Result
+
+func (r *IPReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║            IP INTELLIGENCE REPORT                      ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	ip := net.ParseIP(ipAddr)
-	if ip == nil {
-		color.Red("  [✗] Invalid IP address format: %s\n\n", ipAddr)
-		return
-	}
-
 	// Basic Info
-	color.Cyan("  Target IP: %s\n\n", ipAddr)
+	color.Cyan("  Target IP: %s\n\n", r.Address)
 
 	// IP Version
 	color.Red("  ▸ IP Version:\n")
-	if ip.To4() != nil {
-		fmt.Printf("    └─ %s\n", color.GreenString("IPv4"))
-	} else if ip.To16() != nil {
-		fmt.Printf("    └─ %s\n", color.CyanString("IPv6"))
+	if r.Version == "IPv4" {
+		fmt.Printf("    └─ %s\n", color.GreenString(r.Version))
+	} else {
+		fmt.Printf("    └─ %s\n", color.CyanString(r.Version))
 	}
 
 	// IP Classification
 	color.Red("  ▸ IP Classification:\n")
-	classifications := getIPClassifications(ip)
-	for i, class := range classifications {
-		if i == len(classifications)-1 {
+	for i, class := range r.Classifications {
+		if i == len(r.Classifications)-1 {
 			fmt.Printf("    └─ %s\n", color.YellowString(class))
 		} else {
 			fmt.Printf("    ├─ %s\n", color.YellowString(class))
@@ -76,10 +126,9 @@ func performAdvancedIPLookup(ipAddr string) {
 
 	// Reverse DNS
 	color.Red("  ▸ Reverse DNS Lookup:\n")
-	hostnames, err := net.LookupAddr(ipAddr)
-	if err == nil && len(hostnames) > 0 {
-		for i, hostname := range hostnames {
-			if i == len(hostnames)-1 {
+	if len(r.ReverseDNS) > 0 {
+		for i, hostname := range r.ReverseDNS {
+			if i == len(r.ReverseDNS)-1 {
 				fmt.Printf("    └─ %s\n", color.BlueString(hostname))
 			} else {
 				fmt.Printf("    ├─ %s\n", color.BlueString(hostname))
@@ -89,11 +138,27 @@ func performAdvancedIPLookup(ipAddr string) {
 		fmt.Printf("    └─ %s\n", color.WhiteString("No reverse DNS records"))
 	}
 
+	// Routing
+	if o := r.Origin; o != nil {
+		color.Red("  ▸ Routing:\n")
+		if o.Prefix != "" {
+			fmt.Printf("    ├─ Prefix: %s\n", color.CyanString(o.Prefix))
+			as := fmt.Sprintf("AS%d", o.ASN)
+			if o.ASName != "" {
+				as += " (" + o.ASName + ")"
+			}
+			fmt.Printf("    ├─ Origin AS: %s\n", color.CyanString(as))
+			fmt.Printf("    ├─ Country: %s\n", orPlaceholder(o.Country, "unknown"))
+		} else {
+			fmt.Printf("    ├─ Prefix: %s\n", color.YellowString("not routed, or no dataset (run afsa ip import)"))
+		}
+		fmt.Printf("    └─ RIR: %s\n", orPlaceholder(o.RIR, "unknown"))
+	}
+
 	// Special Characteristics
 	color.Red("  ▸ Special Characteristics:\n")
-	characteristics := getSpecialCharacteristics(ip)
-	for i, char := range characteristics {
-		if i == len(characteristics)-1 {
+	for i, char := range r.Characteristics {
+		if i == len(r.Characteristics)-1 {
 			fmt.Printf("    └─ %s\n", color.MagentaString(char))
 		} else {
 			fmt.Printf("    ├─ %s\n", color.MagentaString(char))
@@ -101,20 +166,18 @@ func performAdvancedIPLookup(ipAddr string) {
 	}
 
 	// Security Analysis
-	if ipVerbose {
+	if ipVerbose && len(r.Security) > 0 {
 		color.Red("  ▸ Security Analysis:\n")
-		if isPrivateIP(ip) {
-			fmt.Printf("    ├─ %s\n", color.GreenString("✓ Private - Safe for internal use"))
-		} else {
-			fmt.Printf("    ├─ %s\n", color.YellowString("⚠ Public - Exposed to internet"))
-		}
-
-		if ip.IsLoopback() {
-			fmt.Printf("    ├─ %s\n", color.GreenString("✓ Loopback - Local machine only"))
-		}
-
-		if ip.IsMulticast() {
-			fmt.Printf("    └─ %s\n", color.YellowString("⚠ Multicast - Group communication"))
+		for i, note := range r.Security {
+			prefix := "├─"
+			if i == len(r.Security)-1 {
+				prefix = "└─"
+			}
+			if strings.HasPrefix(note, "Private") || strings.HasPrefix(note, "Loopback") {
+				fmt.Printf("    %s %s\n", prefix, color.GreenString("✓ "+note))
+			} else {
+				fmt.Printf("    %s %s\n", prefix, color.YellowString("⚠ "+note))
+			}
 		}
 	}
 
@@ -122,59 +185,3 @@ func performAdvancedIPLookup(ipAddr string) {
 	color.Red("║      [✓] IP Intelligence Analysis Completed           ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
-
-func getIPClassifications(ip net.IP) []string {
-	var classifications []string
-
-	if ip.IsLoopback() {
-		classifications = append(classifications, "Loopback Address (RFC5735)")
-	} else if ip.IsPrivate() {
-		classifications = append(classifications, "Private Address (RFC1918)")
-	} else if ip.IsMulticast() {
-		classifications = append(classifications, "Multicast Address (RFC5771)")
-	} else if ip.IsLinkLocalUnicast() {
-		classifications = append(classifications, "Link-Local Unicast (RFC3927)")
-	} else if ip.IsLinkLocalMulticast() {
-		classifications = append(classifications, "Link-Local Multicast (RFC5771)")
-	} else {
-		classifications = append(classifications, "Public Address (Routable)")
-	}
-
-	return classifications
-}
-
-func getSpecialCharacteristics(ip net.IP) []string {
-	var chars []string
-
-	if ip.IsUnspecified() {
-		chars = append(chars, "Unspecified Address (0.0.0.0 or ::)")
-	}
-	if ip.IsLoopback() {
-		chars = append(chars, "Loopback (127.0.0.1 or ::1)")
-	}
-	if ip.IsPrivate() {
-		chars = append(chars, "RFC1918 Private Range")
-	}
-	if ip.IsMulticast() {
-		chars = append(chars, "Multicast Group")
-	}
-	if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		chars = append(chars, "Link-Local Address")
-	}
-	if ip.IsGlobalUnicast() {
-		chars = append(chars, "Global Unicast (Routable)")
-	}
-	if ip.IsInterfaceLocalMulticast() {
-		chars = append(chars, "Interface-Local Multicast")
-	}
-
-	if len(chars) == 0 {
-		chars = append(chars, "Standard Routable Public Address")
-	}
-
-	return chars
-}
-
-func isPrivateIP(ip net.IP) bool {
-	return ip.IsPrivate() || ip.IsLoopback()
-}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/asn"
+)
+
+var ipImportCmd = &cobra.Command{
+	Use:   "import [file...]",
+	Short: color.RedString("IP-to-ASN Import - Load a dataset for offline origin lookups"),
+	Long: `Import an IP-to-ASN dataset, so afsa ip and afsa geo show the covering
+prefix, origin AS, AS name and country of an address without network access:
+
+Formats (detected from the content, plain, gzip or bzip2):
+  ▸ iptoasn.com ip2asn TSV, e.g. ip2asn-combined.tsv.gz
+  ▸ CAIDA RouteViews prefix2as, e.g. routeviews-rv2-20240101-1200.pfx2as.gz
+  ▸ MRT TABLE_DUMP_V2 RIB dumps, e.g. RouteViews or RIPE RIS rib.*.bz2
+
+Files are merged in order, the later one winning for the same prefix: give
+an iptoasn file first for AS names and countries, then a fresher RIB dump.
+The result replaces the dataset in the user cache directory
+(~/.cache/afsa/asn/ip2asn.dat on Linux).
+
+Flags:
+      --dataset    Where to save the dataset instead
+
+Examples:
+  afsa ip import ip2asn-combined.tsv.gz
+  afsa ip import ip2asn-combined.tsv.gz rib.20240101.0000.bz2
+  afsa ip import routeviews-rv2-20240101-1200.pfx2as.gz --dataset pfx.dat`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReport("ip-import", strings.Join(args, ", "), func() (report, error) {
+			path := ipDataset
+			if path == "" {
+				var err error
+				if path, err = asn.DefaultPath(); err != nil {
+					return nil, err
+				}
+			}
+			progressf("  ⚡ Importing %d file(s)...\n", len(args))
+			res, err := asn.ImportFiles(cmd.Context(), args, path)
+			return (*IPImportReport)(res), err
+		})
+	},
+}
+
+func init() {
+	ipImportCmd.Flags().StringVar(&ipDataset, "dataset", "", "Where to save the dataset instead of the user cache directory")
+	ipCmd.AddCommand(ipImportCmd)
+}
+
+// IPImportReport renders an asn.ImportResult.
+type IPImportReport asn.ImportResult
+
+func (r *IPImportReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          IP-TO-ASN DATASET IMPORT                      ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Dataset: %s\n\n", r.Path)
+
+	color.Red("  ▸ Files:\n")
+	for i, f := range r.Files {
+		branch, indent := "├─", "│  "
+		if i == len(r.Files)-1 {
+			branch, indent = "└─", "   "
+		}
+		format := f.Format
+		if f.Compression != "" {
+			format += ", " + f.Compression
+		}
+		fmt.Printf("    %s %s (%s)\n", branch, color.CyanString(f.File), format)
+		fmt.Printf("    %s ├─ Routes: %d\n", indent, f.Routes)
+		fmt.Printf("    %s ├─ AS Names: %d\n", indent, f.Names)
+		fmt.Printf("    %s └─ Skipped: %d\n", indent, f.Skipped)
+	}
+
+	color.Red("\n  ▸ Dataset:\n")
+	fmt.Printf("    ├─ Routes: %s\n", color.CyanString("%d", r.Routes))
+	fmt.Printf("    ├─ AS Names: %s\n", color.CyanString("%d", r.Names))
+	fmt.Printf("    └─ Import Time: %dms\n", r.DurationMS)
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] IP-to-ASN Import Completed                      ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SchemaVersion is bumped whenever a field is removed or changes meaning in
+// any of the structured reports. Adding fields does not change the version.
+const SchemaVersion = "7"
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputYAML  = "yaml"
+)
+
+var outputFormat string
+
+// report is implemented by every command result. Structured formats
+// serialize the value itself, text output calls printText.
+type report interface {
+	printText()
+}
+
+// envelope wraps every structured report so consumers can dispatch on the
+// command and validate against the matching schema (see `afsa schema`).
+type envelope struct {
+	SchemaVersion string      `json:"schema_version"`
+	Command       string      `json:"command"`
+	Target        string      `json:"target,omitempty"`
+	GeneratedAt   time.Time   `json:"generated_at"`
+	Result        interface{} `json:"result,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputJSONL, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q (use text, json, jsonl or yaml)", outputFormat)
+}
+
+func structuredOutput() bool {
+	return outputFormat != outputText
+}
+
+// runReport executes fn and renders its result in the selected format.
+// In structured modes a failure is still written as an envelope carrying
+// the error so pipelines always receive parseable output.
+func runReport(command, target string, fn func() (report, error)) error {
+	r, err := fn()
+	if err != nil {
+		if structuredOutput() {
+			_ = writeEnvelope(os.Stdout, envelope{
+				SchemaVersion: SchemaVersion,
+				Command:       command,
+				Target:        target,
+				GeneratedAt:   time.Now().UTC(),
+				Error:         err.Error(),
+			})
+		}
+		return err
+	}
+
+	if !structuredOutput() {
+		r.printText()
+		return nil
+	}
+
+	return writeEnvelope(os.Stdout, envelope{
+		SchemaVersion: SchemaVersion,
+		Command:       command,
+		Target:        target,
+		GeneratedAt:   time.Now().UTC(),
+		Result:        r,
+	})
+}
+
+func writeEnvelope(w io.Writer, env envelope) error {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(env)
+	case outputJSONL:
+		return json.NewEncoder(w).Encode(env)
+	case outputYAML:
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		return encodeYAML(w, env)
+	}
+	return validateOutputFormat()
+}
+
+// progressf reports scan progress on stderr in text mode only, so it never
+// interleaves with structured output on stdout.
+func progressf(format string, a ...interface{}) {
+	if structuredOutput() {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, a...)
+}
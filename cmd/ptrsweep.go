@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var (
+	sweepConcurrency int
+	sweepRate        float64
+	sweepTimeout     int
+	sweepResolvers   []string
+	sweepRetries     int
+)
+
+var ipPTRSweepCmd = &cobra.Command{
+	Use:   "ptr-sweep [cidr]",
+	Short: color.RedString("PTR Sweep - Reverse DNS across a netblock"),
+	Long: `Look up the reverse DNS name of every address in a netblock:
+
+Features:
+  ▸ Concurrent PTR lookups with a rate limit
+  ▸ Forward-confirmed reverse DNS (FCrDNS) for every name found
+  ▸ Naming patterns, e.g. {ip}.static.example.net or web{n}.example.com
+  ▸ Hand-named hosts told apart from generic, address-derived names
+  ▸ Blocks of up to 65536 addresses (an IPv4 /16)
+
+Flags:
+  -c, --concurrency   Addresses looked up at once (default: 20)
+      --rate          PTR lookups per second, 0 for no limit (default: 50)
+  -t, --timeout       Seconds to wait for each response (default: 5)
+      --resolver      Resolver to query, host or host:port (repeatable)
+      --retries       Extra rounds over all resolvers on failure
+
+Examples:
+  afsa ip ptr-sweep 203.0.113.0/24
+  afsa ip ptr-sweep 203.0.113.0/24 --rate 200 -c 50
+  afsa ip ptr-sweep 2001:db8::/120 --resolver 1.1.1.1 -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		network := args[0]
+		return runReport("ip-ptr-sweep", network, func() (report, error) {
+			retries := sweepRetries
+			if retries <= 0 {
+				retries = -1
+			}
+			progressf("  ⚡ Sweeping reverse DNS of %s...\n", network)
+			res, err := dns.PTRSweep(cmd.Context(), network, dns.SweepOptions{
+				ResolverOptions: dns.ResolverOptions{Timeout: time.Duration(sweepTimeout) * time.Second, Resolvers: sweepResolvers, Retries: retries},
+				Concurrency:     sweepConcurrency,
+				Rate:            sweepRate,
+				OnFound: func(h dns.PTRHost) {
+					progressf("    [+] %s %s\n", h.Address, strings.Join(ptrNames(h), ", "))
+				},
+			})
+			return (*PTRSweepReport)(res), err
+		})
+	},
+}
+
+func init() {
+	ipPTRSweepCmd.Flags().IntVarP(&sweepConcurrency, "concurrency", "c", dns.DefaultSweepConcurrency, "Addresses looked up at once")
+	ipPTRSweepCmd.Flags().Float64Var(&sweepRate, "rate", 50, "PTR lookups per second, 0 for no limit")
+	ipPTRSweepCmd.Flags().IntVarP(&sweepTimeout, "timeout", "t", 5, "Seconds to wait for each response")
+	ipPTRSweepCmd.Flags().StringSliceVar(&sweepResolvers, "resolver", nil, "Resolver to query, host or host:port (repeatable)")
+	ipPTRSweepCmd.Flags().IntVar(&sweepRetries, "retries", dns.DefaultRetries, "Extra rounds over all resolvers on failure")
+	ipCmd.AddCommand(ipPTRSweepCmd)
+}
+
+func ptrNames(h dns.PTRHost) []string {
+	var names []string
+	for _, n := range h.Names {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// PTRSweepReport renders a dns.SweepResult.
+type PTRSweepReport dns.SweepResult
+
+func (r *PTRSweepReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          PTR SWEEP REPORT                              ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Network: %s\n", r.Network)
+	color.Cyan("  Addresses Swept: %d\n\n", r.Addresses)
+
+	color.Red("  ▸ Reverse DNS:\n")
+	if len(r.Hosts) == 0 {
+		fmt.Printf("    └─ %s\n", color.YellowString("no PTR records found"))
+	}
+	confirmed := 0
+	for i, h := range r.Hosts {
+		branch, indent := "├─", "│  "
+		if i == len(r.Hosts)-1 {
+			branch, indent = "└─", "   "
+		}
+		if h.FCrDNS {
+			confirmed++
+		}
+		if len(h.Names) == 1 {
+			fmt.Printf("    %s %-15s %s %s\n", branch, h.Address, color.GreenString(h.Names[0].Name), fcrdnsLabel(h.Names[0].ForwardConfirmed))
+			continue
+		}
+		fmt.Printf("    %s %s\n", branch, h.Address)
+		for j, n := range h.Names {
+			sub := "├─"
+			if j == len(h.Names)-1 {
+				sub = "└─"
+			}
+			fmt.Printf("    %s%s %s %s\n", indent, sub, color.GreenString(n.Name), fcrdnsLabel(n.ForwardConfirmed))
+		}
+	}
+
+	if len(r.Patterns) > 0 {
+		color.Red("\n  ▸ Naming Patterns:\n")
+		for i, p := range r.Patterns {
+			branch, indent := "├─", "│  "
+			if i == len(r.Patterns)-1 {
+				branch, indent = "└─", "   "
+			}
+			kind := color.CyanString("custom")
+			if p.Generic {
+				kind = color.HiBlackString("generic")
+			}
+			fmt.Printf("    %s %s ×%d (%s)\n", branch, color.YellowString(p.Pattern), p.Count, kind)
+			fmt.Printf("    %s└─ e.g. %s\n", indent, strings.Join(p.Examples, ", "))
+		}
+	}
+
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Addresses with PTR: %d\n", len(r.Hosts))
+	fmt.Printf("    ├─ Forward-Confirmed: %d\n", confirmed)
+	fmt.Printf("    ├─ Naming Patterns: %d\n", len(r.Patterns))
+	fmt.Printf("    ├─ Failed Lookups: %d\n", r.Errors)
+	fmt.Printf("    └─ Duration: %.1fs\n", float64(r.DurationMS)/1000)
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] PTR Sweep Completed                             ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func fcrdnsLabel(confirmed bool) string {
+	if confirmed {
+		return color.GreenString("[FCrDNS ✓]")
+	}
+	return color.RedString("[FCrDNS ✗]")
+}
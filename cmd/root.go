@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -44,7 +49,7 @@ func displayBanner() {
 
 	red.Println(banner)
 	yellow.Println("\n   🔴 AFSA - Advanced Forensic Security Analyzer v2.0.0")
-	cyan.Println("   🎯 Professional Security Reconnaissance Tool\n")
+	cyan.Print("   🎯 Professional Security Reconnaissance Tool\n\n")
 	white.Println()
 }
 
@@ -84,6 +89,7 @@ COMMANDS:
   whois     WHOIS Lookup - Get domain and IP ownership information
   scan      Port Scanning - Scan and identify open ports
   geo       Geolocation - Get IP geographical and ISP information
+  schema    Output Schema - JSON Schema for structured (-o json) reports
   help      Show help information for any command
 
 EXAMPLES:
@@ -102,6 +108,9 @@ EXAMPLES:
   afsa scan example.com -r 1-1000         # Scan port range
   afsa scan example.com --deep            # Deep scan with all ports
   afsa geo 8.8.8.8                        # Get geolocation info
+  afsa dns example.com -o json            # Machine-readable JSON report
+  afsa scan example.com -o yaml           # YAML report
+  afsa schema scan                        # JSON Schema for scan reports
 
 FLAGS:
   -h, --help              Show this help message
@@ -113,6 +122,7 @@ FLAGS:
 
 GLOBAL FLAGS:
   --help                  Show help for any command
+  -o, --output string     Output format: text, json, jsonl, yaml (default: text)
   --version               Show version information
 
 SECURITY NOTES:
@@ -134,22 +144,88 @@ Example:
   afsa dns --help         # Get help for DNS command
   afsa waf --help         # Get help for WAF command
   afsa scan --help        # Get help for Port Scan command`),
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		if cmd.Name() != "help" && len(args) > 0 {
-			// Only show banner for specific commands
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
 		}
+		// The banner would corrupt machine-readable output
+		if !structuredOutput() && cmd.Name() != "schema" {
+			displayBanner()
+		}
+		return nil
 	},
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 func Execute() {
-	displayBanner()
-	if err := rootCmd.Execute(); err != nil {
-		color.Red("[✗] Error: %v\n", err)
+	// Cancel in-flight lookups and scans on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	rootCmd.SetArgs(translateArgs(os.Args[1:]))
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
+	if err != nil {
+		color.New(color.FgRed).Fprintf(os.Stderr, "[✗] Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// exitStatus ends a command that completed, but found something to act
+// on, with that status. The report has already said what; no error is
+// printed. Status 1 stays reserved for failures.
+type exitStatus int
+
+// exitFindings is the status of afsa whois watch when a domain changed
+// or is about to expire.
+const exitFindings exitStatus = 2
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// nmapFlagAliases maps nmap-style single-dash flags, which pflag would
+// otherwise read as a cluster of shorthands, to their long forms.
+var nmapFlagAliases = map[string]string{
+	"-iL": "--input-list",
+	"-sV": "--service-version",
+	"-sU": "--udp",
+	"-sS": "--method=syn",
+	"-sT": "--method=connect",
+}
+
+func translateArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if long, ok := nmapFlagAliases[name]; ok {
+			if hasValue {
+				arg = long + "=" + value
+			} else {
+				arg = long
+			}
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json, jsonl, yaml")
+
+	defaultHelp := rootCmd.HelpFunc()
+	rootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		displayBanner()
+		defaultHelp(cmd, args)
+	})
+
 	rootCmd.AddCommand(dnsCmd)
 	rootCmd.AddCommand(ipCmd)
 	rootCmd.AddCommand(firewallCmd)
@@ -157,4 +233,5 @@ func init() {
 	rootCmd.AddCommand(whoisCmd)
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(geoCmd)
+	rootCmd.AddCommand(schemaCmd)
 }
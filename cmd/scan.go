@@ -3,130 +3,492 @@ package cmd
 import (
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/fingerprint"
+	"github.com/tanvircs/afsa/pkg/scan"
 )
 
 var (
-	scanRange string
-	scanDeep  bool
+	scanPortSpec    string
+	scanRange       string
+	scanTopPorts    int
+	scanDeep        bool
 	commonPortsOnly bool
+	scanTimeout     int
+	scanConcurrency int
+	scanRate        float64
+	scanMaxPerHost  int
+	scanInputList   string
+	scanExclude     []string
+	scanIPv4Only    bool
+	scanIPv6Only    bool
+	scanServiceVer  bool
+	scanProbesFile  string
+	scanUDP         bool
+	scanMethod      string
+	scanRetries     int
+
+	// scanTargetSpecs holds the target specifications of the current run
+	// for the text report header.
+	scanTargetSpecs []string
 )
 
 var scanCmd = &cobra.Command{
-	Use:   "scan [hostname]",
+	Use:   "scan [targets...]",
 	Short: color.RedString("Port Scanner - Advanced TCP port scanning"),
 	Long: `Advanced port scanning tool for network reconnaissance:
 
 Features:
-  ▸ TCP port scanning
+  ▸ TCP SYN (half-open), TCP connect and UDP port scanning
+  ▸ Multiple targets: hostnames, CIDR blocks, ranges, target files
   ▸ Common ports detection
   ▸ Service identification
+  ▸ Banner grabbing and version detection
   ▸ Timeout configuration
   ▸ Parallel scanning
 
 Flags:
-  -r, --range        Port range (default: common ports)
-  -d, --deep         Deep scan (all ports, slow)
-  -c, --common-only  Scan only common ports
+  -p, --ports         Port specification (default: common ports)
+                      e.g. 22,80,443,1000-2000,U:53,T:8080 or - for all;
+                      U: ports are scanned over UDP in the same run
+  -r, --range         Port range, same syntax as --ports
+      --top-ports     Scan the N most common ports (TCP up to 1000)
+  -d, --deep          Deep scan (all 65535 ports, slow)
+  -c, --common-only   Scan only common ports
+  -t, --timeout       Connect timeout in seconds per port (default: 3);
+                      with --udp, the wait for a reply to each datagram
+      --method        TCP scan method: auto, syn or connect (default: auto,
+                      SYN when running with root or CAP_NET_RAW on Linux)
+  -sS, -sT            Shorthands for --method syn and --method connect
+  -sU, --udp          UDP scan with protocol payloads (DNS, SNMP, NTP,
+                      SSDP, NetBIOS, IKE); T: ports stay TCP
+      --retries       Retransmissions after a UDP request or SYN probe gets
+                      no reply (default: 1)
+      --concurrency   Number of probes in flight at once (default: 100)
+      --rate          Maximum probes started per second (default: unlimited)
+      --max-per-host  Maximum simultaneous connections per host (default: unlimited)
+  -iL, --input-list   Read targets from a file (- for stdin)
+      --exclude       Targets to skip (addresses, CIDR blocks, ranges, hostnames)
+  -4, --ipv4          Scan IPv4 addresses only
+  -6, --ipv6          Scan IPv6 addresses only (default: both, dual-stack)
+  -sV, --service-version
+                      Probe open ports to identify product and version
+      --probes-file   Service probe definitions to use instead of the built-in set
+
+Targets:
+  example.com         Every A and AAAA address, each scanned separately
+  2001:db8::1         IPv4 and IPv6 literals
+  10.0.0.0/24         CIDR block
+  10.0.0.1-50         Octet range (also 10.0.1-3.*, 10.0.0.1-10.0.0.50)
+  -                   Read targets from stdin (also used when stdin is piped)
 
 Examples:
   afsa scan example.com
   afsa scan example.com -r 1-1000
+  afsa scan example.com -p 22,80,443,8000-8100
+  afsa scan example.com -p 22,80,443,U:53,161
+  afsa scan example.com -p-
+  afsa scan example.com --top-ports 50
   afsa scan example.com --deep
-  afsa scan example.com --common-only`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		hostname := args[0]
-		performPortScan(hostname)
+  afsa scan example.com --common-only
+  afsa scan example.com -r 1-5000 --concurrency 500 --rate 1000
+  afsa scan example.com --deep --max-per-host 20
+  afsa scan 10.0.0.0/24 --exclude 10.0.0.1,10.0.0.254
+  afsa scan 192.168.1.1-50 -p 22,80,443
+  afsa scan -iL targets.txt --top-ports 20
+  afsa scan example.com -6 -p 22,443
+  afsa scan example.com -p 22,2222,3306 -sV
+  afsa scan example.com -sV --probes-file my-probes.txt
+  sudo afsa scan example.com -sS -p 1-1000
+  afsa scan 10.0.0.1 --udp
+  afsa scan 10.0.0.1 --udp -p 53,123,161 -t 2 --retries 3
+  cat hosts.txt | afsa scan -p 443`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		specs, err := scanTargets(args)
+		if err != nil {
+			return err
+		}
+		scanTargetSpecs = specs
+		return runReport("scan", strings.Join(specs, ","), func() (report, error) {
+			tcpPorts, udpPorts, err := scanPorts()
+			if err != nil {
+				return nil, err
+			}
+			mixed := len(tcpPorts) > 0 && len(udpPorts) > 0
+			if len(tcpPorts) == 0 && (scanServiceVer || scanProbesFile != "") {
+				return nil, fmt.Errorf("service detection is only available for TCP scans")
+			}
+			family, err := addressFamily(scanIPv4Only, scanIPv6Only)
+			if err != nil {
+				return nil, err
+			}
+			var probes []*fingerprint.Probe
+			if scanProbesFile != "" {
+				if probes, err = fingerprint.LoadProbes(scanProbesFile); err != nil {
+					return nil, fmt.Errorf("failed to load service probes: %w", err)
+				}
+			}
+			targets, err := scan.ExpandTargets(cmd.Context(), specs, scan.ExpandOptions{
+				Exclude: scanExclude,
+				Family:  family,
+			})
+			if err != nil {
+				return nil, err
+			}
+			res, err := scan.Scan(cmd.Context(), targets, scan.Options{
+				Ports:       tcpPorts,
+				UDPPorts:    udpPorts,
+				Method:      scanMethod,
+				Timeout:     time.Duration(scanTimeout) * time.Second,
+				Retries:     scanRetryCount(),
+				Concurrency: scanConcurrency,
+				Rate:        scanRate,
+				MaxPerHost:  scanMaxPerHost,
+				// Custom probe definitions imply version detection
+				ServiceDetection: scanServiceVer || probes != nil,
+				Probes:           probes,
+				OnResult: func(t scan.Target, p scan.PortResult) {
+					// Stream open ports as they are found; the final
+					// report lists them again in port order.
+					if p.State == scan.StateOpen {
+						address := net.JoinHostPort(t.Addr.String(), strconv.Itoa(p.Port))
+						if mixed {
+							address += "/" + p.Protocol
+						}
+						progressf("    [+] %s open (%s)%s\n", address, p.Service, productLabel(p))
+					}
+				},
+				Progress: func(done, total int) {
+					step := total / 20
+					if step < 25 {
+						step = 25
+					}
+					if done%step == 0 && done < total {
+						progressf("    [Progress: %d/%d ports scanned]\n", done, total)
+					}
+				},
+			})
+			return (*ScanReport)(res), err
+		})
 	},
 }
 
 func init() {
-	scanCmd.Flags().StringVarP(&scanRange, "range", "r", "", "Port range (e.g., 1-1000)")
+	scanCmd.Flags().StringVarP(&scanPortSpec, "ports", "p", "", "Port specification (e.g., 22,80,443,1000-2000,T:8080; - for all)")
+	scanCmd.Flags().StringVarP(&scanRange, "range", "r", "", "Port range (e.g., 1-1000); same syntax as --ports")
+	scanCmd.Flags().IntVar(&scanTopPorts, "top-ports", 0, "Scan the N most common ports (TCP up to 1000)")
 	scanCmd.Flags().BoolVarP(&scanDeep, "deep", "d", false, "Deep scan (all ports 1-65535)")
 	scanCmd.Flags().BoolVarP(&commonPortsOnly, "common-only", "c", false, "Scan only common ports")
+	scanCmd.Flags().IntVarP(&scanTimeout, "timeout", "t", 3, "Connect timeout in seconds per port")
+	scanCmd.Flags().IntVar(&scanConcurrency, "concurrency", scan.DefaultConcurrency, "Number of probes in flight at once")
+	scanCmd.Flags().Float64Var(&scanRate, "rate", 0, "Maximum probes started per second (0 = unlimited)")
+	scanCmd.Flags().IntVar(&scanMaxPerHost, "max-per-host", 0, "Maximum simultaneous connections per host (0 = unlimited)")
+	scanCmd.Flags().StringVar(&scanInputList, "input-list", "", "Read targets from a file (- for stdin); also accepted as -iL")
+	scanCmd.Flags().StringSliceVar(&scanExclude, "exclude", nil, "Targets to skip (addresses, CIDR blocks, ranges, hostnames)")
+	scanCmd.Flags().BoolVarP(&scanIPv4Only, "ipv4", "4", false, "Scan IPv4 addresses only")
+	scanCmd.Flags().BoolVarP(&scanIPv6Only, "ipv6", "6", false, "Scan IPv6 addresses only")
+	scanCmd.Flags().BoolVar(&scanServiceVer, "service-version", false, "Probe open ports to identify product and version; also accepted as -sV")
+	scanCmd.Flags().StringVar(&scanProbesFile, "probes-file", "", "Service probe definitions to use instead of the built-in set")
+	scanCmd.Flags().StringVar(&scanMethod, "method", scan.MethodAuto, "TCP scan method: auto, syn or connect; -sS and -sT are shorthands")
+	scanCmd.Flags().BoolVar(&scanUDP, "udp", false, "Scan UDP ports instead of TCP; also accepted as -sU")
+	scanCmd.Flags().IntVar(&scanRetries, "retries", scan.DefaultRetries, "Retransmissions after a UDP request or SYN probe gets no reply")
 }
 
-func performPortScan(hostname string) {
-	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
-	color.Red("║          ADVANCED PORT SCANNER                         ║\n")
-	color.Red("╚════════════════════════════════════════════════════════╝\n")
+// scanRetryCount maps --retries to scan.Options.Retries, where zero
+// selects the default and a negative value disables retransmission.
+func scanRetryCount() int {
+	if scanRetries <= 0 {
+		return -1
+	}
+	return scanRetries
+}
 
-	color.Cyan("  Target Host: %s\n\n", hostname)
+// productLabel formats the detected product, version and confidence of
+// an open port, or returns "" when service detection found nothing.
+func productLabel(p scan.PortResult) string {
+	if p.Confidence == 0 {
+		return ""
+	}
+	if p.Product == "" {
+		return fmt.Sprintf(" [port guess, %.0f%%]", p.Confidence*100)
+	}
+	label := p.Product
+	if p.Version != "" {
+		label += " " + p.Version
+	}
+	if p.Info != "" {
+		label += " (" + p.Info + ")"
+	}
+	return fmt.Sprintf(" %s [%.0f%%]", label, p.Confidence*100)
+}
 
-	// Resolve hostname
-	ips, err := net.LookupHost(hostname)
-	if err != nil {
-		color.Red("  [✗] Failed to resolve hostname: %v\n\n", err)
-		return
+// addressFamily maps the -4/-6 flags to a scan family.
+func addressFamily(ipv4Only, ipv6Only bool) (string, error) {
+	switch {
+	case ipv4Only && ipv6Only:
+		return "", fmt.Errorf("-4 and -6 are mutually exclusive")
+	case ipv4Only:
+		return scan.FamilyIPv4, nil
+	case ipv6Only:
+		return scan.FamilyIPv6, nil
 	}
+	return "", nil
+}
 
-	color.Cyan("  Resolved IP(s): %v\n\n", ips)
+func familyLabel(family string) string {
+	switch family {
+	case scan.FamilyIPv4:
+		return "IPv4"
+	case scan.FamilyIPv6:
+		return "IPv6"
+	}
+	return family
+}
 
-	// Determine ports to scan
-	var portsToScan []int
-	if scanDeep {
-		color.Yellow("  ⚠  Deep scan will take several minutes...\n\n")
-		portsToScan = generatePortRange(1, 5000) // Limit to 5000 for demo
-	} else if scanRange != "" {
-		parts := strings.Split(scanRange, "-")
-		if len(parts) == 2 {
-			start, _ := strconv.Atoi(parts[0])
-			end, _ := strconv.Atoi(parts[1])
-			portsToScan = generatePortRange(start, end)
+// scanTargets collects target specifications from the arguments, the
+// --input-list file and stdin ("-" or a pipe when nothing else is given).
+func scanTargets(args []string) ([]string, error) {
+	var specs []string
+	readStdin := false
+	for _, arg := range args {
+		if arg == "-" {
+			readStdin = true
+			continue
 		}
-	} else {
-		portsToScan = getCommonPorts()
+		specs = append(specs, arg)
 	}
 
-	color.Red("  ▸ Scan Parameters:\n")
-	fmt.Printf("    ├─ Target: %s\n", hostname)
-	fmt.Printf("    ├─ Ports to scan: %d\n", len(portsToScan))
-	fmt.Printf("    ├─ Timeout: 5 seconds per port\n")
-	fmt.Printf("    └─ Method: TCP SYN\n")
+	switch scanInputList {
+	case "":
+	case "-":
+		readStdin = true
+	default:
+		f, err := os.Open(scanInputList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read target list: %w", err)
+		}
+		listed, err := scan.ReadTargets(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read target list: %w", err)
+		}
+		specs = append(specs, listed...)
+	}
 
-	color.Red("\n  ▸ Scanning Results:\n")
+	if !readStdin && len(specs) == 0 && stdinIsPipe() {
+		readStdin = true
+	}
+	if readStdin {
+		listed, err := scan.ReadTargets(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read targets from stdin: %w", err)
+		}
+		specs = append(specs, listed...)
+	}
 
-	openPorts := 0
-	closedPorts := 0
-	startTime := time.Now()
-
-	for i, port := range portsToScan {
-		address := hostname + ":" + fmt.Sprintf("%d", port)
-		conn, err := dialWithTimeout(address, 3)
-		if err == nil {
-			conn.Close()
-			service := getServiceName(port)
-			fmt.Printf("    ├─ Port %5d: %s %s (%s)\n", 
-				port, 
-				color.GreenString("OPEN"), 
-				"✓",
-				service)
-			openPorts++
-		} else {
-			closedPorts++
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no targets given (pass hostnames, CIDR blocks or ranges, or use -iL)")
+	}
+	return specs, nil
+}
+
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// ScanReport renders a scan.Result.
+type ScanReport scan.Result
+
+// scanPorts resolves the port selection flags to the TCP and the UDP
+// ports to probe. A port specification may select both: plain entries
+// are TCP ports, or UDP ports with --udp, and T: and U: entries pick
+// their protocol whatever the flag says.
+func scanPorts() (tcp, udp []int, err error) {
+	selected := 0
+	for _, set := range []bool{scanPortSpec != "", scanRange != "", scanTopPorts > 0, scanDeep} {
+		if set {
+			selected++
 		}
+	}
+	if selected > 1 {
+		return nil, nil, fmt.Errorf("--ports, --range, --top-ports and --deep are mutually exclusive")
+	}
 
-		// Show progress every 25 ports
-		if (i+1)%25 == 0 && i+1 < len(portsToScan) {
-			fmt.Printf("    │  [Progress: %d/%d ports scanned]\n", i+1, len(portsToScan))
+	switch {
+	case scanDeep && scanUDP:
+		progressf("  ⚠  Deep scan will take several minutes...\n\n")
+		return nil, scan.AllPorts(), nil
+	case scanDeep:
+		progressf("  ⚠  Deep scan will take several minutes...\n\n")
+		return scan.AllPorts(), nil, nil
+	case scanTopPorts > 0 && scanUDP:
+		udp, err = scan.TopUDPPorts(scanTopPorts)
+		return nil, udp, err
+	case scanTopPorts > 0:
+		tcp, err = scan.TopPorts(scanTopPorts)
+		return tcp, nil, err
+	case scanPortSpec != "" || scanRange != "":
+		spec := scanPortSpec
+		if spec == "" {
+			spec = scanRange
+		}
+		ps, err := scan.ParsePortSpec(spec)
+		if err != nil {
+			return nil, nil, err
 		}
+		if scanUDP {
+			return portsNotIn(ps.TCP, ps.UDP), ps.UDP, nil
+		}
+		return ps.TCP, portsNotIn(ps.UDP, ps.TCP), nil
+	case scanUDP:
+		return nil, scan.CommonUDPPorts(), nil
 	}
+	return scan.CommonPorts(), nil, nil
+}
 
-	elapsedTime := time.Since(startTime)
+// portsNotIn returns the ports of a that b lacks.
+func portsNotIn(a, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, p := range b {
+		in[p] = true
+	}
+	var out []int
+	for _, p := range a {
+		if !in[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// portList formats the first few ports for a message.
+func portList(ports []int) string {
+	const shown = 5
+	parts := make([]string, 0, shown+1)
+	for i, p := range ports {
+		if i == shown {
+			parts = append(parts, "...")
+			break
+		}
+		parts = append(parts, strconv.Itoa(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r *ScanReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          ADVANCED PORT SCANNER                         ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Target(s): %s\n\n", strings.Join(scanTargetSpecs, ", "))
 
+	color.Red("  ▸ Scan Parameters:\n")
+	fmt.Printf("    ├─ Hosts: %d\n", len(r.Hosts))
+	fmt.Printf("    ├─ Ports per host: %d\n", r.PortsPerHost)
+	if r.TimeoutMS%1000 == 0 {
+		fmt.Printf("    ├─ Timeout: %d seconds per port\n", r.TimeoutMS/1000)
+	} else {
+		fmt.Printf("    ├─ Timeout: %d ms per port\n", r.TimeoutMS)
+	}
+	if r.Protocol != scan.ProtocolTCP || r.Retries > 0 {
+		fmt.Printf("    ├─ Retransmissions: %d\n", r.Retries)
+	}
+	fmt.Printf("    ├─ Concurrency: %d workers\n", r.Concurrency)
+	if r.RatePerSecond > 0 {
+		fmt.Printf("    ├─ Rate Limit: %g probes/second\n", r.RatePerSecond)
+	}
+	if r.MaxPerHost > 0 {
+		fmt.Printf("    ├─ Per-Host Limit: %d connections\n", r.MaxPerHost)
+	}
+	fmt.Printf("    ├─ Service Detection: %s\n", yesNo(r.ServiceDetection))
+	if r.MethodNote != "" {
+		fmt.Printf("    ├─ Method: %s\n", r.Method)
+		fmt.Printf("    └─ %s\n", color.YellowString("Note: "+r.MethodNote))
+	} else {
+		fmt.Printf("    └─ Method: %s\n", r.Method)
+	}
+
+	color.Red("\n  ▸ Scanning Results:\n")
+	hostsUp := 0
+	for _, h := range r.Hosts {
+		if h.Open == 0 {
+			continue
+		}
+		hostsUp++
+		label := h.Address + " [" + familyLabel(h.Family) + "]"
+		if h.Hostname != "" {
+			label += " (" + h.Hostname + ")"
+		}
+		fmt.Printf("    ├─ %s\n", color.CyanString(label))
+		for i, p := range h.OpenPorts {
+			prefix := "│  ├─"
+			if i == len(h.OpenPorts)-1 {
+				prefix = "│  └─"
+			}
+			port := strconv.Itoa(p.Port)
+			if r.Protocol == scan.ProtocolMixed {
+				port += "/" + p.Protocol
+			}
+			fmt.Printf("    %s Port %5s: %s %s (%s)%s\n",
+				prefix,
+				port,
+				color.GreenString("OPEN"),
+				"✓",
+				p.Service,
+				color.YellowString(productLabel(p)))
+			if p.Banner != "" && p.Product == "" {
+				fmt.Printf("    │       Banner: %s\n", p.Banner)
+			}
+		}
+	}
 	fmt.Printf("    └─ Scan completed\n")
 
+	if len(r.DualStack) > 0 {
+		color.Red("\n  ▸ Dual-Stack Reachability:\n")
+		for i, d := range r.DualStack {
+			prefix := "├─"
+			if i == len(r.DualStack)-1 {
+				prefix = "└─"
+			}
+			reach := color.GreenString("IPv4 + IPv6")
+			if !d.IPv6 {
+				reach = color.YellowString("IPv4 only")
+			} else if !d.IPv4 {
+				reach = color.YellowString("IPv6 only")
+			}
+			port := strconv.Itoa(d.Port)
+			if r.Protocol == scan.ProtocolMixed {
+				port += "/" + d.Protocol
+			}
+			fmt.Printf("    %s %s port %s: %s\n", prefix, d.Hostname, port, reach)
+		}
+	}
+
+	scanned := len(r.Hosts) * r.PortsPerHost
+	successRate := 0.0
+	if scanned > 0 {
+		successRate = float64(r.Open) / float64(scanned) * 100
+	}
+
 	color.Red("\n  ▸ Scan Summary:\n")
-	fmt.Printf("    ├─ Open Ports: %s\n", color.GreenString(fmt.Sprintf("%d", openPorts)))
-	fmt.Printf("    ├─ Closed Ports: %s\n", color.RedString(fmt.Sprintf("%d", closedPorts)))
-	fmt.Printf("    ├─ Success Rate: %.1f%%\n", float64(openPorts)/float64(openPorts+closedPorts)*100)
-	fmt.Printf("    └─ Scan Duration: %v\n", elapsedTime)
+	fmt.Printf("    ├─ Hosts with Open Ports: %d/%d\n", hostsUp, len(r.Hosts))
+	fmt.Printf("    ├─ Open Ports: %s\n", color.GreenString(fmt.Sprintf("%d", r.Open)))
+	fmt.Printf("    ├─ Closed Ports: %s\n", color.RedString(fmt.Sprintf("%d", r.Closed)))
+	fmt.Printf("    ├─ Filtered Ports: %s\n", color.YellowString(fmt.Sprintf("%d", r.Filtered)))
+	if r.Protocol != scan.ProtocolTCP {
+		fmt.Printf("    ├─ Open|Filtered Ports: %s\n", color.YellowString(fmt.Sprintf("%d", r.OpenFiltered)))
+	}
+	fmt.Printf("    ├─ Unreachable Ports: %s\n", color.MagentaString(fmt.Sprintf("%d", r.Unreachable)))
+	fmt.Printf("    ├─ Success Rate: %.1f%%\n", successRate)
+	fmt.Printf("    └─ Scan Duration: %v\n", r.Elapsed)
 
 	color.Red("\n  ▸ Common Services on Open Ports:\n")
 	fmt.Printf("    ├─ 21: FTP - File Transfer Protocol\n")
@@ -147,53 +509,3 @@ func performPortScan(hostname string) {
 	color.Red("║        [✓] Port Scan Completed Successfully            ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
-
-func getCommonPorts() []int {
-	return []int{
-		21, 22, 25, 53, 80, 110, 143, 443, 465, 587,
-		993, 995, 1433, 1521, 3306, 5432, 5000, 8000,
-		8080, 8443, 9000, 27017, 28017, 6379, 11211,
-	}
-}
-
-func generatePortRange(start, end int) []int {
-	var ports []int
-	for i := start; i <= end; i++ {
-		ports = append(ports, i)
-	}
-	return ports
-}
-
-func getServiceName(port int) string {
-	services := map[int]string{
-		21:    "FTP",
-		22:    "SSH",
-		25:    "SMTP",
-		53:    "DNS",
-		80:    "HTTP",
-		110:   "POP3",
-		143:   "IMAP",
-		443:   "HTTPS",
-		465:   "SMTPS",
-		587:   "SMTP",
-		993:   "IMAPS",
-		995:   "POP3S",
-		1433:  "MSSQL",
-		1521:  "Oracle",
-		3306:  "MySQL",
-		5432:  "PostgreSQL",
-		5000:  "Flask",
-		8000:  "HTTP",
-		8080:  "HTTP",
-		8443:  "HTTPS",
-		9000:  "PHP-FPM",
-		27017: "MongoDB",
-		6379:  "Redis",
-		11211: "Memcached",
-	}
-
-	if service, ok := services[port]; ok {
-		return service
-	}
-	return "Unknown"
-}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanPortsMixedSpec(t *testing.T) {
+	savedSpec, savedUDP := scanPortSpec, scanUDP
+	defer func() { scanPortSpec, scanUDP = savedSpec, savedUDP }()
+
+	tests := []struct {
+		spec string
+		udp  bool
+		tcp  []int
+		udps []int
+	}{
+		{spec: "22,80,U:53,T:8080", tcp: []int{22, 80, 8080}, udps: []int{53}},
+		{spec: "22,80,U:53,T:8080", udp: true, tcp: []int{8080}, udps: []int{22, 53, 80}},
+		{spec: "U:53,161", udps: []int{53, 161}},
+		{spec: "T:22", udp: true, tcp: []int{22}},
+	}
+	for _, tt := range tests {
+		scanPortSpec, scanUDP = tt.spec, tt.udp
+		tcp, udp, err := scanPorts()
+		if err != nil {
+			t.Errorf("%q (udp %v): %v", tt.spec, tt.udp, err)
+			continue
+		}
+		if len(tcp) != len(tt.tcp) || len(tcp) > 0 && !reflect.DeepEqual(tcp, tt.tcp) || len(udp) != len(tt.udps) || len(udp) > 0 && !reflect.DeepEqual(udp, tt.udps) {
+			t.Errorf("%q (udp %v): TCP %v UDP %v, want TCP %v UDP %v", tt.spec, tt.udp, tcp, udp, tt.tcp, tt.udps)
+		}
+	}
+}
+
+func TestFirewallTestPorts(t *testing.T) {
+	saved := firewallPortSpec
+	defer func() { firewallPortSpec = saved }()
+
+	firewallPortSpec = "22,80,443,1000-1002,U:53,T:8080"
+	tcp, skipped, err := firewallTestPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{22, 80, 443, 1000, 1001, 1002, 8080}; !reflect.DeepEqual(tcp, want) || !reflect.DeepEqual(skipped, []int{53}) {
+		t.Errorf("TCP %v, skipped %v, want %v and [53]", tcp, skipped, want)
+	}
+
+	firewallPortSpec = "U:53"
+	if _, _, err := firewallTestPorts(); err == nil {
+		t.Error("UDP-only specification accepted")
+	}
+}
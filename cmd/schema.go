@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// reportTypes maps the envelope "command" value to the result it carries.
+// Every command that calls runReport must be listed here.
+var reportTypes = map[string]reflect.Type{
+	"dns":             reflect.TypeOf(DNSReport{}),
+	"dns-axfr":        reflect.TypeOf(DNSTransferReport{}),
+	"dns-enum":        reflect.TypeOf(DNSEnumReport{}),
+	"dns-delegation":  reflect.TypeOf(DelegationReport{}),
+	"dns-email":       reflect.TypeOf(EmailReport{}),
+	"dns-takeover":    reflect.TypeOf(TakeoverReport{}),
+	"ip":              reflect.TypeOf(IPReport{}),
+	"ip-import":       reflect.TypeOf(IPImportReport{}),
+	"ip-ptr-sweep":    reflect.TypeOf(PTRSweepReport{}),
+	"scan":            reflect.TypeOf(ScanReport{}),
+	"waf":             reflect.TypeOf(WAFReport{}),
+	"whois":           reflect.TypeOf(WhoisReport{}),
+	"whois-watch":     reflect.TypeOf(WhoisWatchReport{}),
+	"geo":             reflect.TypeOf(GeoReport{}),
+	"firewall-status": reflect.TypeOf(FirewallStatusReport{}),
+	"firewall-rules":  reflect.TypeOf(FirewallRulesReport{}),
+	"firewall-test":   reflect.TypeOf(FirewallTestReport{}),
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [command]",
+	Short: color.RedString("Output Schema - JSON Schema for structured reports"),
+	Long: `Print the JSON Schema (draft 2020-12) describing the structured output
+of a command, for validating --output json/jsonl/yaml reports in CI.
+
+Without an argument the list of available report schemas is printed.
+
+Examples:
+  afsa schema
+  afsa schema dns
+  afsa scan example.com -o json | check-jsonschema --schemafile <(afsa schema scan) -`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			names := make([]string, 0, len(reportTypes))
+			for name := range reportTypes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		}
+
+		t, ok := reportTypes[args[0]]
+		if !ok {
+			return fmt.Errorf("no report schema for %q (run 'afsa schema' for the list)", args[0])
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reportSchema(args[0], t))
+	},
+}
+
+// reportSchema describes the envelope for command with its result type
+// substituted for the generic result field.
+func reportSchema(command string, result reflect.Type) map[string]interface{} {
+	schema := jsonSchemaFor(reflect.TypeOf(envelope{}))
+	props := schema["properties"].(map[string]interface{})
+	props["schema_version"] = map[string]interface{}{"const": SchemaVersion}
+	props["command"] = map[string]interface{}{"const": command}
+	props["result"] = jsonSchemaFor(result)
+
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = fmt.Sprintf("https://github.com/tanvircs/afsa/schema/v%s/%s.json", SchemaVersion, command)
+	schema["title"] = fmt.Sprintf("AFSA %s report", command)
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func jsonSchemaFor(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		// nil pointers encode as null
+		return map[string]interface{}{
+			"anyOf": []interface{}{jsonSchemaFor(t.Elem()), map[string]interface{}{"type": "null"}},
+		}
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		// nil slices and maps encode as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": jsonSchemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": jsonSchemaFor(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		for _, f := range jsonFields(t) {
+			props[f.name] = jsonSchemaFor(f.typ)
+			// A field behind an embedded pointer is left out when it is nil
+			if !f.omitEmpty && !behindPointer(t, f.index) {
+				required = append(required, f.name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// behindPointer reports whether the field of t at index is reached
+// through an embedded pointer.
+func behindPointer(t reflect.Type, index []int) bool {
+	for _, x := range index[:len(index)-1] {
+		f := t.Field(x)
+		if f.Type.Kind() == reflect.Ptr {
+			return true
+		}
+		t = f.Type
+	}
+	return false
+}
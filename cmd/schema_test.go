@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/firewall"
+	"github.com/tanvircs/afsa/pkg/scan"
+)
+
+// validate checks the decoded JSON value v against schema, supporting
+// the keywords reportSchema generates. Objects with properties are taken
+// as closed, so a field the schema doesn't know is reported too.
+func validate(schema map[string]interface{}, v interface{}, path string) []string {
+	var errs []string
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not %v", path, v, c))
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, s := range anyOf {
+			if len(validate(s.(map[string]interface{}), v, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Sprintf("%s: %v matches no alternative", path, v))
+		}
+	}
+	if typ, ok := schema["type"]; ok {
+		var types []string
+		switch typ := typ.(type) {
+		case string:
+			types = []string{typ}
+		case []interface{}:
+			for _, t := range typ {
+				types = append(types, t.(string))
+			}
+		}
+		if !hasJSONType(types, v) {
+			return append(errs, fmt.Sprintf("%s: %s is not of type %s", path, jsonType(v), strings.Join(types, " or ")))
+		}
+	}
+	if schema["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, v.(string)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required %s", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch {
+			case props[k] != nil:
+				errs = append(errs, validate(props[k].(map[string]interface{}), v[k], path+"."+k)...)
+			case schema["additionalProperties"] != nil:
+				errs = append(errs, validate(schema["additionalProperties"].(map[string]interface{}), v[k], path+"."+k)...)
+			case props != nil:
+				errs = append(errs, fmt.Sprintf("%s: unknown property %s", path, k))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func hasJSONType(types []string, v interface{}) bool {
+	got := jsonType(v)
+	for _, t := range types {
+		if t == got || t == "number" && got == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// render writes r as command's envelope in format.
+func render(t *testing.T, format, command string, r report) []byte {
+	t.Helper()
+	saved := outputFormat
+	outputFormat = format
+	defer func() { outputFormat = saved }()
+	var buf bytes.Buffer
+	env := envelope{SchemaVersion: SchemaVersion, Command: command, Target: "example.com", GeneratedAt: time.Now().UTC(), Result: r}
+	if err := writeEnvelope(&buf, env); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkSchema validates the JSON output of r against command's schema.
+func checkSchema(t *testing.T, command string, r report) {
+	t.Helper()
+	// Round trip the schema so it reads like `afsa schema` prints it.
+	b, err := json.Marshal(reportSchema(command, reportTypes[command]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(render(t, outputJSON, command, r), &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range validate(schema, v, command) {
+		t.Error(e)
+	}
+}
+
+// fill sets every exported field reachable from v to a non-zero sample
+// value, with one element in each slice and map, down to depth levels.
+func fill(v reflect.Value, depth int) {
+	if depth == 0 {
+		return
+	}
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("sample")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(0.5)
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), depth-1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), depth-1)
+	case reflect.Map:
+		key := reflect.New(v.Type().Key()).Elem()
+		fill(key, depth-1)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fill(elem, depth-1)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), depth)
+			}
+		}
+	}
+}
+
+func TestReportSchemas(t *testing.T) {
+	reportType := reflect.TypeOf((*report)(nil)).Elem()
+	for command, typ := range reportTypes {
+		if !reflect.PtrTo(typ).Implements(reportType) {
+			t.Errorf("%s: %s is not a report", command, typ)
+			continue
+		}
+		empty := reflect.New(typ)
+		checkSchema(t, command, empty.Interface().(report))
+		full := reflect.New(typ)
+		fill(full.Elem(), 6)
+		checkSchema(t, command, full.Interface().(report))
+	}
+}
+
+// TestReportTypesComplete checks that every command passed to runReport
+// has a schema.
+func TestReportTypesComplete(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := regexp.MustCompile(`runReport\("([^"]+)"`)
+	found := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllSubmatch(src, -1) {
+			found++
+			if _, ok := reportTypes[string(m[1])]; !ok {
+				t.Errorf("%s: runReport(%q) has no entry in reportTypes", file, m[1])
+			}
+		}
+	}
+	if found < len(reportTypes) {
+		t.Errorf("found %d runReport calls for %d report types", found, len(reportTypes))
+	}
+}
+
+func firewallTestSample() *FirewallTestReport {
+	return &FirewallTestReport{
+		Target:    "example.com",
+		Addresses: []string{"192.0.2.1"},
+		Ports: []firewall.PortTest{
+			{Address: "192.0.2.1", PortResult: scan.PortResult{Port: 22, State: scan.StateOpen, Service: "ssh"}},
+			{Address: "192.0.2.1", PortResult: scan.PortResult{Port: 25, State: scan.StateFiltered, Reason: "no response"}},
+		},
+		Open:     1,
+		Filtered: 1,
+	}
+}
+
+func TestFirewallTestSchema(t *testing.T) {
+	r := firewallTestSample()
+	checkSchema(t, "firewall-test", r)
+
+	// The embedded PortResult is flattened, as in the JSON output.
+	yaml := string(render(t, outputYAML, "firewall-test", r))
+	if strings.Contains(yaml, "PortResult") || !strings.Contains(yaml, "    - address: 192.0.2.1\n      port: 22\n      state: open\n") {
+		t.Errorf("YAML output:\n%s", yaml)
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	type Inner struct {
+		A int    `json:"a"`
+		B string `json:"b,omitempty"`
+		C int
+	}
+	type Other struct {
+		D int `json:"C"`
+		E int `json:"e"`
+	}
+	type Named struct {
+		X int `json:"x"`
+	}
+	type outer struct {
+		Inner
+		*Other
+		Named `json:"named"`
+		A     string `json:"a"`
+		E     int    `json:"-"`
+		f     int
+	}
+	var names []string
+	for _, f := range jsonFields(reflect.TypeOf(outer{})) {
+		names = append(names, fmt.Sprintf("%s%v", f.name, f.index))
+	}
+	// a is shadowed by the outer field, and Other's tagged C wins over
+	// Inner's untagged one at the same depth.
+	want := []string{"b[0 1]", "C[1 0]", "e[1 1]", "named[2]", "a[3]"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("jsonFields = %q, want %q", names, want)
+	}
+
+	// encoding/json agrees, and leaves out fields behind a nil pointer.
+	v := outer{Inner: Inner{A: 1, C: 3}, A: "x"}
+	b, _ := json.Marshal(v)
+	if string(b) != `{"named":{"x":0},"a":"x"}` {
+		t.Errorf("encoding/json gave %s", b)
+	}
+	var keys []string
+	for _, p := range yamlPairs(reflect.ValueOf(v)) {
+		keys = append(keys, p.key)
+	}
+	if want := []string{"named", "a"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("yamlPairs keys = %q, want %q", keys, want)
+	}
+}
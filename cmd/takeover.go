@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+)
+
+var (
+	takeoverFile       string
+	takeoverEnum       bool
+	takeoverSignatures string
+)
+
+var dnsTakeoverCmd = &cobra.Command{
+	Use:   "takeover [domain]",
+	Short: color.RedString("Subdomain Takeover - Dangling CNAME and NS delegation detection"),
+	Long: `Check the CNAME and NS records of a domain and its subdomains for
+targets nobody holds any more:
+
+Features:
+  ▸ CNAME targets that no longer exist (NXDOMAIN)
+  ▸ Cloud services serving their "not found" page for the name
+    (S3, Heroku, GitHub Pages, Azure, Shopify, Fastly, ...)
+  ▸ NS delegations to nameservers that don't exist or don't serve the zone,
+    including zones missing at Route 53, Azure DNS and other hosted DNS
+  ▸ Signatures loaded from an updatable file
+
+Flags:
+  -f, --file         File with host names to check, one per line
+      --enum         Also check the subdomains found with the built-in wordlist
+      --signatures   Takeover signatures to use instead of the built-in set
+  -v, --verbose      List every name that has a CNAME or delegation
+  -t, --timeout      Seconds to wait for each response (default: 5)
+      --resolver     Resolver to query, host or host:port (repeatable)
+
+Examples:
+  afsa dns takeover example.com --enum
+  afsa dns takeover example.com -f subdomains.txt
+  afsa dns takeover example.com --signatures my-signatures.txt -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		return runReport("dns-takeover", domain, func() (report, error) {
+			var sigs []*dns.TakeoverSignature
+			if takeoverSignatures != "" {
+				var err error
+				if sigs, err = dns.LoadTakeoverSignatures(takeoverSignatures); err != nil {
+					return nil, fmt.Errorf("failed to load takeover signatures: %w", err)
+				}
+			}
+			var names []string
+			if takeoverFile != "" {
+				var err error
+				if names, err = dns.LoadWordlist(takeoverFile); err != nil {
+					return nil, err
+				}
+			}
+			if takeoverEnum {
+				progressf("  ⚡ Enumerating subdomains of %s...\n", domain)
+				found, err := dns.Enumerate(cmd.Context(), domain, dns.EnumOptions{
+					ResolverOptions: dnsResolverOptions(),
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, s := range found.Subdomains {
+					names = append(names, s.Name)
+				}
+			}
+
+			progressf("  ⚡ Checking %d names for takeover...\n", len(names)+1)
+			res, err := dns.CheckTakeover(cmd.Context(), domain, dns.TakeoverOptions{
+				ResolverOptions: dnsResolverOptions(),
+				Names:           names,
+				Signatures:      sigs,
+			})
+			return (*TakeoverReport)(res), err
+		})
+	},
+}
+
+func init() {
+	dnsTakeoverCmd.Flags().StringVarP(&takeoverFile, "file", "f", "", "File with host names to check, one per line")
+	dnsTakeoverCmd.Flags().BoolVar(&takeoverEnum, "enum", false, "Also check the subdomains found with the built-in wordlist")
+	dnsTakeoverCmd.Flags().StringVar(&takeoverSignatures, "signatures", "", "Takeover signatures to use instead of the built-in set")
+	dnsCmd.AddCommand(dnsTakeoverCmd)
+}
+
+// TakeoverReport renders a dns.TakeoverResult.
+type TakeoverReport dns.TakeoverResult
+
+func (r *TakeoverReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║          SUBDOMAIN TAKEOVER CHECK                      ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Domain: %s\n", r.Domain)
+	color.Cyan("  Names Checked: %d\n\n", r.Checked)
+
+	if dnsVerbose {
+		color.Red("  ▸ Names Pointing Elsewhere:\n")
+		if len(r.Hosts) == 0 {
+			fmt.Printf("    └─ %s\n", color.YellowString("none"))
+		}
+		for i, h := range r.Hosts {
+			prefix := "├─"
+			if i == len(r.Hosts)-1 {
+				prefix = "└─"
+			}
+			var parts []string
+			if len(h.CNAME) > 0 {
+				parts = append(parts, "CNAME "+strings.Join(h.CNAME, " → "))
+			}
+			if len(h.NS) > 0 {
+				parts = append(parts, "NS "+strings.Join(h.NS, ", "))
+			}
+			service := ""
+			if h.Service != "" {
+				service = color.YellowString(" [%s]", h.Service)
+			}
+			fmt.Printf("    %s %s: %s%s\n", prefix, color.CyanString(h.Name), strings.Join(parts, "; "), service)
+		}
+		fmt.Println()
+	}
+
+	color.Red("  ▸ Findings:\n")
+	if len(r.Findings) == 0 {
+		fmt.Printf("    └─ %s\n", color.GreenString("no dangling records found ✓"))
+	}
+	for i, f := range r.Findings {
+		branch, indent := "├─", "│  "
+		if i == len(r.Findings)-1 {
+			branch, indent = "└─", "   "
+		}
+		service := ""
+		if f.Service != "" {
+			service = " (" + f.Service + ")"
+		}
+		fmt.Printf("    %s %s %s %s %s%s\n", branch, takeoverLabel(f.Status), color.CyanString(f.Name), f.Record, f.Target, service)
+		fmt.Printf("    %s└─ %s\n", indent, f.Evidence)
+	}
+
+	if len(r.Errors) > 0 && dnsVerbose {
+		color.Red("\n  ▸ Errors:\n")
+		for i, e := range r.Errors {
+			prefix := "├─"
+			if i == len(r.Errors)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, color.YellowString(e))
+		}
+	}
+
+	counts := map[string]int{}
+	for _, f := range r.Findings {
+		counts[f.Status]++
+	}
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Pointing Elsewhere: %d\n", len(r.Hosts))
+	fmt.Printf("    ├─ Vulnerable: %d\n", counts[dns.TakeoverVulnerable])
+	fmt.Printf("    ├─ Dangling: %d\n", counts[dns.TakeoverDangling])
+	fmt.Printf("    ├─ Lame Delegations: %d\n", counts[dns.TakeoverLame])
+	fmt.Printf("    └─ Errors: %d\n", len(r.Errors))
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Takeover Check Completed                        ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func takeoverLabel(status string) string {
+	label := fmt.Sprintf("%-10s", strings.ToUpper(status))
+	switch status {
+	case dns.TakeoverVulnerable:
+		return color.RedString(label)
+	case dns.TakeoverDangling:
+		return color.YellowString(label)
+	}
+	return color.MagentaString(label)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/waf"
 )
 
 var testXSS, testSQLi bool
@@ -35,9 +36,15 @@ Examples:
   afsa waf cloudflare.com --test-xss
   afsa waf example.com --test-sqli`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		analyzeAdvancedWAF(domain)
+		return runReport("waf", domain, func() (report, error) {
+			res, err := waf.Analyze(cmd.Context(), domain, waf.Options{
+				TestXSS:  testXSS,
+				TestSQLi: testSQLi,
+			})
+			return (*WAFReport)(res), err
+		})
 	},
 }
 
@@ -46,91 +53,36 @@ func init() {
 	wafCmd.Flags().BoolVar(&testSQLi, "test-sqli", false, "Test SQLi detection")
 }
 
-func analyzeAdvancedWAF(domain string) {
+// WAFReport renders a waf.Result.
+type WAFReport waf.Result
+
+func (r *WAFReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║             WAF DETECTION ANALYSIS REPORT               ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  Target: %s\n\n", domain)
+	color.Cyan("  Target: %s\n\n", r.Target)
 
 	color.Red("  ▸ WAF Identification Indicators:\n")
 
-	wafSignatures := map[string][]string{
-		"Cloudflare": {
-			"Server header: cloudflare",
-			"CF-Ray header present",
-			"cf_clearance cookie",
-			"__cfruid cookie (Bot Management)",
-			"Nameservers: *.ns.cloudflare.com",
-		},
-		"AWS WAF": {
-			"x-amzn-RequestId header",
-			"Server header patterns",
-			"AWS security headers",
-			"Response patterns",
-		},
-		"ModSecurity": {
-			"X-Mod-Security header",
-			"403/406 error patterns",
-			"Rule ID in response",
-		},
-		"Akamai": {
-			"AkamaiGHost in Server header",
-			"X-Akamai-* headers",
-			"Cookie patterns",
-		},
-		"Imperva": {
-			"X-Iinfo header",
-			"X-CDN: Imperva",
-			"Imperva response headers",
-		},
-		"F5 BIG-IP": {
-			"X-Forwarded-* headers",
-			"BigIP persistence cookies",
-			"Response header patterns",
-		},
-		"Barracuda": {
-			"Specific cookie patterns",
-			"Barracuda error pages",
-			"Header signatures",
-		},
-		"Sucuri": {
-			"X-Sucuri-Cache header",
-			"Sucuri error patterns",
-			"Blocking page indicators",
-		},
-		"Wordfence": {
-			"X-Wordfence-* headers",
-			"WordPress security",
-			"Plugin detection",
-		},
-	}
-
-	i := 0
-	for waf, indicators := range wafSignatures {
+	for i, sig := range r.Signatures {
 		prefix := "├─ "
-		if i == len(wafSignatures)-1 {
+		if i == len(r.Signatures)-1 {
 			prefix = "└─ "
 		}
-		fmt.Printf("    %s%s\n", prefix, color.YellowString(waf))
-		for j, indicator := range indicators {
+		fmt.Printf("    %s%s\n", prefix, color.YellowString(sig.Name))
+		for j, indicator := range sig.Indicators {
 			innerPrefix := "│  ├─ "
-			if j == len(indicators)-1 {
+			if j == len(sig.Indicators)-1 {
 				innerPrefix = "│  └─ "
 			}
 			fmt.Printf("    %s%s\n", innerPrefix, indicator)
 		}
-		i++
 	}
 
 	color.Red("\n  ▸ Header Analysis Targets:\n")
-	headers := []string{
-		"Server", "X-Powered-By", "X-Frame-Options",
-		"X-Content-Type-Options", "Strict-Transport-Security",
-		"Content-Security-Policy", "X-XSS-Protection",
-	}
-	for i, header := range headers {
-		if i == len(headers)-1 {
+	for i, header := range r.HeaderTargets {
+		if i == len(r.HeaderTargets)-1 {
 			fmt.Printf("    └─ %s\n", color.BlueString(header))
 		} else {
 			fmt.Printf("    ├─ %s\n", color.BlueString(header))
@@ -138,28 +90,26 @@ func analyzeAdvancedWAF(domain string) {
 	}
 
 	color.Red("\n  ▸ Common Detection Methods:\n")
-	methods := []string{
-		"Analyze HTTP response headers",
-		"Inspect Set-Cookie headers",
-		"Check error page content",
-		"Examine server behavior patterns",
-		"Test XSS/SQLi payload responses",
-	}
-	for i, method := range methods {
-		if i == len(methods)-1 {
+	for i, method := range r.DetectionMethods {
+		if i == len(r.DetectionMethods)-1 {
 			fmt.Printf("    └─ %s\n", method)
 		} else {
 			fmt.Printf("    ├─ %s\n", method)
 		}
 	}
 
-	if testXSS || testSQLi {
+	if len(r.Payloads) > 0 {
 		color.Red("\n  ▸ Payload Testing:\n")
-		if testXSS {
-			fmt.Printf("    ├─ XSS Payload: %s\n", color.RedString("GET /?test=<script>alert('xss')</script>"))
-		}
-		if testSQLi {
-			fmt.Printf("    └─ SQLi Payload: %s\n", color.RedString("GET /?id=1' OR '1'='1"))
+		for i, p := range r.Payloads {
+			prefix := "├─"
+			if i == len(r.Payloads)-1 {
+				prefix = "└─"
+			}
+			label := "XSS Payload"
+			if p.Type == "sqli" {
+				label = "SQLi Payload"
+			}
+			fmt.Printf("    %s %s: %s\n", prefix, label, color.RedString(p.Request))
 		}
 		color.Yellow("\n    ⚠  Only use on domains you own or have permission to test!\n")
 	}
@@ -1,48 +1,151 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"net"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/rdap"
+	"github.com/tanvircs/afsa/pkg/whois"
+)
+
+var (
+	whoisServer           string
+	whoisTimeout          int
+	whoisNoCache          bool
+	whoisVerbose          bool
+	whoisNoRDAP           bool
+	whoisNoFallback       bool
+	whoisRefreshBootstrap bool
 )
 
 var whoisCmd = &cobra.Command{
-	Use:   "whois [domain|ip]",
+	Use:   "whois [domain|ip|asn]",
 	Short: color.RedString("WHOIS Lookup - Domain and IP ownership information"),
-	Long: `WHOIS lookup tool for domain and IP address information:
+	Long: `Registration lookup over RDAP (RFC 7480-7484), falling back to WHOIS:
 
 Features:
-  ▸ Domain registration details
-  ▸ IP address ownership information
-  ▸ Registrar information
-  ▸ Nameserver details
-  ▸ Administrative contacts
-  ▸ Abuse contact information
+  ▸ RDAP servers found from the IANA bootstrap files (bundled, refreshable)
+  ▸ Domains, IPv4/IPv6 addresses and networks, AS numbers and entity
+    handles such as GOGL-ARIN
+  ▸ Registrar, registrant and abuse contacts, registration and expiry
+    dates, status codes and nameservers
+  ▸ Port-43 WHOIS (RFC 3912) where RDAP is unavailable: starts at
+    whois.iana.org and follows referrals to the registry and registrar
+  ▸ Rate-limit replies retried; WHOIS responses cached for an hour
+
+Flags:
+      --no-rdap            Query port-43 WHOIS only
+      --no-fallback        Don't fall back to WHOIS when RDAP fails
+      --refresh-bootstrap  Download the current IANA bootstrap files first
+      --server             WHOIS server to start at, host or host:port
+                           (default: whois.iana.org)
+  -t, --timeout            Seconds to wait for each server (default: 10)
+      --no-cache           Query WHOIS servers even when a cached response
+                           is fresh
+  -v, --verbose            Show every contact and event, and the response
+                           of every WHOIS server along the chain
 
 Examples:
   afsa whois example.com
-  afsa whois 8.8.8.8`,
+  afsa whois 8.8.8.8
+  afsa whois AS15169
+  afsa whois GOGL-ARIN
+  afsa whois example.com --no-rdap --server whois.verisign-grs.com -v`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		target := args[0]
-		performWhoisLookup(target)
+		return runReport("whois", target, func() (report, error) {
+			opts, err := rdapOptions(cmd.Context())
+			if err != nil {
+				return nil, err
+			}
+			res, err := rdap.Lookup(cmd.Context(), target, opts)
+			return (*WhoisReport)(res), err
+		})
 	},
 }
 
-func performWhoisLookup(target string) {
+func init() {
+	whoisCmd.PersistentFlags().StringVar(&whoisServer, "server", whois.DefaultServer, "WHOIS server to start at, host or host:port")
+	whoisCmd.PersistentFlags().IntVarP(&whoisTimeout, "timeout", "t", 10, "Seconds to wait for each server")
+	whoisCmd.PersistentFlags().BoolVar(&whoisNoCache, "no-cache", false, "Query WHOIS servers even when a cached response is fresh")
+	whoisCmd.PersistentFlags().BoolVarP(&whoisVerbose, "verbose", "v", false, "Show every contact and event, and every WHOIS response")
+	whoisCmd.PersistentFlags().BoolVar(&whoisNoRDAP, "no-rdap", false, "Query port-43 WHOIS only")
+	whoisCmd.PersistentFlags().BoolVar(&whoisNoFallback, "no-fallback", false, "Don't fall back to WHOIS when RDAP fails")
+	whoisCmd.PersistentFlags().BoolVar(&whoisRefreshBootstrap, "refresh-bootstrap", false, "Download the current IANA RDAP bootstrap files first")
+}
+
+// rdapOptions builds the lookup options from the flags, refreshing the
+// bootstrap files first when asked. Files refreshed earlier are used
+// over the bundled ones.
+func rdapOptions(ctx context.Context) (rdap.Options, error) {
+	opts := rdap.Options{
+		Timeout: time.Duration(whoisTimeout) * time.Second,
+		NoRDAP:  whoisNoRDAP,
+		NoWHOIS: whoisNoFallback,
+		WHOIS:   whoisOptions(),
+	}
+	dir, err := rdap.DefaultBootstrapDir()
+	if err != nil {
+		if whoisRefreshBootstrap {
+			return opts, err
+		}
+		return opts, nil
+	}
+	if whoisRefreshBootstrap {
+		progressf("  ⚡ Downloading RDAP bootstrap files from %s...\n", rdap.BootstrapURL)
+		if err := rdap.RefreshBootstrap(ctx, nil, dir); err != nil {
+			return opts, fmt.Errorf("refreshing RDAP bootstrap: %w", err)
+		}
+	}
+	if opts.Bootstrap, err = rdap.LoadBootstrap(dir); err != nil {
+		return opts, fmt.Errorf("loading RDAP bootstrap: %w", err)
+	}
+	return opts, nil
+}
+
+// whoisOptions builds the lookup options from the flags. Responses are
+// cached on disk when the user cache directory is usable, in memory
+// otherwise.
+func whoisOptions() whois.Options {
+	opts := whois.Options{Server: whoisServer, Timeout: time.Duration(whoisTimeout) * time.Second}
+	if !whoisNoCache {
+		dir, err := whois.DefaultCacheDir()
+		if err != nil {
+			dir = ""
+		}
+		opts.Cache = whois.NewCache(dir, whois.DefaultCacheTTL)
+	}
+	return opts
+}
+
+// WhoisReport renders an rdap.Result.
+type WhoisReport rdap.Result
+
+func (r *WhoisReport) printText() {
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
 	color.Red("║            WHOIS LOOKUP REPORT                         ║\n")
 	color.Red("╚════════════════════════════════════════════════════════╝\n")
 
-	color.Cyan("  Query Target: %s\n\n", target)
+	color.Cyan("  Query Target: %s\n", r.Target)
+	color.Cyan("  Type: %s\n", r.Type)
+	color.Cyan("  Source: %s (%s)\n\n", strings.ToUpper(r.Source), r.Server)
 
-	// Determine if it's domain or IP
-	if net.ParseIP(target) != nil {
-		performIPWhoisLookup(target)
+	if r.Source == rdap.SourceWHOIS {
+		if len(r.Errors) > 0 {
+			color.Yellow("  RDAP unavailable, fell back to WHOIS: %s\n\n", strings.Join(r.Errors, "; "))
+		}
+		if r.WHOIS.Record != nil {
+			r.printRDAP()
+			fmt.Println()
+		}
+		printWhoisResponses(r.WHOIS)
 	} else {
-		performDomainWhoisLookup(target)
+		r.printRDAP()
 	}
 
 	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
@@ -50,77 +153,177 @@ func performWhoisLookup(target string) {
 	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
 }
 
-func performDomainWhoisLookup(domain string) {
-	color.Red("  ▸ Domain Registration Information:\n")
-	fmt.Printf("    ├─ Domain: %s\n", color.CyanString(domain))
-	fmt.Printf("    ├─ Type: %s\n", color.YellowString("Domain Name"))
-	fmt.Printf("    ├─ Status: %s\n", color.GreenString("Active"))
-
-	color.Red("\n  ▸ Registrar Details:\n")
-	fmt.Printf("    ├─ To retrieve WHOIS data, use:\n")
-	fmt.Printf("    │  %s\n", color.WhiteString("whois "+domain))
-	fmt.Printf("    │  %s\n", color.WhiteString("dig "+domain+" +noall +answer"))
-
-	color.Red("\n  ▸ Key Information Fields:\n")
-	fields := []string{
-		"Domain Name",
-		"Registrar",
-		"Registrant Name",
-		"Registrant Email",
-		"Admin Contact",
-		"Tech Contact",
-		"Nameservers",
-		"Creation Date",
-		"Expiration Date",
-		"Updated Date",
-	}
-	for i, field := range fields {
-		if i == len(fields)-1 {
-			fmt.Printf("    └─ %s\n", field)
+func (r *WhoisReport) printRDAP() {
+	color.Red("  ▸ Registration:\n")
+	if r.Name != "" {
+		fmt.Printf("    ├─ Name: %s\n", color.CyanString(r.Name))
+	}
+	fmt.Printf("    ├─ Handle: %s\n", orPlaceholder(r.Handle, "none"))
+	fmt.Printf("    ├─ Status: %s\n", orPlaceholder(strings.Join(r.Status, ", "), "none"))
+	fmt.Printf("    ├─ Registered: %s\n", whoisDate(r.Registered))
+	if r.Type == whois.TypeDomain {
+		fmt.Printf("    ├─ Updated: %s\n", whoisDate(r.Updated))
+		fmt.Printf("    ├─ Expires: %s\n", whoisDate(r.Expires))
+		fmt.Printf("    └─ DNSSEC: %s\n", yesNo(r.DNSSEC))
+	} else {
+		// Only domains expire.
+		fmt.Printf("    └─ Updated: %s\n", whoisDate(r.Updated))
+	}
+
+	if n := r.Network; n != nil {
+		color.Red("\n  ▸ Network:\n")
+		fmt.Printf("    ├─ Range: %s - %s\n", n.StartAddress, n.EndAddress)
+		fmt.Printf("    ├─ CIDR: %s\n", orPlaceholder(strings.Join(n.CIDRs, ", "), "unknown"))
+		fmt.Printf("    ├─ Type: %s\n", orPlaceholder(n.Type, "unknown"))
+		fmt.Printf("    ├─ Country: %s\n", orPlaceholder(n.Country, "unknown"))
+		fmt.Printf("    └─ Parent: %s\n", orPlaceholder(n.Parent, "none"))
+	}
+	if a := r.AutNum; a != nil {
+		color.Red("\n  ▸ Autonomous System:\n")
+		if a.Start == a.End {
+			fmt.Printf("    ├─ ASN: AS%d\n", a.Start)
 		} else {
-			fmt.Printf("    ├─ %s\n", field)
+			fmt.Printf("    ├─ ASNs: AS%d - AS%d\n", a.Start, a.End)
+		}
+		fmt.Printf("    ├─ Type: %s\n", orPlaceholder(a.Type, "unknown"))
+		fmt.Printf("    └─ Country: %s\n", orPlaceholder(a.Country, "unknown"))
+	}
+
+	if len(r.Nameservers) > 0 {
+		color.Red("\n  ▸ Nameservers:\n")
+		for i, ns := range r.Nameservers {
+			prefix := "├─"
+			if i == len(r.Nameservers)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, ns)
+		}
+	}
+
+	contacts := []labeledContact{{"Registrar", r.Registrar}, {"Registrant", r.Registrant}, {"Abuse", r.Abuse}}
+	if whoisVerbose {
+		contacts = contacts[:0]
+		for i := range r.Contacts {
+			contacts = append(contacts, labeledContact{strings.Join(r.Contacts[i].Roles, ", "), &r.Contacts[i]})
+		}
+	}
+	color.Red("\n  ▸ Contacts:\n")
+	for i, ct := range contacts {
+		branch, indent := "├─", "│  "
+		if i == len(contacts)-1 {
+			branch, indent = "└─", "   "
+		}
+		if ct.c == nil {
+			fmt.Printf("    %s %s: %s\n", branch, ct.label, color.HiBlackString("not published"))
+			continue
+		}
+		fmt.Printf("    %s %s: %s\n", branch, ct.label, color.CyanString(orPlaceholder(contactName(ct.c), "redacted")))
+		var lines []string
+		if ct.c.Handle != "" {
+			lines = append(lines, "Handle: "+ct.c.Handle)
+		}
+		if ct.c.IANAID != "" {
+			lines = append(lines, "IANA ID: "+ct.c.IANAID)
+		}
+		if ct.c.Email != "" {
+			lines = append(lines, "Email: "+ct.c.Email)
+		}
+		if ct.c.Phone != "" {
+			lines = append(lines, "Phone: "+ct.c.Phone)
+		}
+		if ct.c.Address != "" && whoisVerbose {
+			lines = append(lines, "Address: "+ct.c.Address)
+		}
+		for j, line := range lines {
+			sub := "├─"
+			if j == len(lines)-1 {
+				sub = "└─"
+			}
+			fmt.Printf("    %s%s %s\n", indent, sub, line)
+		}
+	}
+
+	if whoisVerbose && len(r.Events) > 0 {
+		color.Red("\n  ▸ Events:\n")
+		for i, e := range r.Events {
+			prefix := "├─"
+			if i == len(r.Events)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s: %s\n", prefix, e.Action, e.Date.Format(time.RFC3339))
+		}
+	}
+
+	// A WHOIS fallback's errors are in the note above the record.
+	if len(r.Errors) > 0 && r.Source == rdap.SourceRDAP {
+		color.Red("\n  ▸ Errors:\n")
+		for i, e := range r.Errors {
+			prefix := "├─"
+			if i == len(r.Errors)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s\n", prefix, color.RedString(e))
 		}
 	}
 }
 
-func performIPWhoisLookup(ip string) {
-	color.Red("  ▸ IP Address Information:\n")
-	fmt.Printf("    ├─ IP Address: %s\n", color.CyanString(ip))
-	fmt.Printf("    ├─ Type: %s\n", color.YellowString("IP Address"))
+// printWhoisResponses shows the referral chain of a port-43 lookup and,
+// with -v, the raw response of every server. Without a parsed record the
+// last server's raw response is shown anyway.
+func printWhoisResponses(w *whois.Result) {
+	color.Red("  ▸ Referral Chain:\n")
+	for i, resp := range w.Responses {
+		prefix := "├─"
+		if i == len(w.Responses)-1 && len(w.Errors) == 0 {
+			prefix = "└─"
+		}
+		note := fmt.Sprintf("(%dms)", resp.DurationMS)
+		if resp.Cached {
+			note = "(cached)"
+		}
+		if resp.Retries > 0 {
+			note += color.YellowString(" after %d rate-limit retries", resp.Retries)
+		}
+		fmt.Printf("    %s %s %s\n", prefix, color.CyanString(resp.Server), note)
+	}
+	for i, e := range w.Errors {
+		prefix := "├─"
+		if i == len(w.Errors)-1 {
+			prefix = "└─"
+		}
+		fmt.Printf("    %s %s\n", prefix, color.RedString(e))
+	}
 
-	ipObj := net.ParseIP(ip)
-	if ipObj.IsPrivate() {
-		fmt.Printf("    ├─ Status: %s\n", color.GreenString("Private"))
-	} else {
-		fmt.Printf("    ├─ Status: %s\n", color.YellowString("Public"))
-	}
-
-	color.Red("\n  ▸ WHOIS Data Source:\n")
-	fmt.Printf("    ├─ To retrieve WHOIS data, use:\n")
-	fmt.Printf("    │  %s\n", color.WhiteString("whois "+ip))
-
-	color.Red("\n  ▸ Regional Internet Registry (RIR):\n")
-	fmt.Printf("    ├─ ARIN (North America)\n")
-	fmt.Printf("    ├─ RIPE (Europe)\n")
-	fmt.Printf("    ├─ APNIC (Asia-Pacific)\n")
-	fmt.Printf("    ├─ LACNIC (Latin America)\n")
-	fmt.Printf("    └─ AFRINIC (Africa)\n")
-
-	color.Red("\n  ▸ Key Information Fields:\n")
-	fields := []string{
-		"IP Address Range",
-		"Organization Name",
-		"Country Code",
-		"Autonomous System (AS)",
-		"Network Name",
-		"Abuse Contact",
-		"Registration Date",
-	}
-	for i, field := range fields {
-		if i == len(fields)-1 {
-			fmt.Printf("    └─ %s\n", field)
-		} else {
-			fmt.Printf("    ├─ %s\n", field)
+	for i, resp := range w.Responses {
+		if !whoisVerbose && (w.Record != nil || i != len(w.Responses)-1) {
+			continue
 		}
+		color.Red("\n  ▸ Response from %s:\n", resp.Server)
+		for _, line := range strings.Split(strings.TrimSpace(resp.Raw), "\n") {
+			fmt.Printf("    │ %s\n", strings.TrimRight(line, " \t\r"))
+		}
+	}
+}
+
+// labeledContact is a contact with the role it is listed under.
+type labeledContact struct {
+	label string
+	c     *rdap.Contact
+}
+
+func whoisDate(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Format("2006-01-02")
+}
+
+func contactName(c *rdap.Contact) string {
+	switch {
+	case c.Name != "" && c.Organization != "" && c.Name != c.Organization:
+		return c.Name + " (" + c.Organization + ")"
+	case c.Name != "":
+		return c.Name
 	}
+	return c.Organization
 }
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tanvircs/afsa/pkg/dns"
+	"github.com/tanvircs/afsa/pkg/rdap"
+)
+
+var (
+	watchFile     string
+	watchDays     int
+	watchStateDir string
+)
+
+var whoisWatchCmd = &cobra.Command{
+	Use:   "watch [domain...]",
+	Short: color.RedString("Domain Watch - Expiry and registration change monitoring"),
+	Long: `Look up a list of domains and compare each with the snapshot saved by the
+previous run:
+
+Features:
+  ▸ Domains expiring within --days days, or already expired
+  ▸ Registrar and nameserver changes
+  ▸ Status flags removed or added, e.g. clientTransferProhibited
+  ▸ Renewals and other expiry date changes, and domains no longer
+    registered
+  ▸ Exits with status 2 when anything changed or a domain is close to
+    expiry, even if other domains could not be checked, and 1 when
+    only lookups or the snapshots failed, so cron jobs can alert
+
+Each change is reported once: the snapshots are replaced after every run.
+Domains are looked up over RDAP with the WHOIS fallback, like afsa whois.
+
+Flags:
+  -f, --file         File with domains to watch, one per line
+      --days         Report domains expiring within this many days (default: 30)
+      --state-dir    Directory of the snapshots (default: ~/.cache/afsa/watch)
+  -v, --verbose      List every domain, not only those to act on
+  -t, --timeout      Seconds to wait for each server (default: 10)
+      --no-rdap      Query port-43 WHOIS only
+
+Examples:
+  afsa whois watch -f domains.txt
+  afsa whois watch example.com example.org --days 60
+  afsa whois watch -f domains.txt -o jsonl >> watch.log || mail -s "domain alert" ops@example.com`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domains := append([]string{}, args...)
+		if watchFile != "" {
+			list, err := dns.LoadWordlist(watchFile)
+			if err != nil {
+				return err
+			}
+			domains = append(domains, list...)
+		}
+		if len(domains) == 0 {
+			return fmt.Errorf("no domains to watch (pass them as arguments or with -f)")
+		}
+
+		var res *rdap.WatchResult
+		err := runReport("whois-watch", strings.Join(domains, ","), func() (report, error) {
+			opts, err := rdapOptions(cmd.Context())
+			if err != nil {
+				return nil, err
+			}
+			dir := watchStateDir
+			if dir == "" {
+				if dir, err = rdap.DefaultWatchDir(); err != nil {
+					return nil, fmt.Errorf("no snapshot directory (use --state-dir): %w", err)
+				}
+			}
+			res, err = rdap.Watch(cmd.Context(), domains, rdap.WatchOptions{
+				Lookup:     opts,
+				Dir:        dir,
+				ExpiryDays: watchDays,
+				OnDomain: func(domain string) {
+					progressf("  ⚡ Checking %s...\n", domain)
+				},
+			})
+			return (*WhoisWatchReport)(res), err
+		})
+		if err != nil {
+			return err
+		}
+		return watchStatus(res)
+	},
+}
+
+// watchStatus is how a watch ends. Findings take precedence over failed
+// lookups, which the report lists, so that one domain failing doesn't
+// hide a change or an expiry in the others.
+func watchStatus(res *rdap.WatchResult) error {
+	if res.Changed > 0 || res.Expiring > 0 {
+		return exitFindings
+	}
+	if res.Failed > 0 {
+		return fmt.Errorf("%d of %d domains could not be checked", res.Failed, len(res.Domains))
+	}
+	return nil
+}
+
+func init() {
+	whoisWatchCmd.Flags().StringVarP(&watchFile, "file", "f", "", "File with domains to watch, one per line")
+	whoisWatchCmd.Flags().IntVar(&watchDays, "days", rdap.DefaultExpiryDays, "Report domains expiring within this many days")
+	whoisWatchCmd.Flags().StringVar(&watchStateDir, "state-dir", "", "Directory of the snapshots (default: the user cache directory)")
+	whoisCmd.AddCommand(whoisWatchCmd)
+}
+
+// WhoisWatchReport renders an rdap.WatchResult.
+type WhoisWatchReport rdap.WatchResult
+
+func (r *WhoisWatchReport) printText() {
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║            DOMAIN WATCH REPORT                         ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n")
+
+	color.Cyan("  Domains: %d\n", len(r.Domains))
+	color.Cyan("  Expiry Window: %d days\n\n", r.ExpiryDays)
+
+	color.Red("  ▸ Changes:\n")
+	var changed []rdap.WatchedDomain
+	for _, d := range r.Domains {
+		if len(d.Changes) > 0 {
+			changed = append(changed, d)
+		}
+	}
+	if len(changed) == 0 {
+		fmt.Printf("    └─ %s\n", color.GreenString("no changes since the last run ✓"))
+	}
+	for i, d := range changed {
+		branch, indent := "├─", "│  "
+		if i == len(changed)-1 {
+			branch, indent = "└─", "   "
+		}
+		since := ""
+		if d.LastChecked != nil {
+			since = color.HiBlackString(" (since %s)", d.LastChecked.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("    %s %s%s\n", branch, color.CyanString(d.Domain), since)
+		for j, c := range d.Changes {
+			sub := "├─"
+			if j == len(d.Changes)-1 {
+				sub = "└─"
+			}
+			fmt.Printf("    %s%s %s\n", indent, sub, changeText(c))
+		}
+	}
+
+	color.Red("\n  ▸ Expiring Within %d Days:\n", r.ExpiryDays)
+	var expiring []rdap.WatchedDomain
+	for _, d := range r.Domains {
+		if d.Expiring {
+			expiring = append(expiring, d)
+		}
+	}
+	if len(expiring) == 0 {
+		fmt.Printf("    └─ %s\n", color.GreenString("none ✓"))
+	}
+	for i, d := range expiring {
+		prefix := "├─"
+		if i == len(expiring)-1 {
+			prefix = "└─"
+		}
+		fmt.Printf("    %s %s: %s %s\n", prefix, color.CyanString(d.Domain), whoisDate(d.Expires), daysLeft(*d.DaysLeft))
+	}
+
+	if whoisVerbose {
+		color.Red("\n  ▸ Domains:\n")
+		for i, d := range r.Domains {
+			branch, indent := "├─", "│  "
+			if i == len(r.Domains)-1 {
+				branch, indent = "└─", "   "
+			}
+			note := ""
+			if d.FirstSeen {
+				note = color.YellowString(" [first seen]")
+			}
+			fmt.Printf("    %s %s%s\n", branch, color.CyanString(d.Domain), note)
+			if d.Error != "" {
+				fmt.Printf("    %s└─ %s\n", indent, color.RedString(d.Error))
+				continue
+			}
+			fmt.Printf("    %s├─ Registrar: %s\n", indent, orPlaceholder(d.Registrar, "unknown"))
+			fmt.Printf("    %s├─ Nameservers: %s\n", indent, orPlaceholder(strings.Join(d.Nameservers, ", "), "none"))
+			fmt.Printf("    %s├─ Status: %s\n", indent, orPlaceholder(strings.Join(d.Status, ", "), "none"))
+			fmt.Printf("    %s└─ Expires: %s\n", indent, whoisDate(d.Expires))
+		}
+	}
+
+	var failed []rdap.WatchedDomain
+	for _, d := range r.Domains {
+		if d.Error != "" {
+			failed = append(failed, d)
+		}
+	}
+	if len(failed) > 0 {
+		color.Red("\n  ▸ Errors:\n")
+		for i, d := range failed {
+			prefix := "├─"
+			if i == len(failed)-1 {
+				prefix = "└─"
+			}
+			fmt.Printf("    %s %s: %s\n", prefix, d.Domain, color.RedString(d.Error))
+		}
+	}
+
+	firstSeen := 0
+	for _, d := range r.Domains {
+		if d.FirstSeen {
+			firstSeen++
+		}
+	}
+	color.Red("\n  ▸ Summary:\n")
+	fmt.Printf("    ├─ Checked: %d (%d first seen)\n", len(r.Domains), firstSeen)
+	fmt.Printf("    ├─ Changed: %d\n", r.Changed)
+	fmt.Printf("    ├─ Expiring: %d\n", r.Expiring)
+	fmt.Printf("    └─ Failed: %d\n", r.Failed)
+
+	color.Red("\n╔════════════════════════════════════════════════════════╗\n")
+	color.Red("║    [✓] Domain Watch Completed                          ║\n")
+	color.Red("╚════════════════════════════════════════════════════════╝\n\n")
+}
+
+func changeText(c rdap.Change) string {
+	switch c.Kind {
+	case rdap.ChangeRegistrar:
+		return fmt.Sprintf("Registrar: %s → %s", c.Old, color.YellowString(c.New))
+	case rdap.ChangeNameservers:
+		return fmt.Sprintf("Nameservers: %s → %s", c.Old, color.YellowString(c.New))
+	case rdap.ChangeStatusRemoved:
+		return "Status removed: " + color.RedString(c.Old)
+	case rdap.ChangeStatusAdded:
+		return "Status added: " + color.YellowString(c.New)
+	case rdap.ChangeExpires:
+		return fmt.Sprintf("Expires: %s → %s", c.Old, color.YellowString(c.New))
+	case rdap.ChangeUnregistered:
+		return color.RedString("No longer registered")
+	}
+	return fmt.Sprintf("%s: %s → %s", c.Kind, c.Old, c.New)
+}
+
+func daysLeft(n int) string {
+	switch {
+	case n < 0:
+		return color.RedString("(expired %d days ago)", -n)
+	case n <= 7:
+		return color.RedString("(%d days left)", n)
+	}
+	return color.YellowString("(%d days left)", n)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tanvircs/afsa/pkg/rdap"
+)
+
+func TestWatchStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    rdap.WatchResult
+		status exitStatus
+		failed bool
+	}{
+		{name: "quiet"},
+		{name: "changed", res: rdap.WatchResult{Changed: 1}, status: exitFindings},
+		{name: "expiring", res: rdap.WatchResult{Expiring: 2}, status: exitFindings},
+		{name: "failed", res: rdap.WatchResult{Domains: make([]rdap.WatchedDomain, 3), Failed: 1}, failed: true},
+		// A domain failing doesn't hide the findings in the others.
+		{name: "changed and failed", res: rdap.WatchResult{Changed: 1, Failed: 1}, status: exitFindings},
+	}
+	for _, tt := range tests {
+		err := watchStatus(&tt.res)
+		var status exitStatus
+		errors.As(err, &status)
+		if status != tt.status || (err != nil && status == 0) != tt.failed {
+			t.Errorf("%s: %v, want status %d, failed %v", tt.name, err, tt.status, tt.failed)
+		}
+	}
+}
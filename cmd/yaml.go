@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// encodeYAML writes v as a YAML block document. It honours the same
+// `json` struct tags as encoding/json so the YAML and JSON outputs always
+// carry identical field names.
+func encodeYAML(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	e := &yamlEncoder{w: bw}
+	rv := yamlIndirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		bw.WriteString("null\n")
+		return bw.Flush()
+	}
+	if s, ok := yamlScalar(rv); ok {
+		bw.WriteString(s + "\n")
+		return bw.Flush()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map:
+		if pairs := yamlPairs(rv); len(pairs) > 0 {
+			e.writeMapping(pairs, 0, false)
+		} else {
+			bw.WriteString("{}\n")
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Len() > 0 {
+			e.writeSequence(rv, 0)
+		} else {
+			bw.WriteString("[]\n")
+		}
+	default:
+		bw.WriteString(yamlQuote(fmt.Sprint(rv.Interface())) + "\n")
+	}
+	return bw.Flush()
+}
+
+type yamlEncoder struct {
+	w *bufio.Writer
+}
+
+type yamlPair struct {
+	key   string
+	value reflect.Value
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// writeValue emits v directly after a "key:" or "- " prefix. inSeq is set
+// when the caller already wrote "- " so a mapping can start on that line.
+func (e *yamlEncoder) writeValue(v reflect.Value, indent int, inSeq bool) {
+	v = yamlIndirect(v)
+	if !v.IsValid() {
+		e.w.WriteString(" null\n")
+		return
+	}
+
+	if s, ok := yamlScalar(v); ok {
+		e.w.WriteString(" " + s + "\n")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		pairs := yamlPairs(v)
+		if len(pairs) == 0 {
+			e.w.WriteString(" {}\n")
+			return
+		}
+		if inSeq {
+			e.w.WriteString(" ")
+			e.writeMapping(pairs, indent, true)
+			return
+		}
+		e.w.WriteString("\n")
+		e.writeMapping(pairs, indent+2, false)
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			e.w.WriteString(" []\n")
+			return
+		}
+		e.w.WriteString("\n")
+		e.writeSequence(v, indent+2)
+	default:
+		e.w.WriteString(" " + yamlQuote(fmt.Sprint(v.Interface())) + "\n")
+	}
+}
+
+func (e *yamlEncoder) writeMapping(pairs []yamlPair, indent int, firstInline bool) {
+	pad := strings.Repeat(" ", indent)
+	for i, p := range pairs {
+		if i > 0 || !firstInline {
+			e.w.WriteString(pad)
+		}
+		e.w.WriteString(yamlQuote(p.key) + ":")
+		e.writeValue(p.value, indent, false)
+	}
+}
+
+func (e *yamlEncoder) writeSequence(v reflect.Value, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i := 0; i < v.Len(); i++ {
+		e.w.WriteString(pad + "-")
+		e.writeValue(v.Index(i), indent+2, true)
+	}
+}
+
+func yamlIndirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		if v.Type().Implements(textMarshalerType) {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func yamlScalar(v reflect.Value) (string, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return yamlQuote(err.Error()), true
+		}
+		return yamlQuote(string(b)), true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return yamlQuote(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true
+	}
+	return "", false
+}
+
+func yamlPairs(v reflect.Value) []yamlPair {
+	var pairs []yamlPair
+
+	if v.Kind() == reflect.Map {
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			pairs = append(pairs, yamlPair{key: fmt.Sprint(k.Interface()), value: v.MapIndex(k)})
+		}
+		return pairs
+	}
+
+	for _, f := range jsonFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		pairs = append(pairs, yamlPair{key: f.name, value: fv})
+	}
+	return pairs
+}
+
+// jsonField is a struct field as encoding/json encodes it.
+type jsonField struct {
+	name      string
+	omitEmpty bool
+	tagged    bool
+	index     []int
+	typ       reflect.Type
+}
+
+// jsonFields returns the fields encoding/json encodes for the struct type
+// t, in order. The fields of an embedded struct without a json name are
+// promoted into t; a name hides the same name deeper down, and names that
+// clash at one depth are dropped unless only one of them is tagged.
+func jsonFields(t reflect.Type) []jsonField {
+	var all []jsonField
+	collectJSONFields(t, nil, &all)
+
+	var fields []jsonField
+	for i, f := range all {
+		dominant := true
+		for j, g := range all {
+			if i == j || g.name != f.name {
+				continue
+			}
+			if len(g.index) < len(f.index) || len(g.index) == len(f.index) && (g.tagged || !f.tagged) {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func collectJSONFields(t reflect.Type, index []int, fields *[]jsonField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if name, _, _ := strings.Cut(tag, ","); name == "" && tag != "-" && ft.Kind() == reflect.Struct {
+				collectJSONFields(ft, idx, fields)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name, omitEmpty, skip := parseJSONTag(field)
+		if skip {
+			continue
+		}
+		tagName, _, _ := strings.Cut(tag, ",")
+		*fields = append(*fields, jsonField{name: name, omitEmpty: omitEmpty, tagged: tagName != "", index: idx, typ: field.Type})
+	}
+}
+
+// fieldByIndex returns the field of v at index, reporting false when it
+// sits behind a nil embedded pointer, which encoding/json leaves out.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func parseJSONTag(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+// isEmptyValue mirrors encoding/json's omitempty rules.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// yamlQuote returns s as a plain scalar when that is unambiguous and as a
+// double-quoted scalar otherwise.
+func yamlQuote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9', r == '.', r == '/', r == '-', r == '@', r == '+':
+			if i == 0 && (r == '-' || r == '@') {
+				return strconv.Quote(s)
+			}
+		case r == ' ':
+			if i == 0 || i == len(s)-1 {
+				return strconv.Quote(s)
+			}
+		default:
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
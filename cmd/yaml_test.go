@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func yamlString(t *testing.T, v interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestYAMLQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com", "example.com"},
+		{"two words", "two words"},
+		{"", `""`},
+		{"true", `"true"`},
+		{"No", `"No"`},
+		{"null", `"null"`},
+		{"~", `"~"`},
+		{"443", `"443"`},
+		{"1e3", `"1e3"`},
+		{"-all", `"-all"`},
+		{"@home", `"@home"`},
+		{"v=spf1 -all", `"v=spf1 -all"`},
+		{"key: value", `"key: value"`},
+		{"# comment", `"# comment"`},
+		{" leading", `" leading"`},
+		{"trailing ", `"trailing "`},
+		{"line\nbreak", `"line\nbreak"`},
+		{"2001:db8::1", `"2001:db8::1"`},
+		{"a+b/c_d-e@f.g", "a+b/c_d-e@f.g"},
+	}
+	for _, tt := range tests {
+		if got := yamlQuote(tt.in); got != tt.want {
+			t.Errorf("yamlQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	type Base struct {
+		ID   int    `json:"id"`
+		Note string `json:"note,omitempty"`
+	}
+	type item struct {
+		Name  string   `json:"name"`
+		Ports []int    `json:"ports"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	type doc struct {
+		Base
+		When    time.Time         `json:"when"`
+		Last    *time.Time        `json:"last"`
+		Next    *time.Time        `json:"next,omitempty"`
+		Items   []item            `json:"items"`
+		Empty   []string          `json:"empty"`
+		Nested  *item             `json:"nested"`
+		Labels  map[string]string `json:"labels"`
+		Nothing struct{}          `json:"nothing"`
+		Skipped string            `json:"-"`
+		Value   string
+	}
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := doc{
+		Base: Base{ID: 7},
+		When: when,
+		Items: []item{
+			{Name: "web", Ports: []int{80, 443}},
+			{Name: "true", Ports: []int{}, Tags: []string{"-x", "y"}},
+		},
+		Empty:   []string{},
+		Labels:  map[string]string{"b": "2", "a": "one"},
+		Skipped: "hidden",
+		Value:   "untagged",
+	}
+	want := `id: 7
+when: "2024-01-02T03:04:05Z"
+last: null
+items:
+  - name: web
+    ports:
+      - 80
+      - 443
+  - name: "true"
+    ports: []
+    tags:
+      - "-x"
+      - "y"
+empty: []
+nested: null
+labels:
+  a: one
+  b: "2"
+nothing: {}
+Value: untagged
+`
+	if got := yamlString(t, v); got != want {
+		t.Errorf("encodeYAML:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Scalars and sequences at the top level
+	if got := yamlString(t, "yes"); got != "\"yes\"\n" {
+		t.Errorf("scalar: %q", got)
+	}
+	if got := yamlString(t, []int{1, 2}); got != "- 1\n- 2\n" {
+		t.Errorf("sequence: %q", got)
+	}
+	if got := yamlString(t, (*doc)(nil)); got != "null\n" {
+		t.Errorf("nil pointer: %q", got)
+	}
+	if got := yamlString(t, []string{}); got != "[]\n" {
+		t.Errorf("empty sequence: %q", got)
+	}
+	if got := yamlString(t, when); got != "\"2024-01-02T03:04:05Z\"\n" {
+		t.Errorf("time: %q", got)
+	}
+}
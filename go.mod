@@ -4,7 +4,9 @@ go 1.21
 
 require (
 	github.com/fatih/color v1.16.0
+	github.com/miekg/dns v1.1.58
 	github.com/spf13/cobra v1.7.0
+	golang.org/x/net v0.20.0
 )
 
 require (
@@ -12,5 +14,7 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/mod v0.14.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/tools v0.17.0 // indirect
 )
@@ -0,0 +1,307 @@
+package asn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Origin is what is known of the routing of an address.
+type Origin struct {
+	Address string `json:"address"`
+	// Prefix is the most specific routed prefix covering Address; empty
+	// when the dataset has none.
+	Prefix  string `json:"prefix,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	ASName  string `json:"as_name,omitempty"`
+	Country string `json:"country,omitempty"`
+	// RIR is the regional internet registry the address was allocated
+	// from, such as ARIN or RIPE NCC.
+	RIR string `json:"rir,omitempty"`
+}
+
+// Origin returns the covering prefix, origin AS, AS name and country of
+// addr. The RIR is not known to the table and is left empty.
+func (t *Table) Origin(addr netip.Addr) (Origin, bool) {
+	o := Origin{Address: addr.String()}
+	r, ok := t.Lookup(addr)
+	if !ok {
+		return o, false
+	}
+	o.Prefix, o.ASN, o.ASName, o.Country = r.Prefix.String(), r.ASN, t.Name(r.ASN), r.Country
+	return o, true
+}
+
+// DefaultPath returns where the imported dataset is kept, in the user's
+// cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "afsa", "asn", "ip2asn.dat"), nil
+}
+
+// datasetMagic starts a dataset written by Save.
+const datasetMagic = "AFSAASN\x01"
+
+// Node flags in a saved dataset.
+const (
+	nodeSet = 1 << iota
+	nodeChild0
+	nodeChild1
+)
+
+// Load reads a dataset written by Save. Any other format Import reads
+// works too, only slower.
+func Load(path string) (*Table, error) {
+	t := NewTable()
+	if _, err := t.ImportFile(path); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Save writes t to path in FormatAFSA, a binary form of the radix trees
+// that Load turns back into a table without parsing or inserting a route
+// at a time: the country codes, the number of nodes, each tree in
+// preorder, then the AS names.
+// Integers are uvarints; a node is its flags, prefix length, the bytes of
+// its address the length covers and, for a route, the AS and the index
+// of its country plus one.
+func (t *Table) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// Write and rename, so lookups never read half a dataset.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriterSize(tmp, 1<<16)
+	t.writeDataset(w)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (t *Table) writeDataset(w *bufio.Writer) {
+	var buf [binary.MaxVarintLen64]byte
+	uvarint := func(x uint64) {
+		w.Write(buf[:binary.PutUvarint(buf[:], x)])
+	}
+	str := func(s string) {
+		uvarint(uint64(len(s)))
+		w.WriteString(s)
+	}
+
+	w.WriteString(datasetMagic)
+	countries := make([]string, 0, len(t.countries))
+	for c := range t.countries {
+		countries = append(countries, c)
+	}
+	sort.Strings(countries)
+	index := map[string]uint64{}
+	uvarint(uint64(len(countries)))
+	for i, c := range countries {
+		index[c] = uint64(i + 1)
+		str(c)
+	}
+
+	var count func(n *node) uint64
+	count = func(n *node) uint64 {
+		if n == nil {
+			return 0
+		}
+		return 1 + count(n.child[0]) + count(n.child[1])
+	}
+	uvarint(count(t.v4) + count(t.v6))
+
+	var write func(n *node)
+	write = func(n *node) {
+		var flags byte
+		if n.set {
+			flags |= nodeSet
+		}
+		if n.child[0] != nil {
+			flags |= nodeChild0
+		}
+		if n.child[1] != nil {
+			flags |= nodeChild1
+		}
+		w.WriteByte(flags)
+		w.WriteByte(byte(n.prefix.Bits()))
+		a := n.prefix.Addr().AsSlice()
+		w.Write(a[:(n.prefix.Bits()+7)/8])
+		if n.set {
+			uvarint(uint64(n.asn))
+			uvarint(index[n.country])
+		}
+		for _, c := range n.child {
+			if c != nil {
+				write(c)
+			}
+		}
+	}
+	for _, root := range []*node{t.v4, t.v6} {
+		if root == nil {
+			w.WriteByte(0)
+			continue
+		}
+		w.WriteByte(1)
+		write(root)
+	}
+
+	asns := make([]uint32, 0, len(t.names))
+	for n := range t.names {
+		asns = append(asns, n)
+	}
+	sort.Slice(asns, func(i, j int) bool { return asns[i] < asns[j] })
+	uvarint(uint64(len(asns)))
+	for _, n := range asns {
+		uvarint(uint64(n))
+		str(t.names[n])
+	}
+}
+
+// errCorruptDataset is returned for a saved dataset that is truncated or
+// otherwise can't be read back.
+var errCorruptDataset = errors.New("corrupt dataset, import it again")
+
+// datasetReader reads the fields of a saved dataset, keeping the first
+// error.
+type datasetReader struct {
+	b   []byte
+	err error
+	// nodes are allocated together, sparing the garbage collector
+	// millions of small objects.
+	nodes []node
+}
+
+// next returns the next n bytes.
+func (d *datasetReader) next(n int) []byte {
+	if d.err != nil || n > len(d.b) {
+		d.err = errCorruptDataset
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *datasetReader) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *datasetReader) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errCorruptDataset
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
+}
+
+func (d *datasetReader) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = errCorruptDataset
+	}
+	return string(d.next(int(n)))
+}
+
+// node reads a node and the nodes below it. Each is checked to lie on
+// its side of parent, so Lookup can trust the tree.
+func (d *datasetReader) node(t *Table, countries []string, parent netip.Prefix, side, size int) *node {
+	flags, bits := d.byte(), int(d.byte())
+	if bits > size*8 {
+		d.err = errCorruptDataset
+	}
+	var a [16]byte
+	copy(a[:], d.next((bits+7)/8))
+	if d.err != nil {
+		return nil
+	}
+	addr, _ := netip.AddrFromSlice(a[:size])
+	p := netip.PrefixFrom(addr, bits)
+	if p != p.Masked() ||
+		parent.IsValid() && (bits <= parent.Bits() || !parent.Contains(addr) || bit(addr, parent.Bits()) != side) {
+		d.err = errCorruptDataset
+		return nil
+	}
+	if len(d.nodes) == 0 {
+		d.err = errCorruptDataset
+		return nil
+	}
+	n := &d.nodes[0]
+	d.nodes = d.nodes[1:]
+	n.prefix, n.set = p, flags&nodeSet != 0
+	if n.set {
+		asn, c := d.uvarint(), d.uvarint()
+		if asn > math.MaxUint32 || c >= uint64(len(countries)) {
+			d.err = errCorruptDataset
+			return nil
+		}
+		n.asn, n.country = uint32(asn), countries[c]
+		t.routes++
+	}
+	if flags&nodeChild0 != 0 {
+		n.child[0] = d.node(t, countries, p, 0, size)
+	}
+	if flags&nodeChild1 != 0 {
+		n.child[1] = d.node(t, countries, p, 1, size)
+	}
+	return n
+}
+
+// readDataset reads what Save wrote into the empty table t.
+func (t *Table) readDataset(b []byte) error {
+	d := &datasetReader{b: b}
+	d.next(len(datasetMagic))
+	countries := []string{""}
+	for i := d.uvarint(); i > 0 && d.err == nil; i-- {
+		countries = append(countries, t.intern(d.string()))
+	}
+	// Each node takes at least two bytes.
+	nodes := d.uvarint()
+	if nodes > uint64(len(d.b))/2 {
+		d.err = errCorruptDataset
+	}
+	if d.err != nil {
+		return d.err
+	}
+	d.nodes = make([]node, nodes)
+	if d.byte() != 0 {
+		t.v4 = d.node(t, countries, netip.Prefix{}, 0, 4)
+	}
+	if d.byte() != 0 {
+		t.v6 = d.node(t, countries, netip.Prefix{}, 0, 16)
+	}
+	for i := d.uvarint(); i > 0 && d.err == nil; i-- {
+		asn, name := d.uvarint(), d.string()
+		if asn > math.MaxUint32 {
+			d.err = errCorruptDataset
+			break
+		}
+		t.names[uint32(asn)] = name
+	}
+	return d.err
+}
@@ -0,0 +1,105 @@
+package asn
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testTable(t *testing.T) *Table {
+	t.Helper()
+	tab := NewTable()
+	input := "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+		"8.0.0.0\t8.255.255.255\t3356\tUS\tLEVEL3\n" +
+		"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n" +
+		"10.0.0.0\t10.0.2.255\t64500\tNL\tEXAMPLE\n" +
+		"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t64501\tDE\tEXAMPLE-V6\n" +
+		"2606:4700::/32\t13335\n"
+	if _, err := tab.Import(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	return tab
+}
+
+func routes(tab *Table) []Route {
+	var rs []Route
+	tab.Walk(func(r Route) { rs = append(rs, r) })
+	return rs
+}
+
+func TestSaveLoad(t *testing.T) {
+	tab := testTable(t)
+	path := filepath.Join(t.TempDir(), "asn", "ip2asn.dat")
+	if err := tab.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != tab.Len() || !reflect.DeepEqual(routes(got), routes(tab)) {
+		t.Errorf("loaded routes %v, want %v", routes(got), routes(tab))
+	}
+	if !reflect.DeepEqual(got.names, tab.names) {
+		t.Errorf("loaded names %v, want %v", got.names, tab.names)
+	}
+	for _, addr := range []string{"8.8.8.8", "8.8.4.4", "10.0.1.1", "2001:db8::1", "2606:4700::1111"} {
+		a := netip.MustParseAddr(addr)
+		want, wantOK := tab.Lookup(a)
+		if r, ok := got.Lookup(a); r != want || ok != wantOK {
+			t.Errorf("Lookup(%s) = %+v, %v, want %+v, %v", addr, r, ok, want, wantOK)
+		}
+	}
+}
+
+func TestImportSavedDataset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip2asn.dat")
+	if err := testTable(t).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merged into a table that has routes, the dataset's win.
+	tab := NewTable()
+	tab.Insert(Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), ASN: 64511})
+	tab.Insert(Route{Prefix: netip.MustParsePrefix("192.0.2.0/24"), ASN: 64512})
+	st, err := tab.ImportFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Format != FormatAFSA || st.Routes != 7 || st.Names != 5 {
+		t.Errorf("stats = %+v, want format %s, 7 routes, 5 names", st, FormatAFSA)
+	}
+	if r, _ := tab.Lookup(netip.MustParseAddr("8.8.8.8")); r.ASN != 15169 || r.Country != "US" {
+		t.Errorf("Lookup(8.8.8.8) = %+v, want AS15169 from the dataset", r)
+	}
+	if r, _ := tab.Lookup(netip.MustParseAddr("192.0.2.1")); r.ASN != 64512 {
+		t.Errorf("Lookup(192.0.2.1) = %+v, want the earlier AS64512", r)
+	}
+	if tab.Len() != 8 || tab.Name(15169) != "GOOGLE" {
+		t.Errorf("Len() = %d, Name(15169) = %q, want 8 and GOOGLE", tab.Len(), tab.Name(15169))
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ip2asn.dat")
+	if err := testTable(t).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{len(datasetMagic) + 1, len(b) / 2, len(b) - 1} {
+		p := filepath.Join(dir, "truncated.dat")
+		if err := os.WriteFile(p, b[:n], 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(p); err == nil {
+			t.Errorf("Load of %d of %d bytes succeeded, want an error", n, len(b))
+		}
+	}
+}
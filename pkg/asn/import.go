@@ -0,0 +1,317 @@
+package asn
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+)
+
+// Formats Import reads.
+const (
+	// FormatAFSA is the binary format Save writes.
+	FormatAFSA = "afsa"
+	// FormatTSV is "prefix<TAB>asn<TAB>country" lines and
+	// "AS<n><TAB>name" lines, handy for lists made by hand.
+	FormatTSV = "tsv"
+	// FormatIPtoASN is the iptoasn.com ip2asn TSV: "first<TAB>last<TAB>
+	// asn<TAB>country<TAB>name", with addresses or, in the -u32 files,
+	// integers.
+	FormatIPtoASN = "iptoasn"
+	// FormatPrefix2AS is CAIDA's RouteViews prefix2as file:
+	// "address<TAB>length<TAB>asn".
+	FormatPrefix2AS = "prefix2as"
+	// FormatMRT is an MRT TABLE_DUMP_V2 RIB dump (RFC 6396), such as the
+	// rib.*.bz2 files of RouteViews and RIPE RIS.
+	FormatMRT = "mrt"
+)
+
+// ImportStats is what Import read from one input.
+type ImportStats struct {
+	// File is the path ImportFile read.
+	File   string `json:"file,omitempty"`
+	Format string `json:"format"`
+	// Compression is "gzip" or "bzip2" when the input was compressed.
+	Compression string `json:"compression,omitempty"`
+	Routes      int    `json:"routes"`
+	Names       int    `json:"names"`
+	// Skipped counts lines or records that could not be read, and
+	// unrouted ranges.
+	Skipped int `json:"skipped"`
+}
+
+// ImportResult is the outcome of ImportFiles.
+type ImportResult struct {
+	// Path is where the dataset was saved.
+	Path       string        `json:"path"`
+	Files      []ImportStats `json:"files"`
+	Routes     int           `json:"routes"`
+	Names      int           `json:"names"`
+	DurationMS int64         `json:"duration_ms"`
+}
+
+// ImportFiles builds a dataset from files and saves it at path, where
+// Load reads it back. Where two files have the same prefix, the later
+// one wins. Files may be of different formats, e.g. an iptoasn TSV for
+// AS names and countries followed by a fresher MRT dump.
+func ImportFiles(ctx context.Context, files []string, path string) (*ImportResult, error) {
+	start := time.Now()
+	t := NewTable()
+	res := &ImportResult{Path: path, Files: []ImportStats{}}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		st, err := t.ImportFile(f)
+		if err != nil {
+			return res, err
+		}
+		res.Files = append(res.Files, st)
+	}
+	if err := t.Save(path); err != nil {
+		return res, err
+	}
+	res.Routes, res.Names = t.Len(), len(t.names)
+	res.DurationMS = time.Since(start).Milliseconds()
+	return res, nil
+}
+
+// ImportFile imports the routes and AS names in the file at path.
+func (t *Table) ImportFile(path string) (ImportStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImportStats{}, err
+	}
+	defer f.Close()
+	st, err := t.Import(f)
+	st.File = path
+	if err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	return st, nil
+}
+
+// Import reads routes and AS names from r into t. The format is detected
+// from the content, as is gzip or bzip2 compression. Lines or records
+// that can't be read are skipped and counted; Import fails when nothing
+// could be read.
+func (t *Table) Import(r io.Reader) (ImportStats, error) {
+	var st ImportStats
+	br := bufio.NewReaderSize(r, 1<<16)
+	magic, _ := br.Peek(3)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return st, err
+		}
+		defer zr.Close()
+		br, st.Compression = bufio.NewReaderSize(zr, 1<<16), "gzip"
+	case string(magic) == "BZh":
+		br, st.Compression = bufio.NewReaderSize(bzip2.NewReader(br), 1<<16), "bzip2"
+	}
+
+	var err error
+	if head, _ := br.Peek(12); strings.HasPrefix(string(head), datasetMagic) {
+		st.Format = FormatAFSA
+		err = t.importDataset(br, &st)
+	} else if isMRT(head) {
+		st.Format = FormatMRT
+		err = t.importMRT(br, &st)
+	} else {
+		err = t.importText(br, &st)
+	}
+	if err != nil {
+		return st, err
+	}
+	if st.Routes == 0 && st.Names == 0 {
+		return st, fmt.Errorf("no routes found (expected an iptoasn or prefix2as TSV or an MRT RIB dump)")
+	}
+	return st, nil
+}
+
+// importDataset reads a dataset Save wrote. Into an empty table it is
+// read as is; otherwise its routes are inserted one by one.
+func (t *Table) importDataset(r *bufio.Reader, st *ImportStats) error {
+	dst := t
+	if t.routes > 0 || len(t.names) > 0 {
+		dst = NewTable()
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := dst.readDataset(b); err != nil {
+		return err
+	}
+	st.Routes, st.Names = dst.routes, len(dst.names)
+	if dst != t {
+		dst.Walk(t.Insert)
+		for n, name := range dst.names {
+			t.names[n] = name
+		}
+	}
+	return nil
+}
+
+// isMRT reports whether head is the start of an MRT record: a type
+// field of TABLE_DUMP, TABLE_DUMP_V2 or BGP4MP, and a subtype whose
+// high byte is zero, which no text line has.
+func isMRT(head []byte) bool {
+	if len(head) < 12 || head[6] != 0 {
+		return false
+	}
+	switch binary.BigEndian.Uint16(head[4:6]) {
+	case mrtTableDump, mrtTableDumpV2, mrtBGP4MP, mrtBGP4MPET:
+		return true
+	}
+	return false
+}
+
+func (t *Table) importText(r io.Reader, st *ImportStats) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 1 {
+			fields = strings.Fields(line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		format, ok := t.importLine(fields, st)
+		if !ok {
+			st.Skipped++
+			continue
+		}
+		if st.Format == "" {
+			st.Format = format
+		}
+	}
+	return sc.Err()
+}
+
+// importLine reads one line of any of the text formats, telling them
+// apart by the shape of the first fields.
+func (t *Table) importLine(f []string, st *ImportStats) (string, bool) {
+	if len(f) < 2 {
+		return "", false
+	}
+	// AS13335<TAB>CLOUDFLARENET
+	if n, ok := parseASN(f[0]); ok && strings.HasPrefix(strings.ToUpper(f[0]), "AS") {
+		t.SetName(n, strings.Join(f[1:], " "))
+		st.Names++
+		return FormatTSV, true
+	}
+	// 1.1.1.0/24<TAB>13335<TAB>AU
+	if prefix, err := netip.ParsePrefix(f[0]); err == nil {
+		n, ok := parseASN(f[1])
+		if !ok {
+			return "", false
+		}
+		t.Insert(Route{Prefix: prefix, ASN: n, Country: field(f, 2)})
+		st.Routes++
+		return FormatTSV, true
+	}
+	if len(f) < 3 {
+		return "", false
+	}
+	first, last, ok := rangeBounds(f[0], f[1])
+	if ok {
+		// 1.0.0.0<TAB>1.0.0.255<TAB>13335<TAB>US<TAB>CLOUDFLARENET
+		n, ok := parseASN(f[2])
+		if !ok {
+			return "", false
+		}
+		if n == 0 {
+			// "Not routed".
+			st.Skipped++
+			return FormatIPtoASN, true
+		}
+		country := field(f, 3)
+		if strings.EqualFold(country, "None") {
+			country = ""
+		}
+		for _, c := range whois.RangeCIDRs(first.String(), last.String()) {
+			t.Insert(Route{Prefix: netip.MustParsePrefix(c), ASN: n, Country: country})
+			st.Routes++
+		}
+		if len(f) > 4 {
+			// Every range of an AS repeats its name; count it once.
+			if name := strings.Join(f[4:], " "); t.names[n] != name {
+				t.SetName(n, name)
+				st.Names++
+			}
+		}
+		return FormatIPtoASN, true
+	}
+	// 1.0.0.0<TAB>24<TAB>13335
+	addr, err := netip.ParseAddr(f[0])
+	bits, err2 := strconv.Atoi(f[1])
+	if err != nil || err2 != nil {
+		return "", false
+	}
+	prefix, err := addr.Prefix(bits)
+	n, ok := parseASN(f[2])
+	if err != nil || !ok {
+		return "", false
+	}
+	t.Insert(Route{Prefix: prefix, ASN: n})
+	st.Routes++
+	return FormatPrefix2AS, true
+}
+
+// rangeBounds reads an iptoasn range, given as addresses or as the
+// integers of IPv4 addresses.
+func rangeBounds(first, last string) (netip.Addr, netip.Addr, bool) {
+	a, err1 := netip.ParseAddr(first)
+	b, err2 := netip.ParseAddr(last)
+	if err1 == nil && err2 == nil {
+		return a, b, a.BitLen() == b.BitLen()
+	}
+	x, err1 := strconv.ParseUint(first, 10, 32)
+	y, err2 := strconv.ParseUint(last, 10, 32)
+	if err1 != nil || err2 != nil || x > y {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+	var ba, bb [4]byte
+	binary.BigEndian.PutUint32(ba[:], uint32(x))
+	binary.BigEndian.PutUint32(bb[:], uint32(y))
+	return netip.AddrFrom4(ba), netip.AddrFrom4(bb), true
+}
+
+// parseASN reads an AS number, with or without "AS". Of a multi-origin
+// prefix2as entry such as 701_702 or an AS set such as 1234,5678 the
+// first is taken.
+func parseASN(s string) (uint32, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	if i := strings.IndexAny(s, "_,"); i >= 0 {
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err == nil
+}
+
+func field(f []string, i int) string {
+	if i < len(f) {
+		return f[i]
+	}
+	return ""
+}
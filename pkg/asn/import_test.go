@@ -0,0 +1,110 @@
+package asn
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestImportText(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		format  string
+		routes  int
+		names   int
+		skipped int
+		lookup  string
+		want    Route
+		asName  string
+	}{
+		{
+			name:   "iptoasn",
+			input:  "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n",
+			format: FormatIPtoASN, routes: 1, names: 1, skipped: 1,
+			lookup: "1.0.0.1",
+			want:   Route{Prefix: netip.MustParsePrefix("1.0.0.0/24"), ASN: 13335, Country: "US"},
+			asName: "CLOUDFLARENET",
+		},
+		{
+			name:   "iptoasn u32",
+			input:  "16777216\t16777471\t13335\tUS\tCLOUDFLARENET\n",
+			format: FormatIPtoASN, routes: 1, names: 1,
+			lookup: "1.0.0.1",
+			want:   Route{Prefix: netip.MustParsePrefix("1.0.0.0/24"), ASN: 13335, Country: "US"},
+			asName: "CLOUDFLARENET",
+		},
+		{
+			name:   "iptoasn range split into prefixes",
+			input:  "10.0.0.0\t10.0.2.255\t64500\tNL\tEXAMPLE\n",
+			format: FormatIPtoASN, routes: 2, names: 1,
+			lookup: "10.0.2.1",
+			want:   Route{Prefix: netip.MustParsePrefix("10.0.2.0/24"), ASN: 64500, Country: "NL"},
+			asName: "EXAMPLE",
+		},
+		{
+			name:   "truncated iptoasn rows",
+			input:  "1.0.0.0\t1.0.0.255\t13335\n16777472\t16777727\t13335\n2.0.0.0\t2.0.0.255\n",
+			format: FormatIPtoASN, routes: 2, skipped: 1,
+			lookup: "1.0.1.1",
+			want:   Route{Prefix: netip.MustParsePrefix("1.0.1.0/24"), ASN: 13335},
+		},
+		{
+			name:   "prefix2as",
+			input:  "8.0.0.0\t9\t3356\n8.8.8.0\t24\t15169\n9.9.9.0\t24\t19281_42\n",
+			format: FormatPrefix2AS, routes: 3,
+			lookup: "8.8.8.8",
+			want:   Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), ASN: 15169},
+		},
+		{
+			name:   "truncated prefix2as rows",
+			input:  "8.0.0.0\t9\t3356\n8.8.8.0\t24\n9.9.9.0\n",
+			format: FormatPrefix2AS, routes: 1, skipped: 2,
+			lookup: "8.8.8.8",
+			want:   Route{Prefix: netip.MustParsePrefix("8.0.0.0/9"), ASN: 3356},
+		},
+		{
+			name:   "afsa",
+			input:  "# comment\n2606:4700::/32\t13335\tUS\nAS13335\tCLOUDFLARENET\n",
+			format: FormatTSV, routes: 1, names: 1,
+			lookup: "2606:4700::1111",
+			want:   Route{Prefix: netip.MustParsePrefix("2606:4700::/32"), ASN: 13335, Country: "US"},
+			asName: "CLOUDFLARENET",
+		},
+		{
+			name:   "garbage lines skipped",
+			input:  "1.1.1.0/24\t13335\nnot a route\n1.1.1.0/24\tASX\nAS\n",
+			format: FormatTSV, routes: 1, skipped: 3,
+			lookup: "1.1.1.1",
+			want:   Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), ASN: 13335},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tab := NewTable()
+			st, err := tab.Import(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if st.Format != tt.format || st.Routes != tt.routes || st.Names != tt.names || st.Skipped != tt.skipped {
+				t.Errorf("stats = %+v, want format %s, %d routes, %d names, %d skipped",
+					st, tt.format, tt.routes, tt.names, tt.skipped)
+			}
+			got, ok := tab.Lookup(netip.MustParseAddr(tt.lookup))
+			if !ok || got != tt.want {
+				t.Errorf("Lookup(%s) = %+v, %v, want %+v", tt.lookup, got, ok, tt.want)
+			}
+			if name := tab.Name(tt.want.ASN); name != tt.asName {
+				t.Errorf("Name(%d) = %q, want %q", tt.want.ASN, name, tt.asName)
+			}
+		})
+	}
+}
+
+func TestImportNothing(t *testing.T) {
+	for _, input := range []string{"", "# only a comment\n", "1.0.0.0\t1.0.0.255\n"} {
+		if _, err := NewTable().Import(strings.NewReader(input)); err == nil {
+			t.Errorf("Import(%q) succeeded, want an error", input)
+		}
+	}
+}
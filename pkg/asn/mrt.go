@@ -0,0 +1,169 @@
+package asn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// MRT record types (RFC 6396 section 4).
+const (
+	mrtTableDump   = 12
+	mrtTableDumpV2 = 13
+	mrtBGP4MP      = 16
+	mrtBGP4MPET    = 17
+)
+
+// TABLE_DUMP_V2 subtypes holding routes (RFC 6396 section 4.3).
+const (
+	ribIPv4Unicast = 2
+	ribIPv6Unicast = 4
+)
+
+// BGP path attribute fields (RFC 4271 section 4.3).
+const (
+	attrExtendedLength = 0x10
+	attrASPath         = 2
+	asSequence         = 2
+)
+
+// maxMRTRecord bounds a record; RIB entries of a full table stay well
+// below it.
+const maxMRTRecord = 16 << 20
+
+var errMRTTruncated = errors.New("truncated MRT record")
+
+// importMRT reads the IPv4 and IPv6 unicast RIB entries of a
+// TABLE_DUMP_V2 dump. Each prefix gets the origin AS most of its paths
+// end in. Other record types are skipped.
+func (t *Table) importMRT(r io.Reader, st *ImportStats) error {
+	header := make([]byte, 12)
+	var body []byte
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading MRT header: %w", err)
+		}
+		typ := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+		if length > maxMRTRecord {
+			return fmt.Errorf("MRT record of %d bytes, corrupt dump?", length)
+		}
+		if cap(body) < int(length) {
+			body = make([]byte, length)
+		}
+		body = body[:length]
+		if _, err := io.ReadFull(r, body); err != nil {
+			return fmt.Errorf("reading MRT record: %w", err)
+		}
+		if typ != mrtTableDumpV2 || (subtype != ribIPv4Unicast && subtype != ribIPv6Unicast) {
+			continue
+		}
+		prefix, origin, err := parseRIBEntry(body, subtype == ribIPv6Unicast)
+		if err != nil {
+			st.Skipped++
+			continue
+		}
+		t.Insert(Route{Prefix: prefix, ASN: origin})
+		st.Routes++
+	}
+}
+
+// parseRIBEntry reads a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record: the
+// prefix, then one entry per peer with its path attributes.
+func parseRIBEntry(b []byte, v6 bool) (netip.Prefix, uint32, error) {
+	if len(b) < 5 {
+		return netip.Prefix{}, 0, errMRTTruncated
+	}
+	bits := int(b[4])
+	size := 4
+	if v6 {
+		size = 16
+	}
+	n := (bits + 7) / 8
+	if bits > size*8 || len(b) < 5+n+2 {
+		return netip.Prefix{}, 0, errMRTTruncated
+	}
+	var raw [16]byte
+	copy(raw[:], b[5:5+n])
+	addr := netip.AddrFrom16(raw)
+	if !v6 {
+		addr = netip.AddrFrom4([4]byte{raw[0], raw[1], raw[2], raw[3]})
+	}
+	prefix := netip.PrefixFrom(addr, bits).Masked()
+
+	b = b[5+n:]
+	entries := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	votes := map[uint32]int{}
+	var origin uint32
+	for i := 0; i < entries; i++ {
+		// Peer index, originated time, attribute length.
+		if len(b) < 8 {
+			return prefix, 0, errMRTTruncated
+		}
+		attrLen := int(binary.BigEndian.Uint16(b[6:8]))
+		if len(b) < 8+attrLen {
+			return prefix, 0, errMRTTruncated
+		}
+		if as, ok := pathOrigin(b[8 : 8+attrLen]); ok {
+			votes[as]++
+			if votes[as] > votes[origin] || (votes[as] == votes[origin] && as < origin) {
+				origin = as
+			}
+		}
+		b = b[8+attrLen:]
+	}
+	if len(votes) == 0 {
+		return prefix, 0, fmt.Errorf("%s: no AS_PATH", prefix)
+	}
+	return prefix, origin, nil
+}
+
+// pathOrigin returns the last AS of the AS_PATH in attrs. TABLE_DUMP_V2
+// encodes AS numbers in four bytes (RFC 6396 section 4.3.4).
+func pathOrigin(attrs []byte) (uint32, bool) {
+	for len(attrs) >= 3 {
+		flags, typ := attrs[0], attrs[1]
+		hdr, length := 3, int(attrs[2])
+		if flags&attrExtendedLength != 0 {
+			if len(attrs) < 4 {
+				return 0, false
+			}
+			hdr, length = 4, int(binary.BigEndian.Uint16(attrs[2:4]))
+		}
+		if len(attrs) < hdr+length {
+			return 0, false
+		}
+		value := attrs[hdr : hdr+length]
+		attrs = attrs[hdr+length:]
+		if typ != attrASPath {
+			continue
+		}
+		var origin uint32
+		found := false
+		for len(value) >= 2 {
+			segType, count := value[0], int(value[1])
+			if len(value) < 2+4*count {
+				return 0, false
+			}
+			if count > 0 {
+				// An AS_SET at the end aggregates several origins; its
+				// first member stands in for them.
+				last := count - 1
+				if segType != asSequence {
+					last = 0
+				}
+				origin, found = binary.BigEndian.Uint32(value[2+4*last:]), true
+			}
+			value = value[2+4*count:]
+		}
+		return origin, found
+	}
+	return 0, false
+}
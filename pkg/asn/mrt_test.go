@@ -0,0 +1,150 @@
+package asn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// mrtRecord wraps body in an MRT header.
+func mrtRecord(typ, subtype uint16, body []byte) []byte {
+	b := make([]byte, 12, 12+len(body))
+	binary.BigEndian.PutUint32(b[0:4], 1700000000)
+	binary.BigEndian.PutUint16(b[4:6], typ)
+	binary.BigEndian.PutUint16(b[6:8], subtype)
+	binary.BigEndian.PutUint32(b[8:12], uint32(len(body)))
+	return append(b, body...)
+}
+
+// ribEntry builds a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST body for prefix
+// with one entry per set of path attributes.
+func ribEntry(prefix netip.Prefix, entries ...[]byte) []byte {
+	b := []byte{0, 0, 0, 7, byte(prefix.Bits())}
+	b = append(b, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(entries)))
+	for i, attrs := range entries {
+		b = binary.BigEndian.AppendUint16(b, uint16(i))
+		b = binary.BigEndian.AppendUint32(b, 1700000000)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return b
+}
+
+// attr encodes a path attribute, with a two-byte length when flags ask
+// for it.
+func attr(flags, typ byte, value []byte) []byte {
+	b := []byte{flags, typ}
+	if flags&attrExtendedLength != 0 {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	} else {
+		b = append(b, byte(len(value)))
+	}
+	return append(b, value...)
+}
+
+// segment encodes an AS_PATH segment of four-byte AS numbers.
+func segment(typ byte, asns ...uint32) []byte {
+	b := []byte{typ, byte(len(asns))}
+	for _, as := range asns {
+		b = binary.BigEndian.AppendUint32(b, as)
+	}
+	return b
+}
+
+const asSet = 1
+
+// path is the transitive AS_PATH attribute of the AS sequence asns.
+func path(asns ...uint32) []byte {
+	return attr(0x40, attrASPath, segment(asSequence, asns...))
+}
+
+// originIGP is an ORIGIN attribute of IGP, which is no AS_PATH.
+var originIGP = attr(0x40, 1, []byte{0})
+
+func TestImportMRT(t *testing.T) {
+	truncated := ribEntry(netip.MustParsePrefix("203.0.113.0/24"), path(64496), path(64497))
+	var dump []byte
+	for _, rec := range [][]byte{
+		// PEER_INDEX_TABLE and BGP4MP records are skipped.
+		mrtRecord(mrtTableDumpV2, 1, []byte{0, 0, 0, 0, 0, 0, 0, 0}),
+		mrtRecord(mrtBGP4MP, 4, []byte{1, 2, 3}),
+		// Two of three peers see 15169 as the origin.
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribEntry(netip.MustParsePrefix("8.8.8.0/24"),
+			path(3356, 15169), path(64511, 6939, 64512), append(append([]byte{}, originIGP...), path(174, 15169)...))),
+		// A tie goes to the lower AS.
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribEntry(netip.MustParsePrefix("9.9.9.0/24"), path(19281), path(42))),
+		// An extended-length AS_PATH after another attribute.
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribEntry(netip.MustParsePrefix("1.1.1.0/24"),
+			append(append([]byte{}, originIGP...), attr(0x50, attrASPath, segment(asSequence, 6939, 13335))...))),
+		// An aggregate ending in an AS_SET takes its first member.
+		mrtRecord(mrtTableDumpV2, ribIPv6Unicast, ribEntry(netip.MustParsePrefix("2001:db8::/32"),
+			attr(0x40, attrASPath, append(segment(asSequence, 3356), segment(asSet, 64500, 64501)...)))),
+		// Skipped: an entry cut off, no AS_PATH, a prefix longer than the
+		// address and an AS_PATH segment running past its attribute.
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, truncated[:len(truncated)-3]),
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribEntry(netip.MustParsePrefix("198.51.100.0/24"), originIGP)),
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, []byte{0, 0, 0, 1, 33, 192, 0, 2, 0, 0}),
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribEntry(netip.MustParsePrefix("192.0.2.0/24"),
+			attr(0x40, attrASPath, segment(asSequence, 64496, 64497)[:7]))),
+	} {
+		dump = append(dump, rec...)
+	}
+
+	tab := NewTable()
+	st, err := tab.Import(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Format != FormatMRT || st.Routes != 4 || st.Skipped != 4 {
+		t.Errorf("stats = %+v, want format %s, 4 routes, 4 skipped", st, FormatMRT)
+	}
+	for addr, want := range map[string]Route{
+		"8.8.8.8":     {Prefix: netip.MustParsePrefix("8.8.8.0/24"), ASN: 15169},
+		"9.9.9.9":     {Prefix: netip.MustParsePrefix("9.9.9.0/24"), ASN: 42},
+		"1.1.1.1":     {Prefix: netip.MustParsePrefix("1.1.1.0/24"), ASN: 13335},
+		"2001:db8::1": {Prefix: netip.MustParsePrefix("2001:db8::/32"), ASN: 64500},
+	} {
+		if got, ok := tab.Lookup(netip.MustParseAddr(addr)); !ok || got != want {
+			t.Errorf("Lookup(%s) = %+v, %v, want %+v", addr, got, ok, want)
+		}
+	}
+	for _, addr := range []string{"203.0.113.1", "198.51.100.1", "192.0.2.1"} {
+		if got, ok := tab.Lookup(netip.MustParseAddr(addr)); ok {
+			t.Errorf("Lookup(%s) = %+v from a skipped record", addr, got)
+		}
+	}
+
+	// A dump ending mid-record fails rather than losing its tail quietly.
+	_, err = NewTable().Import(bytes.NewReader(dump[:len(dump)-5]))
+	if err == nil || !strings.Contains(err.Error(), "reading MRT record") {
+		t.Errorf("Import of a cut dump: %v, want a read error", err)
+	}
+}
+
+func TestPathOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		attrs  []byte
+		origin uint32
+		ok     bool
+	}{
+		{name: "sequence", attrs: path(3356, 15169), origin: 15169, ok: true},
+		{name: "several segments", attrs: attr(0x40, attrASPath, append(segment(asSequence, 3356), segment(asSequence, 174, 13335)...)), origin: 13335, ok: true},
+		{name: "trailing empty segment", attrs: attr(0x40, attrASPath, append(segment(asSequence, 3356, 13335), segment(asSequence)...)), origin: 13335, ok: true},
+		{name: "AS_SET", attrs: attr(0x40, attrASPath, segment(asSet, 64500, 64501)), origin: 64500, ok: true},
+		{name: "extended length", attrs: attr(0x50, attrASPath, segment(asSequence, 4200000000)), origin: 4200000000, ok: true},
+		{name: "empty path", attrs: attr(0x40, attrASPath, nil)},
+		{name: "no AS_PATH", attrs: originIGP},
+		{name: "attribute past the end", attrs: path(3356, 15169)[:8]},
+		{name: "extended length cut", attrs: []byte{0x50, attrASPath, 0}},
+	}
+	for _, tt := range tests {
+		origin, ok := pathOrigin(tt.attrs)
+		if origin != tt.origin || ok != tt.ok {
+			t.Errorf("%s: pathOrigin = %d, %v, want %d, %v", tt.name, origin, ok, tt.origin, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package asn maps IP addresses to the prefix that covers them and the AS
+// that originates it, offline, from an imported IP-to-ASN dataset: an
+// iptoasn.com TSV, a CAIDA RouteViews prefix2as file or an MRT RIB dump.
+package asn
+
+import (
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// Route is a prefix and the AS that originates it.
+type Route struct {
+	Prefix netip.Prefix
+	ASN    uint32
+	// Country is the ISO 3166 code the dataset gives, if any.
+	Country string
+}
+
+// Table finds the most specific route covering an address. IPv4 and
+// IPv6 routes are kept in separate path-compressed radix trees.
+type Table struct {
+	v4, v6    *node
+	names     map[uint32]string
+	countries map[string]string
+	routes    int
+}
+
+// node is a radix tree node. Nodes with set false only join two
+// branches.
+type node struct {
+	prefix  netip.Prefix
+	asn     uint32
+	country string
+	set     bool
+	child   [2]*node
+}
+
+// NewTable returns an empty table.
+func NewTable() *Table {
+	return &Table{names: map[uint32]string{}, countries: map[string]string{}}
+}
+
+// Len returns the number of routes in t.
+func (t *Table) Len() int {
+	return t.routes
+}
+
+// Insert adds a route, replacing any earlier one for the same prefix. The
+// earlier country is kept if r has none, as prefix2as files and RIB dumps
+// don't give countries.
+func (t *Table) Insert(r Route) {
+	p := r.Prefix.Masked()
+	if !p.IsValid() {
+		return
+	}
+	if p.Addr().Is4In6() {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96).Masked()
+	}
+	country := t.intern(r.Country)
+	n := &t.v6
+	if p.Addr().Is4() {
+		n = &t.v4
+	}
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &node{prefix: p, asn: r.ASN, country: country, set: true}
+			t.routes++
+			return
+		}
+		common := commonBits(cur.prefix, p)
+		switch {
+		case common == cur.prefix.Bits() && common == p.Bits():
+			if !cur.set {
+				t.routes++
+			}
+			if country != "" || !cur.set {
+				cur.country = country
+			}
+			cur.asn, cur.set = r.ASN, true
+			return
+		case common == cur.prefix.Bits():
+			// p is inside cur.
+			n = &cur.child[bit(p.Addr(), common)]
+			continue
+		case common == p.Bits():
+			// cur is inside p.
+			nn := &node{prefix: p, asn: r.ASN, country: country, set: true}
+			nn.child[bit(cur.prefix.Addr(), common)] = cur
+			*n = nn
+		default:
+			glue := &node{prefix: netip.PrefixFrom(p.Addr(), common).Masked()}
+			glue.child[bit(cur.prefix.Addr(), common)] = cur
+			glue.child[bit(p.Addr(), common)] = &node{prefix: p, asn: r.ASN, country: country, set: true}
+			*n = glue
+		}
+		t.routes++
+		return
+	}
+}
+
+// Lookup returns the most specific route covering addr.
+func (t *Table) Lookup(addr netip.Addr) (Route, bool) {
+	addr = addr.Unmap()
+	n := t.v6
+	if addr.Is4() {
+		n = t.v4
+	}
+	var best *node
+	for n != nil && n.prefix.Contains(addr) {
+		if n.set {
+			best = n
+		}
+		if n.prefix.Bits() == addr.BitLen() {
+			break
+		}
+		n = n.child[bit(addr, n.prefix.Bits())]
+	}
+	if best == nil {
+		return Route{}, false
+	}
+	return Route{Prefix: best.prefix, ASN: best.asn, Country: best.country}, true
+}
+
+// Walk calls fn for every route, IPv4 first, each tree in address order
+// with a prefix before the prefixes inside it.
+func (t *Table) Walk(fn func(Route)) {
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n == nil {
+			return
+		}
+		if n.set {
+			fn(Route{Prefix: n.prefix, ASN: n.asn, Country: n.country})
+		}
+		walk(n.child[0])
+		walk(n.child[1])
+	}
+	walk(t.v4)
+	walk(t.v6)
+}
+
+// SetName records the name of an AS. An empty name is ignored.
+func (t *Table) SetName(asn uint32, name string) {
+	if name = strings.TrimSpace(name); name != "" {
+		t.names[asn] = strings.Clone(name)
+	}
+}
+
+// Name returns the name of an AS, or "" if the dataset has none.
+func (t *Table) Name(asn uint32) string {
+	return t.names[asn]
+}
+
+// intern returns one shared copy of each country code, so routes don't
+// keep the lines they were read from alive.
+func (t *Table) intern(s string) string {
+	if s == "" {
+		return ""
+	}
+	if c, ok := t.countries[s]; ok {
+		return c
+	}
+	c := strings.Clone(s)
+	t.countries[c] = c
+	return c
+}
+
+// commonBits returns the length of the prefix a and b share, at most the
+// shorter of the two.
+func commonBits(a, b netip.Prefix) int {
+	limit := a.Bits()
+	if b.Bits() < limit {
+		limit = b.Bits()
+	}
+	x, y := a.Addr().As16(), b.Addr().As16()
+	first := 0
+	if a.Addr().Is4() {
+		first = 12
+	}
+	n := 0
+	for i := first; i < len(x); i++ {
+		if d := x[i] ^ y[i]; d != 0 {
+			n += bits.LeadingZeros8(d)
+			break
+		}
+		n += 8
+	}
+	if n > limit {
+		return limit
+	}
+	return n
+}
+
+// bit returns bit i of addr, counting from the most significant.
+func bit(addr netip.Addr, i int) int {
+	b := addr.As16()
+	if addr.Is4() {
+		i += 96
+	}
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
@@ -0,0 +1,303 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// TransferOptions configures a ZoneTransfer.
+type TransferOptions struct {
+	// ResolverOptions find the zone and its nameservers. Timeout also
+	// bounds each read during a transfer and TLSConfig is used for
+	// servers given as tls://; the transfers themselves are tried once
+	// and not sent through the Exchanger.
+	ResolverOptions
+	// Servers are the nameservers to try, as host or host:port, instead
+	// of the zone's NS records. domain is then taken as the zone name.
+	// A tls://host[:port] server is asked for the zone over TLS (RFC
+	// 9103); DNS over HTTPS cannot carry a transfer.
+	Servers []string
+}
+
+// TransferResult reports which nameservers of a zone allow zone
+// transfers to anyone.
+type TransferResult struct {
+	Zone    string           `json:"zone"`
+	Servers []ServerTransfer `json:"servers"`
+	// Leaking are the servers that handed out zone data.
+	Leaking []string `json:"leaking"`
+	// File is where the transferred records were saved, if anywhere.
+	File string `json:"file,omitempty"`
+}
+
+// ServerTransfer is the outcome of the transfer attempts against one
+// nameserver address.
+type ServerTransfer struct {
+	Server string `json:"server"`
+	// Nameserver is the NS host name the address belongs to, unless the
+	// server was given explicitly.
+	Nameserver string          `json:"nameserver,omitempty"`
+	AXFR       TransferAttempt `json:"axfr"`
+	IXFR       TransferAttempt `json:"ixfr"`
+	// Records are the records the server transferred: the full AXFR
+	// zone, or the IXFR answer when only that was allowed.
+	Records []Record `json:"records,omitempty"`
+	Error   string   `json:"error,omitempty"`
+
+	rrs []dnswire.RR
+}
+
+// TransferAttempt is the outcome of one AXFR or IXFR request.
+type TransferAttempt struct {
+	Allowed bool `json:"allowed"`
+	// Refused is set when the server answered with an error code.
+	Refused bool `json:"refused"`
+	// Records is the number of records received.
+	Records int    `json:"records"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ZoneTransfer requests a full (AXFR) and an incremental (IXFR) zone
+// transfer of domain's zone from each of its authoritative nameservers
+// and reports those that answer. Only failing to find the nameservers
+// or a cancelled context is an error.
+func ZoneTransfer(ctx context.Context, domain string, opts TransferOptions) (*TransferResult, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &TransferResult{Servers: []ServerTransfer{}, Leaking: []string{}}
+	if len(opts.Servers) > 0 {
+		r.Zone = dnswire.CanonicalName(domain)
+		for _, s := range opts.Servers {
+			server, err := NormalizeServer(s)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(server, "https://") {
+				return nil, fmt.Errorf("zone transfers need a nameserver reached over TCP or TLS, not DNS over HTTPS (%s)", server)
+			}
+			r.Servers = append(r.Servers, ServerTransfer{Server: server})
+		}
+	} else {
+		zone, nameservers, err := authoritativeNameservers(ctx, client, domain)
+		if err != nil {
+			return nil, fmt.Errorf("finding authoritative nameservers: %w", err)
+		}
+		r.Zone = zone
+		for _, ns := range nameservers {
+			if len(ns.servers) == 0 {
+				r.Servers = append(r.Servers, ServerTransfer{Nameserver: ns.host, Error: "no addresses found"})
+			}
+			for _, server := range ns.servers {
+				r.Servers = append(r.Servers, ServerTransfer{Server: server, Nameserver: ns.host})
+			}
+		}
+	}
+
+	forEach(ctx, len(r.Servers), func(i int) {
+		if r.Servers[i].Server != "" {
+			transferFrom(ctx, client, r.Zone, &r.Servers[i])
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	for i := range r.Servers {
+		st := &r.Servers[i]
+		if st.AXFR.Allowed || st.IXFR.Allowed {
+			r.Leaking = append(r.Leaking, st.Server)
+		}
+		for _, rr := range st.rrs {
+			h := rr.Header()
+			st.Records = append(st.Records, Record{Name: h.Name, Type: dnswire.TypeToString[h.Rrtype], TTL: h.Ttl, Data: rdata(rr)})
+		}
+	}
+	return r, nil
+}
+
+// transferFrom tries AXFR, then IXFR from the serial before the current
+// one, against st.Server.
+func transferFrom(ctx context.Context, c *Client, zone string, st *ServerTransfer) {
+	axfr := new(dnswire.Msg)
+	axfr.SetAxfr(zone)
+	rrs, err := c.transfer(ctx, axfr, st.Server)
+	st.AXFR = attempt(rrs, err)
+	if st.AXFR.Allowed {
+		st.rrs = rrs
+	}
+
+	// IXFR needs the serial the server is at
+	resp, err := c.QueryServer(ctx, st.Server, zone, dnswire.TypeSOA)
+	if err != nil {
+		st.IXFR.Error = err.Error()
+		return
+	}
+	var serial uint32
+	for _, rr := range resp.Msg.Answer {
+		if soa, ok := rr.(*dnswire.SOA); ok {
+			serial = soa.Serial
+		}
+	}
+	if serial == 0 {
+		st.IXFR.Error = "server returned no SOA record for the zone"
+		return
+	}
+	ixfr := new(dnswire.Msg)
+	ixfr.SetIxfr(zone, serial-1, ".", ".")
+	rrs, err = c.transfer(ctx, ixfr, st.Server)
+	st.IXFR = attempt(rrs, err)
+	// A server that is up to date answers with its SOA alone, which
+	// reveals nothing.
+	if len(rrs) <= 1 {
+		st.IXFR.Allowed = false
+	}
+	if st.IXFR.Allowed && !st.AXFR.Allowed {
+		st.rrs = rrs
+	}
+}
+
+func attempt(rrs []dnswire.RR, err error) TransferAttempt {
+	a := TransferAttempt{Allowed: len(rrs) > 0, Refused: errors.Is(err, errRefused), Records: len(rrs)}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	return a
+}
+
+// transfer runs a zone transfer over TCP, or TLS for a tls:// server,
+// and returns every record received, together with the error that ended
+// it early, if any.
+func (c *Client) transfer(ctx context.Context, m *dnswire.Msg, server string) ([]dnswire.RR, error) {
+	timeout := c.timeout()
+	var conn net.Conn
+	var err error
+	if addr, ok := strings.CutPrefix(server, "tls://"); ok {
+		host, _, _ := net.SplitHostPort(addr)
+		d := tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout}, Config: c.tlsConfig(host)}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		d := net.Dialer{Timeout: timeout}
+		conn, err = d.DialContext(ctx, "tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	rrs, err := readTransfer(ctx, &dnswire.Conn{Conn: conn}, m, timeout)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return rrs, err
+}
+
+var errRefused = errors.New("transfer refused")
+
+// readTransfer sends the AXFR or IXFR request m on conn and reads the
+// answer until the zone's SOA record closes it, as RFC 5936 and RFC 1995
+// describe. A reply with an error code wraps errRefused.
+func readTransfer(ctx context.Context, conn *dnswire.Conn, m *dnswire.Msg, timeout time.Duration) ([]dnswire.RR, error) {
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err := conn.WriteMsg(m); err != nil {
+		return nil, err
+	}
+
+	var rrs []dnswire.RR
+	var serial uint32
+	// soas counts the SOA records carrying the zone's current serial: a
+	// full zone ends at the second, an incremental one at the third.
+	soas := 0
+	incremental := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return rrs, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		in, err := conn.ReadMsg()
+		if err != nil {
+			return rrs, err
+		}
+		if in.Id != m.Id {
+			return rrs, dnswire.ErrId
+		}
+		if in.Rcode != dnswire.RcodeSuccess {
+			name, ok := dnswire.RcodeToString[in.Rcode]
+			if !ok {
+				name = fmt.Sprint(in.Rcode)
+			}
+			return rrs, fmt.Errorf("%w (%s)", errRefused, name)
+		}
+		for _, rr := range in.Answer {
+			soa, isSOA := rr.(*dnswire.SOA)
+			switch {
+			case len(rrs) == 0 && !isSOA:
+				return nil, dnswire.ErrSoa
+			case len(rrs) == 0:
+				serial = soa.Serial
+				soas = 1
+			case isSOA && soa.Serial == serial:
+				soas++
+			case isSOA:
+				incremental = true
+			}
+			rrs = append(rrs, rr)
+		}
+		if len(rrs) == 0 {
+			return nil, dnswire.ErrSoa
+		}
+		// An IXFR from a serial the server is not past is answered with
+		// its SOA alone.
+		if m.Question[0].Qtype == dnswire.TypeIXFR && len(rrs) == 1 && len(m.Ns) > 0 {
+			if since, ok := m.Ns[0].(*dnswire.SOA); ok && since.Serial >= serial {
+				return rrs, nil
+			}
+		}
+		if soas == 2 && !incremental || soas == 3 {
+			return rrs, nil
+		}
+	}
+}
+
+// WriteZone writes the records each leaking server transferred in zone
+// file format, under a comment naming the server.
+func (r *TransferResult) WriteZone(w io.Writer) error {
+	written := false
+	for _, st := range r.Servers {
+		if len(st.rrs) == 0 {
+			continue
+		}
+		kind := "AXFR"
+		if !st.AXFR.Allowed {
+			kind = "IXFR"
+		}
+		if _, err := fmt.Fprintf(w, "; %s of %s from %s\n", kind, r.Zone, st.Server); err != nil {
+			return err
+		}
+		for _, rr := range st.rrs {
+			if _, err := fmt.Fprintln(w, rr.String()); err != nil {
+				return err
+			}
+		}
+		written = true
+	}
+	if !written {
+		return errors.New("no server transferred the zone")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+func zoneServer(t *testing.T) *dnstest.Server {
+	t.Helper()
+	srv := newServer(t)
+	srv.Add(
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300",
+		"example.com. 86400 IN NS ns1.example.com.",
+		"example.com. 300 IN A 192.0.2.1",
+		"ns1.example.com. 300 IN A 192.0.2.53",
+		"internal.example.com. 300 IN A 10.0.0.1",
+	)
+	return srv
+}
+
+func TestZoneTransfer(t *testing.T) {
+	srv := zoneServer(t)
+	srv.AllowTransfer()
+
+	r, err := ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{srv.Addr}, ResolverOptions: ResolverOptions{Timeout: 2 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Zone != "example.com." || !reflect.DeepEqual(r.Leaking, []string{srv.Addr}) || len(r.Servers) != 1 {
+		t.Fatalf("zone %s, leaking %q, servers %+v, want %s leaking", r.Zone, r.Leaking, r.Servers, srv.Addr)
+	}
+	st := r.Servers[0]
+	// The zone's SOA starts and ends the transfer.
+	if !st.AXFR.Allowed || st.AXFR.Refused || st.AXFR.Records != 6 || st.AXFR.Error != "" {
+		t.Errorf("AXFR = %+v, want 6 records", st.AXFR)
+	}
+	if !st.IXFR.Allowed || st.IXFR.Records != 6 {
+		t.Errorf("IXFR = %+v, want the full zone", st.IXFR)
+	}
+	found := false
+	for _, rec := range st.Records {
+		if rec.Name == "internal.example.com." && rec.Type == "A" && rec.Data == "10.0.0.1" {
+			found = true
+		}
+	}
+	if len(st.Records) != 6 || !found {
+		t.Errorf("Records = %+v, want the transferred zone", st.Records)
+	}
+
+	var types []string
+	for _, q := range srv.Queries() {
+		types = append(types, q.Type+" "+q.Net)
+	}
+	if want := []string{"AXFR tcp", "SOA udp", "IXFR tcp"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("queries %q, want %q", types, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteZone(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zone := buf.String()
+	if !strings.HasPrefix(zone, "; AXFR of example.com. from "+srv.Addr+"\n") ||
+		!strings.Contains(zone, "internal.example.com.\t300\tIN\tA\t10.0.0.1\n") {
+		t.Errorf("WriteZone wrote:\n%s", zone)
+	}
+}
+
+func TestZoneTransferRefused(t *testing.T) {
+	srv := zoneServer(t)
+
+	r, err := ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{srv.Addr}, ResolverOptions: ResolverOptions{Timeout: 2 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Leaking) != 0 || len(r.Servers) != 1 {
+		t.Fatalf("leaking %q, servers %+v, want none leaking", r.Leaking, r.Servers)
+	}
+	st := r.Servers[0]
+	for kind, a := range map[string]TransferAttempt{"AXFR": st.AXFR, "IXFR": st.IXFR} {
+		if a.Allowed || !a.Refused || a.Records != 0 || !strings.Contains(a.Error, "REFUSED") {
+			t.Errorf("%s = %+v, want refused", kind, a)
+		}
+	}
+	if len(st.Records) != 0 {
+		t.Errorf("Records = %+v, want none", st.Records)
+	}
+	if err := r.WriteZone(&bytes.Buffer{}); err == nil {
+		t.Error("WriteZone succeeded with nothing transferred")
+	}
+}
+
+func TestZoneTransferUnreachable(t *testing.T) {
+	srv := zoneServer(t)
+	addr := srv.Addr
+	srv.Close()
+
+	r, err := ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{addr}, ResolverOptions: ResolverOptions{Timeout: 500 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := r.Servers[0]
+	if len(r.Leaking) != 0 || st.AXFR.Allowed || st.AXFR.Refused || st.AXFR.Error == "" || st.IXFR.Error == "" {
+		t.Errorf("servers %+v, want both attempts failed without a refusal", r.Servers)
+	}
+}
+
+func TestZoneTransferCancelled(t *testing.T) {
+	srv := zoneServer(t)
+	srv.AllowTransfer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ZoneTransfer(ctx, "example.com", TransferOptions{Servers: []string{srv.Addr}}); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestZoneTransferTLS(t *testing.T) {
+	srv := zoneServer(t)
+	srv.AllowTransfer()
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(ts.Close)
+	server := "tls://" + dotServer(t, srv, ts)
+
+	r, err := ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{server}, ResolverOptions: ResolverOptions{Timeout: 2 * time.Second, TLSConfig: testTLSConfig(ts)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := r.Servers[0]
+	if !reflect.DeepEqual(r.Leaking, []string{server}) || st.AXFR.Records != 6 || !st.IXFR.Allowed {
+		t.Errorf("leaking %q, servers %+v, want the zone transferred over TLS", r.Leaking, r.Servers)
+	}
+	var nets []string
+	for _, q := range srv.Queries() {
+		nets = append(nets, q.Type+" "+q.Net)
+	}
+	if want := []string{"AXFR tcp", "SOA tcp", "IXFR tcp"}; !reflect.DeepEqual(nets, want) {
+		t.Errorf("queries %q, want %q", nets, want)
+	}
+
+	// A server that doesn't trust the certificate transfers nothing.
+	r, err = ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{server}, ResolverOptions: ResolverOptions{Timeout: 2 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := r.Servers[0]; len(r.Leaking) != 0 || !strings.Contains(st.AXFR.Error, "certificate") {
+		t.Errorf("servers %+v, want the handshake refused", r.Servers)
+	}
+
+	if _, err := ZoneTransfer(context.Background(), "example.com", TransferOptions{Servers: []string{"https://dns.example/dns-query"}}); err == nil || !strings.Contains(err.Error(), "DNS over HTTPS") {
+		t.Errorf("err = %v, want DNS over HTTPS refused", err)
+	}
+}
@@ -0,0 +1,267 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// Transports accepted by Client.Transport. TransportTLS is DNS over
+// TLS (RFC 7858) and TransportHTTPS DNS over HTTPS (RFC 8484).
+const (
+	TransportUDP   = "udp"
+	TransportTCP   = "tcp"
+	TransportTLS   = "tls"
+	TransportHTTPS = "https"
+)
+
+// DefaultTimeout is how long a Client waits for each response when
+// Client.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultRetries is the number of extra rounds over all servers a Client
+// makes when Client.Retries is zero.
+const DefaultRetries = 2
+
+// DefaultUDPSize is the EDNS0 buffer size advertised when Client.UDPSize
+// is zero; 1232 bytes avoids IP fragmentation on practically every path.
+const DefaultUDPSize = 1232
+
+// fallbackServers are used when the system resolver configuration
+// cannot be read.
+var fallbackServers = []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+// Exchanger sends one query to server (host:port) and returns the reply.
+// Client uses a wire-protocol exchanger by default; tests and alternative
+// transports can supply their own.
+type Exchanger interface {
+	Exchange(ctx context.Context, m *dnswire.Msg, server string) (*dnswire.Msg, error)
+}
+
+// Client queries DNS servers directly over the wire. The zero value
+// queries the system's resolvers over UDP, retrying truncated answers
+// over TCP.
+type Client struct {
+	// Servers to query in order, as host:port, tls://host:port for DNS
+	// over TLS or an https:// URL for DNS over HTTPS; empty means
+	// SystemServers().
+	Servers []string
+	// Transport is TransportUDP or TransportTCP for servers given as
+	// host:port; empty means UDP with TCP fallback for truncated
+	// answers. TransportTLS and TransportHTTPS are only recorded here:
+	// NewClient writes the servers with the tls:// or https:// scheme.
+	Transport string
+	// UDPSize is the EDNS0 buffer size to advertise.
+	UDPSize uint16
+	// Retries is the number of extra rounds over all servers after every
+	// server failed; negative means none.
+	Retries int
+	// Timeout is how long to wait for each response.
+	Timeout time.Duration
+	// DNSSEC sets the DO bit so signatures are returned, and the CD bit
+	// so a validating resolver passes on data it would reject.
+	DNSSEC bool
+	// TLSConfig is used for DNS over TLS and HTTPS; nil means the
+	// system roots, verifying the server's host name or IP address.
+	TLSConfig *tls.Config
+	// HTTPClient sends DNS-over-HTTPS queries; nil means one built from
+	// TLSConfig for each query.
+	HTTPClient *http.Client
+	// Exchanger overrides the transport.
+	Exchanger Exchanger
+}
+
+// Response is a reply together with the server that sent it.
+type Response struct {
+	Msg    *dnswire.Msg
+	Server string
+	RTT    time.Duration
+}
+
+// Query asks the client's servers for name/qtype, moving on to the next
+// server on a network error, SERVFAIL or REFUSED. The last reply received
+// is returned if no server gave a usable one.
+func (c *Client) Query(ctx context.Context, name string, qtype uint16) (*Response, error) {
+	return c.query(ctx, c.servers(), name, qtype)
+}
+
+// QueryServer asks a single server, with the client's retries.
+func (c *Client) QueryServer(ctx context.Context, server, name string, qtype uint16) (*Response, error) {
+	return c.query(ctx, []string{server}, name, qtype)
+}
+
+func (c *Client) query(ctx context.Context, servers []string, name string, qtype uint16) (*Response, error) {
+	m := c.newQuery(name, qtype)
+
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+
+	var last *Response
+	var lastErr error
+	for round := 0; round <= retries; round++ {
+		for _, server := range servers {
+			start := time.Now()
+			reply, err := c.exchange(ctx, m, server)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				lastErr = fmt.Errorf("%s: %w", server, err)
+				continue
+			}
+			last = &Response{Msg: reply, Server: server, RTT: time.Since(start)}
+			if reply.Rcode != dnswire.RcodeServerFailure && reply.Rcode != dnswire.RcodeRefused {
+				return last, nil
+			}
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) newQuery(name string, qtype uint16) *dnswire.Msg {
+	m := new(dnswire.Msg)
+	m.SetQuestion(dnswire.Fqdn(name), qtype)
+	m.RecursionDesired = true
+	m.CheckingDisabled = c.DNSSEC
+	size := c.UDPSize
+	if size == 0 {
+		size = DefaultUDPSize
+	}
+	m.SetEdns0(size, c.DNSSEC)
+	return m
+}
+
+func (c *Client) exchange(ctx context.Context, m *dnswire.Msg, server string) (*dnswire.Msg, error) {
+	if c.Exchanger != nil {
+		return c.Exchanger.Exchange(ctx, m, server)
+	}
+	if strings.HasPrefix(server, "https://") {
+		return c.exchangeHTTPS(ctx, m, server)
+	}
+	if addr, ok := strings.CutPrefix(server, "tls://"); ok {
+		return c.exchangeTLS(ctx, m, addr)
+	}
+
+	transport := c.Transport
+	if transport != TransportTCP {
+		transport = TransportUDP
+	}
+	wc := &dnswire.Client{Net: transport, Timeout: c.timeout(), UDPSize: m.IsEdns0().UDPSize()}
+	reply, _, err := wc.ExchangeContext(ctx, m, server)
+	if err == nil && reply.Truncated && c.Transport != TransportUDP && c.Transport != TransportTCP {
+		wc.Net = TransportTCP
+		reply, _, err = wc.ExchangeContext(ctx, m, server)
+	}
+	return reply, err
+}
+
+// serverTransport returns the transport exchange uses for server.
+func (c *Client) serverTransport(server string) string {
+	switch {
+	case strings.HasPrefix(server, "https://"):
+		return TransportHTTPS
+	case strings.HasPrefix(server, "tls://"):
+		return TransportTLS
+	case c.Transport == TransportTCP:
+		return TransportTCP
+	}
+	return TransportUDP
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+func (c *Client) servers() []string {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+	return SystemServers()
+}
+
+// SystemServers returns the resolvers configured in /etc/resolv.conf,
+// or well-known public resolvers where that file is unavailable.
+func SystemServers() []string {
+	conf, err := dnswire.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil || len(conf.Servers) == 0 {
+		return fallbackServers
+	}
+	servers := make([]string, 0, len(conf.Servers))
+	for _, s := range conf.Servers {
+		servers = append(servers, net.JoinHostPort(s, conf.Port))
+	}
+	return servers
+}
+
+// NormalizeServer turns "1.1.1.1", "2606:4700::1111", "[::1]:5353" or
+// "ns.example.com:53" into a host:port address, defaulting to port 53.
+// DNS-over-TLS servers are written tls://host[:port], defaulting to port
+// 853, and DNS-over-HTTPS servers as https:// URLs, defaulting to the
+// /dns-query path.
+func NormalizeServer(server string) (string, error) {
+	server = strings.TrimSpace(server)
+	if server == "" {
+		return "", errors.New("empty resolver address")
+	}
+	if strings.HasPrefix(server, "https://") {
+		u, err := url.Parse(server)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid DNS-over-HTTPS URL %q", server)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/dns-query"
+		}
+		return u.String(), nil
+	}
+	if rest, ok := strings.CutPrefix(server, "tls://"); ok {
+		addr, err := normalizeHostPort(strings.TrimSuffix(rest, "/"), "853")
+		if err != nil {
+			return "", err
+		}
+		return "tls://" + addr, nil
+	}
+	if strings.Contains(server, "://") {
+		return "", fmt.Errorf("unsupported resolver scheme in %q (use tls:// or https://)", server)
+	}
+	return normalizeHostPort(server, "53")
+}
+
+func normalizeHostPort(server, defaultPort string) (string, error) {
+	if server == "" {
+		return "", errors.New("empty resolver address")
+	}
+	if addr, err := netip.ParseAddr(server); err == nil {
+		return net.JoinHostPort(addr.String(), defaultPort), nil
+	}
+	if strings.HasPrefix(server, "[") && strings.HasSuffix(server, "]") {
+		return normalizeHostPort(server[1:len(server)-1], defaultPort)
+	}
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// No port given
+		return net.JoinHostPort(server, defaultPort), nil
+	}
+	if host == "" || port == "" {
+		return "", fmt.Errorf("invalid resolver address %q", server)
+	}
+	return server, nil
+}
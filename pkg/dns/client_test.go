@@ -0,0 +1,185 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+// queried returns the server and transport of each query srv received.
+func queried(srv *dnstest.Server) []string {
+	var s []string
+	for _, q := range srv.Queries() {
+		s = append(s, strings.TrimSuffix(q.Server+" "+q.Net, " "))
+	}
+	return s
+}
+
+func TestQueryRetriesAcrossResolvers(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN A 192.0.2.1")
+	srv.Drop("192.0.2.1:53")
+	srv.Fail("192.0.2.2:53", dnswire.RcodeServerFailure)
+
+	c := &Client{Servers: []string{"192.0.2.1:53", "192.0.2.2:53", "192.0.2.3:53"}, Exchanger: srv}
+	resp, err := c.Query(context.Background(), "example.com", dnswire.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Server != "192.0.2.3:53" || len(resp.Msg.Answer) != 1 {
+		t.Errorf("answer from %s: %v, want the third server's", resp.Server, resp.Msg.Answer)
+	}
+	if got, want := queried(srv), []string{"192.0.2.1:53", "192.0.2.2:53", "192.0.2.3:53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queried %q, want %q", got, want)
+	}
+}
+
+func TestQueryRetryRounds(t *testing.T) {
+	tests := []struct {
+		retries int
+		rounds  int
+	}{
+		{retries: 0, rounds: 1 + DefaultRetries},
+		{retries: -1, rounds: 1},
+		{retries: 3, rounds: 4},
+	}
+	for _, tt := range tests {
+		srv := newServer(t)
+		srv.Drop("192.0.2.1:53")
+		srv.Drop("192.0.2.2:53")
+		c := &Client{Servers: []string{"192.0.2.1:53", "192.0.2.2:53"}, Retries: tt.retries, Exchanger: srv}
+		_, err := c.Query(context.Background(), "example.com", dnswire.TypeA)
+		if err == nil || !strings.Contains(err.Error(), "192.0.2.2:53") {
+			t.Errorf("Retries %d: err = %v, want the last server's error", tt.retries, err)
+		}
+		if n := len(srv.Queries()); n != 2*tt.rounds {
+			t.Errorf("Retries %d: %d queries sent, want %d", tt.retries, n, 2*tt.rounds)
+		}
+	}
+}
+
+func TestQueryAllServFail(t *testing.T) {
+	srv := newServer(t)
+	srv.Fail("192.0.2.1:53", dnswire.RcodeServerFailure)
+	srv.Fail("192.0.2.2:53", dnswire.RcodeServerFailure)
+	c := &Client{Servers: []string{"192.0.2.1:53", "192.0.2.2:53"}, Exchanger: srv}
+
+	// A SERVFAIL from every server is an answer, not worth more rounds.
+	resp, err := c.Query(context.Background(), "example.com", dnswire.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Msg.Rcode != dnswire.RcodeServerFailure || resp.Server != "192.0.2.2:53" {
+		t.Errorf("reply %s from %s, want SERVFAIL from the last server", dnswire.RcodeToString[resp.Msg.Rcode], resp.Server)
+	}
+	if n := len(srv.Queries()); n != 2 {
+		t.Errorf("%d queries sent, want 2", n)
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	srv := newServer(t)
+	var got *dnswire.Msg
+	c := &Client{
+		Servers:   []string{"192.0.2.1:53"},
+		UDPSize:   4096,
+		DNSSEC:    true,
+		Exchanger: exchangerFunc(func(m *dnswire.Msg) { got = m }, srv),
+	}
+	if _, err := c.Query(context.Background(), "Example.COM", dnswire.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	opt := got.IsEdns0()
+	if got.Question[0].Name != "Example.COM." || !got.RecursionDesired || !got.CheckingDisabled ||
+		opt == nil || opt.UDPSize() != 4096 || !opt.Do() {
+		t.Errorf("query = %v, want RD, CD and EDNS0 with DO and a 4096 byte buffer", got)
+	}
+}
+
+// exchangerFunc passes each query to fn before srv answers it.
+func exchangerFunc(fn func(*dnswire.Msg), srv *dnstest.Server) Exchanger {
+	return exchanger{fn, srv}
+}
+
+type exchanger struct {
+	fn  func(*dnswire.Msg)
+	srv *dnstest.Server
+}
+
+func (e exchanger) Exchange(ctx context.Context, m *dnswire.Msg, server string) (*dnswire.Msg, error) {
+	e.fn(m)
+	return e.srv.Exchange(ctx, m, server)
+}
+
+func TestQueryTruncatedFallsBackToTCP(t *testing.T) {
+	srv := newServer(t)
+	for i := 0; i < 40; i++ {
+		srv.Add(fmt.Sprintf(`big.example.com. 300 IN TXT "record %02d %s"`, i, strings.Repeat("x", 60)))
+	}
+	srv.Add("small.example.com. 300 IN A 192.0.2.1")
+
+	tests := []struct {
+		transport string
+		name      string
+		qtype     uint16
+		answers   int
+		truncated bool
+		queries   []string
+	}{
+		{name: "big.example.com", qtype: dnswire.TypeTXT, answers: 40, queries: []string{srv.Addr + " udp", srv.Addr + " tcp"}},
+		{name: "small.example.com", qtype: dnswire.TypeA, answers: 1, queries: []string{srv.Addr + " udp"}},
+		{transport: TransportUDP, name: "big.example.com", qtype: dnswire.TypeTXT, truncated: true, queries: []string{srv.Addr + " udp"}},
+		{transport: TransportTCP, name: "big.example.com", qtype: dnswire.TypeTXT, answers: 40, queries: []string{srv.Addr + " tcp"}},
+	}
+	seen := 0
+	for _, tt := range tests {
+		c := &Client{Servers: []string{srv.Addr}, Transport: tt.transport, Timeout: 2 * time.Second}
+		resp, err := c.Query(context.Background(), tt.name, tt.qtype)
+		if err != nil {
+			t.Fatalf("%s over %q: %v", tt.name, tt.transport, err)
+		}
+		// A truncated answer keeps the records that fit.
+		if n := len(resp.Msg.Answer); resp.Msg.Truncated != tt.truncated || !tt.truncated && n != tt.answers || tt.truncated && n >= 40 {
+			t.Errorf("%s over %q: %d answers, truncated %v, want %d and %v",
+				tt.name, tt.transport, n, resp.Msg.Truncated, tt.answers, tt.truncated)
+		}
+		q := queried(srv)
+		if got := q[seen:]; !reflect.DeepEqual(got, tt.queries) {
+			t.Errorf("%s over %q: queried %q, want %q", tt.name, tt.transport, got, tt.queries)
+		}
+		seen = len(q)
+	}
+}
+
+func TestNormalizeServer(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":                         "1.1.1.1:53",
+		"2606:4700::1111":                 "[2606:4700::1111]:53",
+		"[::1]:5353":                      "[::1]:5353",
+		"[2001:db8::1]":                   "[2001:db8::1]:53",
+		"ns.example.com":                  "ns.example.com:53",
+		"ns.example.com:5353":             "ns.example.com:5353",
+		"tls://1.1.1.1":                   "tls://1.1.1.1:853",
+		"tls://dns.example.net:8853/":     "tls://dns.example.net:8853",
+		"https://dns.example.net":         "https://dns.example.net/dns-query",
+		"https://dns.example.net/resolve": "https://dns.example.net/resolve",
+		" https://dns.example.net:8443/ ": "https://dns.example.net:8443/dns-query",
+	}
+	for in, want := range tests {
+		got, err := NormalizeServer(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeServer(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "  ", "ftp://dns.example.net", ":53", "https://"} {
+		if got, err := NormalizeServer(in); err == nil {
+			t.Errorf("NormalizeServer(%q) = %q, want an error", in, got)
+		}
+	}
+}
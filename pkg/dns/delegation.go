@@ -0,0 +1,604 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// recursionProbe is the name asked for with recursion desired to find
+// authoritative servers that also resolve for anyone.
+const recursionProbe = "a.root-servers.net."
+
+// errNoIPv6 is the error of IPv6 addresses left unqueried.
+const errNoIPv6 = "not tested: this host has no IPv6 connectivity"
+
+// ipv6Reachable reports whether this host can reach addr over IPv6,
+// from the route a connected UDP socket gets, which sends nothing: with
+// no route, or only a link-local or unique local source address, the
+// nameserver can't be reached. It is a variable so tests can stand in
+// for the host's connectivity.
+var ipv6Reachable = func(ctx context.Context, addr netip.Addr) bool {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp6", netip.AddrPortFrom(addr, 53).String())
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	return ok && local.IP.IsGlobalUnicast() && !local.IP.IsPrivate()
+}
+
+// DelegationOptions configures a CheckDelegation.
+type DelegationOptions struct {
+	ResolverOptions
+}
+
+// DelegationResult is the health of a zone's delegation and of the
+// nameservers serving it.
+type DelegationResult struct {
+	Zone   string `json:"zone"`
+	Parent string `json:"parent"`
+	// ParentNS are the nameservers in the parent zone's referral;
+	// ChildNS those the zone's own nameservers list.
+	ParentNS    []string           `json:"parent_ns"`
+	ChildNS     []string           `json:"child_ns"`
+	Nameservers []NameserverHealth `json:"nameservers"`
+	Findings    []Finding          `json:"findings"`
+	Errors      []string           `json:"errors,omitempty"`
+}
+
+// NameserverHealth is one NS host of the zone.
+type NameserverHealth struct {
+	Host     string `json:"host"`
+	InParent bool   `json:"in_parent"`
+	InChild  bool   `json:"in_child"`
+	// Glue are the addresses the parent's referral gives for the host.
+	Glue      []string            `json:"glue,omitempty"`
+	Addresses []NameserverAddress `json:"addresses"`
+}
+
+// NameserverAddress is what one address of a nameserver answered.
+type NameserverAddress struct {
+	Address string `json:"address"`
+	// ASN, ASName, Prefix and Country describe the network announcing
+	// the address; they are empty when the lookup failed.
+	ASN     uint32 `json:"asn,omitempty"`
+	ASName  string `json:"as_name,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Country string `json:"country,omitempty"`
+	// Responded is whether the address answered at all; Authoritative
+	// whether it answered the zone's SOA with the AA bit set.
+	Responded     bool   `json:"responded"`
+	Authoritative bool   `json:"authoritative"`
+	Serial        uint32 `json:"serial,omitempty"`
+	RTTMS         int64  `json:"rtt_ms,omitempty"`
+	// OpenRecursion is set when the server resolves names outside its
+	// zones for anyone.
+	OpenRecursion bool `json:"open_recursion"`
+	// Untested is set for an IPv6 address left unqueried because this
+	// host has no IPv6 connectivity, so its silence says nothing.
+	Untested bool   `json:"untested,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CheckDelegation audits the delegation of the zone domain belongs to:
+// the parent's NS records against the zone's own, SOA serials across
+// every nameserver address, lame servers, IPv6 reachability, network
+// diversity (ASN and /24) and open recursion. Problems are graded as
+// findings; only an invalid domain or resolver setting, a parent zone
+// that cannot be found or a cancelled context is an error.
+func CheckDelegation(ctx context.Context, domain string, opts DelegationOptions) (*DelegationResult, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &delegation{ctx: ctx, c: client, asNames: map[uint32]string{}}
+	d.zone = d.findZone(domain)
+	_, d.parent, _ = strings.Cut(d.zone, ".")
+	if d.parent == "" {
+		d.parent = "."
+	}
+	parent := d.parent
+	if parent != "." {
+		parent = strings.TrimSuffix(parent, ".")
+	}
+	d.r = &DelegationResult{
+		Zone:        strings.TrimSuffix(d.zone, "."),
+		Parent:      parent,
+		ParentNS:    []string{},
+		ChildNS:     []string{},
+		Nameservers: []NameserverHealth{},
+		Findings:    []Finding{},
+	}
+
+	if err := d.referral(); err != nil {
+		return d.r, err
+	}
+	d.childNS()
+	d.probe()
+	if err := ctx.Err(); err != nil {
+		return d.r, err
+	}
+	d.grade()
+
+	sort.SliceStable(d.r.Findings, func(i, j int) bool {
+		return severityRank[d.r.Findings[i].Severity] < severityRank[d.r.Findings[j].Severity]
+	})
+	return d.r, nil
+}
+
+type delegation struct {
+	ctx context.Context
+	c   *Client
+	// zone and parent are canonical names, with the trailing dot.
+	zone   string
+	parent string
+	r      *DelegationResult
+	// hosts indexes r.Nameservers by canonical host name.
+	hosts map[string]int
+
+	mu      sync.Mutex
+	asNames map[uint32]string
+
+	// ipv6 is whether this host can query IPv6 nameservers, probed
+	// once against the first IPv6 address.
+	ipv6Once sync.Once
+	ipv6     bool
+}
+
+// reachable reports whether addr can be queried from this host.
+func (d *delegation) reachable(addr netip.Addr) bool {
+	if !addr.Is6() {
+		return true
+	}
+	d.ipv6Once.Do(func() { d.ipv6 = ipv6Reachable(d.ctx, addr) })
+	return d.ipv6
+}
+
+func (d *delegation) find(severity, check, format string, args ...interface{}) {
+	d.r.Findings = append(d.r.Findings, Finding{Severity: severity, Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *delegation) errorf(format string, args ...interface{}) {
+	d.r.Errors = append(d.r.Errors, fmt.Sprintf(format, args...))
+}
+
+// findZone returns the zone domain belongs to, from its SOA record. When
+// the resolver can't answer, as for a fully lame zone, domain itself is
+// taken to be the zone.
+func (d *delegation) findZone(domain string) string {
+	name := dnswire.CanonicalName(domain)
+	resp, err := d.c.Query(d.ctx, name, dnswire.TypeSOA)
+	if err != nil {
+		return name
+	}
+	for _, rr := range append(resp.Msg.Answer, resp.Msg.Ns...) {
+		if soa, ok := rr.(*dnswire.SOA); ok {
+			return dnswire.CanonicalName(soa.Hdr.Name)
+		}
+	}
+	return name
+}
+
+// host returns the entry for an NS host, adding it when new.
+func (d *delegation) host(name string) *NameserverHealth {
+	name = dnswire.CanonicalName(name)
+	if d.hosts == nil {
+		d.hosts = map[string]int{}
+	}
+	i, ok := d.hosts[name]
+	if !ok {
+		i = len(d.r.Nameservers)
+		d.hosts[name] = i
+		d.r.Nameservers = append(d.r.Nameservers, NameserverHealth{Host: strings.TrimSuffix(name, "."), Addresses: []NameserverAddress{}})
+	}
+	return &d.r.Nameservers[i]
+}
+
+// referral asks the parent zone's nameservers for the zone's NS records
+// and glue.
+func (d *delegation) referral() error {
+	_, servers, err := AuthoritativeServers(d.ctx, d.c, d.parent)
+	if err != nil {
+		return fmt.Errorf("finding the nameservers of parent zone %s: %w", d.r.Parent, err)
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		resp, err := d.c.QueryServer(d.ctx, server, d.zone, dnswire.TypeNS)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Msg.Rcode == dnswire.RcodeNameError {
+			d.find(SeverityHigh, "delegation", "%s does not exist in the parent zone %s", d.r.Zone, d.r.Parent)
+			return nil
+		}
+		for _, ns := range nsOwnedBy(d.zone, append(resp.Msg.Answer, resp.Msg.Ns...)) {
+			h := d.host(ns)
+			if !h.InParent {
+				h.InParent = true
+				d.r.ParentNS = append(d.r.ParentNS, h.Host)
+			}
+		}
+		for _, rr := range resp.Msg.Extra {
+			var addr netip.Addr
+			switch v := rr.(type) {
+			case *dnswire.A:
+				addr, _ = netip.AddrFromSlice(v.A.To4())
+			case *dnswire.AAAA:
+				addr, _ = netip.AddrFromSlice(v.AAAA)
+			default:
+				continue
+			}
+			if i, ok := d.hosts[dnswire.CanonicalName(rr.Header().Name)]; ok && addr.IsValid() {
+				h := &d.r.Nameservers[i]
+				h.Glue = appendUnique(h.Glue, addr.String())
+			}
+		}
+		if len(d.r.ParentNS) == 0 {
+			d.find(SeverityHigh, "delegation", "the parent zone %s has no NS records for %s", d.r.Parent, d.r.Zone)
+		}
+		return nil
+	}
+	d.errorf("NS %s at parent %s: %v", d.r.Zone, d.r.Parent, lastErr)
+	return nil
+}
+
+// childNS resolves the parent's nameservers and asks each address for
+// the zone's NS records. Without a referral the resolver is asked.
+func (d *delegation) childNS() {
+	if len(d.r.ParentNS) == 0 {
+		resp, err := d.c.Query(d.ctx, d.zone, dnswire.TypeNS)
+		if err != nil {
+			d.errorf("NS %s: %v", d.r.Zone, err)
+			return
+		}
+		for _, ns := range nsOwnedBy(d.zone, resp.Msg.Answer) {
+			d.host(ns)
+		}
+	}
+	d.resolve()
+
+	type answer struct {
+		hosts []string
+		err   error
+	}
+	var servers []string
+	for _, h := range d.r.Nameservers {
+		for _, a := range h.Addresses {
+			servers = append(servers, a.Address)
+		}
+	}
+	answers := make([]answer, len(servers))
+	forEach(d.ctx, len(servers), func(i int) {
+		addr := netip.MustParseAddr(servers[i])
+		if !d.reachable(addr) {
+			return
+		}
+		resp, err := d.c.QueryServer(d.ctx, netip.AddrPortFrom(addr, 53).String(), d.zone, dnswire.TypeNS)
+		if err != nil {
+			answers[i].err = err
+			return
+		}
+		if resp.Msg.Authoritative {
+			answers[i].hosts = nsOwnedBy(d.zone, resp.Msg.Answer)
+		}
+	})
+	for _, a := range answers {
+		for _, ns := range a.hosts {
+			h := d.host(ns)
+			if !h.InChild {
+				h.InChild = true
+				d.r.ChildNS = append(d.r.ChildNS, h.Host)
+			}
+		}
+	}
+	// Hosts only the child lists still need their addresses
+	d.resolve()
+}
+
+// resolve looks up the addresses of the hosts that have none yet,
+// falling back to the glue.
+func (d *delegation) resolve() {
+	var todo []int
+	for i, h := range d.r.Nameservers {
+		if len(h.Addresses) == 0 {
+			todo = append(todo, i)
+		}
+	}
+	addrs := make([][]netip.Addr, len(todo))
+	forEach(d.ctx, len(todo), func(i int) {
+		addrs[i] = resolveHost(d.ctx, d.c, d.r.Nameservers[todo[i]].Host)
+	})
+	for i, idx := range todo {
+		h := &d.r.Nameservers[idx]
+		var list []string
+		for _, addr := range addrs[i] {
+			list = appendUnique(list, addr.String())
+		}
+		if len(list) == 0 {
+			list = h.Glue
+		}
+		for _, a := range list {
+			h.Addresses = append(h.Addresses, NameserverAddress{Address: a})
+		}
+	}
+}
+
+// probe asks every nameserver address for the zone's SOA, tests it for
+// open recursion and looks up the network announcing it. IPv6 addresses
+// are left untested when this host has no IPv6 connectivity.
+func (d *delegation) probe() {
+	var addrs []*NameserverAddress
+	for i := range d.r.Nameservers {
+		for j := range d.r.Nameservers[i].Addresses {
+			addrs = append(addrs, &d.r.Nameservers[i].Addresses[j])
+		}
+	}
+	forEach(d.ctx, len(addrs), func(i int) {
+		a := addrs[i]
+		addr := netip.MustParseAddr(a.Address)
+		server := netip.AddrPortFrom(addr, 53).String()
+		if !d.reachable(addr) {
+			a.Untested = true
+			a.Error = errNoIPv6
+			d.originAS(a)
+			return
+		}
+		resp, err := d.c.QueryServer(d.ctx, server, d.zone, dnswire.TypeSOA)
+		if err != nil {
+			a.Error = err.Error()
+		} else {
+			a.Responded = true
+			a.RTTMS = resp.RTT.Milliseconds()
+			msg := resp.Msg
+			for _, rr := range msg.Answer {
+				if soa, ok := rr.(*dnswire.SOA); ok && strings.EqualFold(soa.Hdr.Name, d.zone) {
+					a.Serial = soa.Serial
+				}
+			}
+			switch {
+			case msg.Rcode != dnswire.RcodeSuccess:
+				a.Error = dnswire.RcodeToString[msg.Rcode]
+			case !msg.Authoritative || a.Serial == 0:
+				a.Error = "not authoritative for the zone"
+			default:
+				a.Authoritative = true
+			}
+
+			if resp, err := d.c.QueryServer(d.ctx, server, recursionProbe, dnswire.TypeA); err == nil {
+				m := resp.Msg
+				a.OpenRecursion = m.RecursionAvailable && !m.Authoritative && m.Rcode == dnswire.RcodeSuccess && len(m.Answer) > 0
+			}
+		}
+		d.originAS(a)
+	})
+}
+
+// originAS fills in the network of a from Team Cymru's IP-to-ASN DNS
+// service.
+func (d *delegation) originAS(a *NameserverAddress) {
+	addr := netip.MustParseAddr(a.Address)
+	rev, err := dnswire.ReverseAddr(addr.String())
+	if err != nil {
+		return
+	}
+	name := strings.TrimSuffix(rev, "in-addr.arpa.") + "origin.asn.cymru.com."
+	if addr.Is6() {
+		name = strings.TrimSuffix(rev, "ip6.arpa.") + "origin6.asn.cymru.com."
+	}
+	// "15169 | 8.8.8.0/24 | US | arin | 2014-03-14"
+	fields := d.cymru(name)
+	if len(fields) < 3 {
+		return
+	}
+	asns := strings.Fields(fields[0])
+	if len(asns) == 0 {
+		return
+	}
+	asn, err := strconv.ParseUint(asns[0], 10, 32)
+	if err != nil {
+		return
+	}
+	a.ASN, a.Prefix, a.Country = uint32(asn), fields[1], fields[2]
+
+	d.mu.Lock()
+	asName, ok := d.asNames[a.ASN]
+	d.mu.Unlock()
+	if !ok {
+		// "15169 | US | arin | 2000-03-30 | GOOGLE - Google LLC, US"
+		if fields := d.cymru(fmt.Sprintf("AS%d.asn.cymru.com.", a.ASN)); len(fields) >= 5 {
+			asName = fields[4]
+		}
+		d.mu.Lock()
+		d.asNames[a.ASN] = asName
+		d.mu.Unlock()
+	}
+	a.ASName = asName
+}
+
+// cymru returns the "|"-separated fields of the TXT record at name.
+func (d *delegation) cymru(name string) []string {
+	resp, err := d.c.Query(d.ctx, name, dnswire.TypeTXT)
+	if err != nil {
+		return nil
+	}
+	for _, rr := range resp.Msg.Answer {
+		if txt, ok := rr.(*dnswire.TXT); ok {
+			fields := strings.Split(strings.Join(txt.Txt, ""), "|")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+			return fields
+		}
+	}
+	return nil
+}
+
+// grade turns what was collected into findings.
+func (d *delegation) grade() {
+	r := d.r
+	if len(r.Nameservers) == 0 {
+		d.find(SeverityHigh, "delegation", "no nameservers found for %s", r.Zone)
+		return
+	}
+	if len(r.Nameservers) < 2 {
+		d.find(SeverityHigh, "diversity", "%s has a single nameserver; RFC 2182 asks for at least two", r.Zone)
+	}
+
+	if len(r.ParentNS) > 0 && len(r.ChildNS) > 0 {
+		for _, h := range r.Nameservers {
+			switch {
+			case h.InParent && !h.InChild:
+				d.find(SeverityMedium, "delegation", "%s is in the parent's NS set but not in the zone's own", h.Host)
+			case h.InChild && !h.InParent:
+				d.find(SeverityMedium, "delegation", "%s is in the zone's NS set but not delegated to by the parent", h.Host)
+			}
+		}
+	}
+
+	serials := map[uint32][]string{}
+	var v4, v6 []NameserverAddress
+	var asns []string
+	untested := false
+	for _, h := range r.Nameservers {
+		if len(h.Addresses) == 0 {
+			d.find(SeverityHigh, "lame", "%s has no addresses", h.Host)
+			continue
+		}
+		served := false
+		v4ok, v6ok := false, false
+		for _, a := range h.Addresses {
+			addr := netip.MustParseAddr(a.Address)
+			switch {
+			case a.Authoritative:
+				served = true
+				serials[a.Serial] = append(serials[a.Serial], a.Address)
+			case a.Responded:
+				d.find(SeverityHigh, "lame", "%s (%s) answered %s for %s", h.Host, a.Address, a.Error, r.Zone)
+			}
+			if addr.Is4() {
+				v4 = append(v4, a)
+				v4ok = v4ok || a.Responded
+			} else {
+				v6 = append(v6, a)
+				v6ok = v6ok || a.Responded
+			}
+			if a.ASN != 0 {
+				asns = append(asns, strconv.FormatUint(uint64(a.ASN), 10))
+			}
+			if a.OpenRecursion {
+				d.find(SeverityHigh, "recursion", "%s (%s) resolves queries for anyone (open recursion)", h.Host, a.Address)
+			}
+		}
+		n := countUntested(h.Addresses)
+		untested = untested || n > 0
+		if !served && !anyResponded(h.Addresses) {
+			switch {
+			case n == len(h.Addresses):
+				d.find(SeverityInfo, "ipv6", "%s is untested: it has only IPv6 addresses and this host has no IPv6 connectivity", h.Host)
+			case n > 0:
+				d.find(SeverityHigh, "lame", "%s did not respond on any IPv4 address (its IPv6 addresses are untested)", h.Host)
+			default:
+				d.find(SeverityHigh, "lame", "%s did not respond on any address", h.Host)
+			}
+		}
+		if v4ok && !v6ok && n == 0 && hasIPv6(h.Addresses) {
+			d.find(SeverityMedium, "ipv6", "%s has an IPv6 address but did not respond over IPv6", h.Host)
+		}
+		for _, glue := range h.Glue {
+			if !hasAddress(h.Addresses, glue) {
+				d.find(SeverityLow, "delegation", "glue %s for %s in the parent does not match the host's address records", glue, h.Host)
+			}
+		}
+	}
+
+	if len(serials) > 1 {
+		var parts []string
+		for serial, addrs := range serials {
+			parts = append(parts, fmt.Sprintf("%d at %s", serial, strings.Join(addrs, ", ")))
+		}
+		sort.Strings(parts)
+		d.find(SeverityMedium, "soa", "nameservers disagree on the SOA serial: %s", strings.Join(parts, "; "))
+	}
+
+	if len(v6) == 0 {
+		d.find(SeverityLow, "ipv6", "no nameserver has an IPv6 address; IPv6-only resolvers cannot reach the zone")
+	} else if untested {
+		d.find(SeverityInfo, "ipv6", "IPv6 reachability of the nameservers is untested: this host has no IPv6 connectivity")
+	}
+
+	if len(r.Nameservers) > 1 {
+		if len(asns) > 1 && allSame(asns) {
+			d.find(SeverityMedium, "diversity", "every nameserver address is in AS%s; one network outage takes the zone down", asns[0])
+		}
+		var nets []string
+		for _, a := range v4 {
+			if p, err := netip.MustParseAddr(a.Address).Prefix(24); err == nil {
+				nets = append(nets, p.String())
+			}
+		}
+		if len(nets) > 1 && allSame(nets) {
+			d.find(SeverityMedium, "diversity", "every IPv4 nameserver address is in %s", nets[0])
+		}
+	}
+}
+
+func anyResponded(addrs []NameserverAddress) bool {
+	for _, a := range addrs {
+		if a.Responded {
+			return true
+		}
+	}
+	return false
+}
+
+func countUntested(addrs []NameserverAddress) int {
+	n := 0
+	for _, a := range addrs {
+		if a.Untested {
+			n++
+		}
+	}
+	return n
+}
+
+func hasIPv6(addrs []NameserverAddress) bool {
+	for _, a := range addrs {
+		if netip.MustParseAddr(a.Address).Is6() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAddress(addrs []NameserverAddress, addr string) bool {
+	for _, a := range addrs {
+		if a.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func allSame(list []string) bool {
+	for _, v := range list[1:] {
+		if v != list[0] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+// delegationServer serves com. and example.com., whose nameservers are
+// ns1.example.com, on IPv4 and IPv6, and the IPv6-only ns2.example.net.
+func delegationServer(t *testing.T) *dnstest.Server {
+	t.Helper()
+	srv := newServer(t)
+	srv.Add(
+		"com. 900 IN SOA a.gtld-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400",
+		"com. 172800 IN NS a.gtld-servers.net.",
+		"a.gtld-servers.net. 172800 IN A 192.0.2.100",
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300",
+		"example.com. 86400 IN NS ns1.example.com.",
+		"example.com. 86400 IN NS ns2.example.net.",
+		"ns1.example.com. 3600 IN A 192.0.2.1",
+		"ns1.example.com. 3600 IN AAAA 2001:db8::1",
+		"ns2.example.net. 3600 IN AAAA 2001:db8::2",
+	)
+	return srv
+}
+
+// withIPv6 makes the delegation checks take this host as having IPv6
+// connectivity or not.
+func withIPv6(t *testing.T, reachable bool) {
+	t.Helper()
+	saved := ipv6Reachable
+	ipv6Reachable = func(context.Context, netip.Addr) bool { return reachable }
+	t.Cleanup(func() { ipv6Reachable = saved })
+}
+
+// delegationFindings returns r's findings for check as "severity: message".
+func delegationFindings(r *DelegationResult, check string) []string {
+	var msgs []string
+	for _, f := range r.Findings {
+		if f.Check == check {
+			msgs = append(msgs, f.Severity+": "+f.Message)
+		}
+	}
+	return msgs
+}
+
+func TestCheckDelegationIPv6(t *testing.T) {
+	tests := []struct {
+		name      string
+		reachable bool
+		drop      []string
+		untested  bool
+		ipv6      []string
+		lame      []string
+	}{
+		{name: "reachable", reachable: true},
+		{
+			name: "no local IPv6", untested: true,
+			ipv6: []string{
+				"info: ns2.example.net is untested: it has only IPv6 addresses and this host has no IPv6 connectivity",
+				"info: IPv6 reachability of the nameservers is untested: this host has no IPv6 connectivity",
+			},
+		},
+		{
+			name: "silent over IPv6", reachable: true, drop: []string{"[2001:db8::1]:53", "[2001:db8::2]:53"},
+			ipv6: []string{"medium: ns1.example.com has an IPv6 address but did not respond over IPv6"},
+			lame: []string{"high: ns2.example.net did not respond on any address"},
+		},
+	}
+	for _, tt := range tests {
+		withIPv6(t, tt.reachable)
+		srv := delegationServer(t)
+		for _, server := range tt.drop {
+			srv.Drop(server)
+		}
+		r, err := CheckDelegation(context.Background(), "example.com", DelegationOptions{ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Retries: -1, Exchanger: srv}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Zone != "example.com" || r.Parent != "com" || len(r.Nameservers) != 2 {
+			t.Fatalf("%s: zone %s under %s with %+v", tt.name, r.Zone, r.Parent, r.Nameservers)
+		}
+		for _, h := range r.Nameservers {
+			for _, a := range h.Addresses {
+				v6 := strings.Contains(a.Address, ":")
+				if a.Untested != (v6 && tt.untested) {
+					t.Errorf("%s: %s %s untested %v", tt.name, h.Host, a.Address, a.Untested)
+				}
+				if a.Untested && (a.Responded || a.Error != errNoIPv6) {
+					t.Errorf("%s: untested %s: %+v", tt.name, a.Address, a)
+				}
+				if !v6 && !a.Authoritative {
+					t.Errorf("%s: %s %s not authoritative: %s", tt.name, h.Host, a.Address, a.Error)
+				}
+			}
+		}
+		if got := delegationFindings(r, "ipv6"); strings.Join(got, "\n") != strings.Join(tt.ipv6, "\n") {
+			t.Errorf("%s: ipv6 findings %q, want %q", tt.name, got, tt.ipv6)
+		}
+		if got := delegationFindings(r, "lame"); strings.Join(got, "\n") != strings.Join(tt.lame, "\n") {
+			t.Errorf("%s: lame findings %q, want %q", tt.name, got, tt.lame)
+		}
+		if tt.untested {
+			for _, q := range srv.Queries() {
+				if strings.HasPrefix(q.Server, "[") {
+					t.Errorf("%s: queried %s over IPv6", tt.name, q.Server)
+				}
+			}
+		}
+	}
+}
+
+func TestIPv6ReachableLoopback(t *testing.T) {
+	// Loopback is routed, but no nameserver is reached from it.
+	if ipv6Reachable(context.Background(), netip.MustParseAddr("::1")) {
+		t.Error("IPv6 reachable from the loopback address")
+	}
+}
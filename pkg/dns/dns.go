@@ -0,0 +1,541 @@
+// Package dns performs DNS reconnaissance for a domain: address, mail,
+// nameserver, service, security and other records, queried over the
+// wire from a chosen resolver or the domain's authoritative nameservers.
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// ResolverOptions are the resolver settings shared by the lookups of
+// this package, each of which builds its Client from them.
+type ResolverOptions struct {
+	// Timeout is how long to wait for each response. Zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+	// Resolvers to query, as host or host:port; empty means the system's.
+	Resolvers []string
+	// Retries is the number of extra rounds over all resolvers; zero
+	// means DefaultRetries, negative means none.
+	Retries int
+	// TLSConfig is used for DNS over TLS and HTTPS; nil means the
+	// system roots.
+	TLSConfig *tls.Config
+	// Exchanger overrides the transport, e.g. with an in-memory server.
+	Exchanger Exchanger
+}
+
+// Options configures a Lookup.
+type Options struct {
+	ResolverOptions
+	// Transport is TransportUDP, TransportTCP, TransportTLS or
+	// TransportHTTPS; empty means UDP with TCP fallback for truncated
+	// answers. Resolvers written as tls:// or https:// addresses use
+	// that transport whatever this says.
+	Transport string
+	// UDPSize is the EDNS0 buffer size to advertise; zero means
+	// DefaultUDPSize.
+	UDPSize uint16
+	// Authoritative sends the queries to the domain's own nameservers
+	// instead of a recursive resolver.
+	Authoritative bool
+	// Types selects the record types to query (see AllTypes, "ALL"
+	// for every one); empty means DefaultTypes, or PTR for an IP.
+	Types []string
+	// Compare queries every resolver (or authoritative server) and
+	// reports the questions they disagree on.
+	Compare bool
+	// DNSSEC validates the chain of trust from the root down to the
+	// domain (see ValidateDNSSEC).
+	DNSSEC bool
+	// TrustAnchors replaces RootTrustAnchors as the DS records validation
+	// starts from.
+	TrustAnchors []string
+	// Tamper asks every question over plain DNS and over encrypted DNS
+	// and reports answers that look altered in transit (see
+	// TamperResult).
+	Tamper bool
+	// EncryptedResolvers are the tls:// or https:// resolvers Tamper
+	// compares against; empty means the encrypted Resolvers, or
+	// DefaultEncryptedResolvers.
+	EncryptedResolvers []string
+}
+
+// Result holds the records found for a domain. Record types that could
+// not be resolved are left out and the failure is listed in Errors.
+type Result struct {
+	Domain string `json:"domain"`
+	// Types are the record types that were queried.
+	Types   []string `json:"types"`
+	Records []Record `json:"records"`
+	// NXDomain is set when the domain (or, for an IP, its reverse name)
+	// does not exist. Enumerated SRV and TLSA names not existing is
+	// normal and does not set it.
+	NXDomain bool `json:"nxdomain,omitempty"`
+
+	// Resolvers are the servers queried, in order of preference.
+	Resolvers []string `json:"resolvers"`
+	// Transport is how they were queried, e.g. "udp", "https", or
+	// "udp+https" for a mix.
+	Transport string `json:"transport"`
+	// Zone is set in authoritative mode to the zone whose nameservers
+	// answered.
+	Zone          string        `json:"zone,omitempty"`
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+	// DNSSEC is the chain-of-trust validation, when requested.
+	DNSSEC *DNSSECResult `json:"dnssec,omitempty"`
+	// Tampering compares plain and encrypted answers, when requested.
+	Tampering *TamperResult `json:"tampering,omitempty"`
+	Errors    []string      `json:"errors,omitempty"`
+}
+
+// Record is one resource record of an answer. Data is the record data
+// in zone file presentation format, except that TXT strings are joined
+// without quotes.
+type Record struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl"`
+	Data string `json:"data"`
+}
+
+// Discrepancy is a question for which the compared servers returned
+// different data.
+type Discrepancy struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Answers []ServerAnswer `json:"answers"`
+}
+
+// ServerAnswer is the record data one server returned for a question.
+type ServerAnswer struct {
+	Server  string   `json:"server"`
+	Records []string `json:"records"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// queryConcurrency bounds the questions in flight during a Lookup.
+const queryConcurrency = 8
+
+// Lookup queries the selected record types of domain, or the PTR record
+// when domain is an IP address. Individual questions failing is not an
+// error; only an invalid domain or type, an unusable resolver setting
+// or a cancelled context is.
+func Lookup(ctx context.Context, domain string, opts Options) (*Result, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+
+	types, err := ParseTypes(opts.Types)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		types = DefaultTypes
+		if _, err := netip.ParseAddr(domain); err == nil {
+			types = []string{"PTR"}
+		}
+	}
+	queries, err := buildQueries(domain, types)
+	if err != nil {
+		return nil, err
+	}
+	// owner is the name the domain itself is asked under.
+	owner := dnswire.Fqdn(domain)
+	if len(queries) == 1 && queries[0].qtype == dnswire.TypePTR {
+		owner = queries[0].name
+	}
+
+	client, err := NewClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{Domain: domain, Types: types, Records: []Record{}}
+
+	if opts.DNSSEC {
+		// Validation walks down from the root, so it needs a recursive
+		// resolver even in authoritative mode.
+		r.DNSSEC, err = ValidateDNSSEC(ctx, client, owner, opts.TrustAnchors, time.Now())
+		if err != nil {
+			return r, err
+		}
+	}
+
+	if opts.Authoritative {
+		zone, servers, err := AuthoritativeServers(ctx, client, domain)
+		if err != nil {
+			return nil, fmt.Errorf("finding authoritative nameservers: %w", err)
+		}
+		r.Zone = zone
+		client.Servers = servers
+	}
+	r.Resolvers = client.servers()
+	var transports []string
+	for _, server := range r.Resolvers {
+		transports = appendUnique(transports, client.serverTransport(server))
+	}
+	r.Transport = strings.Join(transports, "+")
+
+	replies := make([]*Response, len(queries))
+	errs := make([]error, len(queries))
+	forEach(ctx, len(queries), func(i int) {
+		replies[i], errs[i] = client.Query(ctx, queries[i].name, queries[i].qtype)
+	})
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	for i, q := range queries {
+		typeName := dnswire.TypeToString[q.qtype]
+		if errs[i] != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %v", typeName, q.name, errs[i]))
+			continue
+		}
+		msg := replies[i].Msg
+		if msg.Rcode == dnswire.RcodeNameError {
+			// After a CNAME the rcode is about the last name of the
+			// chain (RFC 6604), not the one asked for.
+			if q.name == owner && len(answerRecords(msg, dnswire.TypeCNAME)) == 0 {
+				r.NXDomain = true
+			}
+			continue
+		}
+		if msg.Rcode != dnswire.RcodeSuccess {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %s", typeName, q.name, dnswire.RcodeToString[msg.Rcode]))
+			continue
+		}
+		r.Records = append(r.Records, answerRecords(msg, q.qtype)...)
+	}
+
+	if opts.Compare {
+		r.Discrepancies, err = compare(ctx, client, queries, r.Resolvers)
+		if err != nil {
+			return r, err
+		}
+	}
+	if opts.Tamper {
+		r.Tampering, err = detectTampering(ctx, client, queries, opts.EncryptedResolvers)
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// RecordsOf returns the records of the given type, e.g. "MX".
+func (r *Result) RecordsOf(typ string) []Record {
+	var records []Record
+	for _, rec := range r.Records {
+		if rec.Type == typ {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// forEach calls fn for 0..n-1 on up to queryConcurrency goroutines.
+func forEach(ctx context.Context, n int, fn func(i int)) {
+	forEachN(ctx, n, queryConcurrency, fn)
+}
+
+// forEachN calls fn for 0..n-1 on up to workers goroutines.
+func forEachN(ctx context.Context, n, workers int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	if workers > n {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// answerRecords returns the answers of type qtype, leaving out the
+// CNAME chain that led to them. MX and SRV records are ordered by
+// preference and priority.
+func answerRecords(msg *dnswire.Msg, qtype uint16) []Record {
+	var rrs []dnswire.RR
+	for _, rr := range msg.Answer {
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	sort.SliceStable(rrs, func(i, j int) bool {
+		switch a := rrs[i].(type) {
+		case *dnswire.MX:
+			return a.Preference < rrs[j].(*dnswire.MX).Preference
+		case *dnswire.SRV:
+			b := rrs[j].(*dnswire.SRV)
+			if a.Priority != b.Priority {
+				return a.Priority < b.Priority
+			}
+			return a.Weight > b.Weight
+		}
+		return false
+	})
+
+	records := make([]Record, 0, len(rrs))
+	for _, rr := range rrs {
+		h := rr.Header()
+		records = append(records, Record{
+			Name: h.Name,
+			Type: dnswire.TypeToString[h.Rrtype],
+			TTL:  h.Ttl,
+			Data: rdata(rr),
+		})
+	}
+	return records
+}
+
+// NewClient builds the Client a Lookup with opts would use. With
+// TransportTLS or TransportHTTPS, resolvers given as a plain host are
+// written as tls:// or https:// addresses; DNS over TLS without
+// resolvers uses the system's on port 853.
+func NewClient(opts Options) (*Client, error) {
+	c := &Client{
+		Transport: opts.Transport,
+		UDPSize:   opts.UDPSize,
+		Retries:   opts.Retries,
+		Timeout:   opts.Timeout,
+		TLSConfig: opts.TLSConfig,
+		Exchanger: opts.Exchanger,
+	}
+	c.HTTPClient = newHTTPClient(c.TLSConfig)
+
+	resolvers := opts.Resolvers
+	switch c.Transport {
+	case "", TransportUDP, TransportTCP:
+	case TransportTLS:
+		if len(resolvers) == 0 {
+			for _, s := range SystemServers() {
+				host, _, _ := net.SplitHostPort(s)
+				resolvers = append(resolvers, host)
+			}
+		}
+	case TransportHTTPS:
+		if len(resolvers) == 0 {
+			return nil, fmt.Errorf("DNS over HTTPS needs a resolver URL, e.g. https://cloudflare-dns.com/dns-query")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transport %q (use udp, tcp, tls or https)", c.Transport)
+	}
+	for _, s := range resolvers {
+		if (c.Transport == TransportTLS || c.Transport == TransportHTTPS) && !strings.Contains(s, "://") {
+			if addr, err := netip.ParseAddr(s); err == nil && addr.Is6() {
+				s = "[" + s + "]"
+			}
+			s = c.Transport + "://" + s
+		}
+		server, err := NormalizeServer(s)
+		if err != nil {
+			return nil, err
+		}
+		c.Servers = append(c.Servers, server)
+	}
+	return c, nil
+}
+
+// AuthoritativeServers finds the zone domain belongs to and returns it
+// with the addresses (host:53) of the zone's nameservers.
+func AuthoritativeServers(ctx context.Context, c *Client, domain string) (string, []string, error) {
+	zone, nameservers, err := authoritativeNameservers(ctx, c, domain)
+	if err != nil {
+		return "", nil, err
+	}
+	var servers []string
+	seen := map[string]bool{}
+	for _, ns := range nameservers {
+		for _, server := range ns.servers {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	if len(servers) == 0 {
+		return "", nil, fmt.Errorf("no reachable nameserver addresses for zone %s", zone)
+	}
+	return zone, servers, nil
+}
+
+// nameserver is an NS host with its addresses as host:53.
+type nameserver struct {
+	host    string
+	servers []string
+}
+
+// authoritativeNameservers returns the zone domain belongs to, from its
+// SOA record, and the zone's NS hosts with their addresses.
+func authoritativeNameservers(ctx context.Context, c *Client, domain string) (string, []nameserver, error) {
+	resp, err := c.Query(ctx, dnswire.Fqdn(domain), dnswire.TypeSOA)
+	if err != nil {
+		return "", nil, err
+	}
+	zone := ""
+	for _, rr := range append(resp.Msg.Answer, resp.Msg.Ns...) {
+		if soa, ok := rr.(*dnswire.SOA); ok {
+			zone = soa.Hdr.Name
+			break
+		}
+	}
+	if zone == "" {
+		return "", nil, fmt.Errorf("no SOA record found for %s", domain)
+	}
+
+	resp, err = c.Query(ctx, zone, dnswire.TypeNS)
+	if err != nil {
+		return "", nil, err
+	}
+	var nameservers []nameserver
+	for _, rr := range resp.Msg.Answer {
+		ns, ok := rr.(*dnswire.NS)
+		if !ok {
+			continue
+		}
+		n := nameserver{host: ns.Ns}
+		for _, addr := range resolveHost(ctx, c, ns.Ns) {
+			n.servers = append(n.servers, netip.AddrPortFrom(addr, 53).String())
+		}
+		nameservers = append(nameservers, n)
+	}
+	if len(nameservers) == 0 {
+		return "", nil, fmt.Errorf("no NS records found for zone %s", zone)
+	}
+	return zone, nameservers, nil
+}
+
+// resolveHost returns the IPv4 and IPv6 addresses of host.
+func resolveHost(ctx context.Context, c *Client, host string) []netip.Addr {
+	var addrs []netip.Addr
+	for _, qtype := range []uint16{dnswire.TypeA, dnswire.TypeAAAA} {
+		resp, err := c.Query(ctx, host, qtype)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Msg.Answer {
+			var ip net.IP
+			switch v := rr.(type) {
+			case *dnswire.A:
+				ip = v.A
+			case *dnswire.AAAA:
+				ip = v.AAAA
+			}
+			if addr, ok := netip.AddrFromSlice(ip); ok {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+	return addrs
+}
+
+// compare asks every server each question and returns those whose
+// answers differ. TTLs are ignored.
+func compare(ctx context.Context, c *Client, queries []query, servers []string) ([]Discrepancy, error) {
+	if len(servers) < 2 {
+		return nil, nil
+	}
+	answers := make([][]ServerAnswer, len(queries))
+	forEach(ctx, len(queries), func(i int) {
+		q := queries[i]
+		for _, server := range servers {
+			a := ServerAnswer{Server: server, Records: []string{}}
+			resp, err := c.QueryServer(ctx, server, q.name, q.qtype)
+			switch {
+			case err != nil:
+				a.Error = err.Error()
+			case resp.Msg.Rcode != dnswire.RcodeSuccess:
+				a.Error = dnswire.RcodeToString[resp.Msg.Rcode]
+			default:
+				for _, rec := range answerRecords(resp.Msg, q.qtype) {
+					a.Records = append(a.Records, rec.Data)
+				}
+				sort.Strings(a.Records)
+			}
+			answers[i] = append(answers[i], a)
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []Discrepancy
+	for i, q := range queries {
+		if !sameAnswers(answers[i]) {
+			result = append(result, Discrepancy{Name: q.name, Type: dnswire.TypeToString[q.qtype], Answers: answers[i]})
+		}
+	}
+	return result, nil
+}
+
+func sameAnswers(answers []ServerAnswer) bool {
+	for _, a := range answers[1:] {
+		if a.Error != answers[0].Error || strings.Join(a.Records, "\n") != strings.Join(answers[0].Records, "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// rdata returns the presentation form of rr without its owner, TTL,
+// class and type.
+func rdata(rr dnswire.RR) string {
+	if txt, ok := rr.(*dnswire.TXT); ok {
+		return strings.Join(txt.Txt, "")
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// ValidateDomain performs basic sanity checks on a domain name.
+func ValidateDomain(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+	if len(domain) > 253 {
+		return fmt.Errorf("domain name too long")
+	}
+	return nil
+}
+
+// FilterIPv4 returns the IPv4 addresses in ips.
+func FilterIPv4(ips []string) []string {
+	var result []string
+	for _, ip := range ips {
+		if net.ParseIP(ip).To4() != nil {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
+
+// FilterIPv6 returns the IPv6 addresses in ips.
+func FilterIPv6(ips []string) []string {
+	var result []string
+	for _, ip := range ips {
+		if net.ParseIP(ip).To4() == nil && net.ParseIP(ip) != nil {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
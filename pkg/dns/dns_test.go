@@ -0,0 +1,219 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	dnswire "github.com/miekg/dns"
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+func newServer(t *testing.T) *dnstest.Server {
+	t.Helper()
+	srv, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// testResolver is the resolver in-memory lookups ask.
+const testResolver = "192.0.2.53:53"
+
+func TestLookupRecordTypes(t *testing.T) {
+	srv := newServer(t)
+	srv.Add(
+		"example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN A 192.0.2.2",
+		"example.com. 300 IN AAAA 2001:db8::1",
+		"www.example.com. 300 IN CNAME example.com.",
+		"example.com. 300 IN MX 20 mx2.example.com.",
+		"example.com. 300 IN MX 10 mx1.example.com.",
+		"example.com. 86400 IN NS ns1.example.com.",
+		`example.com. 300 IN TXT "v=spf1 -all"`,
+		`example.com. 300 IN TXT "part one " "part two"`,
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300",
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+		"_sip._tcp.example.com. 300 IN SRV 20 0 5060 sip2.example.com.",
+		"_sip._tcp.example.com. 300 IN SRV 10 5 5060 sip1.example.com.",
+		"_443._tcp.example.com. 300 IN TLSA 3 1 1 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"example.com. 300 IN HTTPS 1 . alpn=h2",
+		`example.com. 300 IN NAPTR 100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`,
+		"example.com. 300 IN DS 12345 13 2 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	)
+
+	r, err := Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"ALL"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NXDomain || len(r.Errors) != 0 {
+		t.Errorf("NXDomain = %v, Errors = %q", r.NXDomain, r.Errors)
+	}
+	if !reflect.DeepEqual(r.Resolvers, []string{testResolver}) || r.Transport != TransportUDP {
+		t.Errorf("Resolvers = %q over %s", r.Resolvers, r.Transport)
+	}
+
+	tests := []struct {
+		typ  string
+		data []string
+	}{
+		{"A", []string{"192.0.2.1", "192.0.2.2"}},
+		{"AAAA", []string{"2001:db8::1"}},
+		{"MX", []string{"10 mx1.example.com.", "20 mx2.example.com."}},
+		{"NS", []string{"ns1.example.com."}},
+		{"TXT", []string{"v=spf1 -all", "part one part two"}},
+		{"SOA", []string{"ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300"}},
+		{"CAA", []string{`0 issue "letsencrypt.org"`}},
+		{"SRV", []string{"10 5 5060 sip1.example.com.", "20 0 5060 sip2.example.com."}},
+		{"TLSA", []string{"3 1 1 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}},
+		{"HTTPS", []string{`1 . alpn="h2"`}},
+		{"NAPTR", []string{`100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`}},
+		{"DS", []string{"12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"}},
+		{"CNAME", nil},
+		{"PTR", nil},
+	}
+	for _, tt := range tests {
+		var data []string
+		for _, rec := range r.RecordsOf(tt.typ) {
+			data = append(data, rec.Data)
+		}
+		if !reflect.DeepEqual(data, tt.data) {
+			t.Errorf("%s records = %q, want %q", tt.typ, data, tt.data)
+		}
+	}
+	if srv := r.RecordsOf("SRV"); len(srv) > 0 && srv[0].Name != "_sip._tcp.example.com." {
+		t.Errorf("SRV owner = %q, want the service name", srv[0].Name)
+	}
+	if mx := r.RecordsOf("MX"); len(mx) > 0 && mx[0].TTL != 300 {
+		t.Errorf("MX TTL = %d, want 300", mx[0].TTL)
+	}
+}
+
+func TestLookupCNAME(t *testing.T) {
+	srv := newServer(t)
+	srv.Add(
+		"www.example.com. 300 IN CNAME web.example.net.",
+		"web.example.net. 60 IN A 192.0.2.10",
+	)
+	r, err := Lookup(context.Background(), "www.example.com", Options{
+		Types:           []string{"A", "CNAME"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Name: "web.example.net.", Type: "A", TTL: 60, Data: "192.0.2.10"},
+		{Name: "www.example.com.", Type: "CNAME", TTL: 300, Data: "web.example.net."},
+	}
+	if !reflect.DeepEqual(r.Records, want) {
+		t.Errorf("Records = %+v, want %+v", r.Records, want)
+	}
+}
+
+func TestLookupPTR(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("1.2.0.192.in-addr.arpa. 300 IN PTR host.example.com.")
+	r, err := Lookup(context.Background(), "192.0.2.1", Options{ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Types, []string{"PTR"}) || len(r.Records) != 1 || r.Records[0].Data != "host.example.com." {
+		t.Errorf("Types = %q, Records = %+v, want the PTR record", r.Types, r.Records)
+	}
+}
+
+func TestLookupNXDomain(t *testing.T) {
+	srv := newServer(t)
+	srv.Add(
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"example.com. 300 IN A 192.0.2.1",
+		"dangling.example.com. 300 IN CNAME gone.example.com.",
+	)
+	tests := []struct {
+		domain   string
+		types    []string
+		nxdomain bool
+	}{
+		{domain: "missing.example.com", nxdomain: true},
+		{domain: "198.51.100.7", nxdomain: true},
+		// The SRV names under an existing domain not existing is normal.
+		{domain: "example.com", types: []string{"A", "SRV"}},
+		{domain: "example.com", types: []string{"SRV"}},
+		// The name exists; its CNAME target doesn't.
+		{domain: "dangling.example.com", types: []string{"A"}},
+	}
+	for _, tt := range tests {
+		r, err := Lookup(context.Background(), tt.domain, Options{
+			Types:           tt.types,
+			ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.NXDomain != tt.nxdomain || len(r.Errors) != 0 {
+			t.Errorf("Lookup(%s, %q): NXDomain = %v, Errors = %q, want NXDomain %v", tt.domain, tt.types, r.NXDomain, r.Errors, tt.nxdomain)
+		}
+	}
+}
+
+func TestLookupServFail(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN A 192.0.2.1")
+	srv.Fail("192.0.2.53:53", dnswire.RcodeServerFailure)
+	srv.Fail("192.0.2.54:53", dnswire.RcodeRefused)
+
+	r, err := Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"A", "MX"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53", "192.0.2.54"}, Exchanger: srv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A example.com.: REFUSED", "MX example.com.: REFUSED"}
+	if !reflect.DeepEqual(r.Errors, want) || len(r.Records) != 0 {
+		t.Errorf("Errors = %q, Records = %+v, want %q and none", r.Errors, r.Records, want)
+	}
+
+	r, err = Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"A"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 1 || !strings.HasSuffix(r.Errors[0], "SERVFAIL") || r.NXDomain {
+		t.Errorf("Errors = %q, NXDomain = %v, want SERVFAIL reported", r.Errors, r.NXDomain)
+	}
+}
+
+func TestLookupCompare(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN A 192.0.2.1")
+	srv.Fail("192.0.2.54:53", dnswire.RcodeServerFailure)
+
+	r, err := Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"A"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53", "192.0.2.54"}, Exchanger: srv},
+		Compare:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Discrepancies) != 1 {
+		t.Fatalf("Discrepancies = %+v, want one", r.Discrepancies)
+	}
+	want := []ServerAnswer{
+		{Server: "192.0.2.53:53", Records: []string{"192.0.2.1"}},
+		{Server: "192.0.2.54:53", Records: []string{}, Error: "SERVFAIL"},
+	}
+	if got := r.Discrepancies[0].Answers; !reflect.DeepEqual(got, want) {
+		t.Errorf("Answers = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,754 @@
+package dns
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// DNSSEC chain statuses, as defined in RFC 4035 section 4.3.
+const (
+	StatusSecure        = "secure"
+	StatusInsecure      = "insecure"
+	StatusBogus         = "bogus"
+	StatusIndeterminate = "indeterminate"
+)
+
+// RootTrustAnchors are the DS records of the root zone's key signing
+// keys (KSK-2017 and KSK-2024), where validation starts.
+var RootTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// SignatureExpiryWarning is how close to its expiration a valid RRSIG
+// is reported.
+const SignatureExpiryWarning = 7 * 24 * time.Hour
+
+// weakAlgorithms are the DNSKEY algorithms RFC 8624 deprecates for
+// signing.
+var weakAlgorithms = map[uint8]bool{
+	dnswire.RSAMD5:           true,
+	dnswire.DSA:              true,
+	dnswire.RSASHA1:          true,
+	dnswire.DSANSEC3SHA1:     true,
+	dnswire.RSASHA1NSEC3SHA1: true,
+	dnswire.ECCGOST:          true,
+}
+
+// DNSSECResult is the outcome of validating a name's chain of trust.
+type DNSSECResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Reason explains the status, e.g. where the chain broke.
+	Reason string `json:"reason,omitempty"`
+	// Zones are the links of the chain from the root down to the zone
+	// holding Name, as far as it could be followed.
+	Zones []ZoneSecurity `json:"zones"`
+	// Answer are the signatures over Name's own records, or over the
+	// proof that it has none.
+	Answer   []Signature `json:"answer,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// ZoneSecurity is one link of the chain of trust.
+type ZoneSecurity struct {
+	Zone   string `json:"zone"`
+	Status string `json:"status"`
+	// DS are the delegation signer records the parent publishes for the
+	// zone; for the root, the trust anchors.
+	DS   []DelegationSigner `json:"ds,omitempty"`
+	Keys []ZoneKey          `json:"keys,omitempty"`
+	// Signatures cover the DS records (made by the parent) and the
+	// DNSKEY records, or the parent's proof that no DS exists.
+	Signatures []Signature `json:"signatures,omitempty"`
+	// Denial is how the zone proves names do not exist: "NSEC" or
+	// "NSEC3".
+	Denial string       `json:"denial,omitempty"`
+	NSEC3  *NSEC3Params `json:"nsec3,omitempty"`
+}
+
+// DelegationSigner is a DS record.
+type DelegationSigner struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  string `json:"algorithm"`
+	DigestType string `json:"digest_type"`
+	// Matched is set when a DNSKEY of the zone hashes to the digest.
+	Matched bool `json:"matched"`
+}
+
+// ZoneKey is a DNSKEY record.
+type ZoneKey struct {
+	KeyTag    uint16 `json:"key_tag"`
+	Algorithm string `json:"algorithm"`
+	// Role is "KSK" for keys with the secure entry point flag, "ZSK"
+	// otherwise.
+	Role string `json:"role"`
+	// Bits is the modulus size of RSA keys.
+	Bits int `json:"bits,omitempty"`
+}
+
+// Signature is an RRSIG record and whether it verified.
+type Signature struct {
+	Covers     string    `json:"covers"`
+	KeyTag     uint16    `json:"key_tag"`
+	Algorithm  string    `json:"algorithm"`
+	Inception  time.Time `json:"inception"`
+	Expiration time.Time `json:"expiration"`
+	Valid      bool      `json:"valid"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// NSEC3Params are the hashing parameters of a zone's NSEC3 records.
+type NSEC3Params struct {
+	Iterations uint16 `json:"iterations"`
+	SaltLength int    `json:"salt_length"`
+	OptOut     bool   `json:"opt_out"`
+}
+
+// ValidateDNSSEC follows the chain of trust from the root down to name
+// through c, which should be a recursive resolver. Trust starts at
+// anchors, root DS records in zone file format (RootTrustAnchors when
+// empty). Lookup failures make the result indeterminate rather than an
+// error; only an invalid anchor or a cancelled context is.
+func ValidateDNSSEC(ctx context.Context, c *Client, name string, anchors []string, now time.Time) (*DNSSECResult, error) {
+	if len(anchors) == 0 {
+		anchors = RootTrustAnchors
+	}
+	var ds []*dnswire.DS
+	for _, a := range anchors {
+		rr, err := dnswire.NewRR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor: %w", err)
+		}
+		d, ok := rr.(*dnswire.DS)
+		if !ok || d.Hdr.Name != "." {
+			return nil, fmt.Errorf("trust anchor %q is not a DS record for the root", a)
+		}
+		ds = append(ds, d)
+	}
+
+	dc := *c
+	dc.DNSSEC = true
+	v := &validator{
+		ctx: ctx,
+		c:   &dc,
+		now: now,
+		res: &DNSSECResult{Name: dnswire.CanonicalName(name), Zones: []ZoneSecurity{}},
+	}
+	v.walk(ds)
+	if err := ctx.Err(); err != nil {
+		return v.res, err
+	}
+	v.denialWarnings()
+	return v.res, nil
+}
+
+type validator struct {
+	ctx context.Context
+	c   *Client
+	now time.Time
+	res *DNSSECResult
+}
+
+// walk validates each zone from the root down. It stops at the first
+// link that is not secure, which decides the status.
+func (v *validator) walk(anchors []*dnswire.DS) {
+	zone := "."
+	keys, ok := v.enterZone(zone, anchors, nil)
+	if !ok {
+		return
+	}
+	labels := dnswire.SplitDomainName(v.res.Name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		child := dnswire.Fqdn(strings.Join(labels[i:], "."))
+		cut, ok := v.zoneCut(child, keys)
+		if !ok {
+			return
+		}
+		if !cut {
+			continue
+		}
+		ds, sigs, ok := v.delegation(zone, child, keys)
+		if !ok {
+			return
+		}
+		zone = child
+		if keys, ok = v.enterZone(zone, ds, sigs); !ok {
+			return
+		}
+	}
+	v.answer(zone, keys)
+}
+
+// conclude sets the chain status; the first link to conclude wins.
+func (v *validator) conclude(status, format string, args ...interface{}) {
+	if v.res.Status == "" {
+		v.res.Status = status
+		v.res.Reason = fmt.Sprintf(format, args...)
+	}
+}
+
+func (v *validator) warnf(format string, args ...interface{}) {
+	v.res.Warnings = append(v.res.Warnings, fmt.Sprintf(format, args...))
+}
+
+// enterZone authenticates zone's DNSKEY set against the DS records that
+// vouch for it and returns the keys.
+func (v *validator) enterZone(zone string, ds []*dnswire.DS, dsSigs []Signature) ([]*dnswire.DNSKEY, bool) {
+	v.res.Zones = append(v.res.Zones, ZoneSecurity{Zone: zone, Status: StatusSecure, Signatures: dsSigs})
+	z := &v.res.Zones[len(v.res.Zones)-1]
+
+	rrset, sigs, _, err := v.fetch(zone, dnswire.TypeDNSKEY)
+	if err != nil {
+		z.Status = StatusIndeterminate
+		v.conclude(StatusIndeterminate, "fetching DNSKEY records of %s: %v", zone, err)
+		return nil, false
+	}
+	if zone == "." && (len(rrset) == 0 || len(sigs) == 0) {
+		z.Status = StatusIndeterminate
+		v.conclude(StatusIndeterminate, "the resolver returned no signed DNSKEY records for the root; it may not support DNSSEC")
+		return nil, false
+	}
+
+	var keys []*dnswire.DNSKEY
+	for _, rr := range rrset {
+		k := rr.(*dnswire.DNSKEY)
+		keys = append(keys, k)
+		z.Keys = append(z.Keys, v.zoneKey(zone, k))
+	}
+
+	var entry []*dnswire.DNSKEY
+	for _, d := range ds {
+		info := DelegationSigner{KeyTag: d.KeyTag, Algorithm: algorithmName(d.Algorithm), DigestType: digestName(d.DigestType)}
+		for _, k := range keys {
+			if k.KeyTag() != d.KeyTag || k.Algorithm != d.Algorithm {
+				continue
+			}
+			if kd := k.ToDS(d.DigestType); kd != nil && strings.EqualFold(kd.Digest, d.Digest) {
+				info.Matched = true
+				entry = append(entry, k)
+			}
+		}
+		if d.DigestType == dnswire.SHA1 {
+			v.warnf("%s: DS %d uses the SHA-1 digest", zone, d.KeyTag)
+		}
+		z.DS = append(z.DS, info)
+	}
+
+	if len(keys) == 0 {
+		z.Status = StatusBogus
+		v.conclude(StatusBogus, "%s has DS records but serves no DNSKEY records", zone)
+		return nil, false
+	}
+	if len(entry) == 0 {
+		z.Status = StatusBogus
+		v.conclude(StatusBogus, "no DNSKEY of %s matches its DS records", zone)
+		return nil, false
+	}
+	verified, ok := v.verify(rrset, sigs, entry)
+	z.Signatures = append(z.Signatures, verified...)
+	if !ok {
+		z.Status = StatusBogus
+		v.conclude(StatusBogus, "the DNSKEY records of %s are not validly signed by a key matching its DS records", zone)
+		return nil, false
+	}
+
+	z.Denial, z.NSEC3 = v.probeDenial(zone)
+	return keys, true
+}
+
+// zoneCut reports whether child is the apex of a zone of its own. A
+// name that does not exist ends the walk.
+func (v *validator) zoneCut(child string, keys []*dnswire.DNSKEY) (cut, ok bool) {
+	rrset, _, msg, err := v.fetch(child, dnswire.TypeSOA)
+	if err != nil {
+		v.conclude(StatusIndeterminate, "looking up %s: %v", child, err)
+		return false, false
+	}
+	if msg.Rcode == dnswire.RcodeNameError {
+		sigs, proved := v.verifyDenial(msg, child, dnswire.TypeSOA, keys)
+		v.res.Answer = sigs
+		if !proved {
+			v.conclude(StatusBogus, "%s does not exist, but the denial is not validly signed", child)
+		} else {
+			v.conclude(StatusSecure, "%s does not exist (authenticated denial of existence)", child)
+		}
+		return false, false
+	}
+	return len(rrset) > 0, true
+}
+
+// delegation fetches the DS records parent publishes for child. Without
+// them, parent must prove they do not exist, which makes child and
+// everything below it insecure.
+func (v *validator) delegation(parent, child string, parentKeys []*dnswire.DNSKEY) ([]*dnswire.DS, []Signature, bool) {
+	rrset, sigs, msg, err := v.fetch(child, dnswire.TypeDS)
+	if err != nil {
+		v.res.Zones = append(v.res.Zones, ZoneSecurity{Zone: child, Status: StatusIndeterminate})
+		v.conclude(StatusIndeterminate, "fetching DS records of %s: %v", child, err)
+		return nil, nil, false
+	}
+
+	if len(rrset) > 0 {
+		verified, ok := v.verify(rrset, sigs, parentKeys)
+		if !ok {
+			v.res.Zones = append(v.res.Zones, ZoneSecurity{Zone: child, Status: StatusBogus, Signatures: verified})
+			v.conclude(StatusBogus, "the DS records of %s are not validly signed by %s", child, parent)
+			return nil, nil, false
+		}
+		ds := make([]*dnswire.DS, 0, len(rrset))
+		for _, rr := range rrset {
+			ds = append(ds, rr.(*dnswire.DS))
+		}
+		return ds, verified, true
+	}
+
+	z := ZoneSecurity{Zone: child, Status: StatusInsecure}
+	verified, proved := v.verifyNoDS(msg, child, parentKeys)
+	z.Signatures = verified
+	if proved {
+		v.conclude(StatusInsecure, "%s is an unsigned delegation from %s (no DS record)", child, parent)
+	} else {
+		z.Status = StatusBogus
+		v.conclude(StatusBogus, "%s is signed but does not prove that %s has no DS record", parent, child)
+	}
+	v.res.Zones = append(v.res.Zones, z)
+	return nil, nil, false
+}
+
+// answer validates the name's own records with the keys of the zone
+// holding it: its SOA at a zone apex, its A records (or CNAME)
+// elsewhere, or the signed proof that there are none.
+func (v *validator) answer(zone string, keys []*dnswire.DNSKEY) {
+	qtype := dnswire.TypeA
+	if v.res.Name == zone {
+		qtype = dnswire.TypeSOA
+	}
+	_, _, msg, err := v.fetch(v.res.Name, qtype)
+	if err != nil {
+		v.conclude(StatusIndeterminate, "looking up %s: %v", v.res.Name, err)
+		return
+	}
+
+	ok := true
+	found := false
+	for _, set := range rrsets(msg.Answer) {
+		if !strings.EqualFold(set.name, v.res.Name) {
+			continue
+		}
+		found = true
+		verified, valid := v.verify(set.rrs, set.sigs, keys)
+		v.res.Answer = append(v.res.Answer, verified...)
+		ok = ok && valid
+	}
+	if !found {
+		v.res.Answer, ok = v.verifyDenial(msg, v.res.Name, qtype, keys)
+	}
+	if !ok {
+		v.conclude(StatusBogus, "the records of %s are not validly signed by %s", v.res.Name, zone)
+		return
+	}
+	v.conclude(StatusSecure, "every link from the root to %s is signed and verified", v.res.Name)
+}
+
+// fetch asks for name/qtype and returns the answer records owned by
+// name with the signatures over them.
+func (v *validator) fetch(name string, qtype uint16) ([]dnswire.RR, []*dnswire.RRSIG, *dnswire.Msg, error) {
+	resp, err := v.c.Query(v.ctx, name, qtype)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	msg := resp.Msg
+	if msg.Rcode != dnswire.RcodeSuccess && msg.Rcode != dnswire.RcodeNameError {
+		return nil, nil, msg, errors.New(dnswire.RcodeToString[msg.Rcode])
+	}
+	for _, set := range rrsets(msg.Answer) {
+		if set.rrtype == qtype && strings.EqualFold(set.name, name) {
+			return set.rrs, set.sigs, msg, nil
+		}
+	}
+	return nil, nil, msg, nil
+}
+
+// verify checks each signature over rrset with the matching key. The
+// set is authentic when at least one signature verifies and is within
+// its validity period.
+func (v *validator) verify(rrset []dnswire.RR, sigs []*dnswire.RRSIG, keys []*dnswire.DNSKEY) ([]Signature, bool) {
+	ok := false
+	var out []Signature
+	for _, sig := range sigs {
+		s := Signature{
+			Covers:     dnswire.TypeToString[sig.TypeCovered],
+			KeyTag:     sig.KeyTag,
+			Algorithm:  algorithmName(sig.Algorithm),
+			Inception:  time.Unix(int64(sig.Inception), 0).UTC(),
+			Expiration: time.Unix(int64(sig.Expiration), 0).UTC(),
+		}
+		err := fmt.Errorf("no DNSKEY with tag %d", sig.KeyTag)
+		for _, k := range keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err = sig.Verify(k, rrset); err == nil {
+				break
+			}
+		}
+		if err == nil && !sig.ValidityPeriod(v.now) {
+			err = errors.New("outside its validity period")
+		}
+		if err != nil {
+			s.Error = err.Error()
+		} else {
+			s.Valid = true
+			ok = true
+			if left := s.Expiration.Sub(v.now); left < SignatureExpiryWarning {
+				v.warnf("%s: RRSIG over %s by key %d expires %s (in %s)", sig.Hdr.Name, s.Covers, sig.KeyTag,
+					s.Expiration.Format(time.RFC3339), left.Round(time.Hour))
+			}
+		}
+		out = append(out, s)
+	}
+	return out, ok
+}
+
+// verifyDenial checks that msg's authority section proves name has no
+// records of qtype: for NXDOMAIN, that no such name exists and no
+// wildcard could answer for it; otherwise, that the name, or the
+// wildcard answering for it, has no records of the type (RFC 4035
+// section 5.4, RFC 5155 section 8). Only validly signed NSEC and NSEC3
+// records count toward the proof.
+func (v *validator) verifyDenial(msg *dnswire.Msg, name string, qtype uint16, keys []*dnswire.DNSKEY) ([]Signature, bool) {
+	out, nsec, nsec3 := v.denialRecords(msg, keys)
+	nxdomain := msg.Rcode == dnswire.RcodeNameError
+	switch {
+	case len(nsec) > 0:
+		return out, nsecDenies(nsec, name, qtype, nxdomain)
+	case len(nsec3) > 0:
+		return out, nsec3Denies(nsec3, name, qtype, nxdomain)
+	}
+	return out, false
+}
+
+// verifyNoDS checks that msg proves name is a delegation without DS
+// records: a signed NSEC or NSEC3 record for name whose type bitmap has
+// no DS, or the closest encloser proof of name with an opt-out NSEC3
+// record covering the next closer name (RFC 5155 section 8.6).
+func (v *validator) verifyNoDS(msg *dnswire.Msg, name string, keys []*dnswire.DNSKEY) ([]Signature, bool) {
+	out, nsec, nsec3 := v.denialRecords(msg, keys)
+	for _, n := range nsec {
+		if strings.EqualFold(n.Hdr.Name, name) && !hasType(n.TypeBitMap, dnswire.TypeDS) {
+			return out, true
+		}
+	}
+	for _, n := range nsec3 {
+		if n.Match(name) && !hasType(n.TypeBitMap, dnswire.TypeDS) {
+			return out, true
+		}
+	}
+	_, cover := nsec3ClosestEncloser(nsec3, name)
+	return out, cover != nil && cover.Flags&1 == 1
+}
+
+// denialRecords verifies the NSEC and NSEC3 sets in msg's authority
+// section and returns the records of those validly signed.
+func (v *validator) denialRecords(msg *dnswire.Msg, keys []*dnswire.DNSKEY) ([]Signature, []*dnswire.NSEC, []*dnswire.NSEC3) {
+	var out []Signature
+	var nsec []*dnswire.NSEC
+	var nsec3 []*dnswire.NSEC3
+	for _, set := range rrsets(msg.Ns) {
+		if set.rrtype != dnswire.TypeNSEC && set.rrtype != dnswire.TypeNSEC3 {
+			continue
+		}
+		verified, ok := v.verify(set.rrs, set.sigs, keys)
+		out = append(out, verified...)
+		if !ok {
+			continue
+		}
+		for _, rr := range set.rrs {
+			switch x := rr.(type) {
+			case *dnswire.NSEC:
+				nsec = append(nsec, x)
+			case *dnswire.NSEC3:
+				nsec3 = append(nsec3, x)
+			}
+		}
+	}
+	return out, nsec, nsec3
+}
+
+// nsecDenies reports whether the NSEC records prove that name does not
+// exist (nxdomain) or has no records of qtype.
+func nsecDenies(records []*dnswire.NSEC, name string, qtype uint16, nxdomain bool) bool {
+	if !nxdomain {
+		for _, n := range records {
+			if strings.EqualFold(n.Hdr.Name, name) {
+				return lacksType(n.TypeBitMap, qtype)
+			}
+		}
+	}
+	// Otherwise name must not exist, and neither may the wildcard at its
+	// closest encloser, or that wildcard must lack the type.
+	var cover *dnswire.NSEC
+	for _, n := range records {
+		if nsecCovers(n, name) {
+			cover = n
+			break
+		}
+	}
+	if cover == nil {
+		return false
+	}
+	common := max(dnswire.CompareDomainName(name, cover.Hdr.Name), dnswire.CompareDomainName(name, cover.NextDomain))
+	labels := dnswire.SplitDomainName(name)
+	wildcard := wildcardAt(strings.Join(labels[len(labels)-common:], "."))
+	for _, n := range records {
+		if nxdomain && nsecCovers(n, wildcard) {
+			return true
+		}
+		if !nxdomain && strings.EqualFold(n.Hdr.Name, wildcard) && lacksType(n.TypeBitMap, qtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsecCovers reports whether name falls strictly between the owner and
+// the next name of n. The last NSEC of a zone points back to the apex.
+func nsecCovers(n *dnswire.NSEC, name string) bool {
+	owner, next := n.Hdr.Name, n.NextDomain
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(owner, name) < 0 && canonicalCompare(name, next) < 0
+	}
+	return canonicalCompare(owner, name) < 0 || canonicalCompare(name, next) < 0
+}
+
+// nsec3Denies reports whether the NSEC3 records prove that name does not
+// exist (nxdomain) or has no records of qtype.
+func nsec3Denies(records []*dnswire.NSEC3, name string, qtype uint16, nxdomain bool) bool {
+	if !nxdomain {
+		for _, n := range records {
+			if n.Match(name) {
+				return lacksType(n.TypeBitMap, qtype)
+			}
+		}
+	}
+	encloser, cover := nsec3ClosestEncloser(records, name)
+	if cover == nil {
+		return false
+	}
+	wildcard := wildcardAt(encloser)
+	for _, n := range records {
+		if nxdomain && nsec3Covers(n, wildcard) {
+			return true
+		}
+		if !nxdomain && n.Match(wildcard) && lacksType(n.TypeBitMap, qtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsec3ClosestEncloser finds the closest encloser of name, its longest
+// ancestor an NSEC3 record matches, and the record covering the next
+// closer name, one label longer (RFC 5155 section 8.3). The record is
+// nil when there is no such proof.
+func nsec3ClosestEncloser(records []*dnswire.NSEC3, name string) (string, *dnswire.NSEC3) {
+	labels := dnswire.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		encloser := dnswire.Fqdn(strings.Join(labels[i:], "."))
+		nextCloser := dnswire.Fqdn(strings.Join(labels[i-1:], "."))
+		for _, n := range records {
+			if !n.Match(encloser) {
+				continue
+			}
+			for _, c := range records {
+				if nsec3Covers(c, nextCloser) {
+					return encloser, c
+				}
+			}
+			return "", nil
+		}
+	}
+	return "", nil
+}
+
+// nsec3Covers reports whether the hash of name falls strictly between
+// the owner and next hashes of n; Cover alone also accepts a match.
+func nsec3Covers(n *dnswire.NSEC3, name string) bool {
+	return n.Cover(name) && !n.Match(name)
+}
+
+// wildcardAt returns the wildcard name directly below name.
+func wildcardAt(name string) string {
+	if name == "" || name == "." {
+		return "*."
+	}
+	return "*." + dnswire.Fqdn(name)
+}
+
+// lacksType reports whether a type bitmap shows neither qtype nor a
+// CNAME, which would have answered instead.
+func lacksType(bitmap []uint16, qtype uint16) bool {
+	return !hasType(bitmap, qtype) && !hasType(bitmap, dnswire.TypeCNAME)
+}
+
+// canonicalCompare orders names as RFC 4034 section 6.1 does: label by
+// label from the root, case-insensitively.
+func canonicalCompare(a, b string) int {
+	la := dnswire.SplitDomainName(strings.ToLower(a))
+	lb := dnswire.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// probeDenial asks for a name that cannot exist in zone and reports
+// how the zone denies it.
+func (v *validator) probeDenial(zone string) (string, *NSEC3Params) {
+	name := randomLabel() + "." + strings.TrimPrefix(zone, ".")
+	resp, err := v.c.Query(v.ctx, name, dnswire.TypeA)
+	if err != nil {
+		return "", nil
+	}
+	for _, rr := range resp.Msg.Ns {
+		switch x := rr.(type) {
+		case *dnswire.NSEC3:
+			return "NSEC3", &NSEC3Params{
+				Iterations: x.Iterations,
+				SaltLength: int(x.SaltLength),
+				OptOut:     x.Flags&1 == 1,
+			}
+		case *dnswire.NSEC:
+			return "NSEC", nil
+		}
+	}
+	return "", nil
+}
+
+// denialWarnings reports the denial-of-existence setup of the zone
+// holding the name; the root and TLDs are outside the owner's control.
+func (v *validator) denialWarnings() {
+	if len(v.res.Zones) < 2 {
+		return
+	}
+	z := v.res.Zones[len(v.res.Zones)-1]
+	switch {
+	case z.Denial == "NSEC":
+		v.warnf("%s: NSEC lets anyone enumerate the zone's names (zone walking); NSEC3 or compact denial avoids this", z.Zone)
+	case z.NSEC3 != nil && z.NSEC3.Iterations > 0:
+		v.warnf("%s: NSEC3 uses %d additional iterations; RFC 9276 recommends 0", z.Zone, z.NSEC3.Iterations)
+	}
+	if z.NSEC3 != nil && z.NSEC3.SaltLength > 0 {
+		v.warnf("%s: NSEC3 uses a %d-byte salt; RFC 9276 recommends none", z.Zone, z.NSEC3.SaltLength)
+	}
+}
+
+func (v *validator) zoneKey(zone string, k *dnswire.DNSKEY) ZoneKey {
+	key := ZoneKey{KeyTag: k.KeyTag(), Algorithm: algorithmName(k.Algorithm), Role: "ZSK"}
+	if k.Flags&dnswire.SEP != 0 {
+		key.Role = "KSK"
+	}
+	if weakAlgorithms[k.Algorithm] {
+		v.warnf("%s: DNSKEY %d uses the deprecated algorithm %s", zone, key.KeyTag, key.Algorithm)
+	}
+	switch k.Algorithm {
+	case dnswire.RSAMD5, dnswire.RSASHA1, dnswire.RSASHA1NSEC3SHA1, dnswire.RSASHA256, dnswire.RSASHA512:
+		key.Bits = rsaBits(k.PublicKey)
+		if key.Bits > 0 && key.Bits < 2048 {
+			v.warnf("%s: DNSKEY %d is a %d-bit RSA key; use at least 2048 bits", zone, key.KeyTag, key.Bits)
+		}
+	}
+	return key
+}
+
+// rsaBits returns the modulus size of an RSA public key in the RFC 3110
+// encoding: exponent length, exponent, modulus.
+func rsaBits(publicKey string) int {
+	b, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(b) < 3 {
+		return 0
+	}
+	explen, off := int(b[0]), 1
+	if explen == 0 {
+		explen, off = int(b[1])<<8|int(b[2]), 3
+	}
+	modulus := b[min(off+explen, len(b)):]
+	for len(modulus) > 0 && modulus[0] == 0 {
+		modulus = modulus[1:]
+	}
+	return len(modulus) * 8
+}
+
+// rrset is the records of one owner and type with their signatures.
+type rrset struct {
+	name   string
+	rrtype uint16
+	rrs    []dnswire.RR
+	sigs   []*dnswire.RRSIG
+}
+
+// rrsets groups records by owner and type, attaching each RRSIG to the
+// set it covers.
+func rrsets(records []dnswire.RR) []*rrset {
+	var sets []*rrset
+	find := func(name string, rrtype uint16) *rrset {
+		for _, s := range sets {
+			if s.rrtype == rrtype && strings.EqualFold(s.name, name) {
+				return s
+			}
+		}
+		s := &rrset{name: name, rrtype: rrtype}
+		sets = append(sets, s)
+		return s
+	}
+	for _, rr := range records {
+		h := rr.Header()
+		if sig, ok := rr.(*dnswire.RRSIG); ok {
+			s := find(h.Name, sig.TypeCovered)
+			s.sigs = append(s.sigs, sig)
+			continue
+		}
+		s := find(h.Name, h.Rrtype)
+		s.rrs = append(s.rrs, rr)
+	}
+	return sets
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+func algorithmName(alg uint8) string {
+	if name, ok := dnswire.AlgorithmToString[alg]; ok {
+		return name
+	}
+	return strconv.Itoa(int(alg))
+}
+
+func digestName(digest uint8) string {
+	if name, ok := dnswire.HashToString[digest]; ok {
+		return name
+	}
+	return strconv.Itoa(int(digest))
+}
+
+// randomLabel returns a label that is practically certain not to exist.
+func randomLabel() string {
+	return fmt.Sprintf("afsa-%012x", rand.Int63()&(1<<48-1))
+}
@@ -0,0 +1,305 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+func mustRR(t *testing.T, s string) dnswire.RR {
+	t.Helper()
+	rr, err := dnswire.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func nsecRR(t *testing.T, owner, next, types string) *dnswire.NSEC {
+	t.Helper()
+	return mustRR(t, owner+" 300 IN NSEC "+next+" "+types).(*dnswire.NSEC)
+}
+
+func TestNSECDenies(t *testing.T) {
+	// The zone has example., a.example. and c.example.
+	apex := nsecRR(t, "example.", "a.example.", "SOA NS RRSIG NSEC DNSKEY")
+	a := nsecRR(t, "a.example.", "c.example.", "A RRSIG NSEC")
+	c := nsecRR(t, "c.example.", "example.", "A MX RRSIG NSEC")
+	star := nsecRR(t, "*.example.", "a.example.", "TXT RRSIG NSEC")
+	apexToStar := nsecRR(t, "example.", "*.example.", "SOA NS RRSIG NSEC DNSKEY")
+	cname := nsecRR(t, "a.example.", "c.example.", "CNAME RRSIG NSEC")
+
+	tests := []struct {
+		name     string
+		records  []*dnswire.NSEC
+		qname    string
+		qtype    uint16
+		nxdomain bool
+		want     bool
+	}{
+		{name: "name and wildcard covered", records: []*dnswire.NSEC{a, apex}, qname: "b.example.", nxdomain: true, want: true},
+		{name: "no wildcard proof", records: []*dnswire.NSEC{a}, qname: "b.example.", nxdomain: true},
+		{name: "name not covered", records: []*dnswire.NSEC{apex}, qname: "b.example.", nxdomain: true},
+		{name: "owner is not covered", records: []*dnswire.NSEC{a, apex}, qname: "a.example.", nxdomain: true},
+		{name: "last NSEC wraps to the apex", records: []*dnswire.NSEC{c, apex}, qname: "d.example.", nxdomain: true, want: true},
+		{name: "below an existing name", records: []*dnswire.NSEC{a, c}, qname: "x.a.example.", nxdomain: true, want: true},
+		{name: "wildcard exists", records: []*dnswire.NSEC{a, star, apexToStar}, qname: "b.example.", nxdomain: true},
+		{name: "nodata", records: []*dnswire.NSEC{c}, qname: "c.example.", qtype: dnswire.TypeAAAA, want: true},
+		{name: "nodata for a type that exists", records: []*dnswire.NSEC{c}, qname: "c.example.", qtype: dnswire.TypeMX},
+		{name: "nodata with a CNAME", records: []*dnswire.NSEC{cname}, qname: "a.example.", qtype: dnswire.TypeAAAA},
+		{name: "nodata without the name's NSEC", records: []*dnswire.NSEC{apex}, qname: "c.example.", qtype: dnswire.TypeAAAA},
+		{name: "wildcard nodata", records: []*dnswire.NSEC{a, star}, qname: "b.example.", qtype: dnswire.TypeA, want: true},
+		{name: "wildcard has the type", records: []*dnswire.NSEC{a, star}, qname: "b.example.", qtype: dnswire.TypeTXT},
+	}
+	for _, tt := range tests {
+		if tt.qtype == 0 {
+			tt.qtype = dnswire.TypeA
+		}
+		if got := nsecDenies(tt.records, tt.qname, tt.qtype, tt.nxdomain); got != tt.want {
+			t.Errorf("%s: nsecDenies(%s) = %v, want %v", tt.name, tt.qname, got, tt.want)
+		}
+	}
+}
+
+// nsec3Zone is a zone's NSEC3 chain over names, hashed without salt or
+// extra iterations.
+type nsec3Zone struct {
+	zone    string
+	records []*dnswire.NSEC3
+}
+
+func newNSEC3Zone(t *testing.T, zone string, names map[string]string, optOut bool) *nsec3Zone {
+	t.Helper()
+	type entry struct{ hash, types string }
+	var entries []entry
+	for name, types := range names {
+		entries = append(entries, entry{dnswire.HashName(name, dnswire.SHA1, 0, ""), types})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+	flags := "0"
+	if optOut {
+		flags = "1"
+	}
+	z := &nsec3Zone{zone: zone}
+	for i, e := range entries {
+		next := entries[(i+1)%len(entries)].hash
+		rr := mustRR(t, e.hash+"."+zone+" 300 IN NSEC3 1 "+flags+" 0 - "+next+" "+e.types)
+		z.records = append(z.records, rr.(*dnswire.NSEC3))
+	}
+	return z
+}
+
+// matching returns the record whose hash is name's.
+func (z *nsec3Zone) matching(t *testing.T, name string) *dnswire.NSEC3 {
+	t.Helper()
+	for _, n := range z.records {
+		if n.Match(name) {
+			return n
+		}
+	}
+	t.Fatalf("no NSEC3 matches %s", name)
+	return nil
+}
+
+// covering returns the record whose hash range holds name's.
+func (z *nsec3Zone) covering(t *testing.T, name string) *dnswire.NSEC3 {
+	t.Helper()
+	for _, n := range z.records {
+		if nsec3Covers(n, name) {
+			return n
+		}
+	}
+	t.Fatalf("no NSEC3 covers %s", name)
+	return nil
+}
+
+func TestNSEC3Denies(t *testing.T) {
+	z := newNSEC3Zone(t, "example.", map[string]string{
+		"example.":   "SOA NS RRSIG DNSKEY NSEC3PARAM",
+		"a.example.": "A RRSIG",
+		"c.example.": "A MX RRSIG",
+		"d.example.": "CNAME RRSIG",
+	}, false)
+	// Pick a missing name whose hash falls in another range than the
+	// wildcard's, so the two proofs need different records.
+	missing := ""
+	for _, label := range []string{"b", "e", "f", "g", "h", "i", "j", "k"} {
+		name := label + ".example."
+		if z.covering(t, name) != z.covering(t, "*.example.") {
+			missing = name
+			break
+		}
+	}
+	if missing == "" {
+		t.Fatal("no missing name hashes apart from the wildcard")
+	}
+	apex := z.matching(t, "example.")
+	nextCloser := z.covering(t, missing)
+	wildcard := z.covering(t, "*.example.")
+
+	tests := []struct {
+		name     string
+		records  []*dnswire.NSEC3
+		qname    string
+		qtype    uint16
+		nxdomain bool
+		want     bool
+	}{
+		{name: "closest encloser proof and wildcard", records: []*dnswire.NSEC3{apex, nextCloser, wildcard}, qname: missing, nxdomain: true, want: true},
+		{name: "no wildcard proof", records: []*dnswire.NSEC3{apex, nextCloser}, qname: missing, nxdomain: true},
+		{name: "no closest encloser", records: []*dnswire.NSEC3{nextCloser, wildcard}, qname: missing, nxdomain: true},
+		{name: "next closer not covered", records: []*dnswire.NSEC3{apex, wildcard}, qname: missing, nxdomain: true},
+		{name: "deeper name", records: []*dnswire.NSEC3{z.matching(t, "a.example."), z.covering(t, "x.a.example."), z.covering(t, "*.a.example.")}, qname: "y.x.a.example.", nxdomain: true, want: true},
+		{name: "existing name", records: z.records, qname: "a.example.", nxdomain: true},
+		{name: "nodata", records: []*dnswire.NSEC3{z.matching(t, "c.example.")}, qname: "c.example.", qtype: dnswire.TypeAAAA, want: true},
+		{name: "nodata for a type that exists", records: []*dnswire.NSEC3{z.matching(t, "c.example.")}, qname: "c.example.", qtype: dnswire.TypeMX},
+		{name: "nodata with a CNAME", records: []*dnswire.NSEC3{z.matching(t, "d.example.")}, qname: "d.example.", qtype: dnswire.TypeAAAA},
+		{name: "nodata without a matching record", records: []*dnswire.NSEC3{apex}, qname: "c.example.", qtype: dnswire.TypeAAAA},
+	}
+	for _, tt := range tests {
+		if tt.qtype == 0 {
+			tt.qtype = dnswire.TypeA
+		}
+		if got := nsec3Denies(tt.records, tt.qname, tt.qtype, tt.nxdomain); got != tt.want {
+			t.Errorf("%s: nsec3Denies(%s) = %v, want %v", tt.name, tt.qname, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalCompare(t *testing.T) {
+	// RFC 4034 section 6.1.
+	ordered := []string{
+		"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.",
+		"zABC.a.EXAMPLE.", "z.example.", "*.z.example.",
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, b := ordered[i-1], ordered[i]
+		if canonicalCompare(a, b) >= 0 || canonicalCompare(b, a) <= 0 {
+			t.Errorf("canonicalCompare(%s, %s) does not order them", a, b)
+		}
+	}
+	if canonicalCompare("A.Example.", "a.example.") != 0 {
+		t.Error("canonicalCompare is not case-insensitive")
+	}
+}
+
+// signer holds a zone's key.
+type signer struct {
+	zone string
+	key  *dnswire.DNSKEY
+	priv crypto.Signer
+}
+
+func newSigner(t *testing.T, zone string) *signer {
+	t.Helper()
+	key := &dnswire.DNSKEY{
+		Hdr:       dnswire.RR_Header{Name: zone, Rrtype: dnswire.TypeDNSKEY, Class: dnswire.ClassINET, Ttl: 3600},
+		Flags:     dnswire.ZONE | dnswire.SEP,
+		Protocol:  3,
+		Algorithm: dnswire.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{zone: zone, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns rrs and the RRSIG over them.
+func (s *signer) sign(t *testing.T, rrs ...dnswire.RR) []dnswire.RR {
+	t.Helper()
+	h := rrs[0].Header()
+	now := time.Now()
+	sig := &dnswire.RRSIG{
+		Hdr:        dnswire.RR_Header{Name: h.Name, Rrtype: dnswire.TypeRRSIG, Class: dnswire.ClassINET, Ttl: h.Ttl},
+		KeyTag:     s.key.KeyTag(),
+		SignerName: s.zone,
+		Algorithm:  s.key.Algorithm,
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		Expiration: uint32(now.Add(30 * 24 * time.Hour).Unix()),
+	}
+	if err := sig.Sign(s.priv, rrs); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrs, sig)
+}
+
+// signedZones serves a signed root delegating to a signed example.
+// zone, whose NSEC chain skips the records named in omit.
+func signedZones(t *testing.T, omit ...string) (*Client, []string) {
+	t.Helper()
+	srv := newServer(t)
+	root, zone := newSigner(t, "."), newSigner(t, "example.")
+	add := func(s *signer, records ...string) {
+		var rrs []dnswire.RR
+		for _, r := range records {
+			rrs = append(rrs, mustRR(t, r))
+		}
+		srv.AddRR(s.sign(t, rrs...)...)
+	}
+	srv.AddRR(root.sign(t, root.key)...)
+	add(root, ". 86400 IN SOA a.root. hostmaster.root. 1 1800 900 604800 86400")
+	srv.AddRR(root.sign(t, zone.key.ToDS(dnswire.SHA256))...)
+
+	srv.AddRR(zone.sign(t, zone.key)...)
+	add(zone, "example. 3600 IN SOA ns.example. hostmaster.example. 1 7200 3600 1209600 300")
+	add(zone, "www.example. 300 IN A 192.0.2.1")
+	add(zone, "mail.example. 300 IN MX 10 www.example.")
+	nsec := map[string]string{
+		"example.":      "example. 300 IN NSEC mail.example. SOA RRSIG NSEC DNSKEY",
+		"mail.example.": "mail.example. 300 IN NSEC www.example. MX RRSIG NSEC",
+		"www.example.":  "www.example. 300 IN NSEC example. A RRSIG NSEC",
+	}
+	for owner, r := range nsec {
+		if !contains(omit, owner) {
+			add(zone, r)
+		}
+	}
+
+	ds := root.key.ToDS(dnswire.SHA256)
+	c := &Client{Servers: []string{testResolver}, Exchanger: srv}
+	return c, []string{ds.String()}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateDNSSECDenial(t *testing.T) {
+	tests := []struct {
+		name   string
+		omit   []string
+		status string
+		reason string
+	}{
+		{name: "www.example.", status: StatusSecure, reason: "every link"},
+		// missing.example. sorts between mail. and www.; the wildcard
+		// between the apex and mail.
+		{name: "missing.example.", status: StatusSecure, reason: "does not exist"},
+		{name: "missing.example.", omit: []string{"mail.example."}, status: StatusBogus, reason: "not validly signed"},
+		{name: "missing.example.", omit: []string{"example."}, status: StatusBogus, reason: "not validly signed"},
+		// mail.example. has no A record, which its NSEC proves.
+		{name: "mail.example.", status: StatusSecure, reason: "every link"},
+		{name: "mail.example.", omit: []string{"mail.example."}, status: StatusBogus, reason: "not validly signed"},
+	}
+	for _, tt := range tests {
+		c, anchors := signedZones(t, tt.omit...)
+		r, err := ValidateDNSSEC(context.Background(), c, tt.name, anchors, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != tt.status || !strings.Contains(r.Reason, tt.reason) {
+			t.Errorf("%s without NSEC at %q: %s (%s), want %s", tt.name, tt.omit, r.Status, r.Reason, tt.status)
+		}
+	}
+}
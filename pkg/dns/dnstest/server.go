@@ -0,0 +1,373 @@
+// Package dnstest runs a fake DNS server, in the manner of
+// net/http/httptest, so lookups can be exercised without the network.
+package dnstest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// maxCNAMEs bounds the CNAME chain an answer follows.
+const maxCNAMEs = 8
+
+// Server answers from the records added to it, as the authoritative
+// server of every zone they belong to and a recursive resolver for
+// anything else. Wildcard records answer for the names below them that
+// don't exist. Queries with the DO bit get the RRSIG records added
+// for the answers, and negative answers the zone's NSEC or NSEC3
+// records. It listens over UDP and TCP on Addr, truncating UDP
+// answers larger than the client's buffer, and can stand in for any
+// number of servers in memory: use it as dns.ResolverOptions.Exchanger
+// and the server address of each query picks which one is asked.
+type Server struct {
+	// Addr is the host:port the server listens on, over UDP and TCP.
+	Addr string
+
+	udp, tcp  *dnswire.Server
+	mu        sync.Mutex
+	records   []dnswire.RR
+	failing   map[string]int
+	dropped   map[string]bool
+	transfers bool
+	queries   []Query
+}
+
+// Query is a query the server received.
+type Query struct {
+	// Server is Addr, or the server an in-memory exchange was for.
+	Server string
+	// Net is "udp" or "tcp", or "" for an in-memory exchange.
+	Net  string
+	Name string
+	Type string
+}
+
+// NewServer starts a Server on a loopback port. Close it when done.
+func NewServer() (*Server, error) {
+	s := &Server{failing: map[string]int{}, dropped: map[string]bool{}}
+	// UDP and TCP share the port, so find one free for both.
+	var err error
+	for i := 0; i < 10; i++ {
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			return nil, err
+		}
+		var ln net.Listener
+		if ln, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			pc.Close()
+			continue
+		}
+		s.Addr = pc.LocalAddr().String()
+		s.udp = &dnswire.Server{PacketConn: pc, Handler: s}
+		s.tcp = &dnswire.Server{Listener: ln, Handler: s}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, srv := range []*dnswire.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+	}
+	return s, nil
+}
+
+// Add adds records in zone file format, e.g. "example.com. 300 IN A
+// 192.0.2.1". It panics on a record that doesn't parse, as tests write
+// them out literally.
+func (s *Server) Add(records ...string) {
+	for _, r := range records {
+		rr, err := dnswire.NewRR(r)
+		if err != nil || rr == nil {
+			panic(fmt.Sprintf("dnstest: bad record %q: %v", r, err))
+		}
+		s.AddRR(rr)
+	}
+}
+
+// AddRR adds records, such as signatures made by the test.
+func (s *Server) AddRR(rrs ...dnswire.RR) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rr := range rrs {
+		rr = dnswire.Copy(rr)
+		rr.Header().Name = dnswire.CanonicalName(rr.Header().Name)
+		s.records = append(s.records, rr)
+	}
+}
+
+// Fail makes server answer every query with rcode, e.g.
+// dns.RcodeServerFailure.
+func (s *Server) Fail(server string, rcode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[server] = rcode
+}
+
+// Drop makes server leave every query unanswered: over the network the
+// client times out, in memory the exchange fails at once.
+func (s *Server) Drop(server string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped[server] = true
+}
+
+// AllowTransfer makes the server hand out zones over AXFR and IXFR,
+// which it otherwise refuses.
+func (s *Server) AllowTransfer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfers = true
+}
+
+// Queries returns the queries received so far, in order.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.udp.Shutdown()
+	if err2 := s.tcp.Shutdown(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Exchange answers m as server without going through the network. It
+// implements dns.Exchanger.
+func (s *Server) Exchange(ctx context.Context, m *dnswire.Msg, server string) (*dnswire.Msg, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reply, ok := s.Reply(m, server, "")
+	if !ok {
+		return nil, fmt.Errorf("dnstest: %s is not answering", server)
+	}
+	return reply, nil
+}
+
+// ServeDNS answers a query received over the network. It implements
+// dns.Handler, so the server can also be run behind other listeners,
+// e.g. for DNS over TLS.
+func (s *Server) ServeDNS(w dnswire.ResponseWriter, req *dnswire.Msg) {
+	network := w.LocalAddr().Network()
+	if len(req.Question) == 1 && (req.Question[0].Qtype == dnswire.TypeAXFR || req.Question[0].Qtype == dnswire.TypeIXFR) {
+		s.transfer(w, req, network)
+		return
+	}
+	reply, ok := s.Reply(req, s.Addr, network)
+	if !ok {
+		return
+	}
+	if network == "udp" {
+		size := dnswire.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+		reply.Truncate(size)
+	}
+	_ = w.WriteMsg(reply)
+}
+
+// Reply returns the answer server gives to req, recording the query as
+// received over network. It returns false when server is dropped.
+func (s *Server) Reply(req *dnswire.Msg, server, network string) (*dnswire.Msg, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := dnswire.Question{}
+	if len(req.Question) > 0 {
+		q = req.Question[0]
+	}
+	s.queries = append(s.queries, Query{Server: server, Net: network, Name: q.Name, Type: dnswire.TypeToString[q.Qtype]})
+	if s.dropped[server] {
+		return nil, false
+	}
+
+	m := new(dnswire.Msg)
+	m.SetReply(req)
+	m.RecursionAvailable = true
+	opt := req.IsEdns0()
+	if opt != nil {
+		m.SetEdns0(opt.UDPSize(), opt.Do())
+	}
+	if rcode, ok := s.failing[server]; ok {
+		m.Rcode = rcode
+		return m, true
+	}
+	if len(req.Question) != 1 {
+		m.Rcode = dnswire.RcodeFormatError
+		return m, true
+	}
+	do := opt != nil && opt.Do()
+
+	name := dnswire.CanonicalName(q.Name)
+	for i := 0; i < maxCNAMEs; i++ {
+		src := s.source(name)
+		if rrs := s.rrset(src, q.Qtype); len(rrs) > 0 {
+			m.Answer = append(m.Answer, rename(s.signed(rrs, do), name)...)
+			m.Authoritative = true
+			return m, true
+		}
+		cname := s.rrset(src, dnswire.TypeCNAME)
+		if len(cname) == 0 || q.Qtype == dnswire.TypeCNAME {
+			break
+		}
+		m.Answer = append(m.Answer, rename(s.signed(cname, do), name)...)
+		name = dnswire.CanonicalName(cname[0].(*dnswire.CNAME).Target)
+	}
+	if !s.exists(s.source(name)) {
+		m.Rcode = dnswire.RcodeNameError
+	}
+	if soa := s.soa(name); soa != nil {
+		m.Ns = s.signed([]dnswire.RR{soa}, do)
+		m.Authoritative = true
+		if do {
+			m.Ns = append(m.Ns, s.denial(soa.Header().Name)...)
+		}
+	}
+	return m, true
+}
+
+// denial returns every NSEC and NSEC3 record of zone with its
+// signatures. A real server picks the few that prove the answer; these
+// are a superset, and leaving records out of the zone makes a broken
+// proof.
+func (s *Server) denial(zone string) []dnswire.RR {
+	var rrs []dnswire.RR
+	for _, rr := range s.records {
+		h := rr.Header()
+		if (h.Rrtype == dnswire.TypeNSEC || h.Rrtype == dnswire.TypeNSEC3) && dnswire.IsSubDomain(zone, h.Name) {
+			rrs = append(rrs, s.signed([]dnswire.RR{dnswire.Copy(rr)}, true)...)
+		}
+	}
+	return rrs
+}
+
+// rrset returns copies of the records of name and type.
+func (s *Server) rrset(name string, qtype uint16) []dnswire.RR {
+	var rrs []dnswire.RR
+	for _, rr := range s.records {
+		if rr.Header().Name == name && rr.Header().Rrtype == qtype {
+			rrs = append(rrs, dnswire.Copy(rr))
+		}
+	}
+	return rrs
+}
+
+// source returns the owner of the records answering for name: name
+// itself, or when it doesn't exist the wildcard at its closest encloser
+// if there is one (RFC 4592).
+func (s *Server) source(name string) string {
+	if s.exists(name) {
+		return name
+	}
+	labels := dnswire.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		parent := dnswire.Fqdn(strings.Join(labels[i:], "."))
+		if !s.exists(parent) {
+			continue
+		}
+		if wildcard := "*." + parent; s.exists(wildcard) {
+			return wildcard
+		}
+		break
+	}
+	return name
+}
+
+// rename sets the owner of rrs to name, as when a wildcard answers.
+func rename(rrs []dnswire.RR, name string) []dnswire.RR {
+	for _, rr := range rrs {
+		rr.Header().Name = name
+	}
+	return rrs
+}
+
+// signed returns rrs followed, when do is set, by the signatures that
+// cover them.
+func (s *Server) signed(rrs []dnswire.RR, do bool) []dnswire.RR {
+	if !do || len(rrs) == 0 {
+		return rrs
+	}
+	h := rrs[0].Header()
+	for _, rr := range s.records {
+		if sig, ok := rr.(*dnswire.RRSIG); ok && sig.Hdr.Name == h.Name && sig.TypeCovered == h.Rrtype {
+			rrs = append(rrs, dnswire.Copy(sig))
+		}
+	}
+	return rrs
+}
+
+// exists reports whether name has records or names below it.
+func (s *Server) exists(name string) bool {
+	if name == "." {
+		return len(s.records) > 0
+	}
+	for _, rr := range s.records {
+		if owner := rr.Header().Name; owner == name || strings.HasSuffix(owner, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// soa returns the SOA record of the zone name is in, if there is one.
+func (s *Server) soa(name string) dnswire.RR {
+	var best dnswire.RR
+	for _, rr := range s.records {
+		owner := rr.Header().Name
+		if rr.Header().Rrtype != dnswire.TypeSOA || !dnswire.IsSubDomain(owner, name) {
+			continue
+		}
+		if best == nil || dnswire.CountLabel(owner) > dnswire.CountLabel(best.Header().Name) {
+			best = rr
+		}
+	}
+	if best != nil {
+		best = dnswire.Copy(best)
+	}
+	return best
+}
+
+// transfer answers an AXFR, or an IXFR with the full zone as RFC 1995
+// allows, when transfers are allowed.
+func (s *Server) transfer(w dnswire.ResponseWriter, req *dnswire.Msg, network string) {
+	s.mu.Lock()
+	zone := dnswire.CanonicalName(req.Question[0].Name)
+	s.queries = append(s.queries, Query{Server: s.Addr, Net: network, Name: req.Question[0].Name, Type: dnswire.TypeToString[req.Question[0].Qtype]})
+	soa := s.rrset(zone, dnswire.TypeSOA)
+	var rrs []dnswire.RR
+	if s.transfers && len(soa) > 0 {
+		rrs = append(rrs, soa[0])
+		for _, rr := range s.records {
+			if dnswire.IsSubDomain(zone, rr.Header().Name) && rr.Header().Rrtype != dnswire.TypeSOA {
+				rrs = append(rrs, dnswire.Copy(rr))
+			}
+		}
+		rrs = append(rrs, soa[0])
+	}
+	s.mu.Unlock()
+
+	if rrs == nil {
+		m := new(dnswire.Msg)
+		m.SetRcode(req, dnswire.RcodeRefused)
+		_ = w.WriteMsg(m)
+		return
+	}
+	ch := make(chan *dnswire.Envelope, 1)
+	ch <- &dnswire.Envelope{RR: rrs}
+	close(ch)
+	tr := new(dnswire.Transfer)
+	_ = tr.Out(w, req, ch)
+	w.Close()
+}
@@ -0,0 +1,804 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	dnswire "github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Finding severities, most severe first.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+)
+
+var severityRank = map[string]int{SeverityHigh: 0, SeverityMedium: 1, SeverityLow: 2, SeverityInfo: 3}
+
+// SPFLookupLimit is the number of DNS-querying SPF terms an evaluation
+// may use before it fails with a permerror (RFC 7208 section 4.6.4).
+const SPFLookupLimit = 10
+
+// spfVoidLimit is the number of lookups returning nothing an SPF
+// evaluation tolerates.
+const spfVoidLimit = 2
+
+// DKIMSelectors are the selectors probed for DKIM keys when
+// EmailOptions.Selectors is empty: generic names and those used by
+// common mail providers.
+var DKIMSelectors = []string{
+	"default", "dkim", "mail", "email", "selector1", "selector2", "google",
+	"k1", "k2", "k3", "s1", "s2", "smtp", "key1", "key2", "sig1",
+	"mandrill", "mailjet", "zoho", "protonmail", "protonmail2", "protonmail3",
+	"fm1", "fm2", "fm3", "mxvault", "everlytickey1", "everlytickey2",
+	"cm", "mta", "pm", "smtpapi",
+}
+
+// EmailOptions configures an EmailPosture check.
+type EmailOptions struct {
+	// ResolverOptions.Timeout also bounds the MTA-STS policy fetch.
+	ResolverOptions
+	// Selectors are the DKIM selectors to probe; empty means
+	// DKIMSelectors.
+	Selectors []string
+	// HTTPClient fetches the MTA-STS policy; nil means a client with
+	// Timeout.
+	HTTPClient *http.Client
+}
+
+// EmailResult is the email security posture of a domain.
+type EmailResult struct {
+	Domain string `json:"domain"`
+	// MX are the mail exchangers, most preferred first.
+	MX       []string      `json:"mx"`
+	SPF      *SPFResult    `json:"spf"`
+	DMARC    *DMARCResult  `json:"dmarc"`
+	DKIM     []DKIMKey     `json:"dkim"`
+	MTASTS   *MTASTSResult `json:"mta_sts"`
+	TLSRPT   *TLSRPTResult `json:"tls_rpt"`
+	BIMI     *BIMIResult   `json:"bimi"`
+	Findings []Finding     `json:"findings"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+// Finding is one graded observation.
+type Finding struct {
+	Severity string `json:"severity"`
+	// Check is what it concerns: "spf", "dmarc", "dkim", "mta-sts",
+	// "tls-rpt", "bimi" or "mx" for EmailPosture; "delegation", "soa",
+	// "lame", "ipv6", "diversity" or "recursion" for CheckDelegation.
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// SPFResult is a domain's SPF policy with every include and redirect
+// expanded.
+type SPFResult struct {
+	Record string `json:"record"`
+	// All is the qualified all mechanism that ends evaluation, e.g.
+	// "-all", taken from a redirect target if the record has none.
+	All string `json:"all,omitempty"`
+	// Lookups counts the terms that cost a DNS lookup, across every
+	// expanded record.
+	Lookups int `json:"lookups"`
+	// VoidLookups counts the a and mx terms that find no records and the
+	// includes and redirects to domains without an SPF record.
+	VoidLookups int `json:"void_lookups"`
+	// Records are the domain's record followed by every record it
+	// includes or redirects to, depth first.
+	Records []SPFRecord `json:"records"`
+}
+
+// SPFRecord is one SPF record in the expansion.
+type SPFRecord struct {
+	Domain string `json:"domain"`
+	// Via is "include" or "redirect" for expanded records, empty for
+	// the domain's own.
+	Via    string    `json:"via,omitempty"`
+	Depth  int       `json:"depth"`
+	Record string    `json:"record,omitempty"`
+	Terms  []SPFTerm `json:"terms,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// SPFTerm is a mechanism or modifier of an SPF record.
+type SPFTerm struct {
+	// Qualifier is "+", "-", "~" or "?" for mechanisms, empty for
+	// modifiers.
+	Qualifier string `json:"qualifier,omitempty"`
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+}
+
+// DMARCResult is a domain's DMARC policy.
+type DMARCResult struct {
+	Record string `json:"record"`
+	// Policy is the p= tag; SubdomainPolicy the sp= tag, defaulting to
+	// Policy.
+	Policy          string `json:"policy"`
+	SubdomainPolicy string `json:"subdomain_policy"`
+	Percent         int    `json:"percent"`
+	// Alignment modes for DKIM and SPF: "r" (relaxed) or "s" (strict).
+	DKIMAlignment string `json:"dkim_alignment"`
+	SPFAlignment  string `json:"spf_alignment"`
+	// AggregateReports (rua) and ForensicReports (ruf) are the report
+	// destination URIs.
+	AggregateReports []string          `json:"aggregate_reports"`
+	ForensicReports  []string          `json:"forensic_reports"`
+	Tags             map[string]string `json:"tags"`
+	// OrganizationalDomain is set when the domain has no record of its
+	// own and the record is that of its organizational domain, whose
+	// subdomain policy then applies (RFC 7489 section 6.6.3).
+	OrganizationalDomain string `json:"organizational_domain,omitempty"`
+}
+
+// applied returns the policy receivers apply to the domain itself.
+func (d *DMARCResult) applied() string {
+	if d.OrganizationalDomain != "" {
+		return d.SubdomainPolicy
+	}
+	return d.Policy
+}
+
+// DKIMKey is a key published under a DKIM selector.
+type DKIMKey struct {
+	Selector string `json:"selector"`
+	Record   string `json:"record"`
+	KeyType  string `json:"key_type"`
+	// Bits is the RSA modulus size.
+	Bits int `json:"bits,omitempty"`
+	// Revoked is set for an empty p= tag.
+	Revoked bool `json:"revoked"`
+	// Testing is set by the t=y flag.
+	Testing bool `json:"testing"`
+}
+
+// MTASTSResult is a domain's MTA-STS record and policy.
+type MTASTSResult struct {
+	Record string `json:"record"`
+	ID     string `json:"id"`
+	// PolicyURL is where the policy was fetched from.
+	PolicyURL string   `json:"policy_url"`
+	Mode      string   `json:"mode,omitempty"`
+	MX        []string `json:"mx,omitempty"`
+	MaxAge    int      `json:"max_age,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// TLSRPTResult is a domain's SMTP TLS reporting record.
+type TLSRPTResult struct {
+	Record  string   `json:"record"`
+	Reports []string `json:"reports"`
+}
+
+// BIMIResult is a domain's default BIMI record.
+type BIMIResult struct {
+	Record string `json:"record"`
+	// Logo is the l= SVG location; Certificate the a= mark certificate.
+	Logo        string `json:"logo,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// EmailPosture checks the records that protect mail for domain: SPF
+// (with includes expanded against the lookup limit), DMARC, DKIM keys at
+// common selectors, MTA-STS with its policy file, TLS-RPT and BIMI, and
+// grades what it finds. Missing records become findings, not errors.
+func EmailPosture(ctx context.Context, domain string, opts EmailOptions) (*EmailResult, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	selectors := opts.Selectors
+	if len(selectors) == 0 {
+		selectors = DKIMSelectors
+	}
+
+	p := &posture{
+		ctx:  ctx,
+		c:    client,
+		http: httpClient,
+		r: &EmailResult{
+			Domain:   strings.TrimSuffix(dnswire.CanonicalName(domain), "."),
+			MX:       []string{},
+			DKIM:     []DKIMKey{},
+			Findings: []Finding{},
+		},
+	}
+	p.checkMX()
+	p.checkSPF()
+	p.checkDMARC()
+	p.checkDKIM(selectors)
+	p.checkMTASTS()
+	p.checkTLSRPT()
+	p.checkBIMI()
+	if err := ctx.Err(); err != nil {
+		return p.r, err
+	}
+
+	sort.SliceStable(p.r.Findings, func(i, j int) bool {
+		return severityRank[p.r.Findings[i].Severity] < severityRank[p.r.Findings[j].Severity]
+	})
+	return p.r, nil
+}
+
+type posture struct {
+	ctx  context.Context
+	c    *Client
+	http *http.Client
+	r    *EmailResult
+}
+
+func (p *posture) find(severity, check, format string, args ...interface{}) {
+	p.r.Findings = append(p.r.Findings, Finding{Severity: severity, Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+// txt returns the TXT strings at name that start with prefix
+// (case-insensitively). A name that does not exist has none.
+func (p *posture) txt(name, prefix string) ([]string, error) {
+	resp, err := p.c.Query(p.ctx, name, dnswire.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Msg.Rcode != dnswire.RcodeSuccess && resp.Msg.Rcode != dnswire.RcodeNameError {
+		return nil, fmt.Errorf("%s", dnswire.RcodeToString[resp.Msg.Rcode])
+	}
+	var records []string
+	for _, rec := range answerRecords(resp.Msg, dnswire.TypeTXT) {
+		if len(rec.Data) >= len(prefix) && strings.EqualFold(rec.Data[:len(prefix)], prefix) {
+			records = append(records, rec.Data)
+		}
+	}
+	return records, nil
+}
+
+// spfRecords returns the SPF records at name: those starting with the
+// version v=spf1 followed by a space or nothing (RFC 7208 section 4.5),
+// so a record such as "v=spf10" is not taken for one.
+func (p *posture) spfRecords(name string) ([]string, error) {
+	txt, err := p.txt(name, "v=spf1")
+	var records []string
+	for _, rec := range txt {
+		if len(rec) == len("v=spf1") || rec[len("v=spf1")] == ' ' {
+			records = append(records, rec)
+		}
+	}
+	return records, err
+}
+
+// lookupError records a failed query; the check it belongs to is then
+// skipped rather than reported as missing.
+func (p *posture) lookupError(what string, err error) {
+	p.r.Errors = append(p.r.Errors, fmt.Sprintf("%s: %v", what, err))
+}
+
+func (p *posture) checkMX() {
+	resp, err := p.c.Query(p.ctx, p.r.Domain, dnswire.TypeMX)
+	if err != nil {
+		p.lookupError("MX", err)
+		return
+	}
+	for _, rec := range answerRecords(resp.Msg, dnswire.TypeMX) {
+		_, host, _ := strings.Cut(rec.Data, " ")
+		p.r.MX = append(p.r.MX, strings.TrimSuffix(host, "."))
+	}
+	switch {
+	case len(p.r.MX) == 1 && p.r.MX[0] == "":
+		p.find(SeverityInfo, "mx", "null MX record: the domain accepts no mail (RFC 7505)")
+	case len(p.r.MX) == 0:
+		p.find(SeverityInfo, "mx", "no MX records; mail is delivered to the domain's address records, if any")
+	}
+}
+
+func (p *posture) checkSPF() {
+	records, err := p.spfRecords(p.r.Domain)
+	if err != nil {
+		p.lookupError("SPF", err)
+		return
+	}
+	switch len(records) {
+	case 0:
+		p.find(SeverityHigh, "spf", "no SPF record; anyone can send mail as %s", p.r.Domain)
+		return
+	case 1:
+	default:
+		p.find(SeverityHigh, "spf", "%d SPF records published; receivers treat this as a permanent error", len(records))
+	}
+
+	spf := &SPFResult{Record: records[0]}
+	p.r.SPF = spf
+	spf.All = p.expandSPF(spf, p.r.Domain, records[0], "", 0, map[string]bool{})
+
+	if spf.All == "" {
+		p.find(SeverityMedium, "spf", "SPF record has no all mechanism; unmatched senders get a neutral result")
+	}
+	switch spf.All {
+	case "+all":
+		p.find(SeverityHigh, "spf", "SPF ends in +all, which authorizes every server on the internet")
+	case "?all":
+		p.find(SeverityHigh, "spf", "SPF ends in ?all (neutral); unauthorized senders are not rejected")
+	case "~all":
+		p.find(SeverityMedium, "spf", "SPF ends in ~all (softfail); unauthorized mail is usually accepted and only marked")
+	case "-all":
+		p.find(SeverityInfo, "spf", "SPF ends in -all (hard fail)")
+	}
+	if spf.Lookups > SPFLookupLimit {
+		p.find(SeverityHigh, "spf", "SPF needs %d DNS lookups, over the limit of %d; receivers return a permanent error", spf.Lookups, SPFLookupLimit)
+	} else if spf.Lookups >= SPFLookupLimit-2 {
+		p.find(SeverityLow, "spf", "SPF needs %d of the %d allowed DNS lookups", spf.Lookups, SPFLookupLimit)
+	}
+	if spf.VoidLookups > spfVoidLimit {
+		p.find(SeverityMedium, "spf", "SPF has %d lookups that return nothing, over the limit of %d", spf.VoidLookups, spfVoidLimit)
+	}
+}
+
+// expandSPF parses record and follows its include and redirect terms,
+// counting DNS lookups, and returns the all term evaluation of record
+// ends with: its own or, failing that, its redirect target's. path holds the domains being expanded on the way
+// down to this one: only a record including one of those loops, while a
+// domain reached through two includes is expanded, and counted, twice.
+func (p *posture) expandSPF(spf *SPFResult, domain, record, via string, depth int, path map[string]bool) string {
+	key := strings.ToLower(domain)
+	path[key] = true
+	defer delete(path, key)
+	node := SPFRecord{Domain: domain, Via: via, Depth: depth, Record: record, Terms: parseSPF(record)}
+	spf.Records = append(spf.Records, node)
+
+	all := ""
+	redirect := ""
+	for _, t := range node.Terms {
+		switch t.Name {
+		case "all":
+			all = t.Qualifier + "all"
+		case "a", "mx":
+			spf.Lookups++
+			if p.spfVoid(domain, t) {
+				spf.VoidLookups++
+			}
+		case "exists":
+			spf.Lookups++
+		case "ptr":
+			spf.Lookups++
+			p.find(SeverityLow, "spf", "%s uses the ptr mechanism, which RFC 7208 says not to use", domain)
+		case "include":
+			spf.Lookups++
+			p.followSPF(spf, t.Value, "include", depth, path)
+		case "redirect":
+			redirect = t.Value
+		}
+	}
+	if redirect != "" && all == "" {
+		spf.Lookups++
+		all = p.followSPF(spf, redirect, "redirect", depth, path)
+	}
+	return all
+}
+
+// spfVoid reports whether the a or mx term t of domain's record looks up
+// a name with no address or MX records, a void lookup. Failed queries
+// and targets with macros are not counted.
+func (p *posture) spfVoid(domain string, t SPFTerm) bool {
+	target, _, _ := strings.Cut(t.Value, "/")
+	if target == "" {
+		target = domain
+	}
+	if strings.Contains(target, "%") {
+		return false
+	}
+	qtypes := []uint16{dnswire.TypeMX}
+	if t.Name == "a" {
+		qtypes = []uint16{dnswire.TypeA, dnswire.TypeAAAA}
+	}
+	for _, qtype := range qtypes {
+		resp, err := p.c.Query(p.ctx, target, qtype)
+		if err != nil || (resp.Msg.Rcode != dnswire.RcodeSuccess && resp.Msg.Rcode != dnswire.RcodeNameError) {
+			return false
+		}
+		if len(answerRecords(resp.Msg, qtype)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// followSPF expands the SPF record of target and returns the all term
+// it ends with, through its own redirect if it has one.
+func (p *posture) followSPF(spf *SPFResult, target, via string, depth int, path map[string]bool) string {
+	if strings.Contains(target, "%") {
+		// Macros depend on the sender, so the target can't be resolved here
+		spf.Records = append(spf.Records, SPFRecord{Domain: target, Via: via, Depth: depth + 1, Error: "macro not expanded"})
+		return ""
+	}
+	if path[strings.ToLower(target)] || depth >= SPFLookupLimit {
+		spf.Records = append(spf.Records, SPFRecord{Domain: target, Via: via, Depth: depth + 1, Error: "loop or nesting too deep"})
+		p.find(SeverityHigh, "spf", "SPF %s of %s loops back or nests too deep", via, target)
+		return ""
+	}
+	records, err := p.spfRecords(target)
+	if err != nil {
+		spf.Records = append(spf.Records, SPFRecord{Domain: target, Via: via, Depth: depth + 1, Error: err.Error()})
+		return ""
+	}
+	if len(records) == 0 {
+		spf.VoidLookups++
+	}
+	if len(records) != 1 {
+		spf.Records = append(spf.Records, SPFRecord{Domain: target, Via: via, Depth: depth + 1, Error: fmt.Sprintf("%d SPF records", len(records))})
+		p.find(SeverityHigh, "spf", "SPF %s:%s has %d SPF records; receivers return a permanent error", via, target, len(records))
+		return ""
+	}
+
+	return p.expandSPF(spf, target, records[0], via, depth+1, path)
+}
+
+// parseSPF splits an SPF record into its terms, skipping the version.
+func parseSPF(record string) []SPFTerm {
+	var terms []SPFTerm
+	for _, field := range strings.Fields(record)[1:] {
+		lower := strings.ToLower(field)
+		if i := strings.IndexAny(lower, "=:/"); i > 0 && lower[i] == '=' {
+			terms = append(terms, SPFTerm{Name: lower[:i], Value: field[i+1:]})
+			continue
+		}
+		t := SPFTerm{Qualifier: "+"}
+		if strings.ContainsAny(field[:1], "+-~?") {
+			t.Qualifier = field[:1]
+			field, lower = field[1:], lower[1:]
+		}
+		i := strings.IndexAny(lower, ":/")
+		if i < 0 {
+			t.Name = lower
+		} else {
+			t.Name = lower[:i]
+			t.Value = strings.TrimPrefix(field[i:], ":")
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// parseTags splits a DMARC, DKIM, MTA-STS, TLS-RPT or BIMI record into
+// its tag=value pairs. Tag names are lowercased.
+func parseTags(record string) map[string]string {
+	tags := map[string]string{}
+	for _, part := range strings.Split(record, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return tags
+}
+
+func splitURIs(value string) []string {
+	uris := []string{}
+	for _, u := range strings.Split(value, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			uris = append(uris, u)
+		}
+	}
+	return uris
+}
+
+func (p *posture) checkDMARC() {
+	records, err := p.txt("_dmarc."+p.r.Domain, "v=DMARC1")
+	if err != nil {
+		p.lookupError("DMARC", err)
+		return
+	}
+	org := ""
+	if len(records) == 0 {
+		// Receivers fall back to the organizational domain's record
+		// (RFC 7489 section 6.6.3).
+		if o, err := publicsuffix.EffectiveTLDPlusOne(p.r.Domain); err == nil && o != p.r.Domain {
+			org = o
+			if records, err = p.txt("_dmarc."+org, "v=DMARC1"); err != nil {
+				p.lookupError("DMARC", err)
+				return
+			}
+		}
+	}
+	if len(records) == 0 {
+		if org != "" {
+			p.find(SeverityHigh, "dmarc", "no DMARC record at _dmarc.%s or _dmarc.%s; receivers apply no policy to spoofed mail", p.r.Domain, org)
+		} else {
+			p.find(SeverityHigh, "dmarc", "no DMARC record at _dmarc.%s; receivers apply no policy to spoofed mail", p.r.Domain)
+		}
+		return
+	}
+	if len(records) > 1 {
+		p.find(SeverityHigh, "dmarc", "%d DMARC records published; receivers ignore them all", len(records))
+	}
+
+	tags := parseTags(records[0])
+	d := &DMARCResult{
+		Record:               records[0],
+		Policy:               strings.ToLower(tags["p"]),
+		SubdomainPolicy:      strings.ToLower(tags["sp"]),
+		Percent:              100,
+		DKIMAlignment:        "r",
+		SPFAlignment:         "r",
+		AggregateReports:     splitURIs(tags["rua"]),
+		ForensicReports:      splitURIs(tags["ruf"]),
+		Tags:                 tags,
+		OrganizationalDomain: org,
+	}
+	p.r.DMARC = d
+	if d.SubdomainPolicy == "" {
+		d.SubdomainPolicy = d.Policy
+	}
+	if pct, err := strconv.Atoi(tags["pct"]); err == nil {
+		d.Percent = pct
+	}
+	if v := strings.ToLower(tags["adkim"]); v != "" {
+		d.DKIMAlignment = v
+	}
+	if v := strings.ToLower(tags["aspf"]); v != "" {
+		d.SPFAlignment = v
+	}
+
+	tag := "p"
+	if org != "" {
+		p.find(SeverityInfo, "dmarc", "no DMARC record at _dmarc.%s; the record of the organizational domain %s applies", p.r.Domain, org)
+		if _, ok := tags["sp"]; ok {
+			tag = "sp"
+		}
+	}
+	switch d.applied() {
+	case "reject":
+		p.find(SeverityInfo, "dmarc", "DMARC policy %s=reject", tag)
+	case "quarantine":
+		p.find(SeverityLow, "dmarc", "DMARC policy %s=quarantine sends spoofed mail to spam rather than rejecting it", tag)
+	case "none":
+		p.find(SeverityMedium, "dmarc", "DMARC policy %s=none only monitors; spoofed mail is still delivered", tag)
+	default:
+		p.find(SeverityHigh, "dmarc", "DMARC record has no valid %s= policy (%q)", tag, tags[tag])
+	}
+	if org == "" && d.SubdomainPolicy == "none" && d.Policy != "none" {
+		p.find(SeverityMedium, "dmarc", "DMARC sp=none leaves subdomains unprotected")
+	}
+	if d.Percent < 100 {
+		p.find(SeverityLow, "dmarc", "DMARC pct=%d applies the policy to only part of the failing mail", d.Percent)
+	}
+	if len(d.AggregateReports) == 0 {
+		p.find(SeverityLow, "dmarc", "DMARC has no rua= address, so no aggregate reports are received")
+	}
+}
+
+func (p *posture) checkDKIM(selectors []string) {
+	keys := make([]*DKIMKey, len(selectors))
+	errs := make([]error, len(selectors))
+	forEach(p.ctx, len(selectors), func(i int) {
+		var records []string
+		records, errs[i] = p.txt(selectors[i]+"._domainkey."+p.r.Domain, "")
+		for _, rec := range records {
+			tags := parseTags(rec)
+			if _, ok := tags["p"]; !ok {
+				continue
+			}
+			keys[i] = dkimKey(selectors[i], rec, tags)
+			break
+		}
+	})
+	for i, k := range keys {
+		if errs[i] != nil {
+			p.lookupError("DKIM "+selectors[i], errs[i])
+		}
+		if k == nil {
+			continue
+		}
+		p.r.DKIM = append(p.r.DKIM, *k)
+		switch {
+		case k.Revoked:
+			p.find(SeverityInfo, "dkim", "DKIM selector %s is revoked (empty key)", k.Selector)
+		case k.KeyType == "rsa" && k.Bits > 0 && k.Bits < 1024:
+			p.find(SeverityHigh, "dkim", "DKIM selector %s uses a %d-bit RSA key, which can be factored", k.Selector, k.Bits)
+		case k.KeyType == "rsa" && k.Bits > 0 && k.Bits < 2048:
+			p.find(SeverityLow, "dkim", "DKIM selector %s uses a %d-bit RSA key; 2048 bits is recommended", k.Selector, k.Bits)
+		}
+		if k.Testing {
+			p.find(SeverityLow, "dkim", "DKIM selector %s is in testing mode (t=y)", k.Selector)
+		}
+	}
+	if len(p.r.DKIM) == 0 {
+		p.find(SeverityLow, "dkim", "no DKIM key found at %d common selectors (a custom selector may still be in use)", len(selectors))
+	}
+}
+
+func dkimKey(selector, record string, tags map[string]string) *DKIMKey {
+	k := &DKIMKey{Selector: selector, Record: record, KeyType: strings.ToLower(tags["k"])}
+	if k.KeyType == "" {
+		k.KeyType = "rsa"
+	}
+	for _, flag := range strings.Split(tags["t"], ":") {
+		if strings.TrimSpace(flag) == "y" {
+			k.Testing = true
+		}
+	}
+	data := strings.Join(strings.Fields(tags["p"]), "")
+	if data == "" {
+		k.Revoked = true
+		return k
+	}
+	if k.KeyType == "rsa" {
+		der, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return k
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			pub, err = x509.ParsePKCS1PublicKey(der)
+		}
+		if rsaKey, ok := pub.(*rsa.PublicKey); ok && err == nil {
+			k.Bits = rsaKey.N.BitLen()
+		}
+	}
+	return k
+}
+
+func (p *posture) checkMTASTS() {
+	records, err := p.txt("_mta-sts."+p.r.Domain, "v=STSv1")
+	if err != nil {
+		p.lookupError("MTA-STS", err)
+		return
+	}
+	if len(records) == 0 {
+		p.find(SeverityLow, "mta-sts", "no MTA-STS record; mail to %s can be downgraded to plaintext in transit", p.r.Domain)
+		return
+	}
+	tags := parseTags(records[0])
+	m := &MTASTSResult{
+		Record:    records[0],
+		ID:        tags["id"],
+		PolicyURL: "https://mta-sts." + p.r.Domain + "/.well-known/mta-sts.txt",
+	}
+	p.r.MTASTS = m
+	if err := p.fetchMTASTSPolicy(m); err != nil {
+		m.Error = err.Error()
+		p.find(SeverityMedium, "mta-sts", "MTA-STS record exists but the policy could not be fetched: %v", err)
+		return
+	}
+
+	switch m.Mode {
+	case "enforce":
+		p.find(SeverityInfo, "mta-sts", "MTA-STS policy is enforced")
+	case "testing":
+		p.find(SeverityLow, "mta-sts", "MTA-STS policy is in testing mode; TLS failures are only reported")
+	case "none":
+		p.find(SeverityMedium, "mta-sts", "MTA-STS policy mode is none; the policy is withdrawn")
+	default:
+		p.find(SeverityMedium, "mta-sts", "MTA-STS policy has an invalid mode %q", m.Mode)
+	}
+	for _, mx := range p.r.MX {
+		if mx != "" && !mtaSTSMatch(m.MX, mx) {
+			p.find(SeverityMedium, "mta-sts", "MX %s is not covered by the MTA-STS policy; delivery to it fails when enforced", mx)
+		}
+	}
+}
+
+// fetchMTASTSPolicy downloads and parses the policy file (RFC 8461
+// section 3.2). A redirect is not followed but fails the fetch, as
+// senders must treat it (section 3.3).
+func (p *posture) fetchMTASTSPolicy(m *MTASTSResult) error {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, m.PolicyURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "afsa")
+	client := *p.http
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return fmt.Errorf("HTTP %s redirects to %q; the policy must be served without redirects", resp.Status, resp.Header.Get("Location"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	sc := bufio.NewScanner(io.LimitReader(resp.Body, 64<<10))
+	version := ""
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "version":
+			version = value
+		case "mode":
+			m.Mode = value
+		case "mx":
+			m.MX = append(m.MX, value)
+		case "max_age":
+			m.MaxAge, _ = strconv.Atoi(value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if version != "STSv1" {
+		return fmt.Errorf("policy version is %q, not STSv1", version)
+	}
+	return nil
+}
+
+// mtaSTSMatch reports whether host matches one of the policy's mx
+// patterns, where "*." matches a single leftmost label.
+func mtaSTSMatch(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if wildcard, ok := strings.CutPrefix(pattern, "*."); ok {
+			if _, rest, ok := strings.Cut(host, "."); ok && rest == wildcard {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *posture) checkTLSRPT() {
+	records, err := p.txt("_smtp._tls."+p.r.Domain, "v=TLSRPTv1")
+	if err != nil {
+		p.lookupError("TLS-RPT", err)
+		return
+	}
+	if len(records) == 0 {
+		p.find(SeverityLow, "tls-rpt", "no TLS-RPT record; delivery TLS failures go unreported")
+		return
+	}
+	p.r.TLSRPT = &TLSRPTResult{Record: records[0], Reports: splitURIs(parseTags(records[0])["rua"])}
+	if len(p.r.TLSRPT.Reports) == 0 {
+		p.find(SeverityLow, "tls-rpt", "TLS-RPT record has no rua= destination")
+	}
+}
+
+func (p *posture) checkBIMI() {
+	records, err := p.txt("default._bimi."+p.r.Domain, "v=BIMI1")
+	if err != nil {
+		p.lookupError("BIMI", err)
+		return
+	}
+	if len(records) == 0 {
+		p.find(SeverityInfo, "bimi", "no BIMI record; mail clients show no brand logo")
+		return
+	}
+	tags := parseTags(records[0])
+	p.r.BIMI = &BIMIResult{Record: records[0], Logo: tags["l"], Certificate: tags["a"]}
+	if p.r.DMARC == nil || (p.r.DMARC.applied() != "quarantine" && p.r.DMARC.applied() != "reject") {
+		p.find(SeverityMedium, "bimi", "BIMI requires DMARC p=quarantine or p=reject; the logo will not be shown")
+	}
+	if p.r.BIMI.Certificate == "" {
+		p.find(SeverityInfo, "bimi", "BIMI has no mark certificate (a=); some mailbox providers require one")
+	}
+}
@@ -0,0 +1,333 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// emailPosture checks domain against a server holding records, fetching
+// MTA-STS policies from policy whatever host they are asked from.
+func emailPosture(t *testing.T, domain string, records []string, policy http.HandlerFunc) *EmailResult {
+	t.Helper()
+	srv := newServer(t)
+	srv.Add(records...)
+	opts := EmailOptions{ResolverOptions: ResolverOptions{Resolvers: []string{"192.0.2.53"}, Exchanger: srv}, Selectors: []string{"default"}}
+	if policy != nil {
+		ts := httptest.NewUnstartedServer(policy)
+		ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+		ts.StartTLS()
+		t.Cleanup(ts.Close)
+		client := ts.Client()
+		transport := client.Transport.(*http.Transport).Clone()
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, ts.Listener.Addr().String())
+		}
+		client.Transport = transport
+		opts.HTTPClient = client
+	}
+	r, err := EmailPosture(context.Background(), domain, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 0 {
+		t.Fatalf("Errors = %q", r.Errors)
+	}
+	return r
+}
+
+// findings returns the messages of r's findings for check.
+func findings(r *EmailResult, check string) []string {
+	var msgs []string
+	for _, f := range r.Findings {
+		if f.Check == check {
+			msgs = append(msgs, f.Severity+": "+f.Message)
+		}
+	}
+	return msgs
+}
+
+func hasFinding(r *EmailResult, check, substr string) bool {
+	for _, msg := range findings(r, check) {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSPFVersion(t *testing.T) {
+	tests := []struct {
+		record string
+		found  bool
+	}{
+		{record: "v=spf1 -all", found: true},
+		{record: "V=SPF1 -all", found: true},
+		{record: "v=spf1", found: true},
+		{record: "v=spf10 -all"},
+		{record: "v=spf1x -all"},
+		{record: "v=spf1-all"},
+	}
+	for _, tt := range tests {
+		r := emailPosture(t, "example.com", []string{`example.com. 300 IN TXT "` + tt.record + `"`}, nil)
+		if found := r.SPF != nil; found != tt.found {
+			t.Errorf("%q: SPF found %v, want %v", tt.record, found, tt.found)
+		}
+		if !tt.found && !hasFinding(r, "spf", "no SPF record") {
+			t.Errorf("%q: findings %q, want no SPF record reported", tt.record, findings(r, "spf"))
+		}
+	}
+
+	// Another record beside the SPF one is no second SPF record.
+	r := emailPosture(t, "example.com", []string{
+		`example.com. 300 IN TXT "v=spf1 -all"`,
+		`example.com. 300 IN TXT "v=spf10 +all"`,
+	}, nil)
+	if r.SPF == nil || r.SPF.All != "-all" || hasFinding(r, "spf", "SPF records published") {
+		t.Errorf("SPF = %+v, findings %q, want the one v=spf1 record", r.SPF, findings(r, "spf"))
+	}
+}
+
+func TestSPFIncludes(t *testing.T) {
+	// a and b both include shared: a diamond, not a loop.
+	diamond := []string{
+		`example.com. 300 IN TXT "v=spf1 include:a.example.net include:b.example.net -all"`,
+		`a.example.net. 300 IN TXT "v=spf1 include:shared.example.net ~all"`,
+		`b.example.net. 300 IN TXT "v=spf1 include:shared.example.net ~all"`,
+		`shared.example.net. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 mx ~all"`,
+	}
+	r := emailPosture(t, "example.com", diamond, nil)
+	if hasFinding(r, "spf", "loops") {
+		t.Errorf("findings %q, want no loop", findings(r, "spf"))
+	}
+	var expanded []string
+	for _, rec := range r.SPF.Records {
+		expanded = append(expanded, rec.Domain)
+		if rec.Error != "" {
+			t.Errorf("%s: %s", rec.Domain, rec.Error)
+		}
+	}
+	want := "example.com a.example.net shared.example.net b.example.net shared.example.net"
+	if got := strings.Join(expanded, " "); got != want {
+		t.Errorf("expanded %s, want %s", got, want)
+	}
+	// Every include and the shared mx are counted each time they're met.
+	if r.SPF.Lookups != 6 || r.SPF.All != "-all" {
+		t.Errorf("Lookups = %d, All = %s, want 6 and -all", r.SPF.Lookups, r.SPF.All)
+	}
+
+	loops := []string{
+		`example.com. 300 IN TXT "v=spf1 include:a.example.net -all"`,
+		`a.example.net. 300 IN TXT "v=spf1 include:b.example.net ~all"`,
+		`b.example.net. 300 IN TXT "v=spf1 include:a.example.net ~all"`,
+	}
+	r = emailPosture(t, "example.com", loops, nil)
+	if !hasFinding(r, "spf", "SPF include of a.example.net loops") {
+		t.Errorf("findings %q, want the loop reported", findings(r, "spf"))
+	}
+
+	self := []string{`example.com. 300 IN TXT "v=spf1 mx redirect=example.com"`}
+	r = emailPosture(t, "example.com", self, nil)
+	if !hasFinding(r, "spf", "SPF redirect of example.com loops") {
+		t.Errorf("findings %q, want the redirect loop reported", findings(r, "spf"))
+	}
+}
+
+func TestSPFVoidLookups(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		void    int
+		finding string
+	}{
+		{
+			name: "a and mx with records",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 a mx a:www.example.com/24 mx:example.net -all"`,
+				`example.com. 300 IN A 192.0.2.1`,
+				`example.com. 300 IN MX 10 mail.example.com.`,
+				`www.example.com. 300 IN AAAA 2001:db8::1`,
+				`example.net. 300 IN MX 10 mail.example.net.`,
+			},
+		},
+		{
+			name: "a and mx finding nothing",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 a mx a:gone.example.com//64 mx:example.net -all"`,
+				`example.com. 300 IN A 192.0.2.1`,
+				`example.net. 300 IN A 192.0.2.2`,
+			},
+			void:    3,
+			finding: "medium: SPF has 3 lookups that return nothing, over the limit of 2",
+		},
+		{
+			name: "include without SPF",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 include:example.net include:gone.example.net -all"`,
+				`example.net. 300 IN TXT "google-site-verification=x"`,
+			},
+			void:    2,
+			finding: "high: SPF include:example.net has 0 SPF records",
+		},
+		{
+			// Two records are a permanent error, not a void lookup.
+			name: "include with two SPF records",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 include:example.net -all"`,
+				`example.net. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 -all"`,
+				`example.net. 300 IN TXT "v=spf1 ip4:198.51.100.0/24 -all"`,
+			},
+			finding: "high: SPF include:example.net has 2 SPF records",
+		},
+		{
+			name:    "macro",
+			records: []string{`example.com. 300 IN TXT "v=spf1 a:%{d}.spf.example.net -all"`},
+		},
+	}
+	for _, tt := range tests {
+		r := emailPosture(t, "example.com", tt.records, nil)
+		if r.SPF == nil || r.SPF.VoidLookups != tt.void {
+			t.Errorf("%s: SPF = %+v, want %d void lookups", tt.name, r.SPF, tt.void)
+			continue
+		}
+		if tt.finding != "" && !hasFinding(r, "spf", tt.finding) {
+			t.Errorf("%s: findings %q, want %q", tt.name, findings(r, "spf"), tt.finding)
+		}
+		if tt.finding == "" && hasFinding(r, "spf", "return nothing") {
+			t.Errorf("%s: findings %q, want no void lookups reported", tt.name, findings(r, "spf"))
+		}
+	}
+}
+
+func TestSPFRedirectAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		all     string
+	}{
+		{
+			name: "redirect",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 redirect=a.example.net"`,
+				`a.example.net. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 ~all"`,
+			},
+			all: "~all",
+		},
+		{
+			name: "nested redirect",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 redirect=a.example.net"`,
+				`a.example.net. 300 IN TXT "v=spf1 include:c.example.net redirect=b.example.net"`,
+				`b.example.net. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 -all"`,
+				`c.example.net. 300 IN TXT "v=spf1 ip4:198.51.100.0/24 ?all"`,
+			},
+			all: "-all",
+		},
+		{
+			// A record's own all wins over its redirect
+			name: "all and redirect",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 redirect=a.example.net"`,
+				`a.example.net. 300 IN TXT "v=spf1 ~all redirect=b.example.net"`,
+				`b.example.net. 300 IN TXT "v=spf1 -all"`,
+			},
+			all: "~all",
+		},
+		{
+			// An include's all applies to the include only
+			name: "redirect from an include",
+			records: []string{
+				`example.com. 300 IN TXT "v=spf1 include:a.example.net"`,
+				`a.example.net. 300 IN TXT "v=spf1 redirect=b.example.net"`,
+				`b.example.net. 300 IN TXT "v=spf1 -all"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := emailPosture(t, "example.com", tt.records, nil)
+		if r.SPF == nil || r.SPF.All != tt.all {
+			t.Errorf("%s: SPF = %+v, want all %q", tt.name, r.SPF, tt.all)
+		}
+		if noAll := hasFinding(r, "spf", "no all mechanism"); noAll != (tt.all == "") {
+			t.Errorf("%s: findings %q", tt.name, findings(r, "spf"))
+		}
+	}
+}
+
+func TestDMARCOrganizationalDomain(t *testing.T) {
+	org := `_dmarc.example.co.uk. 300 IN TXT "v=DMARC1; p=reject; sp=quarantine; rua=mailto:d@example.co.uk"`
+	tests := []struct {
+		name    string
+		domain  string
+		records []string
+		org     string
+		finding string
+	}{
+		{name: "own record", domain: "example.co.uk", records: []string{org}, finding: "info: DMARC policy p=reject"},
+		{name: "inherited", domain: "mail.example.co.uk", records: []string{org}, org: "example.co.uk", finding: "low: DMARC policy sp=quarantine"},
+		{name: "inherited without sp", domain: "a.b.example.com", org: "example.com", finding: "info: DMARC policy p=reject",
+			records: []string{`_dmarc.example.com. 300 IN TXT "v=DMARC1; p=reject; rua=mailto:d@example.com"`}},
+		{name: "subdomain's own record", domain: "mail.example.co.uk", finding: "medium: DMARC policy p=none",
+			records: []string{org, `_dmarc.mail.example.co.uk. 300 IN TXT "v=DMARC1; p=none; rua=mailto:d@example.co.uk"`}},
+		{name: "none anywhere", domain: "mail.example.co.uk", finding: "high: no DMARC record at _dmarc.mail.example.co.uk or _dmarc.example.co.uk"},
+		{name: "none at the organizational domain", domain: "example.co.uk", finding: "high: no DMARC record at _dmarc.example.co.uk;"},
+	}
+	for _, tt := range tests {
+		r := emailPosture(t, tt.domain, tt.records, nil)
+		if tt.org != "" && (r.DMARC == nil || r.DMARC.OrganizationalDomain != tt.org) {
+			t.Errorf("%s: DMARC = %+v, want inherited from %s", tt.name, r.DMARC, tt.org)
+		}
+		if tt.org == "" && r.DMARC != nil && r.DMARC.OrganizationalDomain != "" {
+			t.Errorf("%s: DMARC inherited from %s, want the domain's own", tt.name, r.DMARC.OrganizationalDomain)
+		}
+		if !hasFinding(r, "dmarc", tt.finding) {
+			t.Errorf("%s: findings %q, want %q", tt.name, findings(r, "dmarc"), tt.finding)
+		}
+	}
+}
+
+func TestMTASTSPolicy(t *testing.T) {
+	records := []string{
+		"example.com. 300 IN MX 10 mx1.example.com.",
+		`_mta-sts.example.com. 300 IN TXT "v=STSv1; id=20240101"`,
+	}
+	policy := "version: STSv1\nmode: enforce\nmx: mx1.example.com\nmax_age: 86400\n"
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		mode    string
+		err     string
+	}{
+		{name: "served", mode: "enforce", handler: func(w http.ResponseWriter, r *http.Request) {
+			if r.Host != "mta-sts.example.com" || r.URL.Path != "/.well-known/mta-sts.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, policy)
+		}},
+		{name: "redirected", err: `redirects to "https://mta-sts.example.com/policy.txt"`, handler: func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/policy.txt" {
+				io.WriteString(w, policy)
+				return
+			}
+			http.Redirect(w, r, "https://mta-sts.example.com/policy.txt", http.StatusFound)
+		}},
+		{name: "missing", err: "HTTP 404", handler: http.NotFound},
+	}
+	for _, tt := range tests {
+		r := emailPosture(t, "example.com", records, tt.handler)
+		m := r.MTASTS
+		if m == nil {
+			t.Fatalf("%s: no MTA-STS result", tt.name)
+		}
+		if m.Mode != tt.mode || tt.err == "" && m.Error != "" || !strings.Contains(m.Error, tt.err) {
+			t.Errorf("%s: mode %q, error %q, want %q and %q", tt.name, m.Mode, m.Error, tt.mode, tt.err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// dohMediaType is the content type of DNS messages over HTTPS.
+const dohMediaType = "application/dns-message"
+
+// newHTTPClient returns the client used for DNS over HTTPS with
+// tlsConfig (nil for the system roots).
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: queryConcurrency,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: DefaultTimeout,
+	}}
+}
+
+// tlsConfig returns the TLS settings for server, verifying its host name
+// or IP address unless the Client's TLSConfig names another.
+func (c *Client) tlsConfig(host string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSConfig != nil {
+		cfg = c.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// exchangeTLS sends m over DNS over TLS (RFC 7858) to addr, a host:port.
+func (c *Client) exchangeTLS(ctx context.Context, m *dnswire.Msg, addr string) (*dnswire.Msg, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	wc := &dnswire.Client{Net: "tcp-tls", Timeout: c.timeout(), TLSConfig: c.tlsConfig(host)}
+	reply, _, err := wc.ExchangeContext(ctx, m, addr)
+	return reply, err
+}
+
+// exchangeHTTPS sends m over DNS over HTTPS (RFC 8484) to the URL, as a
+// GET request with the message ID set to zero so caches can share the
+// answer.
+func (c *Client) exchangeHTTPS(ctx context.Context, m *dnswire.Msg, endpoint string) (*dnswire.Msg, error) {
+	q := m.Copy()
+	q.Id = 0
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	query.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", dohMediaType)
+	req.Header.Set("User-Agent", "afsa")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = newHTTPClient(c.TLSConfig)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != dohMediaType {
+		return nil, fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dnswire.MaxMsgSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(dnswire.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, fmt.Errorf("invalid DNS message: %w", err)
+	}
+	if len(reply.Question) != 1 || !strings.EqualFold(reply.Question[0].Name, m.Question[0].Name) {
+		return nil, fmt.Errorf("reply is for a different question")
+	}
+	reply.Id = m.Id
+	return reply, nil
+}
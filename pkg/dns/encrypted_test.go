@@ -0,0 +1,213 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+// dohServer serves srv's answers over DNS over HTTPS at /dns-query,
+// passing each decoded query to inspect first.
+func dohServer(t *testing.T, srv *dnstest.Server, inspect func(*http.Request, *dnswire.Msg)) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dns-query" {
+			http.NotFound(w, r)
+			return
+		}
+		b, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		req := new(dnswire.Msg)
+		if err == nil {
+			err = req.Unpack(b)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if inspect != nil {
+			inspect(r, req)
+		}
+		reply, _ := srv.Reply(req, "doh", "https")
+		packed, err := reply.Pack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(packed)
+	}))
+	// Tests make clients that don't trust the server fail the handshake.
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// testTLSConfig trusts the certificate of ts, which covers 127.0.0.1.
+func testTLSConfig(ts *httptest.Server) *tls.Config {
+	return &tls.Config{RootCAs: ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+}
+
+func TestLookupHTTPS(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN A 192.0.2.1")
+	var mu sync.Mutex
+	var requests []string
+	ts := dohServer(t, srv, func(r *http.Request, m *dnswire.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, strings.Join([]string{r.Method, r.Header.Get("Accept"), fmt.Sprint(m.Id)}, " "))
+	})
+
+	endpoint := strings.Replace(ts.URL, "https://", "", 1)
+	r, err := Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"A"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{endpoint}, TLSConfig: testTLSConfig(ts)},
+		Transport:       TransportHTTPS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Transport != TransportHTTPS || !reflect.DeepEqual(r.Resolvers, []string{ts.URL + "/dns-query"}) {
+		t.Errorf("Resolvers = %q over %s, want %s/dns-query over HTTPS", r.Resolvers, r.Transport, ts.URL)
+	}
+	if len(r.Errors) != 0 || len(r.Records) != 1 || r.Records[0].Data != "192.0.2.1" {
+		t.Errorf("Records = %+v, Errors = %q, want the A record", r.Records, r.Errors)
+	}
+	// GET with the ID zeroed, so caches can share the answer.
+	if want := []string{"GET " + dohMediaType + " 0"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests %q, want %q", requests, want)
+	}
+}
+
+func TestQueryHTTPSErrors(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN A 192.0.2.1", "example.net. 300 IN A 192.0.2.2")
+	good := dohServer(t, srv, nil)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{name: "status", want: "HTTP 503", handler: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}},
+		{name: "content type", want: "unexpected content type", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		}},
+		{name: "garbage", want: "invalid DNS message", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", dohMediaType)
+			w.Write([]byte{1, 2, 3})
+		}},
+		{name: "other question", want: "different question", handler: func(w http.ResponseWriter, r *http.Request) {
+			m := new(dnswire.Msg)
+			m.SetQuestion("example.net.", dnswire.TypeA)
+			reply, _ := srv.Reply(m, "doh", "https")
+			packed, _ := reply.Pack()
+			w.Header().Set("Content-Type", dohMediaType)
+			w.Write(packed)
+		}},
+	}
+	for _, tt := range tests {
+		ts := httptest.NewTLSServer(tt.handler)
+		c := &Client{Servers: []string{ts.URL + "/dns-query"}, Retries: -1, TLSConfig: testTLSConfig(ts)}
+		_, err := c.Query(context.Background(), "example.com", dnswire.TypeA)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+		ts.Close()
+	}
+
+	// The server's certificate is not trusted without its root.
+	c := &Client{Servers: []string{good.URL + "/dns-query"}, Retries: -1}
+	if _, err := c.Query(context.Background(), "example.com", dnswire.TypeA); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("untrusted server: err = %v, want a certificate error", err)
+	}
+}
+
+// dotServer serves srv's answers over DNS over TLS with the certificate
+// of ts, and returns its address.
+func dotServer(t *testing.T, srv *dnstest.Server, ts *httptest.Server) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := &dnswire.Server{Listener: ln, Handler: srv}
+	started := make(chan struct{})
+	ws.NotifyStartedFunc = func() { close(started) }
+	go ws.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { ws.Shutdown() })
+	return ln.Addr().String()
+}
+
+func TestLookupTLS(t *testing.T) {
+	srv := newServer(t)
+	srv.Add("example.com. 300 IN MX 10 mx.example.com.")
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(ts.Close)
+	addr := dotServer(t, srv, ts)
+
+	r, err := Lookup(context.Background(), "example.com", Options{
+		Types:           []string{"MX"},
+		ResolverOptions: ResolverOptions{Resolvers: []string{addr}, TLSConfig: testTLSConfig(ts)},
+		Transport:       TransportTLS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Transport != TransportTLS || !reflect.DeepEqual(r.Resolvers, []string{"tls://" + addr}) {
+		t.Errorf("Resolvers = %q over %s, want tls://%s", r.Resolvers, r.Transport, addr)
+	}
+	if len(r.Errors) != 0 || len(r.Records) != 1 || r.Records[0].Data != "10 mx.example.com." {
+		t.Errorf("Records = %+v, Errors = %q, want the MX record", r.Records, r.Errors)
+	}
+	if q := srv.Queries(); len(q) != 1 || q[0].Net != "tcp" {
+		t.Errorf("queries %+v, want one over the TLS connection", q)
+	}
+
+	tests := []struct {
+		name string
+		cfg  *tls.Config
+	}{
+		{name: "untrusted root"},
+		// The certificate covers 127.0.0.1 and example.com, not this.
+		{name: "wrong name", cfg: &tls.Config{RootCAs: testTLSConfig(ts).RootCAs, ServerName: "dns.example.org"}},
+	}
+	for _, tt := range tests {
+		c := &Client{Servers: []string{"tls://" + addr}, Retries: -1, Timeout: 2 * time.Second, TLSConfig: tt.cfg}
+		if _, err := c.Query(context.Background(), "example.com", dnswire.TypeMX); err == nil || !strings.Contains(err.Error(), "certificate") {
+			t.Errorf("%s: err = %v, want a certificate error", tt.name, err)
+		}
+	}
+}
+
+func TestQueryTLSUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	c := &Client{Servers: []string{"tls://" + addr}, Retries: -1, Timeout: time.Second}
+	if _, err := c.Query(context.Background(), "example.com", dnswire.TypeA); err == nil || !strings.Contains(err.Error(), addr) {
+		t.Errorf("err = %v, want the connection error for %s", err, addr)
+	}
+}
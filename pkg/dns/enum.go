@@ -0,0 +1,426 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+//go:embed subdomains.txt
+var defaultWordlist string
+
+// DefaultEnumConcurrency is the number of names resolved at once when
+// EnumOptions.Concurrency is zero.
+const DefaultEnumConcurrency = 20
+
+// wildcardProbes is the number of random labels asked for to detect a
+// wildcard record.
+const wildcardProbes = 3
+
+// permutationWords are combined with the labels found to guess related
+// hosts, e.g. api → api-dev, dev-api, api2.
+var permutationWords = []string{
+	"dev", "test", "staging", "stage", "prod", "qa", "uat", "internal",
+	"old", "new", "backup", "admin", "api", "v2",
+}
+
+// Enumeration sources.
+const (
+	SourceWordlist    = "wordlist"
+	SourcePermutation = "permutation"
+	SourceRecursive   = "recursive"
+)
+
+// EnumOptions configures an Enumerate.
+type EnumOptions struct {
+	ResolverOptions
+	// Wordlist holds the labels to try; empty means DefaultWordlist().
+	Wordlist []string
+	// Concurrency is the number of names resolved at once.
+	Concurrency int
+	// Permutations also tries variants of the labels found.
+	Permutations bool
+	// Depth is how many levels below the names found the wordlist is
+	// tried again; zero means no recursion.
+	Depth int
+	// OnFound is called for each subdomain as it is found. It may be
+	// called from several goroutines at once.
+	OnFound func(Subdomain)
+}
+
+// EnumResult lists the subdomains found for a domain.
+type EnumResult struct {
+	Domain string `json:"domain"`
+	// Wildcards are the names answering for any label below them, with
+	// the answers that were filtered out of the results.
+	Wildcards []Wildcard `json:"wildcards"`
+	// WildcardsUnknown are the names whose wildcard probes all failed:
+	// whether they have a wildcard is unknown, and the names found below
+	// them are not filtered.
+	WildcardsUnknown []string    `json:"wildcards_unknown"`
+	Candidates       int         `json:"candidates"`
+	Subdomains       []Subdomain `json:"subdomains"`
+	// Errors counts the names that could not be resolved.
+	Errors int `json:"errors"`
+	// WildcardErrors counts the wildcard probes that could not be
+	// resolved.
+	WildcardErrors int   `json:"wildcard_errors"`
+	DurationMS     int64 `json:"duration_ms"`
+}
+
+// Wildcard is a name with a wildcard record below it.
+type Wildcard struct {
+	Name    string   `json:"name"`
+	Answers []string `json:"answers"`
+}
+
+// Subdomain is a name that resolved.
+type Subdomain struct {
+	Name  string   `json:"name"`
+	A     []string `json:"a,omitempty"`
+	AAAA  []string `json:"aaaa,omitempty"`
+	CNAME []string `json:"cname,omitempty"`
+	// Source is how the name was guessed: SourceWordlist,
+	// SourcePermutation or SourceRecursive.
+	Source string `json:"source"`
+}
+
+// DefaultWordlist returns the subdomain labels built into AFSA.
+func DefaultWordlist() []string {
+	words, err := ParseWordlist(strings.NewReader(defaultWordlist))
+	if err != nil {
+		panic("dns: built-in wordlist: " + err.Error())
+	}
+	return words
+}
+
+// LoadWordlist reads subdomain labels from a file, one per line.
+func LoadWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words, err := ParseWordlist(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return words, nil
+}
+
+// ParseWordlist reads labels one per line, skipping blank lines and
+// comments starting with '#'. Labels are lowercased and deduplicated.
+func ParseWordlist(r io.Reader) ([]string, error) {
+	var words []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		word := strings.ToLower(strings.TrimSpace(sc.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		word = strings.Trim(word, ".")
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words, sc.Err()
+}
+
+// Enumerate finds subdomains of domain by resolving wordlist labels
+// below it. Names under a wildcard record are only reported when they
+// resolve to something other than the wildcard. Individual names
+// failing is not an error; only an invalid domain or resolver setting
+// or a cancelled context is.
+func Enumerate(ctx context.Context, domain string, opts EnumOptions) (*EnumResult, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+	words := opts.Wordlist
+	if len(words) == 0 {
+		words = DefaultWordlist()
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultEnumConcurrency
+	}
+
+	start := time.Now()
+	e := &enumerator{
+		ctx:       ctx,
+		c:         client,
+		workers:   workers,
+		opts:      opts,
+		tried:     map[string]bool{},
+		wildcards: map[string]map[string]bool{},
+	}
+	r := &EnumResult{Domain: strings.TrimSuffix(dnswire.CanonicalName(domain), "."), Wildcards: []Wildcard{}, WildcardsUnknown: []string{}, Subdomains: []Subdomain{}}
+
+	found := e.round(below(words, r.Domain), SourceWordlist)
+	if opts.Permutations {
+		found = append(found, e.round(permutations(found), SourcePermutation)...)
+	}
+	level := found
+	for depth := 0; depth < opts.Depth && len(level) > 0; depth++ {
+		var names []string
+		for _, s := range level {
+			names = append(names, below(words, s.Name)...)
+		}
+		level = e.round(names, SourceRecursive)
+		found = append(found, level...)
+	}
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	r.Subdomains = append(r.Subdomains, found...)
+	r.Candidates = len(e.tried)
+	r.Errors = e.errors
+	r.WildcardErrors = e.wildcardErrors
+	for name, answers := range e.wildcards {
+		if answers == nil {
+			r.WildcardsUnknown = append(r.WildcardsUnknown, strings.TrimSuffix(name, "."))
+			continue
+		}
+		if len(answers) == 0 {
+			continue
+		}
+		w := Wildcard{Name: strings.TrimSuffix(name, ".")}
+		for a := range answers {
+			w.Answers = append(w.Answers, a)
+		}
+		sort.Strings(w.Answers)
+		r.Wildcards = append(r.Wildcards, w)
+	}
+	sort.Slice(r.Wildcards, func(i, j int) bool { return r.Wildcards[i].Name < r.Wildcards[j].Name })
+	sort.Strings(r.WildcardsUnknown)
+	r.DurationMS = time.Since(start).Milliseconds()
+	return r, nil
+}
+
+type enumerator struct {
+	ctx     context.Context
+	c       *Client
+	workers int
+	opts    EnumOptions
+
+	mu    sync.Mutex
+	tried map[string]bool
+	// wildcards maps each parent name checked to its wildcard answers,
+	// empty when it has none and nil when every probe failed.
+	wildcards      map[string]map[string]bool
+	errors         int
+	wildcardErrors int
+}
+
+// below returns word.parent for every word.
+func below(words []string, parent string) []string {
+	names := make([]string, 0, len(words))
+	for _, w := range words {
+		names = append(names, w+"."+parent)
+	}
+	return names
+}
+
+// permutations combines the first label of each name found with
+// permutationWords and numeric suffixes.
+func permutations(found []Subdomain) []string {
+	var names []string
+	for _, s := range found {
+		label, parent, ok := strings.Cut(s.Name, ".")
+		if !ok {
+			continue
+		}
+		for _, w := range permutationWords {
+			if w == label {
+				continue
+			}
+			names = append(names, label+"-"+w+"."+parent, w+"-"+label+"."+parent, label+w+"."+parent)
+		}
+		base := strings.TrimRight(label, "0123456789")
+		for n := 1; n <= 3; n++ {
+			names = append(names, base+strconv.Itoa(n)+"."+parent)
+		}
+	}
+	return names
+}
+
+// round resolves the names not tried before and returns the hits.
+func (e *enumerator) round(names []string, source string) []Subdomain {
+	var todo []string
+	e.mu.Lock()
+	for _, name := range names {
+		name = dnswire.CanonicalName(name)
+		if !e.tried[name] {
+			e.tried[name] = true
+			todo = append(todo, name)
+		}
+	}
+	e.mu.Unlock()
+
+	// Wildcards are checked once per parent before its names
+	parents := map[string]bool{}
+	var parentList []string
+	for _, name := range todo {
+		_, parent, _ := strings.Cut(name, ".")
+		if !parents[parent] {
+			parents[parent] = true
+			parentList = append(parentList, parent)
+		}
+	}
+	forEachN(e.ctx, len(parentList), e.workers, func(i int) { e.detectWildcard(parentList[i]) })
+
+	results := make([]*Subdomain, len(todo))
+	forEachN(e.ctx, len(todo), e.workers, func(i int) {
+		s := e.resolve(todo[i])
+		if s == nil {
+			return
+		}
+		s.Source = source
+		results[i] = s
+		if e.opts.OnFound != nil {
+			e.opts.OnFound(*s)
+		}
+	})
+
+	var found []Subdomain
+	for _, s := range results {
+		if s != nil {
+			found = append(found, *s)
+		}
+	}
+	return found
+}
+
+// detectWildcard asks for random labels below parent and records what
+// they resolve to, or nil when none of them could be resolved.
+func (e *enumerator) detectWildcard(parent string) {
+	e.mu.Lock()
+	_, done := e.wildcards[parent]
+	e.mu.Unlock()
+	if done {
+		return
+	}
+
+	answers := map[string]bool{}
+	failed := 0
+	for i := 0; i < wildcardProbes; i++ {
+		s, err := e.lookup(randomLabel() + "." + parent)
+		if err != nil {
+			failed++
+			continue
+		}
+		if s == nil {
+			continue
+		}
+		for _, a := range wildcardAnswers(s) {
+			answers[a] = true
+		}
+	}
+	if failed == wildcardProbes {
+		answers = nil
+	}
+	e.mu.Lock()
+	e.wildcards[parent] = answers
+	e.wildcardErrors += failed
+	e.mu.Unlock()
+}
+
+// resolve looks up name and returns it unless it did not resolve or
+// resolved only to its parent's wildcard answers.
+func (e *enumerator) resolve(name string) *Subdomain {
+	s, err := e.lookup(name)
+	if err != nil {
+		e.mu.Lock()
+		e.errors++
+		e.mu.Unlock()
+		return nil
+	}
+	if s == nil {
+		return nil
+	}
+
+	_, parent, _ := strings.Cut(name, ".")
+	e.mu.Lock()
+	wildcard := e.wildcards[parent]
+	e.mu.Unlock()
+	if len(wildcard) > 0 {
+		distinct := false
+		for _, a := range wildcardAnswers(s) {
+			if !wildcard[a] {
+				distinct = true
+				break
+			}
+		}
+		if !distinct {
+			return nil
+		}
+	}
+	return s
+}
+
+// lookup asks for the A and AAAA records of name. It returns nil
+// without an error when the name does not exist or has no addresses.
+func (e *enumerator) lookup(name string) (*Subdomain, error) {
+	s := &Subdomain{Name: strings.TrimSuffix(name, ".")}
+	for _, qtype := range []uint16{dnswire.TypeA, dnswire.TypeAAAA} {
+		resp, err := e.c.Query(e.ctx, name, qtype)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.Msg.Rcode {
+		case dnswire.RcodeSuccess:
+		case dnswire.RcodeNameError:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%s", dnswire.RcodeToString[resp.Msg.Rcode])
+		}
+		for _, rr := range resp.Msg.Answer {
+			switch v := rr.(type) {
+			case *dnswire.A:
+				s.A = appendUnique(s.A, v.A.String())
+			case *dnswire.AAAA:
+				s.AAAA = appendUnique(s.AAAA, v.AAAA.String())
+			case *dnswire.CNAME:
+				s.CNAME = appendUnique(s.CNAME, strings.TrimSuffix(v.Target, "."))
+			}
+		}
+	}
+	if len(s.A) == 0 && len(s.AAAA) == 0 && len(s.CNAME) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// wildcardAnswers are the values a name resolved to, compared against
+// a wildcard's.
+func wildcardAnswers(s *Subdomain) []string {
+	answers := append(append([]string{}, s.A...), s.AAAA...)
+	return append(answers, s.CNAME...)
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
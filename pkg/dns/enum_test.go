@@ -0,0 +1,148 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	dnswire "github.com/miekg/dns"
+	"github.com/tanvircs/afsa/pkg/dns/dnstest"
+)
+
+// servfail answers the queries for names fail picks with SERVFAIL and
+// passes the rest to srv.
+type servfail struct {
+	srv  *dnstest.Server
+	fail func(name string) bool
+}
+
+func (e servfail) Exchange(ctx context.Context, m *dnswire.Msg, server string) (*dnswire.Msg, error) {
+	if e.fail(m.Question[0].Name) {
+		r := new(dnswire.Msg)
+		r.SetRcode(m, dnswire.RcodeServerFailure)
+		return r, nil
+	}
+	return e.srv.Exchange(ctx, m, server)
+}
+
+func TestEnumerate(t *testing.T) {
+	srv := newServer(t)
+	srv.Add(
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"api.example.com. 300 IN AAAA 2001:db8::2",
+		"dev.example.com. 300 IN A 192.0.2.3",
+		"*.dev.example.com. 300 IN A 192.0.2.200",
+		"app.dev.example.com. 300 IN CNAME www.example.com.",
+		"www.app.dev.example.com. 300 IN A 192.0.2.5",
+		"api-dev.example.com. 300 IN A 192.0.2.6",
+		"www2.example.com. 300 IN A 192.0.2.7",
+		"flaky.example.com. 300 IN A 192.0.2.8",
+		"www.flaky.example.com. 300 IN A 192.0.2.9",
+	)
+	// Everything below flaky.example.com but its www fails, wildcard
+	// probes included.
+	exchanger := servfail{srv: srv, fail: func(name string) bool {
+		return strings.HasSuffix(name, ".flaky.example.com.") && name != "www.flaky.example.com."
+	}}
+	wordlist := []string{"www", "api", "dev", "app", "mail", "flaky"}
+
+	tests := []struct {
+		name        string
+		opts        EnumOptions
+		found       []string
+		wildcards   []Wildcard
+		unknown     []string
+		errors      int
+		probeErrors int
+		// candidates is left unchecked when negative.
+		candidates int
+	}{
+		{
+			name:  "wordlist",
+			found: []string{"api.example.com wordlist", "dev.example.com wordlist", "flaky.example.com wordlist", "www.example.com wordlist"},
+			// The random labels below example.com don't exist.
+			wildcards:  []Wildcard{},
+			unknown:    []string{},
+			candidates: 6,
+		},
+		{
+			name:       "permutations",
+			opts:       EnumOptions{Permutations: true},
+			found:      []string{"api-dev.example.com permutation", "api.example.com wordlist", "dev.example.com wordlist", "flaky.example.com wordlist", "www.example.com wordlist", "www2.example.com permutation"},
+			wildcards:  []Wildcard{},
+			unknown:    []string{},
+			candidates: -1,
+		},
+		{
+			// Below dev, only app resolves to something besides the
+			// wildcard; below flaky, the failed probes leave www
+			// unfiltered.
+			name: "depth 1",
+			opts: EnumOptions{Depth: 1},
+			found: []string{
+				"api.example.com wordlist", "app.dev.example.com recursive", "dev.example.com wordlist",
+				"flaky.example.com wordlist", "www.example.com wordlist", "www.flaky.example.com recursive",
+			},
+			wildcards:   []Wildcard{{Name: "dev.example.com", Answers: []string{"192.0.2.200"}}},
+			unknown:     []string{"flaky.example.com"},
+			errors:      5,
+			probeErrors: wildcardProbes,
+			candidates:  6 + 4*6,
+		},
+		{
+			// The wildcard at dev doesn't reach below app, which exists,
+			// and www.flaky's probes fail like its parent's.
+			name: "depth 2",
+			opts: EnumOptions{Depth: 2},
+			found: []string{
+				"api.example.com wordlist", "app.dev.example.com recursive", "dev.example.com wordlist",
+				"flaky.example.com wordlist", "www.app.dev.example.com recursive", "www.example.com wordlist",
+				"www.flaky.example.com recursive",
+			},
+			wildcards:   []Wildcard{{Name: "dev.example.com", Answers: []string{"192.0.2.200"}}},
+			unknown:     []string{"flaky.example.com", "www.flaky.example.com"},
+			errors:      5 + 6,
+			probeErrors: 2 * wildcardProbes,
+			candidates:  6 + 4*6 + 2*6,
+		},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		opts.Resolvers = []string{"192.0.2.53"}
+		opts.Retries = -1
+		opts.Exchanger = exchanger
+		opts.Wordlist = wordlist
+		var mu sync.Mutex
+		streamed := 0
+		opts.OnFound = func(Subdomain) {
+			mu.Lock()
+			streamed++
+			mu.Unlock()
+		}
+		r, err := Enumerate(context.Background(), "Example.COM.", opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var found []string
+		for _, s := range r.Subdomains {
+			found = append(found, s.Name+" "+s.Source)
+		}
+		if r.Domain != "example.com" || !reflect.DeepEqual(found, tt.found) {
+			t.Errorf("%s: %s found %q, want %q", tt.name, r.Domain, found, tt.found)
+		}
+		if streamed != len(r.Subdomains) {
+			t.Errorf("%s: OnFound called %d times for %d subdomains", tt.name, streamed, len(r.Subdomains))
+		}
+		if !reflect.DeepEqual(r.Wildcards, tt.wildcards) || !reflect.DeepEqual(r.WildcardsUnknown, tt.unknown) {
+			t.Errorf("%s: wildcards %+v, unknown %q, want %+v and %q", tt.name, r.Wildcards, r.WildcardsUnknown, tt.wildcards, tt.unknown)
+		}
+		if r.Errors != tt.errors || r.WildcardErrors != tt.probeErrors || tt.candidates >= 0 && r.Candidates != tt.candidates {
+			t.Errorf("%s: %d errors, %d wildcard errors, %d candidates, want %d, %d and %d",
+				tt.name, r.Errors, r.WildcardErrors, r.Candidates, tt.errors, tt.probeErrors, tt.candidates)
+		}
+	}
+
+}
@@ -0,0 +1,313 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// DefaultSweepConcurrency is the number of addresses looked up at once
+// when SweepOptions.Concurrency is zero.
+const DefaultSweepConcurrency = 20
+
+// MaxSweepAddresses bounds the size of a swept block, so a typo such as
+// /8 fails fast instead of queueing millions of lookups.
+const MaxSweepAddresses = 1 << 16
+
+// SweepOptions configures a PTRSweep.
+type SweepOptions struct {
+	ResolverOptions
+	// Concurrency is the number of addresses looked up at once.
+	Concurrency int
+	// Rate caps the PTR lookups started per second; zero means no limit.
+	Rate float64
+	// OnFound is called for each address with a PTR record as it is
+	// found. It may be called from several goroutines at once.
+	OnFound func(PTRHost)
+}
+
+// SweepResult lists the reverse DNS names found in a netblock.
+type SweepResult struct {
+	Network string `json:"network"`
+	// Addresses is the number of addresses looked up.
+	Addresses int       `json:"addresses"`
+	Hosts     []PTRHost `json:"hosts"`
+	// Patterns group the names by their shape, most common first.
+	Patterns []NamingPattern `json:"patterns"`
+	// Errors counts the addresses whose lookup failed.
+	Errors     int   `json:"errors"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// PTRHost is an address with reverse DNS names.
+type PTRHost struct {
+	Address string    `json:"address"`
+	Names   []PTRName `json:"names"`
+	// FCrDNS is set when at least one name resolves back to the
+	// address (forward-confirmed reverse DNS).
+	FCrDNS bool `json:"fcrdns"`
+}
+
+// PTRName is one PTR record of an address.
+type PTRName struct {
+	Name string `json:"name"`
+	// ForwardConfirmed is set when the name's A or AAAA records include
+	// the address.
+	ForwardConfirmed bool `json:"forward_confirmed"`
+	// Pattern is the name with the address and numbers replaced by
+	// {ip} and {n}.
+	Pattern string `json:"pattern"`
+}
+
+// NamingPattern is a shape shared by names in the block.
+type NamingPattern struct {
+	Pattern string `json:"pattern"`
+	Count   int    `json:"count"`
+	// Generic patterns embed the address, as ISPs and clouds name
+	// addresses nobody named by hand.
+	Generic  bool     `json:"generic"`
+	Examples []string `json:"examples"`
+}
+
+// patternExamples is the number of example names kept per pattern.
+const patternExamples = 3
+
+var digitRun = regexp.MustCompile(`[0-9]+`)
+
+// PTRSweep looks up the PTR records of every address in network (CIDR
+// notation, or a single address), checks each name with a forward
+// lookup and groups the names into naming patterns. Individual lookups
+// failing is not an error; only an invalid or oversized network, an
+// unusable resolver setting or a cancelled context is.
+func PTRSweep(ctx context.Context, network string, opts SweepOptions) (*SweepResult, error) {
+	prefix, err := parseSweepNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultSweepConcurrency
+	}
+
+	var addrs []netip.Addr
+	for a := prefix.Addr(); prefix.Contains(a); a = a.Next() {
+		addrs = append(addrs, a)
+		if !a.Next().IsValid() {
+			break
+		}
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	start := time.Now()
+	r := &SweepResult{Network: prefix.String(), Addresses: len(addrs), Hosts: []PTRHost{}, Patterns: []NamingPattern{}}
+	hosts := make([]*PTRHost, len(addrs))
+	var mu sync.Mutex
+	forEachN(ctx, len(addrs), workers, func(i int) {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		h, err := reverseHost(ctx, client, addrs[i])
+		if err != nil {
+			mu.Lock()
+			r.Errors++
+			mu.Unlock()
+			return
+		}
+		if h == nil {
+			return
+		}
+		hosts[i] = h
+		if opts.OnFound != nil {
+			opts.OnFound(*h)
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	patterns := map[string]*NamingPattern{}
+	for _, h := range hosts {
+		if h == nil {
+			continue
+		}
+		r.Hosts = append(r.Hosts, *h)
+		for _, n := range h.Names {
+			p, ok := patterns[n.Pattern]
+			if !ok {
+				p = &NamingPattern{Pattern: n.Pattern, Generic: strings.Contains(n.Pattern, "{ip}")}
+				patterns[n.Pattern] = p
+			}
+			p.Count++
+			if len(p.Examples) < patternExamples {
+				p.Examples = append(p.Examples, n.Name)
+			}
+		}
+	}
+	for _, p := range patterns {
+		r.Patterns = append(r.Patterns, *p)
+	}
+	sort.Slice(r.Patterns, func(i, j int) bool {
+		if r.Patterns[i].Count != r.Patterns[j].Count {
+			return r.Patterns[i].Count > r.Patterns[j].Count
+		}
+		return r.Patterns[i].Pattern < r.Patterns[j].Pattern
+	})
+	r.DurationMS = time.Since(start).Milliseconds()
+	return r, nil
+}
+
+// parseSweepNetwork parses a CIDR block or single address and checks
+// its size.
+func parseSweepNetwork(network string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(network))
+	if err != nil {
+		addr, aerr := netip.ParseAddr(strings.TrimSpace(network))
+		if aerr != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q: want CIDR notation such as 203.0.113.0/24", network)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	prefix = prefix.Masked()
+	if hostBits := prefix.Addr().BitLen() - prefix.Bits(); hostBits > 16 {
+		return netip.Prefix{}, fmt.Errorf("network %s has more than %d addresses; split it into smaller blocks", prefix, MaxSweepAddresses)
+	}
+	return prefix, nil
+}
+
+// reverseHost returns the PTR names of addr with their forward
+// confirmation, or nil when it has none.
+func reverseHost(ctx context.Context, c *Client, addr netip.Addr) (*PTRHost, error) {
+	rev, err := dnswire.ReverseAddr(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Query(ctx, rev, dnswire.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Msg.Rcode {
+	case dnswire.RcodeSuccess:
+	case dnswire.RcodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s", dnswire.RcodeToString[resp.Msg.Rcode])
+	}
+
+	h := &PTRHost{Address: addr.String()}
+	for _, rr := range resp.Msg.Answer {
+		ptr, ok := rr.(*dnswire.PTR)
+		if !ok {
+			continue
+		}
+		n := PTRName{Name: strings.TrimSuffix(ptr.Ptr, "."), Pattern: namePattern(ptr.Ptr, addr)}
+		n.ForwardConfirmed = forwardConfirmed(ctx, c, ptr.Ptr, addr)
+		h.FCrDNS = h.FCrDNS || n.ForwardConfirmed
+		h.Names = append(h.Names, n)
+	}
+	if len(h.Names) == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// forwardConfirmed reports whether name resolves to addr.
+func forwardConfirmed(ctx context.Context, c *Client, name string, addr netip.Addr) bool {
+	qtype := dnswire.TypeA
+	if addr.Is6() {
+		qtype = dnswire.TypeAAAA
+	}
+	resp, err := c.Query(ctx, name, qtype)
+	if err != nil {
+		return false
+	}
+	for _, rec := range answerRecords(resp.Msg, qtype) {
+		if a, err := netip.ParseAddr(rec.Data); err == nil && a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// namePattern replaces the forms of addr commonly embedded in host
+// names with {ip}, and the remaining digit runs with {n}:
+// 203-0-113-5.static.example.net becomes {ip}.static.example.net and
+// web12.example.com becomes web{n}.example.com.
+func namePattern(name string, addr netip.Addr) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, form := range addressForms(addr) {
+		if i := indexAddress(name, form); i >= 0 {
+			name = name[:i] + "\x00" + name[i+len(form):]
+			break
+		}
+	}
+	name = digitRun.ReplaceAllString(name, "{n}")
+	return strings.Replace(name, "\x00", "{ip}", 1)
+}
+
+// indexAddress returns the index of the first form in name that isn't
+// part of a longer number, so 1-2-3-4 isn't found in 11-2-3-45, or -1.
+func indexAddress(name, form string) int {
+	for start := 0; ; {
+		i := strings.Index(name[start:], form)
+		if i < 0 {
+			return -1
+		}
+		i += start
+		end := i + len(form)
+		if (i == 0 || !isDigit(name[i-1])) && (end == len(name) || !isDigit(name[end])) {
+			return i
+		}
+		start = i + 1
+	}
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// addressForms returns the spellings of addr found in host names,
+// longest first so a dotted address isn't matched as a partial one.
+func addressForms(addr netip.Addr) []string {
+	if addr.Is4() {
+		b := addr.As4()
+		dec := func(i int) string { return fmt.Sprint(b[i]) }
+		pad := func(i int) string { return fmt.Sprintf("%03d", b[i]) }
+		forms := []string{
+			strings.Join([]string{pad(0), pad(1), pad(2), pad(3)}, "-"),
+			strings.Join([]string{pad(0), pad(1), pad(2), pad(3)}, ""),
+			strings.Join([]string{dec(0), dec(1), dec(2), dec(3)}, "-"),
+			strings.Join([]string{dec(0), dec(1), dec(2), dec(3)}, "."),
+			strings.Join([]string{dec(3), dec(2), dec(1), dec(0)}, "-"),
+			strings.Join([]string{dec(3), dec(2), dec(1), dec(0)}, "."),
+			fmt.Sprintf("%02x%02x%02x%02x", b[0], b[1], b[2], b[3]),
+		}
+		sort.SliceStable(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+		return forms
+	}
+	b := addr.As16()
+	var groups []string
+	for i := 0; i < 16; i += 2 {
+		groups = append(groups, fmt.Sprintf("%x", uint16(b[i])<<8|uint16(b[i+1])))
+	}
+	full := fmt.Sprintf("%x", b[:])
+	return []string{full, strings.Join(groups, "-"), strings.ReplaceAll(addr.String(), ":", "-")}
+}
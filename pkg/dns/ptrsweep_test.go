@@ -0,0 +1,71 @@
+package dns
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestNamePattern(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		// IPv4 forms
+		{"203-0-113-5.static.example.net.", "203.0.113.5", "{ip}.static.example.net"},
+		{"5-113-0-203.dsl.example.net", "203.0.113.5", "{ip}.dsl.example.net"},
+		{"5.113.0.203.dsl.example.net", "203.0.113.5", "{ip}.dsl.example.net"},
+		{"host203.0.113.5.example.net", "203.0.113.5", "host{ip}.example.net"},
+		{"host-203-000-113-005.example.net", "203.0.113.5", "host-{ip}.example.net"},
+		{"ip203000113005.example.net", "203.0.113.5", "ip{ip}.example.net"},
+		{"CB007105.Pool.Example.NET.", "203.0.113.5", "{ip}.pool.example.net"},
+		// The zero-padded form wins over the shorter ones inside it
+		{"010-000-000-001.example.net", "10.0.0.1", "{ip}.example.net"},
+		// Other digits are numbered, including those of other addresses
+		{"cust12-203-0-113-5.pop3.example.net", "203.0.113.5", "cust{n}-{ip}.pop{n}.example.net"},
+		{"203-0-113-6.example.net", "203.0.113.5", "{n}-{n}-{n}-{n}.example.net"},
+		// An address inside a longer number is not this one
+		{"host-11-2-3-45.example.com", "1.2.3.4", "host-{n}-{n}-{n}-{n}.example.com"},
+		{"host-11-2-3-45.1-2-3-4.example.com", "1.2.3.4", "host-{n}-{n}-{n}-{n}.{ip}.example.com"},
+		{"web12.example.com", "203.0.113.5", "web{n}.example.com"},
+		{"mail.example.com", "203.0.113.5", "mail.example.com"},
+
+		// IPv6 forms
+		{"2001-db8-0-0-0-0-0-1.example.net", "2001:db8::1", "{ip}.example.net"},
+		{"2001-db8--1.example.net", "2001:db8::1", "{ip}.example.net"},
+		{"20010db8000000000000000000000001.example.net", "2001:db8::1", "{ip}.example.net"},
+		{"host-2001-db8-0-0-0-0-0-2.example.net", "2001:db8::1", "host-{n}-db{n}-{n}-{n}-{n}-{n}-{n}-{n}.example.net"},
+	}
+	for _, tt := range tests {
+		if got := namePattern(tt.name, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("namePattern(%q, %s) = %q, want %q", tt.name, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAddressForms(t *testing.T) {
+	got := addressForms(netip.MustParseAddr("192.0.2.10"))
+	want := []string{
+		"192-000-002-010",
+		"192000002010",
+		"192-0-2-10",
+		"192.0.2.10",
+		"10-2-0-192",
+		"10.2.0.192",
+		"c000020a",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IPv4 forms = %q, want %q", got, want)
+	}
+
+	got = addressForms(netip.MustParseAddr("2001:db8::a:1"))
+	want = []string{
+		"20010db80000000000000000000a0001",
+		"2001-db8-0-0-0-0-a-1",
+		"2001-db8--a-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IPv6 forms = %q, want %q", got, want)
+	}
+}
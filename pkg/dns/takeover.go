@@ -0,0 +1,590 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	dnswire "github.com/miekg/dns"
+)
+
+//go:embed takeover-signatures.txt
+var defaultTakeoverSignatures string
+
+// Takeover statuses, from most to least certain.
+const (
+	// TakeoverVulnerable means a service signature shows the resource
+	// the name points at is unclaimed.
+	TakeoverVulnerable = "vulnerable"
+	// TakeoverDangling means the CNAME target or nameserver does not
+	// exist; whoever registers it controls the name.
+	TakeoverDangling = "dangling"
+	// TakeoverLame means the delegated nameservers don't serve the
+	// zone, at a provider without a signature.
+	TakeoverLame = "lame"
+)
+
+var takeoverRank = map[string]int{TakeoverVulnerable: 0, TakeoverDangling: 1, TakeoverLame: 2}
+
+// TakeoverSignature identifies a service whose unclaimed resources can
+// be taken over by creating them, from the names pointing at it and the
+// page it serves for them.
+type TakeoverSignature struct {
+	Service string
+	// CNAME and NS are host patterns for the service's CNAME targets and
+	// nameservers; * matches any run of characters.
+	CNAME []string
+	NS    []string
+	// NXDomain means a CNAME target that does not exist can be claimed.
+	NXDomain bool
+	// Status and Body describe the "not found" page: its HTTP status
+	// (zero for any) and texts of which one must appear.
+	Status int
+	Body   []string
+	// HTTPS fetches the page over HTTPS.
+	HTTPS bool
+}
+
+// TakeoverOptions configures a CheckTakeover.
+type TakeoverOptions struct {
+	// ResolverOptions.Timeout also bounds each HTTP fetch.
+	ResolverOptions
+	// Names are further host names to check besides the domain, e.g.
+	// the subdomains found by Enumerate.
+	Names []string
+	// Signatures replaces DefaultTakeoverSignatures().
+	Signatures []*TakeoverSignature
+	// Concurrency is the number of names checked at once; zero means
+	// queryConcurrency.
+	Concurrency int
+	// HTTPClient fetches the service pages; nil means a client with
+	// Timeout.
+	HTTPClient *http.Client
+}
+
+// TakeoverResult lists the names checked for takeover and what was
+// found.
+type TakeoverResult struct {
+	Domain  string `json:"domain"`
+	Checked int    `json:"checked"`
+	// Hosts are the names checked that have a CNAME or are delegated.
+	Hosts    []TakeoverHost    `json:"hosts"`
+	Findings []TakeoverFinding `json:"findings"`
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+// TakeoverHost is a name that points elsewhere.
+type TakeoverHost struct {
+	Name string `json:"name"`
+	// CNAME is the chain of targets, in order.
+	CNAME []string `json:"cname,omitempty"`
+	// NS are the nameservers the name is delegated to.
+	NS []string `json:"ns,omitempty"`
+	// Service is the signature the CNAME chain matched.
+	Service string `json:"service,omitempty"`
+}
+
+// TakeoverFinding is a CNAME or NS record pointing at something that
+// is not there.
+type TakeoverFinding struct {
+	Name string `json:"name"`
+	// Record is "CNAME" or "NS"; Target the CNAME target or nameserver.
+	Record  string `json:"record"`
+	Target  string `json:"target"`
+	Service string `json:"service,omitempty"`
+	// Status is TakeoverVulnerable, TakeoverDangling or TakeoverLame.
+	Status   string `json:"status"`
+	Evidence string `json:"evidence"`
+}
+
+// DefaultTakeoverSignatures returns the takeover signatures built into
+// AFSA.
+func DefaultTakeoverSignatures() []*TakeoverSignature {
+	sigs, err := ParseTakeoverSignatures(strings.NewReader(defaultTakeoverSignatures))
+	if err != nil {
+		panic("dns: built-in takeover signatures: " + err.Error())
+	}
+	return sigs
+}
+
+// LoadTakeoverSignatures reads takeover signatures from a file in the
+// takeover-signatures.txt format.
+func LoadTakeoverSignatures(path string) ([]*TakeoverSignature, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sigs, err := ParseTakeoverSignatures(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sigs, nil
+}
+
+// ParseTakeoverSignatures parses takeover signatures: a Service line
+// followed by cname, ns, nxdomain, status, body and https lines.
+func ParseTakeoverSignatures(r io.Reader) ([]*TakeoverSignature, error) {
+	var sigs []*TakeoverSignature
+	var current *TakeoverSignature
+
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+
+		if directive == "Service" {
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: Service needs a name", line)
+			}
+			current = &TakeoverSignature{Service: rest}
+			sigs = append(sigs, current)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: %s before the first Service", line, directive)
+		}
+
+		var err error
+		switch directive {
+		case "cname":
+			current.CNAME = append(current.CNAME, strings.Fields(strings.ToLower(rest))...)
+		case "ns":
+			current.NS = append(current.NS, strings.Fields(strings.ToLower(rest))...)
+		case "nxdomain":
+			current.NXDomain = true
+		case "status":
+			current.Status, err = strconv.Atoi(rest)
+			if err == nil && (current.Status < 100 || current.Status > 599) {
+				err = fmt.Errorf("invalid HTTP status %d", current.Status)
+			}
+		case "body":
+			if rest == "" {
+				err = fmt.Errorf("empty body text")
+			}
+			current.Body = append(current.Body, rest)
+		case "https":
+			current.HTTPS = true
+		default:
+			err = fmt.Errorf("unknown directive %q", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("no signatures defined")
+	}
+	for _, s := range sigs {
+		if len(s.CNAME) == 0 && len(s.NS) == 0 {
+			return nil, fmt.Errorf("signature %s has no cname or ns patterns", s.Service)
+		}
+	}
+	return sigs, nil
+}
+
+// matchHost reports whether host matches pattern, where * matches any
+// run of characters. Both are compared without a trailing dot.
+func matchHost(pattern, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(host, parts[0]) {
+		return false
+	}
+	host = host[len(parts[0]):]
+	if len(parts) == 1 {
+		return host == ""
+	}
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(host, part)
+		if i < 0 {
+			return false
+		}
+		host = host[i+len(part):]
+	}
+	return len(host) >= len(last) && strings.HasSuffix(host, last)
+}
+
+func matchAny(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if matchHost(p, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTakeover looks for CNAME and NS records of domain and opts.Names
+// that point at resources nobody holds: CNAME targets and nameservers
+// that do not exist, cloud services serving their "not found" page for
+// the name, and delegations to nameservers that don't serve the zone.
+// Individual names failing is not an error; only an invalid domain or
+// resolver setting or a cancelled context is.
+func CheckTakeover(ctx context.Context, domain string, opts TakeoverOptions) (*TakeoverResult, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	client, err := NewClient(Options{ResolverOptions: opts.ResolverOptions})
+	if err != nil {
+		return nil, err
+	}
+	sigs := opts.Signatures
+	if len(sigs) == 0 {
+		sigs = DefaultTakeoverSignatures()
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = queryConcurrency
+	}
+
+	r := &TakeoverResult{
+		Domain:   strings.TrimSuffix(dnswire.CanonicalName(domain), "."),
+		Hosts:    []TakeoverHost{},
+		Findings: []TakeoverFinding{},
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range append([]string{domain}, opts.Names...) {
+		if err := ValidateDomain(name); err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		name = dnswire.CanonicalName(name)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	t := &takeover{ctx: ctx, c: client, http: httpClient, sigs: sigs, parents: map[string]*parentServers{}}
+	checks := make([]*takeoverCheck, len(names))
+	forEachN(ctx, len(names), workers, func(i int) { checks[i] = t.check(names[i]) })
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	r.Checked = len(names)
+	for _, tc := range checks {
+		if len(tc.host.CNAME) > 0 || len(tc.host.NS) > 0 {
+			r.Hosts = append(r.Hosts, tc.host)
+		}
+		r.Findings = append(r.Findings, tc.findings...)
+		r.Errors = append(r.Errors, tc.errors...)
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Status != b.Status {
+			return takeoverRank[a.Status] < takeoverRank[b.Status]
+		}
+		return a.Name < b.Name
+	})
+	return r, nil
+}
+
+type takeover struct {
+	ctx  context.Context
+	c    *Client
+	http *http.Client
+	sigs []*TakeoverSignature
+
+	mu sync.Mutex
+	// parents caches the nameserver addresses of parent zones asked for
+	// referrals.
+	parents map[string]*parentServers
+}
+
+type parentServers struct {
+	once    sync.Once
+	servers []string
+	err     error
+}
+
+// takeoverCheck collects what was found for one name.
+type takeoverCheck struct {
+	host     TakeoverHost
+	findings []TakeoverFinding
+	errors   []string
+}
+
+func (tc *takeoverCheck) errorf(format string, args ...interface{}) {
+	tc.errors = append(tc.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *takeover) check(name string) *takeoverCheck {
+	tc := &takeoverCheck{host: TakeoverHost{Name: strings.TrimSuffix(name, ".")}}
+	t.checkCNAME(name, tc)
+	t.checkNS(name, tc)
+	return tc
+}
+
+// checkCNAME follows the CNAME chain of name. A target that does not
+// exist is dangling; one that exists is fetched over HTTP when the
+// chain matches a service signature.
+func (t *takeover) checkCNAME(name string, tc *takeoverCheck) {
+	resp, err := t.c.Query(t.ctx, name, dnswire.TypeA)
+	if err != nil {
+		tc.errorf("A %s: %v", tc.host.Name, err)
+		return
+	}
+	for _, rr := range resp.Msg.Answer {
+		if cname, ok := rr.(*dnswire.CNAME); ok {
+			tc.host.CNAME = append(tc.host.CNAME, strings.TrimSuffix(cname.Target, "."))
+		}
+	}
+	if len(tc.host.CNAME) == 0 {
+		return
+	}
+
+	target := tc.host.CNAME[len(tc.host.CNAME)-1]
+	var sig *TakeoverSignature
+	for _, s := range t.sigs {
+		for _, hop := range tc.host.CNAME {
+			if matchAny(s.CNAME, hop) {
+				sig = s
+				break
+			}
+		}
+		if sig != nil {
+			tc.host.Service = sig.Service
+			break
+		}
+	}
+
+	f := TakeoverFinding{Name: tc.host.Name, Record: "CNAME", Target: target}
+	switch resp.Msg.Rcode {
+	case dnswire.RcodeNameError:
+		f.Status, f.Evidence = TakeoverDangling, fmt.Sprintf("%s does not exist (NXDOMAIN)", target)
+		if sig != nil {
+			f.Service = sig.Service
+			if sig.NXDomain {
+				f.Status = TakeoverVulnerable
+				f.Evidence += "; the name can be claimed at " + sig.Service
+			}
+		}
+		tc.findings = append(tc.findings, f)
+	case dnswire.RcodeSuccess:
+		if sig == nil || (sig.Status == 0 && len(sig.Body) == 0) {
+			return
+		}
+		evidence, err := t.fingerprint(name, sig)
+		if err != nil {
+			tc.errorf("HTTP %s: %v", tc.host.Name, err)
+			return
+		}
+		if evidence != "" {
+			f.Service, f.Status, f.Evidence = sig.Service, TakeoverVulnerable, evidence
+			tc.findings = append(tc.findings, f)
+		}
+	default:
+		tc.errorf("A %s: %s", tc.host.Name, dnswire.RcodeToString[resp.Msg.Rcode])
+	}
+}
+
+// fingerprint fetches / from name and describes how the response
+// matched sig's "not found" page, or returns "" when it didn't.
+func (t *takeover) fingerprint(name string, sig *TakeoverSignature) (string, error) {
+	scheme := "http"
+	if sig.HTTPS {
+		scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodGet, scheme+"://"+strings.TrimSuffix(name, ".")+"/", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "afsa")
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+	if err != nil {
+		return "", err
+	}
+
+	if sig.Status != 0 && resp.StatusCode != sig.Status {
+		return "", nil
+	}
+	if len(sig.Body) == 0 {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+	}
+	for _, text := range sig.Body {
+		if bytes.Contains(body, []byte(text)) {
+			return fmt.Sprintf("HTTP %d with %q", resp.StatusCode, text), nil
+		}
+	}
+	return "", nil
+}
+
+// checkNS finds the nameservers name is delegated to, if any, and checks
+// that each exists and serves the zone.
+func (t *takeover) checkNS(name string, tc *takeoverCheck) {
+	hosts, err := t.delegation(name)
+	if err != nil {
+		tc.errorf("NS %s: %v", tc.host.Name, err)
+		return
+	}
+	for _, ns := range hosts {
+		tc.host.NS = append(tc.host.NS, strings.TrimSuffix(ns, "."))
+	}
+	for _, ns := range hosts {
+		t.checkNameserver(name, ns, tc)
+	}
+}
+
+// delegation returns the NS hosts of name when it is a zone. A lame
+// delegation usually fails at the resolver, so then the parent zone's
+// nameservers are asked for the referral instead.
+func (t *takeover) delegation(name string) ([]string, error) {
+	resp, err := t.c.Query(t.ctx, name, dnswire.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Msg.Rcode {
+	case dnswire.RcodeSuccess:
+		return nsOwnedBy(name, resp.Msg.Answer), nil
+	case dnswire.RcodeNameError:
+		return nil, nil
+	}
+
+	_, parent, _ := strings.Cut(name, ".")
+	if parent == "" {
+		return nil, fmt.Errorf("%s", dnswire.RcodeToString[resp.Msg.Rcode])
+	}
+	servers, err := t.parentServers(parent)
+	if err != nil {
+		return nil, fmt.Errorf("%s, and no referral from the parent zone: %w", dnswire.RcodeToString[resp.Msg.Rcode], err)
+	}
+	lastErr := fmt.Errorf("%s", dnswire.RcodeToString[resp.Msg.Rcode])
+	for _, server := range servers {
+		resp, err := t.c.QueryServer(t.ctx, server, name, dnswire.TypeNS)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return nsOwnedBy(name, append(resp.Msg.Answer, resp.Msg.Ns...)), nil
+	}
+	return nil, lastErr
+}
+
+// parentServers returns the nameserver addresses of the zone parent
+// belongs to, looked up once per parent.
+func (t *takeover) parentServers(parent string) ([]string, error) {
+	t.mu.Lock()
+	p, ok := t.parents[parent]
+	if !ok {
+		p = &parentServers{}
+		t.parents[parent] = p
+	}
+	t.mu.Unlock()
+
+	p.once.Do(func() {
+		_, p.servers, p.err = AuthoritativeServers(t.ctx, t.c, parent)
+	})
+	return p.servers, p.err
+}
+
+func nsOwnedBy(name string, rrs []dnswire.RR) []string {
+	var hosts []string
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dnswire.NS); ok && strings.EqualFold(ns.Hdr.Name, name) {
+			hosts = append(hosts, ns.Ns)
+		}
+	}
+	return hosts
+}
+
+// checkNameserver reports ns as dangling when it does not exist, and
+// as lame (or vulnerable, at a hosted DNS service) when the first of
+// its addresses to answer is not authoritative for name.
+func (t *takeover) checkNameserver(name, ns string, tc *takeoverCheck) {
+	host := strings.TrimSuffix(ns, ".")
+	f := TakeoverFinding{Name: tc.host.Name, Record: "NS", Target: host}
+	for _, s := range t.sigs {
+		if matchAny(s.NS, ns) {
+			f.Service = s.Service
+			break
+		}
+	}
+
+	resp, err := t.c.Query(t.ctx, ns, dnswire.TypeA)
+	if err != nil {
+		tc.errorf("A %s: %v", host, err)
+		return
+	}
+	if resp.Msg.Rcode == dnswire.RcodeNameError {
+		f.Status, f.Evidence = TakeoverDangling, fmt.Sprintf("nameserver %s does not exist (NXDOMAIN)", host)
+		tc.findings = append(tc.findings, f)
+		return
+	}
+	addrs := resolveHost(t.ctx, t.c, ns)
+	if len(addrs) == 0 {
+		tc.errorf("NS %s: no addresses", host)
+		return
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		server := netip.AddrPortFrom(addr, 53).String()
+		resp, err := t.c.QueryServer(t.ctx, server, name, dnswire.TypeSOA)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		msg := resp.Msg
+		if msg.Rcode == dnswire.RcodeSuccess && msg.Authoritative && hasSOA(name, msg.Answer) {
+			return
+		}
+		answer := dnswire.RcodeToString[msg.Rcode]
+		if msg.Rcode == dnswire.RcodeSuccess {
+			answer = "a non-authoritative answer"
+		}
+		f.Status, f.Evidence = TakeoverLame, fmt.Sprintf("%s returned %s for the zone", server, answer)
+		if f.Service != "" {
+			f.Status = TakeoverVulnerable
+			f.Evidence += "; the zone can be created at " + f.Service
+		}
+		tc.findings = append(tc.findings, f)
+		return
+	}
+	tc.errorf("SOA %s at %s: %v", tc.host.Name, host, lastErr)
+}
+
+func hasSOA(name string, rrs []dnswire.RR) bool {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dnswire.SOA); ok && strings.EqualFold(soa.Hdr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
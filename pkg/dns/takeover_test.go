@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"*.github.io", "acme.github.io", true},
+		{"*.github.io", "acme.github.io.", true},
+		{"*.github.io", "ACME.GitHub.IO.", true},
+		{"*.github.io", "a.b.github.io", true},
+		// The suffix alone is not a name under it
+		{"*.github.io", "github.io", false},
+		{"*.github.io", "evilgithub.io", false},
+		{"*.github.io", "acme.github.io.example.com", false},
+		{"stats.uptimerobot.com", "stats.uptimerobot.com.", true},
+		{"stats.uptimerobot.com", "x.stats.uptimerobot.com", false},
+		{"stats.uptimerobot.com", "stats.uptimerobot.co", false},
+		// * within a label
+		{"*.s3-website*.amazonaws.com", "b.s3-website-us-east-1.amazonaws.com", true},
+		{"*.s3-website*.amazonaws.com", "b.s3-website.amazonaws.com", true},
+		{"*.s3-website*.amazonaws.com", "b.s3.amazonaws.com", false},
+		// * across labels
+		{"*.s3.*.amazonaws.com", "b.s3.dualstack.eu-west-1.amazonaws.com", true},
+		{"*.s3.*.amazonaws.com", "b.s3.amazonaws.com", false},
+		{"ns-*.awsdns-*.co.uk", "ns-1536.awsdns-00.co.uk", true},
+		{"ns-*.awsdns-*.co.uk", "ns-1536.awsdns-00.com", false},
+		{"ns*-*.azure-dns.com", "ns1-01.azure-dns.com", true},
+		{"ns*-*.azure-dns.com", "ns1.azure-dns.com", false},
+		{"dns*.p*.nsone.net", "dns1.p01.nsone.net", true},
+		// The middle and the suffix may not share characters
+		{"a*ab", "ab", false},
+		{"a*ab", "aab", true},
+		{"*a*a", "a", false},
+		{"*a*a", "aa", true},
+		{"*", "anything.example", true},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestParseTakeoverSignatures(t *testing.T) {
+	input := `# comment
+
+Service Example Pages
+cname *.Pages.Example.NET  pages.example.net
+cname *.pages-cdn.example.net
+nxdomain
+status 404
+body There is no site here
+body   Project not found
+https
+
+Service Example DNS
+ns ns*.example-dns.com
+`
+	sigs, err := ParseTakeoverSignatures(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*TakeoverSignature{
+		{
+			Service:  "Example Pages",
+			CNAME:    []string{"*.pages.example.net", "pages.example.net", "*.pages-cdn.example.net"},
+			NXDomain: true,
+			Status:   404,
+			Body:     []string{"There is no site here", "Project not found"},
+			HTTPS:    true,
+		},
+		{
+			Service: "Example DNS",
+			NS:      []string{"ns*.example-dns.com"},
+		},
+	}
+	if !reflect.DeepEqual(sigs, want) {
+		for i, s := range sigs {
+			t.Logf("signature %d: %+v", i, *s)
+		}
+		t.Fatal("signatures differ")
+	}
+
+	errs := []struct {
+		input string
+		err   string
+	}{
+		{"", "no signatures defined"},
+		{"# only comments\n", "no signatures defined"},
+		{"cname *.example.net\n", "line 1: cname before the first Service"},
+		{"Service\n", "line 1: Service needs a name"},
+		{"Service A\ncname *.a.net\nstatus abc\n", `line 3: strconv.Atoi: parsing "abc"`},
+		{"Service A\ncname *.a.net\nstatus 700\n", "line 3: invalid HTTP status 700"},
+		{"Service A\ncname *.a.net\nbody\n", "line 3: empty body text"},
+		{"Service A\n\ncname *.a.net\nfingerprint x\n", `line 4: unknown directive "fingerprint"`},
+		{"Service A\ncname *.a.net\nService B\nstatus 404\n", "signature B has no cname or ns patterns"},
+	}
+	for _, tt := range errs {
+		_, err := ParseTakeoverSignatures(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: err = %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestLoadTakeoverSignatures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.txt")
+	if err := os.WriteFile(path, []byte("Service A\ncname *.a.net\nstatus 4o4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTakeoverSignatures(path); err == nil || !strings.HasPrefix(err.Error(), path+": line 3:") {
+		t.Errorf("err = %v, want the path and line", err)
+	}
+	if _, err := LoadTakeoverSignatures(filepath.Join(t.TempDir(), "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v", err)
+	}
+
+	// The built-in signatures parse and cover the common services.
+	services := map[string]*TakeoverSignature{}
+	for _, s := range DefaultTakeoverSignatures() {
+		services[s.Service] = s
+	}
+	for host, service := range map[string]string{
+		"bucket.s3.amazonaws.com.": "AWS S3",
+		"acme.github.io.":          "GitHub Pages",
+		"acme.herokuapp.com.":      "Heroku",
+	} {
+		if s := services[service]; s == nil || !matchAny(s.CNAME, host) {
+			t.Errorf("%s is not matched by %s", host, service)
+		}
+	}
+	if s := services["AWS Route 53"]; s == nil || !matchAny(s.NS, "ns-1536.awsdns-00.co.uk.") {
+		t.Error("Route 53 nameserver not matched")
+	}
+}
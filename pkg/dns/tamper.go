@@ -0,0 +1,216 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// DefaultEncryptedResolvers are compared against plain DNS when no
+// encrypted resolver is given.
+var DefaultEncryptedResolvers = []string{
+	"https://cloudflare-dns.com/dns-query",
+	"https://dns.google/dns-query",
+}
+
+// interceptionProbe is an address in TEST-NET-1 (RFC 5737), where no DNS
+// server runs. An answer from it means something on the path answers
+// port 53 for any destination.
+const interceptionProbe = "192.0.2.1:53"
+
+// Tampering verdicts.
+const (
+	// TamperClean means plain and encrypted DNS agree, or differ only in
+	// ways resolvers legitimately do.
+	TamperClean = "clean"
+	// TamperSuspicious means plain DNS denied or redirected names that
+	// encrypted DNS resolves.
+	TamperSuspicious = "suspicious"
+	// TamperIntercepted means port 53 traffic is answered by something
+	// other than the addressed server.
+	TamperIntercepted = "intercepted"
+)
+
+// TamperResult compares the answers of plain and encrypted resolvers
+// for the same questions.
+type TamperResult struct {
+	Plain     []string `json:"plain"`
+	Encrypted []string `json:"encrypted"`
+	// Intercepted is set when a query to an address without a DNS
+	// server was answered.
+	Intercepted bool               `json:"intercepted"`
+	Differences []TamperDifference `json:"differences"`
+	// Verdict is TamperClean, TamperSuspicious or TamperIntercepted.
+	Verdict string `json:"verdict"`
+}
+
+// TamperDifference is a question plain and encrypted DNS answered
+// differently.
+type TamperDifference struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Plain and Encrypted are the record data, or the response code or
+	// error when there were no records.
+	Plain     []string `json:"plain"`
+	Encrypted []string `json:"encrypted"`
+	// Suspicious marks differences typical of tampering rather than of
+	// resolvers answering by location.
+	Suspicious bool   `json:"suspicious"`
+	Reason     string `json:"reason"`
+}
+
+// detectTampering asks every question over the plain and the encrypted
+// resolvers and compares the answers, probing for port 53 interception
+// alongside.
+func detectTampering(ctx context.Context, c *Client, queries []query, encrypted []string) (*TamperResult, error) {
+	r := &TamperResult{Plain: []string{}, Encrypted: []string{}, Differences: []TamperDifference{}}
+	for _, s := range c.servers() {
+		if strings.Contains(s, "://") {
+			r.Encrypted = append(r.Encrypted, s)
+		} else {
+			r.Plain = append(r.Plain, s)
+		}
+	}
+	if len(r.Plain) == 0 {
+		r.Plain = SystemServers()
+	}
+	if len(encrypted) > 0 || len(r.Encrypted) == 0 {
+		if len(encrypted) == 0 {
+			encrypted = DefaultEncryptedResolvers
+		}
+		r.Encrypted = r.Encrypted[:0]
+		for _, s := range encrypted {
+			server, err := NormalizeServer(s)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.Contains(server, "://") {
+				return nil, fmt.Errorf("encrypted resolver %q must be a tls:// or https:// address", s)
+			}
+			r.Encrypted = append(r.Encrypted, server)
+		}
+	}
+
+	intercepted := make(chan bool, 1)
+	go func() {
+		_, err := c.exchange(ctx, c.newQuery(".", dnswire.TypeNS), interceptionProbe)
+		intercepted <- err == nil
+	}()
+
+	diffs := make([]*TamperDifference, len(queries))
+	forEach(ctx, len(queries), func(i int) {
+		q := queries[i]
+		plain := tamperAnswer(c.query(ctx, r.Plain, q.name, q.qtype))
+		enc := tamperAnswer(c.query(ctx, r.Encrypted, q.name, q.qtype))
+		if sameStrings(plain.values, enc.values) {
+			return
+		}
+		d := &TamperDifference{Name: q.name, Type: dnswire.TypeToString[q.qtype], Plain: plain.values, Encrypted: enc.values}
+		d.Suspicious, d.Reason = classifyDifference(plain, enc)
+		diffs[i] = d
+	})
+	r.Intercepted = <-intercepted
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+
+	r.Verdict = TamperClean
+	for _, d := range diffs {
+		if d == nil {
+			continue
+		}
+		r.Differences = append(r.Differences, *d)
+		if d.Suspicious {
+			r.Verdict = TamperSuspicious
+		}
+	}
+	if r.Intercepted {
+		r.Verdict = TamperIntercepted
+	}
+	return r, nil
+}
+
+// tamperReply is one side of a comparison.
+type tamperReply struct {
+	// values are the sorted record data, or the response code or error
+	// when there are no records.
+	values  []string
+	records bool
+	failed  bool
+	rcode   int
+}
+
+func tamperAnswer(resp *Response, err error) tamperReply {
+	if err != nil {
+		return tamperReply{values: []string{"error: " + err.Error()}, failed: true}
+	}
+	t := tamperReply{rcode: resp.Msg.Rcode}
+	for _, rr := range resp.Msg.Answer {
+		if rr.Header().Rrtype == resp.Msg.Question[0].Qtype {
+			t.values = append(t.values, rdata(rr))
+		}
+	}
+	sort.Strings(t.values)
+	t.records = len(t.values) > 0
+	if !t.records {
+		t.values = []string{dnswire.RcodeToString[resp.Msg.Rcode]}
+		if resp.Msg.Rcode == dnswire.RcodeSuccess {
+			t.values = []string{"NODATA"}
+		}
+	}
+	return t
+}
+
+// classifyDifference tells blocking and redirection apart from the
+// differences CDNs and load balancers cause between resolvers.
+func classifyDifference(plain, enc tamperReply) (bool, string) {
+	switch {
+	case plain.failed && !enc.failed:
+		return false, "the plain query failed; port 53 may be blocked"
+	case enc.failed:
+		return false, "the encrypted query failed"
+	case !plain.records && enc.records:
+		return true, fmt.Sprintf("plain DNS answered %s for a name encrypted DNS resolves", plain.values[0])
+	case plain.records && !enc.records:
+		return true, "plain DNS returned records encrypted DNS does not have"
+	}
+	for _, v := range plain.values {
+		addr, err := netip.ParseAddr(v)
+		if err == nil && sinkholeAddress(addr) && !containsString(enc.values, v) {
+			return true, fmt.Sprintf("plain DNS redirects to %s, a non-public address", v)
+		}
+	}
+	return false, "answers differ; CDNs and load balancers answer differently by resolver"
+}
+
+// sinkholeAddress reports whether addr is an address blocking resolvers
+// commonly answer with.
+func sinkholeAddress(addr netip.Addr) bool {
+	return addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || netip.MustParsePrefix("100.64.0.0/10").Contains(addr)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// DefaultTypes are the record types Lookup queries for a domain when
+// Options.Types is empty.
+var DefaultTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT", "SOA", "CAA"}
+
+// AllTypes are every record type Lookup supports, in report order.
+var AllTypes = []string{
+	"A", "AAAA", "CNAME", "MX", "NS", "TXT", "SOA", "CAA",
+	"SRV", "PTR", "DNSKEY", "DS", "TLSA", "HTTPS", "SVCB", "NAPTR",
+}
+
+// SRVServices are the service labels queried under the domain for SRV
+// records.
+var SRVServices = []string{
+	"_autodiscover._tcp", "_caldav._tcp", "_caldavs._tcp", "_carddav._tcp",
+	"_carddavs._tcp", "_gc._tcp", "_h323cs._tcp", "_http._tcp",
+	"_https._tcp", "_imap._tcp", "_imaps._tcp", "_jabber._tcp",
+	"_kerberos._tcp", "_kerberos._udp", "_kpasswd._tcp", "_kpasswd._udp",
+	"_ldap._tcp", "_ldap._tcp.dc._msdcs", "_matrix._tcp", "_minecraft._tcp",
+	"_mongodb._tcp", "_pop3._tcp", "_pop3s._tcp", "_sip._tcp",
+	"_sip._tls", "_sip._udp", "_sipfederationtls._tcp", "_sips._tcp",
+	"_stun._udp", "_submission._tcp", "_submissions._tcp", "_turn._udp",
+	"_xmpp-client._tcp", "_xmpp-server._tcp",
+}
+
+// TLSAPorts are the TCP ports queried under the domain for TLSA
+// (DANE) records.
+var TLSAPorts = []int{25, 443, 465, 587, 993, 995}
+
+// ParseTypes validates record type names, accepting "ALL" for AllTypes.
+// Names are case-insensitive and may be comma separated.
+func ParseTypes(names []string) ([]string, error) {
+	var types []string
+	seen := map[string]bool{}
+	for _, entry := range names {
+		for _, name := range strings.Split(entry, ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == "ALL" {
+				for _, t := range AllTypes {
+					if !seen[t] {
+						seen[t] = true
+						types = append(types, t)
+					}
+				}
+				continue
+			}
+			if !supportedType(name) {
+				return nil, fmt.Errorf("unsupported record type %q (supported: %s, ALL)", name, strings.Join(AllTypes, ", "))
+			}
+			if !seen[name] {
+				seen[name] = true
+				types = append(types, name)
+			}
+		}
+	}
+	return types, nil
+}
+
+func supportedType(name string) bool {
+	for _, t := range AllTypes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// query is one question Lookup asks.
+type query struct {
+	name  string
+	qtype uint16
+}
+
+// buildQueries expands the selected types into questions: SRV and TLSA
+// are asked for every well-known service and port under the domain, and
+// an IP address target is looked up through its reverse name.
+func buildQueries(target string, types []string) ([]query, error) {
+	if addr, err := netip.ParseAddr(target); err == nil {
+		reverse, err := dnswire.ReverseAddr(addr.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range types {
+			if t != "PTR" {
+				return nil, fmt.Errorf("record type %s needs a domain name, not an IP address", t)
+			}
+		}
+		return []query{{name: reverse, qtype: dnswire.TypePTR}}, nil
+	}
+
+	fqdn := dnswire.Fqdn(target)
+	var queries []query
+	for _, t := range types {
+		qtype := dnswire.StringToType[t]
+		switch t {
+		case "SRV":
+			for _, service := range SRVServices {
+				queries = append(queries, query{name: service + "." + fqdn, qtype: qtype})
+			}
+		case "TLSA":
+			for _, port := range TLSAPorts {
+				queries = append(queries, query{name: "_" + strconv.Itoa(port) + "._tcp." + fqdn, qtype: qtype})
+			}
+		default:
+			queries = append(queries, query{name: fqdn, qtype: qtype})
+		}
+	}
+	return queries, nil
+}
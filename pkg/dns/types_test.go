@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	dnswire "github.com/miekg/dns"
+)
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+		err   string
+	}{
+		{name: "none", input: nil, want: nil},
+		{name: "case-insensitive", input: []string{"a", "Aaaa", "mx"}, want: []string{"A", "AAAA", "MX"}},
+		{name: "comma separated", input: []string{"A, MX,,txt", " NS "}, want: []string{"A", "MX", "TXT", "NS"}},
+		{name: "duplicates", input: []string{"A,a", "A"}, want: []string{"A"}},
+		{name: "all", input: []string{"all"}, want: AllTypes},
+		// ALL keeps the order of the types named before it.
+		{name: "all after others", input: []string{"TLSA,all", "mx"}, want: append([]string{"TLSA"}, withoutType(AllTypes, "TLSA")...)},
+		{name: "unknown", input: []string{"A", "AXFR"}, err: `unsupported record type "AXFR" (supported: A, AAAA,`},
+		{name: "numeric", input: []string{"TYPE65"}, err: `unsupported record type "TYPE65"`},
+	}
+	for _, tt := range tests {
+		got, err := ParseTypes(tt.input)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseTypes(%q) = %q, %v, want %q", tt.name, tt.input, got, err, tt.want)
+		}
+	}
+
+	// Every supported type is one the wire format knows.
+	for _, typ := range AllTypes {
+		if dnswire.StringToType[typ] == 0 {
+			t.Errorf("%s has no query type", typ)
+		}
+	}
+}
+
+func withoutType(types []string, drop string) []string {
+	var out []string
+	for _, t := range types {
+		if t != drop {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func TestBuildQueries(t *testing.T) {
+	queries, err := buildQueries("example.com", []string{"A", "SRV", "TLSA", "PTR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1+len(SRVServices)+len(TLSAPorts)+1 {
+		t.Fatalf("%d queries", len(queries))
+	}
+	checks := []struct {
+		i     int
+		name  string
+		qtype uint16
+	}{
+		{i: 0, name: "example.com.", qtype: dnswire.TypeA},
+		{i: 1, name: "_autodiscover._tcp.example.com.", qtype: dnswire.TypeSRV},
+		{i: len(SRVServices), name: "_xmpp-server._tcp.example.com.", qtype: dnswire.TypeSRV},
+		{i: 1 + len(SRVServices), name: "_25._tcp.example.com.", qtype: dnswire.TypeTLSA},
+		{i: len(SRVServices) + len(TLSAPorts), name: "_995._tcp.example.com.", qtype: dnswire.TypeTLSA},
+		{i: len(queries) - 1, name: "example.com.", qtype: dnswire.TypePTR},
+	}
+	for _, c := range checks {
+		if q := queries[c.i]; q.name != c.name || q.qtype != c.qtype {
+			t.Errorf("query %d = %s %s, want %s %s", c.i, q.name, dnswire.TypeToString[q.qtype], c.name, dnswire.TypeToString[c.qtype])
+		}
+	}
+
+	// An address is looked up through its reverse name.
+	for target, reverse := range map[string]string{
+		"192.0.2.1":   "1.2.0.192.in-addr.arpa.",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		queries, err := buildQueries(target, []string{"PTR"})
+		if err != nil || len(queries) != 1 || queries[0].name != reverse || queries[0].qtype != dnswire.TypePTR {
+			t.Errorf("%s: queries %+v, %v, want PTR %s", target, queries, err, reverse)
+		}
+	}
+	if _, err := buildQueries("192.0.2.1", []string{"PTR", "A"}); err == nil || !strings.Contains(err.Error(), "record type A needs a domain name") {
+		t.Errorf("A query of an address: %v", err)
+	}
+}
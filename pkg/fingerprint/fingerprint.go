@@ -0,0 +1,259 @@
+// Package fingerprint identifies the service behind an open TCP port by
+// reading its banner and sending protocol probes, in the spirit of
+// nmap's version detection.
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxResponse caps how much of a response is read and matched.
+const maxResponse = 8192
+
+// idleWait is how long to keep reading once a response has started, to
+// collect replies split across several segments.
+const idleWait = 300 * time.Millisecond
+
+// Options configures Detect.
+type Options struct {
+	// Probes to try; nil means DefaultProbes().
+	Probes []*Probe
+	// Timeout is the dial timeout for each probe connection.
+	Timeout time.Duration
+	// Wait overrides the per-probe response wait when non-zero.
+	Wait time.Duration
+}
+
+// Result describes the service identified on a port.
+type Result struct {
+	Service    string  `json:"service"`
+	Product    string  `json:"product,omitempty"`
+	Version    string  `json:"version,omitempty"`
+	Info       string  `json:"info,omitempty"`
+	Confidence float64 `json:"confidence"`
+	// Probe is the name of the probe whose response matched.
+	Probe string `json:"probe,omitempty"`
+	// Banner is the first printable line the service sent.
+	Banner string `json:"banner,omitempty"`
+}
+
+// Detect connects to address once per probe until a rule matches. It
+// returns nil when nothing matched, in which case the caller falls back
+// to a port-based guess. A soft match is returned if no probe found a
+// full match.
+func Detect(ctx context.Context, network, address string, opts Options) (*Result, error) {
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	probes := opts.Probes
+	if probes == nil {
+		probes = DefaultProbes()
+	}
+
+	var soft *Result
+	banner := ""
+	for _, p := range orderProbes(probes, port) {
+		if soft != nil && !probeKnows(p, soft.Service) {
+			// Once the service is known only probes for it can add a version
+			continue
+		}
+
+		resp, err := exchange(ctx, network, address, p, opts)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && len(resp) == 0 {
+			continue
+		}
+		if banner == "" {
+			banner = firstLine(resp)
+		}
+
+		res := match(p, resp)
+		if res == nil {
+			continue
+		}
+		res.Banner = banner
+		if !res.soft {
+			return &res.Result, nil
+		}
+		if soft == nil {
+			soft = &res.Result
+		}
+	}
+
+	if soft != nil {
+		return soft, nil
+	}
+	if banner != "" {
+		return &Result{Banner: banner}, nil
+	}
+	return nil, nil
+}
+
+// orderProbes returns the NULL probe first, then probes registered for
+// port, then the remaining probes, each group in definition order.
+func orderProbes(probes []*Probe, port int) []*Probe {
+	ordered := make([]*Probe, 0, len(probes))
+	for _, p := range probes {
+		if len(p.Payload) == 0 {
+			ordered = append(ordered, p)
+		}
+	}
+	for _, p := range probes {
+		if len(p.Payload) > 0 && p.Ports[port] {
+			ordered = append(ordered, p)
+		}
+	}
+	for _, p := range probes {
+		if len(p.Payload) > 0 && !p.Ports[port] {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+func probeKnows(p *Probe, service string) bool {
+	for _, r := range p.Rules {
+		if !r.Soft && r.Service == service {
+			return true
+		}
+	}
+	return false
+}
+
+// exchange opens a connection, sends the probe payload and reads the
+// response until the server goes quiet, closes, or the wait expires.
+func exchange(ctx context.Context, network, address string, p *Probe, opts Options) ([]byte, error) {
+	d := net.Dialer{Timeout: opts.Timeout}
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Unblock reads when the scan is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	wait := p.Wait
+	if opts.Wait > 0 {
+		wait = opts.Wait
+	}
+	deadline := time.Now().Add(wait)
+	conn.SetDeadline(deadline)
+
+	if len(p.Payload) > 0 {
+		if _, err := conn.Write(p.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, maxResponse)
+	n := 0
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && n > 0 {
+				err = nil
+			}
+			return buf[:n], err
+		}
+		if idle := time.Now().Add(idleWait); idle.Before(deadline) {
+			conn.SetReadDeadline(idle)
+		}
+	}
+	return buf[:n], nil
+}
+
+type matchResult struct {
+	Result
+	soft bool
+}
+
+// match runs the rules of p against resp. Responses are matched as
+// Latin-1 so that \xHH in a pattern matches the byte 0xHH.
+func match(p *Probe, resp []byte) *matchResult {
+	text := latin1(resp)
+	for _, r := range p.Rules {
+		groups := r.Pattern.FindStringSubmatch(text)
+		if groups == nil {
+			continue
+		}
+		return &matchResult{
+			Result: Result{
+				Service:    r.Service,
+				Product:    expand(r.Product, groups),
+				Version:    expand(r.Version, groups),
+				Info:       expand(r.Info, groups),
+				Confidence: r.Confidence,
+				Probe:      p.Name,
+			},
+			soft: r.Soft,
+		}
+	}
+	return nil
+}
+
+var groupRef = regexp.MustCompile(`\$[1-9]`)
+
+// expand substitutes capture groups into a template and tidies the result.
+func expand(template string, groups []string) string {
+	s := groupRef.ReplaceAllStringFunc(template, func(ref string) string {
+		i := int(ref[1] - '0')
+		if i < len(groups) {
+			return groups[i]
+		}
+		return ""
+	})
+	return strings.Join(strings.Fields(printable(s)), " ")
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 && unicode.IsPrint(r) {
+			return r
+		}
+		return ' '
+	}, s)
+}
+
+// firstLine returns the first line of resp if it is mostly text.
+func firstLine(resp []byte) string {
+	line := string(resp)
+	if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+	clean := strings.TrimSpace(printable(line))
+	if len(clean) < 4 || strings.Count(clean, " ") > len(clean)/2 {
+		return ""
+	}
+	const maxBanner = 120
+	if len(clean) > maxBanner {
+		clean = clean[:maxBanner]
+	}
+	return clean
+}
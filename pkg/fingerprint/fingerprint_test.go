@@ -0,0 +1,195 @@
+package fingerprint
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadTestProbes(t *testing.T) map[string]*Probe {
+	t.Helper()
+	probes, err := LoadProbes("testdata/probes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]*Probe{}
+	for _, p := range probes {
+		byName[p.Name] = p
+	}
+	return byName
+}
+
+func TestParseProbes(t *testing.T) {
+	probes, err := LoadProbes("testdata/probes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range probes {
+		names = append(names, p.Name)
+	}
+	if want := []string{"NULL", "GetRequest", "Binary"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("probes %q, want %q", names, want)
+	}
+
+	null, get, binary := probes[0], probes[1], probes[2]
+	if len(null.Payload) != 0 || null.Wait != DefaultWait || len(null.Ports) != 0 || len(null.Rules) != 3 {
+		t.Errorf("NULL = %+v", null)
+	}
+	if string(get.Payload) != "GET / HTTP/1.0\r\n\r\n" || get.Wait != 500*time.Millisecond {
+		t.Errorf("GetRequest payload %q, wait %v", get.Payload, get.Wait)
+	}
+	if want := map[int]bool{80: true, 443: true, 8000: true, 8001: true, 8002: true}; !reflect.DeepEqual(get.Ports, want) {
+		t.Errorf("GetRequest ports %v, want %v", get.Ports, want)
+	}
+	if want := []byte{0x16, 0x03, 0x01, 0, '\t', '\\'}; !bytes.Equal(binary.Payload, want) {
+		t.Errorf("Binary payload %q, want %q", binary.Payload, want)
+	}
+
+	rules := []struct {
+		rule       Rule
+		service    string
+		product    string
+		confidence float64
+		soft       bool
+	}{
+		{rule: null.Rules[0], service: "SSH", product: "OpenSSH", confidence: 0.98},
+		{rule: null.Rules[1], service: "FTP", product: "$1 ftpd", confidence: matchConfidence},
+		{rule: null.Rules[2], service: "FTP", confidence: softmatchConfidence, soft: true},
+		{rule: get.Rules[1], service: "HTTP", confidence: softmatchConfidence, soft: true},
+		{rule: binary.Rules[0], service: "TLS", product: "TLS", confidence: 0.6},
+	}
+	for _, tt := range rules {
+		r := tt.rule
+		if r.Service != tt.service || r.Product != tt.product || r.Confidence != tt.confidence || r.Soft != tt.soft {
+			t.Errorf("rule %s = %+v, want %s %q cf %v soft %v", r.Pattern, r, tt.service, tt.product, tt.confidence, tt.soft)
+		}
+	}
+	// The regex flags become inline flags.
+	if got := null.Rules[2].Pattern.String(); !strings.HasPrefix(got, "(?i)") {
+		t.Errorf("softmatch pattern %s, want case-insensitive", got)
+	}
+	if got := get.Rules[0].Pattern.String(); !strings.HasPrefix(got, "(?s)") {
+		t.Errorf("match pattern %s, want dot matching newlines", got)
+	}
+
+	// The built-in file parses too.
+	if len(DefaultProbes()) == 0 {
+		t.Error("no built-in probes")
+	}
+}
+
+func TestParseProbesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "empty", input: "# nothing\n", err: "no probes defined"},
+		{name: "directive before Probe", input: "match SSH m|^SSH|\n", err: "line 1: match before the first Probe"},
+		{name: "UDP probe", input: "Probe UDP DNS q||\n", err: `line 1: unsupported probe protocol "UDP"`},
+		{name: "short Probe line", input: "Probe TCP\n", err: "malformed Probe line"},
+		{name: "payload without q", input: "Probe TCP X |abc|\n", err: "payload must be q|...|"},
+		{name: "unterminated payload", input: "Probe TCP X q|abc\n", err: "unterminated payload"},
+		{name: "bad escape", input: `Probe TCP X q|\q|` + "\n", err: `unknown escape \q`},
+		{name: "short hex escape", input: `Probe TCP X q|\x4|` + "\n", err: `truncated \x escape`},
+		{name: "bad hex escape", input: `Probe TCP X q|\xzz|` + "\n", err: `invalid escape \xzz`},
+		{name: "bad ports", input: "Probe TCP X q||\nports 80,0\n", err: `line 2: invalid port "0"`},
+		{name: "reversed range", input: "Probe TCP X q||\nports 90-80\n", err: `invalid port range "90-80"`},
+		{name: "zero waitms", input: "Probe TCP X q||\nwaitms 0\n", err: "waitms must be positive"},
+		{name: "unknown directive", input: "Probe TCP X q||\nrarity 3\n", err: `line 2: unknown directive "rarity"`},
+		{name: "match without regex", input: "Probe TCP X q||\nmatch SSH\n", err: "malformed match line"},
+		{name: "unterminated regex", input: "Probe TCP X q||\nmatch SSH m|^SSH\n", err: "SSH: unterminated regex"},
+		{name: "unknown flag", input: "Probe TCP X q||\nmatch SSH m|^SSH|x\n", err: `unknown regex flag "x"`},
+		{name: "invalid regex", input: "Probe TCP X q||\nmatch SSH m|^(SSH|\n", err: "SSH: error parsing regexp"},
+		{name: "unknown field", input: "Probe TCP X q||\nmatch SSH m|^SSH| o/x/\n", err: `unknown field "o"`},
+		{name: "unterminated field", input: "Probe TCP X q||\nmatch SSH m|^SSH| p/OpenSSH\n", err: "unterminated p field"},
+		{name: "confidence out of range", input: "Probe TCP X q||\nmatch SSH m|^SSH| cf/1.5/\n", err: `confidence "1.5" must be in (0, 1]`},
+	}
+	for _, tt := range tests {
+		_, err := ParseProbes(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	probes := loadTestProbes(t)
+	tests := []struct {
+		name  string
+		probe string
+		resp  string
+		want  *Result
+		soft  bool
+	}{
+		{
+			name: "OpenSSH", probe: "NULL", resp: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n",
+			want: &Result{Service: "SSH", Product: "OpenSSH", Version: "9.6p1", Info: "protocol 2.0 Ubuntu-3ubuntu13", Confidence: 0.98, Probe: "NULL"},
+		},
+		{
+			// Trailing spaces from an empty group are tidied away.
+			name: "OpenSSH without comment", probe: "NULL", resp: "SSH-2.0-OpenSSH_8.0\r\n",
+			want: &Result{Service: "SSH", Product: "OpenSSH", Version: "8.0", Info: "protocol 2.0", Confidence: 0.98, Probe: "NULL"},
+		},
+		{
+			name: "alternation delimiter", probe: "NULL", resp: "220 ProFTPD 1.3.8 Server ready\r\n",
+			want: &Result{Service: "FTP", Product: "1.3.8 ftpd", Confidence: matchConfidence, Probe: "NULL"},
+		},
+		{
+			name: "case-insensitive softmatch", probe: "NULL", resp: "220 Welcome to the example.com FTP service\r\n",
+			want: &Result{Service: "FTP", Confidence: softmatchConfidence, Probe: "NULL"}, soft: true,
+		},
+		{name: "no match", probe: "NULL", resp: "hello\r\n"},
+		{
+			// A group the pattern doesn't have expands to nothing.
+			name: "header across lines", probe: "GetRequest", resp: "HTTP/1.1 200 OK\r\nDate: now\r\nServer: nginx/1.25.3\r\n\r\n",
+			want: &Result{Service: "HTTP", Product: "nginx", Version: "1.25.3", Confidence: matchConfidence, Probe: "GetRequest"},
+		},
+		{
+			name: "HTTP softmatch", probe: "GetRequest", resp: "HTTP/1.0 404 Not Found\r\nServer: Apache\r\n\r\n",
+			want: &Result{Service: "HTTP", Confidence: softmatchConfidence, Probe: "GetRequest"}, soft: true,
+		},
+		{
+			// Bytes, NULs included, match \xHH escapes one to one.
+			name: "binary", probe: "Binary", resp: "\x16\x03\x03\x00\x02\x02\x46",
+			want: &Result{Service: "TLS", Product: "TLS", Confidence: 0.6, Probe: "Binary"},
+		},
+		{name: "binary mismatch", probe: "Binary", resp: "\x15\x03\x03\x00\x02\x02\x46"},
+	}
+	for _, tt := range tests {
+		m := match(probes[tt.probe], []byte(tt.resp))
+		switch {
+		case m == nil && tt.want == nil:
+		case m == nil || tt.want == nil:
+			t.Errorf("%s: match = %+v, want %+v", tt.name, m, tt.want)
+		case m.Result != *tt.want || m.soft != tt.soft:
+			t.Errorf("%s: match = %+v soft %v, want %+v soft %v", tt.name, m.Result, m.soft, *tt.want, tt.soft)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	groups := []string{"whole", "2.0", "OpenSSH_9.6", "", "a\x00b\r\nc"}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "", want: ""},
+		{template: "no groups", want: "no groups"},
+		{template: "$2", want: "OpenSSH_9.6"},
+		{template: "protocol $1 $3", want: "protocol 2.0"},
+		{template: "$1$2", want: "2.0OpenSSH_9.6"},
+		{template: "$9 missing", want: "missing"},
+		{template: "$0 and $ stay", want: "$0 and $ stay"},
+		{template: "$4", want: "a b c"},
+		{template: "$12", want: "2.02"},
+	}
+	for _, tt := range tests {
+		if got := expand(tt.template, groups); got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package fingerprint
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed service-probes.txt
+var defaultProbes string
+
+// DefaultWait is how long a probe waits for a response when its
+// definition has no waitms line.
+const DefaultWait = 3 * time.Second
+
+// Default confidences for matches that don't set cf/.
+const (
+	matchConfidence     = 0.9
+	softmatchConfidence = 0.5
+)
+
+// Probe is one request sent to a service together with the patterns its
+// response is matched against.
+type Probe struct {
+	Name    string
+	Payload []byte
+	// Ports lists the ports this probe is tried on before the others.
+	Ports map[int]bool
+	Wait  time.Duration
+	Rules []Rule
+}
+
+// Rule is a match or softmatch line. Product, Version and Info are
+// templates that may reference capture groups as $1-$9.
+type Rule struct {
+	Service    string
+	Pattern    *regexp.Regexp
+	Product    string
+	Version    string
+	Info       string
+	Confidence float64
+	// Soft rules identify the service but keep probing for a version.
+	Soft bool
+}
+
+// DefaultProbes returns the probe definitions built into AFSA.
+func DefaultProbes() []*Probe {
+	probes, err := ParseProbes(strings.NewReader(defaultProbes))
+	if err != nil {
+		panic("fingerprint: built-in probes: " + err.Error())
+	}
+	return probes
+}
+
+// LoadProbes reads probe definitions from a file in the
+// service-probes.txt format.
+func LoadProbes(path string) ([]*Probe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	probes, err := ParseProbes(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return probes, nil
+}
+
+// ParseProbes parses probe definitions, a subset of the nmap-service-probes
+// format: Probe, ports, waitms, match and softmatch lines.
+func ParseProbes(r io.Reader) ([]*Probe, error) {
+	var probes []*Probe
+	var current *Probe
+
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+
+		if directive == "Probe" {
+			p, err := parseProbeLine(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			probes = append(probes, p)
+			current = p
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: %s before the first Probe", line, directive)
+		}
+
+		var err error
+		switch directive {
+		case "ports":
+			current.Ports, err = parsePortList(rest)
+		case "waitms":
+			var ms int
+			ms, err = strconv.Atoi(rest)
+			if err == nil && ms <= 0 {
+				err = fmt.Errorf("waitms must be positive")
+			}
+			current.Wait = time.Duration(ms) * time.Millisecond
+		case "match", "softmatch":
+			var rule Rule
+			rule, err = parseRule(rest, directive == "softmatch")
+			current.Rules = append(current.Rules, rule)
+		default:
+			err = fmt.Errorf("unknown directive %q", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("no probes defined")
+	}
+	return probes, nil
+}
+
+// parseProbeLine parses "TCP <name> q|<payload>|".
+func parseProbeLine(s string) (*Probe, error) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("malformed Probe line, want: Probe TCP <name> q|<payload>|")
+	}
+	if fields[0] != "TCP" {
+		return nil, fmt.Errorf("unsupported probe protocol %q", fields[0])
+	}
+
+	spec := strings.TrimSpace(fields[2])
+	if !strings.HasPrefix(spec, "q") || len(spec) < 3 {
+		return nil, fmt.Errorf("probe %s: payload must be q|...|", fields[1])
+	}
+	raw, tail, ok := cutDelimited(spec[1:])
+	if !ok || strings.TrimSpace(tail) != "" {
+		return nil, fmt.Errorf("probe %s: unterminated payload", fields[1])
+	}
+	payload, err := unescape(raw)
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", fields[1], err)
+	}
+	return &Probe{Name: fields[1], Payload: payload, Wait: DefaultWait}, nil
+}
+
+// parseRule parses "<service> m|<regex>|[flags] [p/../] [v/../] [i/../] [cf/../]".
+func parseRule(s string, soft bool) (Rule, error) {
+	service, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	if service == "" || !strings.HasPrefix(rest, "m") {
+		return Rule{}, fmt.Errorf("malformed match line, want: match <service> m|<regex>|")
+	}
+
+	expr, rest, ok := cutDelimited(rest[1:])
+	if !ok {
+		return Rule{}, fmt.Errorf("%s: unterminated regex", service)
+	}
+	flags := ""
+	for rest != "" && rest[0] != ' ' {
+		switch rest[0] {
+		case 'i', 's':
+			flags += rest[:1]
+		default:
+			return Rule{}, fmt.Errorf("%s: unknown regex flag %q", service, rest[:1])
+		}
+		rest = rest[1:]
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return Rule{}, fmt.Errorf("%s: %w", service, err)
+	}
+
+	rule := Rule{Service: service, Pattern: pattern, Soft: soft, Confidence: matchConfidence}
+	if soft {
+		rule.Confidence = softmatchConfidence
+	}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key := rest[:1]
+		if strings.HasPrefix(rest, "cf") {
+			key = "cf"
+		}
+		var value string
+		value, rest, ok = cutDelimited(rest[len(key):])
+		if !ok {
+			return Rule{}, fmt.Errorf("%s: unterminated %s field", service, key)
+		}
+		switch key {
+		case "p":
+			rule.Product = value
+		case "v":
+			rule.Version = value
+		case "i":
+			rule.Info = value
+		case "cf":
+			cf, err := strconv.ParseFloat(value, 64)
+			if err != nil || cf <= 0 || cf > 1 {
+				return Rule{}, fmt.Errorf("%s: confidence %q must be in (0, 1]", service, value)
+			}
+			rule.Confidence = cf
+		default:
+			return Rule{}, fmt.Errorf("%s: unknown field %q", service, key)
+		}
+	}
+	return rule, nil
+}
+
+// cutDelimited splits "<d>body<d>rest" where <d> is the first character.
+// Like nmap, the body ends at the next occurrence of the delimiter.
+func cutDelimited(s string) (body, rest string, ok bool) {
+	if s == "" {
+		return "", "", false
+	}
+	delim := s[:1]
+	body, rest, ok = strings.Cut(s[1:], delim)
+	return body, rest, ok
+}
+
+// unescape decodes the C-style escapes allowed in probe payloads.
+func unescape(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("trailing backslash in payload")
+		}
+		switch s[i] {
+		case '0':
+			out = append(out, 0)
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case '\\':
+			out = append(out, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("truncated \\x escape in payload")
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape \\x%s in payload", s[i+1:i+3])
+			}
+			out = append(out, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c in payload", s[i])
+		}
+	}
+	return out, nil
+}
+
+// parsePortList parses a comma separated list of ports and ranges.
+func parsePortList(s string) (map[int]bool, error) {
+	ports := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil || start < 1 || start > 65535 {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(hi)
+			if err != nil || end < start || end > 65535 {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		for p := start; p <= end; p++ {
+			ports[p] = true
+		}
+	}
+	return ports, nil
+}
@@ -0,0 +1,199 @@
+// Package firewall reports host firewall tooling and tests TCP
+// reachability of ports through a firewall.
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/scan"
+)
+
+// DefaultTimeout is the per-port dial timeout used when TestOptions.Timeout
+// is zero.
+const DefaultTimeout = 5 * time.Second
+
+// Command is a shell command for inspecting a host firewall.
+type Command struct {
+	Platform string `json:"platform"`
+	Tool     string `json:"tool"`
+	Command  string `json:"command"`
+}
+
+// StatusResult describes how to check the local firewall status.
+type StatusResult struct {
+	System        string    `json:"system"`
+	Status        string    `json:"status"`
+	Note          string    `json:"note"`
+	CheckCommands []Command `json:"check_commands"`
+	Services      []string  `json:"services"`
+}
+
+// RulesResult lists the commands that enumerate firewall rules.
+type RulesResult struct {
+	Note     string    `json:"note"`
+	Commands []Command `json:"commands"`
+}
+
+// TestOptions configures Test.
+type TestOptions struct {
+	// Timeout is the per-port dial timeout.
+	Timeout time.Duration
+	// Family restricts testing to scan.FamilyIPv4 or scan.FamilyIPv6;
+	// empty tests every address of both families.
+	Family string
+	// Concurrency is the number of connections in flight at once; zero
+	// means scan.DefaultConcurrency.
+	Concurrency int
+}
+
+// TestResult is the outcome of testing port reachability on a host.
+// Ports that failed are split by cause, see scan.ClassifyDialError.
+type TestResult struct {
+	Target string `json:"target"`
+	// Addresses are the addresses tested, the host's A and AAAA records
+	// when it is a name.
+	Addresses []string `json:"addresses"`
+	// Ports holds one entry per port and address, by port, then in
+	// address order.
+	Ports []PortTest `json:"ports"`
+	// SkippedUDPPorts are UDP-only ports of the request's port
+	// specification, left untested because the test connects over TCP.
+	SkippedUDPPorts []int `json:"skipped_udp_ports,omitempty"`
+	// DualStack is filled when both families were tested, one entry per
+	// port open on either.
+	DualStack   []scan.PortReachability `json:"dual_stack,omitempty"`
+	Open        int                     `json:"open"`
+	Closed      int                     `json:"closed"`
+	Filtered    int                     `json:"filtered"`
+	Unreachable int                     `json:"unreachable"`
+	DurationMS  int64                   `json:"duration_ms"`
+}
+
+// PortTest is the outcome of connecting to one port of one address.
+type PortTest struct {
+	Address string `json:"address"`
+	scan.PortResult
+}
+
+// System returns a display name for the operating system.
+func System() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "Linux"
+	case "darwin":
+		return "macOS"
+	}
+	return runtime.GOOS
+}
+
+// Status returns the local firewall status guidance. Reading the live
+// status requires elevated privileges and is left to the listed commands.
+func Status() *StatusResult {
+	return &StatusResult{
+		System: System(),
+		Status: "Requires elevated privileges",
+		Note:   "Run with 'sudo' for full details",
+		CheckCommands: []Command{
+			{Platform: "macOS", Tool: "launchctl", Command: "sudo launchctl list | grep -i firewall"},
+			{Platform: "Linux", Tool: "ufw", Command: "sudo systemctl status ufw"},
+			{Platform: "Linux", Tool: "iptables", Command: "sudo iptables -L -n"},
+		},
+		Services: []string{
+			"macOS: pf (Packet Filter)",
+			"Linux: ufw (Uncomplicated Firewall)",
+			"Linux: firewalld (Dynamic Firewall Manager)",
+			"Linux: iptables (Netfilter)",
+		},
+	}
+}
+
+// Rules returns the commands that list active firewall rules.
+func Rules() *RulesResult {
+	return &RulesResult{
+		Note: "Detailed firewall rules require elevated privileges (sudo)",
+		Commands: []Command{
+			{Platform: "Linux", Tool: "iptables", Command: "sudo iptables -L -n -v"},
+			{Platform: "Linux", Tool: "iptables", Command: "sudo iptables -L INPUT -n -v"},
+			{Platform: "Linux", Tool: "iptables", Command: "sudo iptables -L OUTPUT -n -v"},
+			{Platform: "Linux", Tool: "firewalld", Command: "sudo firewall-cmd --list-all"},
+			{Platform: "Linux", Tool: "firewalld", Command: "sudo firewall-cmd --list-ports"},
+			{Platform: "Linux", Tool: "firewalld", Command: "sudo firewall-cmd --list-rich-rules"},
+			{Platform: "Linux", Tool: "ufw", Command: "sudo ufw status verbose"},
+			{Platform: "macOS", Tool: "pf", Command: "sudo pfctl -s rules"},
+		},
+	}
+}
+
+// Test connects to each port on every address of host, over both
+// families unless opts.Family picks one, and records whether it accepted
+// the connection. Connections are made by the scan worker pool.
+func Test(ctx context.Context, host string, ports []int, opts TestOptions) (*TestResult, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+
+	r := &TestResult{Target: host, Addresses: []string{}, Ports: []PortTest{}}
+	// Accept bracketed IPv6 literals such as [2001:db8::1]
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	targets, err := scan.ExpandTargets(ctx, []string{host}, scan.ExpandOptions{Family: opts.Family})
+	if err != nil {
+		return nil, err
+	}
+	order := map[string]int{}
+	for i, t := range targets {
+		order[t.Addr.String()] = i
+		r.Addresses = append(r.Addresses, t.Addr.String())
+	}
+
+	res, err := scan.Scan(ctx, targets, scan.Options{
+		Ports:       ports,
+		Method:      scan.MethodConnect,
+		Timeout:     timeout,
+		Concurrency: opts.Concurrency,
+		OnResult: func(t scan.Target, p scan.PortResult) {
+			p.Family = scan.FamilyOf(t.Addr)
+			if name := serviceName(p.Port); name != "" {
+				p.Service = name
+			}
+			r.Ports = append(r.Ports, PortTest{Address: t.Addr.String(), PortResult: p})
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(r.Ports, func(i, j int) bool {
+		a, b := r.Ports[i], r.Ports[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return order[a.Address] < order[b.Address]
+	})
+	r.DualStack = res.DualStack
+	r.Open, r.Closed, r.Filtered, r.Unreachable = res.Open, res.Closed, res.Filtered, res.Unreachable
+	r.DurationMS = res.DurationMS
+	return r, nil
+}
+
+func serviceName(port int) string {
+	serviceMap := map[int]string{
+		22:   "SSH",
+		80:   "HTTP",
+		443:  "HTTPS",
+		3306: "MySQL",
+		5432: "PostgreSQL",
+		5000: "Flask/Django",
+		8080: "HTTP Alt",
+	}
+	return serviceMap[port]
+}
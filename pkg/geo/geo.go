@@ -0,0 +1,64 @@
+// Package geo reports geographical and ISP information for IP addresses.
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/tanvircs/afsa/pkg/asn"
+)
+
+// Options configures Lookup.
+type Options struct {
+	// ASN fills in the AS number and its name as the organization; nil
+	// leaves them empty.
+	ASN *asn.Table
+}
+
+// Result is the outcome of a geolocation analysis. Fields that need a
+// GeoIP database are left empty until one is integrated.
+type Result struct {
+	IP              string   `json:"ip"`
+	Country         string   `json:"country"`
+	City            string   `json:"city"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+	ISP             string   `json:"isp"`
+	ASN             string   `json:"asn"`
+	Organization    string   `json:"organization"`
+	TimeZone        string   `json:"time_zone"`
+	ConnectionType  string   `json:"connection_type"`
+	VPNDetected     bool     `json:"vpn_detected"`
+	ProxyDetected   bool     `json:"proxy_detected"`
+	GeoIPServices   []string `json:"geoip_services"`
+	DatabaseMissing bool     `json:"database_missing"`
+}
+
+// Lookup returns the geolocation details known for ipAddr.
+func Lookup(ctx context.Context, ipAddr string, opts Options) (*Result, error) {
+	addr, err := netip.ParseAddr(ipAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address format: %s", ipAddr)
+	}
+
+	r := &Result{
+		IP:             ipAddr,
+		ConnectionType: "Standard (Likely ISP)",
+		GeoIPServices: []string{
+			"MaxMind GeoIP2 - Commercial & free options",
+			"IP2Location - Comprehensive database",
+			"ipapi.co - Free REST API",
+			"geoip.dev - Simple API",
+			"ipstack - Detailed information",
+		},
+		DatabaseMissing: true,
+	}
+	if opts.ASN != nil {
+		if o, ok := opts.ASN.Origin(addr); ok {
+			r.ASN = fmt.Sprintf("AS%d", o.ASN)
+			r.Organization = o.ASName
+		}
+	}
+	return r, ctx.Err()
+}
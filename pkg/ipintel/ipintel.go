@@ -0,0 +1,167 @@
+// Package ipintel classifies IP addresses (RFC 1918/5735/5771 ranges,
+// special-purpose characteristics) and resolves their reverse DNS names.
+package ipintel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/tanvircs/afsa/pkg/asn"
+	"github.com/tanvircs/afsa/pkg/rdap"
+)
+
+// Options configures Analyze.
+type Options struct {
+	// Resolver is used for reverse DNS; nil means net.DefaultResolver.
+	Resolver *net.Resolver
+	// SkipReverseDNS disables the PTR lookup.
+	SkipReverseDNS bool
+	// ASN finds the covering prefix and origin AS of public addresses;
+	// nil leaves them out.
+	ASN *asn.Table
+	// Bootstrap tells the RIR of public addresses; nil means
+	// rdap.DefaultBootstrap.
+	Bootstrap *rdap.Bootstrap
+}
+
+// Result is the analysis of a single IP address.
+type Result struct {
+	Address         string   `json:"address"`
+	Version         string   `json:"version"`
+	Classifications []string `json:"classifications"`
+	ReverseDNS      []string `json:"reverse_dns"`
+	Characteristics []string `json:"characteristics"`
+	Security        []string `json:"security"`
+	// Origin is the routing and allocation of a public address.
+	Origin *asn.Origin `json:"origin,omitempty"`
+}
+
+// Analyze classifies addr and looks up its reverse DNS names.
+func Analyze(ctx context.Context, addr string, opts Options) (*Result, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address format: %s", addr)
+	}
+
+	r := &Result{
+		Address:         addr,
+		Version:         "IPv6",
+		Classifications: Classify(ip),
+		Characteristics: Characteristics(ip),
+		Security:        SecurityNotes(ip),
+	}
+	if ip.To4() != nil {
+		r.Version = "IPv4"
+	}
+
+	if ip.IsGlobalUnicast() && !IsPrivate(ip) {
+		r.Origin = origin(ip, opts)
+	}
+
+	if !opts.SkipReverseDNS {
+		resolver := opts.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		if hostnames, err := resolver.LookupAddr(ctx, addr); err == nil {
+			r.ReverseDNS = hostnames
+		}
+	}
+
+	return r, ctx.Err()
+}
+
+// origin looks addr up in the ASN table and the RDAP bootstrap, both
+// local.
+func origin(ip net.IP, opts Options) *asn.Origin {
+	addr, _ := netip.AddrFromSlice(ip)
+	addr = addr.Unmap()
+	o := &asn.Origin{Address: addr.String()}
+	if opts.ASN != nil {
+		*o, _ = opts.ASN.Origin(addr)
+	}
+	b := opts.Bootstrap
+	if b == nil {
+		b = rdap.DefaultBootstrap()
+	}
+	o.RIR = b.IPRegistry(addr)
+	return o
+}
+
+// Classify returns the primary RFC classification of ip.
+func Classify(ip net.IP) []string {
+	var classifications []string
+
+	if ip.IsLoopback() {
+		classifications = append(classifications, "Loopback Address (RFC5735)")
+	} else if ip.IsPrivate() {
+		classifications = append(classifications, "Private Address (RFC1918)")
+	} else if ip.IsMulticast() {
+		classifications = append(classifications, "Multicast Address (RFC5771)")
+	} else if ip.IsLinkLocalUnicast() {
+		classifications = append(classifications, "Link-Local Unicast (RFC3927)")
+	} else if ip.IsLinkLocalMulticast() {
+		classifications = append(classifications, "Link-Local Multicast (RFC5771)")
+	} else {
+		classifications = append(classifications, "Public Address (Routable)")
+	}
+
+	return classifications
+}
+
+// Characteristics lists every special-purpose property that applies to ip.
+func Characteristics(ip net.IP) []string {
+	var chars []string
+
+	if ip.IsUnspecified() {
+		chars = append(chars, "Unspecified Address (0.0.0.0 or ::)")
+	}
+	if ip.IsLoopback() {
+		chars = append(chars, "Loopback (127.0.0.1 or ::1)")
+	}
+	if ip.IsPrivate() {
+		chars = append(chars, "RFC1918 Private Range")
+	}
+	if ip.IsMulticast() {
+		chars = append(chars, "Multicast Group")
+	}
+	if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		chars = append(chars, "Link-Local Address")
+	}
+	if ip.IsGlobalUnicast() {
+		chars = append(chars, "Global Unicast (Routable)")
+	}
+	if ip.IsInterfaceLocalMulticast() {
+		chars = append(chars, "Interface-Local Multicast")
+	}
+
+	if len(chars) == 0 {
+		chars = append(chars, "Standard Routable Public Address")
+	}
+
+	return chars
+}
+
+// SecurityNotes summarises the exposure of ip.
+func SecurityNotes(ip net.IP) []string {
+	var notes []string
+	if IsPrivate(ip) {
+		notes = append(notes, "Private - Safe for internal use")
+	} else {
+		notes = append(notes, "Public - Exposed to internet")
+	}
+	if ip.IsLoopback() {
+		notes = append(notes, "Loopback - Local machine only")
+	}
+	if ip.IsMulticast() {
+		notes = append(notes, "Multicast - Group communication")
+	}
+	return notes
+}
+
+// IsPrivate reports whether ip is private or loopback.
+func IsPrivate(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback()
+}
@@ -0,0 +1,320 @@
+package rdap
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed bootstrap/*.json
+var bundled embed.FS
+
+// Bootstrap registry files (RFC 7484, RFC 8521), as published under
+// BootstrapURL.
+const (
+	FileDNS        = "dns.json"
+	FileIPv4       = "ipv4.json"
+	FileIPv6       = "ipv6.json"
+	FileASN        = "asn.json"
+	FileObjectTags = "object-tags.json"
+)
+
+// BootstrapURL is where IANA publishes the bootstrap files.
+const BootstrapURL = "https://data.iana.org/rdap/"
+
+var bootstrapFiles = []string{FileDNS, FileIPv4, FileIPv6, FileASN, FileObjectTags}
+
+// Bootstrap maps domains, addresses, AS numbers and entity handles to
+// the RDAP servers responsible for them.
+type Bootstrap struct {
+	// Publication is the oldest publication time of the loaded files.
+	Publication time.Time
+
+	domains []domainService
+	ipv4    []prefixService
+	ipv6    []prefixService
+	asns    []asnService
+	tags    map[string][]string
+}
+
+type domainService struct {
+	label string
+	urls  []string
+}
+
+type prefixService struct {
+	prefix netip.Prefix
+	urls   []string
+}
+
+type asnService struct {
+	first, last uint32
+	urls        []string
+}
+
+// registry is the JSON layout shared by the bootstrap files: every
+// service is an array of entries followed by the URLs serving them.
+// Object tags put the registrant's contacts first.
+type registry struct {
+	Publication time.Time           `json:"publication"`
+	Services    [][]json.RawMessage `json:"services"`
+}
+
+// DefaultBootstrap returns the bootstrap files built into AFSA: IANA's
+// address and AS number files whole, so the registry of any allocated
+// address is known offline, and a subset of the domain file covering the
+// largest registries.
+func DefaultBootstrap() *Bootstrap {
+	b, err := LoadBootstrap("")
+	if err != nil {
+		panic("rdap: built-in bootstrap: " + err.Error())
+	}
+	return b
+}
+
+// DefaultBootstrapDir returns the directory RefreshBootstrap stores the
+// IANA files in, inside the user's cache directory.
+func DefaultBootstrapDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "afsa", "rdap"), nil
+}
+
+// LoadBootstrap reads the bootstrap files from dir, using the built-in
+// copy of any file dir doesn't have. An empty dir loads only the
+// built-in files.
+func LoadBootstrap(dir string) (*Bootstrap, error) {
+	b := &Bootstrap{tags: map[string][]string{}}
+	for _, name := range bootstrapFiles {
+		data, err := bundled.ReadFile("bootstrap/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			path := filepath.Join(dir, name)
+			if local, err := os.ReadFile(path); err == nil {
+				data = local
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		if err := b.add(name, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return b, nil
+}
+
+// RefreshBootstrap downloads the current bootstrap files from IANA into
+// dir. Files are replaced only once all of them downloaded and parsed.
+func RefreshBootstrap(ctx context.Context, client *http.Client, dir string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	files := map[string][]byte{}
+	for _, name := range bootstrapFiles {
+		data, err := fetchBootstrap(ctx, client, BootstrapURL+name)
+		if err != nil {
+			return err
+		}
+		if err := (&Bootstrap{tags: map[string][]string{}}).add(name, data); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = data
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		tmp := filepath.Join(dir, name+".tmp")
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetchBootstrap(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "afsa")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+// add parses one bootstrap file into b.
+func (b *Bootstrap) add(name string, data []byte) error {
+	var reg registry
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return err
+	}
+	if b.Publication.IsZero() || (!reg.Publication.IsZero() && reg.Publication.Before(b.Publication)) {
+		b.Publication = reg.Publication
+	}
+	for i, svc := range reg.Services {
+		if name == FileObjectTags && len(svc) == 3 {
+			svc = svc[1:]
+		}
+		if len(svc) != 2 {
+			return fmt.Errorf("service %d: want entries and URLs, got %d elements", i+1, len(svc))
+		}
+		var entries, urls []string
+		if err := json.Unmarshal(svc[0], &entries); err != nil {
+			return fmt.Errorf("service %d: %w", i+1, err)
+		}
+		if err := json.Unmarshal(svc[1], &urls); err != nil {
+			return fmt.Errorf("service %d: %w", i+1, err)
+		}
+		for _, e := range entries {
+			if err := b.addEntry(name, e, urls); err != nil {
+				return fmt.Errorf("service %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Bootstrap) addEntry(name, entry string, urls []string) error {
+	switch name {
+	case FileDNS:
+		b.domains = append(b.domains, domainService{label: strings.ToLower(strings.Trim(entry, ".")), urls: urls})
+	case FileIPv4, FileIPv6:
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return err
+		}
+		if name == FileIPv4 {
+			b.ipv4 = append(b.ipv4, prefixService{prefix: prefix, urls: urls})
+		} else {
+			b.ipv6 = append(b.ipv6, prefixService{prefix: prefix, urls: urls})
+		}
+	case FileASN:
+		first, last, found := strings.Cut(entry, "-")
+		if !found {
+			last = first
+		}
+		lo, err := strconv.ParseUint(first, 10, 32)
+		if err != nil {
+			return err
+		}
+		hi, err := strconv.ParseUint(last, 10, 32)
+		if err != nil {
+			return err
+		}
+		b.asns = append(b.asns, asnService{first: uint32(lo), last: uint32(hi), urls: urls})
+	case FileObjectTags:
+		b.tags[strings.ToUpper(entry)] = urls
+	}
+	return nil
+}
+
+// DomainServers returns the RDAP base URLs for domain: those of the
+// longest matching entry (RFC 7484 section 4).
+func (b *Bootstrap) DomainServers(domain string) []string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	var best domainService
+	for _, s := range b.domains {
+		if (domain == s.label || strings.HasSuffix(domain, "."+s.label)) && len(s.label) > len(best.label) {
+			best = s
+		}
+	}
+	return preferHTTPS(best.urls)
+}
+
+// IPServers returns the RDAP base URLs for prefix, those of the most
+// specific entry covering it.
+func (b *Bootstrap) IPServers(prefix netip.Prefix) []string {
+	services := b.ipv4
+	if prefix.Addr().Is6() {
+		services = b.ipv6
+	}
+	best := prefixService{prefix: netip.PrefixFrom(prefix.Addr(), 0)}
+	for _, s := range services {
+		if s.prefix.Bits() <= prefix.Bits() && s.prefix.Contains(prefix.Addr()) && (best.urls == nil || s.prefix.Bits() > best.prefix.Bits()) {
+			best = s
+		}
+	}
+	return preferHTTPS(best.urls)
+}
+
+// ASNServers returns the RDAP base URLs for AS number asn.
+func (b *Bootstrap) ASNServers(asn uint32) []string {
+	for _, s := range b.asns {
+		if asn >= s.first && asn <= s.last {
+			return preferHTTPS(s.urls)
+		}
+	}
+	return nil
+}
+
+// EntityServers returns the RDAP base URLs for an entity handle tagged
+// with its registry, such as GOGL-ARIN (RFC 8521).
+func (b *Bootstrap) EntityServers(handle string) []string {
+	i := strings.LastIndex(handle, "-")
+	if i < 0 {
+		return nil
+	}
+	return preferHTTPS(b.tags[strings.ToUpper(handle[i+1:])])
+}
+
+// registries names the regional internet registries by the host of
+// their RDAP service.
+var registries = map[string]string{
+	"rdap.afrinic.net": "AFRINIC",
+	"rdap.apnic.net":   "APNIC",
+	"rdap.arin.net":    "ARIN",
+	"rdap.lacnic.net":  "LACNIC",
+	"rdap.db.ripe.net": "RIPE NCC",
+}
+
+// IPRegistry returns the regional internet registry addr was allocated
+// from, going by the RDAP server the bootstrap names for it, or "" when
+// it is not in the bootstrap file or not served by an RIR.
+func (b *Bootstrap) IPRegistry(addr netip.Addr) string {
+	addr = addr.Unmap()
+	for _, u := range b.IPServers(netip.PrefixFrom(addr, addr.BitLen())) {
+		if parsed, err := url.Parse(u); err == nil {
+			if name := registries[strings.ToLower(parsed.Hostname())]; name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// preferHTTPS orders https URLs before plain http ones.
+func preferHTTPS(urls []string) []string {
+	var secure, plain []string
+	for _, u := range urls {
+		if strings.HasPrefix(strings.ToLower(u), "https://") {
+			secure = append(secure, u)
+		} else {
+			plain = append(plain, u)
+		}
+	}
+	return append(secure, plain...)
+}
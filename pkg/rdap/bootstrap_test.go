@@ -0,0 +1,45 @@
+package rdap
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPRegistry(t *testing.T) {
+	b := DefaultBootstrap()
+	tests := map[string]string{
+		"1.1.1.1":         "APNIC",
+		"17.253.144.10":   "ARIN",
+		"140.82.112.3":    "ARIN",
+		"157.240.1.35":    "ARIN",
+		"139.130.4.5":     "ARIN",
+		"57.128.0.1":      "RIPE NCC",
+		"193.0.6.139":     "RIPE NCC",
+		"41.1.1.1":        "AFRINIC",
+		"200.160.2.3":     "LACNIC",
+		"::ffff:8.8.8.8":  "ARIN",
+		"2001:4860::8888": "ARIN",
+		"2a00:1450::1":    "RIPE NCC",
+		"2c0f:f000::1":    "AFRINIC",
+		"10.0.0.1":        "",
+		"fd00::1":         "",
+	}
+	for addr, want := range tests {
+		if got := b.IPRegistry(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IPRegistry(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestBundledIPv4Complete(t *testing.T) {
+	b := DefaultBootstrap()
+	for first := 1; first < 224; first++ {
+		if first == 10 || first == 127 {
+			continue
+		}
+		addr := netip.AddrFrom4([4]byte{byte(first), 0, 0, 1})
+		if b.IPRegistry(addr) == "" {
+			t.Errorf("no registry for %d.0.0.0/8", first)
+		}
+	}
+}
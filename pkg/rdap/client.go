@@ -0,0 +1,116 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mediaType is the RDAP content type (RFC 7480 section 4.2).
+const mediaType = "application/rdap+json"
+
+// maxResponseSize bounds a response body; objects are a few KiB.
+const maxResponseSize = 4 << 20
+
+// maxRetryAfter caps how long a 429 response's Retry-After is honoured.
+const maxRetryAfter = 10 * time.Second
+
+// ErrRateLimited is returned, wrapped, when a server keeps answering
+// 429 Too Many Requests.
+var ErrRateLimited = errors.New("rate limited")
+
+type client struct {
+	http    *http.Client
+	timeout time.Duration
+}
+
+func newClient(opts Options) *client {
+	c := &client{http: opts.HTTPClient, timeout: opts.Timeout}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.http == nil {
+		c.http = &http.Client{}
+	}
+	return c
+}
+
+// get fetches and decodes the object at url, following redirects to
+// other registries. A 429 response is retried once after its
+// Retry-After delay.
+func (c *client) get(ctx context.Context, url string) (*object, error) {
+	for attempt := 0; ; attempt++ {
+		obj, retryAfter, err := c.fetch(ctx, url)
+		if !errors.Is(err, ErrRateLimited) || attempt > 0 || retryAfter > maxRetryAfter {
+			return obj, err
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *client) fetch(ctx context.Context, url string) (*object, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", mediaType+", application/json")
+	req.Header.Set("User-Agent", "afsa")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", url, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, fmt.Errorf("%s: %w", url, ErrNotFound)
+	case http.StatusTooManyRequests:
+		wait := time.Second
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		return nil, wait, fmt.Errorf("%s: %w", url, ErrRateLimited)
+	default:
+		return nil, 0, fmt.Errorf("%s: HTTP %s%s", url, resp.Status, errorDetail(body))
+	}
+
+	obj := new(object)
+	if err := json.Unmarshal(body, obj); err != nil {
+		return nil, 0, fmt.Errorf("%s: invalid RDAP response: %w", url, err)
+	}
+	return obj, 0, nil
+}
+
+// errorDetail returns ": title" from an RDAP error response body, if
+// it is one (RFC 9083 section 6).
+func errorDetail(body []byte) string {
+	var e struct {
+		Title       string   `json:"title"`
+		Description []string `json:"description"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	detail := strings.TrimSpace(strings.Join(append([]string{e.Title}, e.Description...), " "))
+	if detail == "" {
+		return ""
+	}
+	return ": " + detail
+}
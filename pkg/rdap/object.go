@@ -0,0 +1,376 @@
+package rdap
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+)
+
+// object is the JSON of any RDAP object class (RFC 9083); fields a class
+// doesn't have stay empty.
+type object struct {
+	ObjectClassName string   `json:"objectClassName"`
+	Handle          string   `json:"handle"`
+	LDHName         string   `json:"ldhName"`
+	Name            string   `json:"name"`
+	Status          []string `json:"status"`
+	Events          []struct {
+		Action string `json:"eventAction"`
+		Actor  string `json:"eventActor"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+	Entities    []object `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	SecureDNS *struct {
+		DelegationSigned bool `json:"delegationSigned"`
+	} `json:"secureDNS"`
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+		Type string `json:"type"`
+	} `json:"links"`
+
+	// IP networks.
+	StartAddress string `json:"startAddress"`
+	EndAddress   string `json:"endAddress"`
+	IPVersion    string `json:"ipVersion"`
+	Type         string `json:"type"`
+	Country      string `json:"country"`
+	ParentHandle string `json:"parentHandle"`
+	CIDRs        []struct {
+		V4Prefix string `json:"v4prefix"`
+		V6Prefix string `json:"v6prefix"`
+		Length   int    `json:"length"`
+	} `json:"cidr0_cidrs"`
+
+	// Autonomous system numbers.
+	StartAutnum uint32 `json:"startAutnum"`
+	EndAutnum   uint32 `json:"endAutnum"`
+
+	// Entities.
+	Roles      []string        `json:"roles"`
+	VCardArray json.RawMessage `json:"vcardArray"`
+	PublicIDs  []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"publicIds"`
+}
+
+// fill copies obj into r.
+func (r *Result) fill(obj *object) {
+	r.Handle = obj.Handle
+	r.Name = strings.ToLower(obj.LDHName)
+	if r.Name == "" {
+		r.Name = obj.Name
+	}
+	r.Status = append(r.Status, obj.Status...)
+	for _, e := range obj.Events {
+		date, ok := parseTime(e.Date)
+		if !ok {
+			continue
+		}
+		r.Events = append(r.Events, Event{Action: e.Action, Date: date, Actor: e.Actor})
+		d := date
+		switch e.Action {
+		case "registration":
+			r.Registered = &d
+		case "expiration":
+			r.Expires = &d
+		case "last changed":
+			r.Updated = &d
+		}
+	}
+	r.Nameservers = nameservers(obj)
+	r.DNSSEC = obj.SecureDNS != nil && obj.SecureDNS.DelegationSigned
+
+	if r.Type == TypeEntity {
+		self := contact(*obj)
+		r.Name = self.Name
+		r.addContact(self)
+	}
+	for _, ct := range contacts(obj.Entities) {
+		r.addContact(ct)
+	}
+
+	switch obj.ObjectClassName {
+	case "ip network":
+		r.Network = &Network{
+			StartAddress: obj.StartAddress,
+			EndAddress:   obj.EndAddress,
+			CIDRs:        networkCIDRs(obj),
+			Version:      obj.IPVersion,
+			Type:         obj.Type,
+			Country:      obj.Country,
+			Parent:       obj.ParentHandle,
+		}
+	case "autnum":
+		r.AutNum = &AutNum{Start: obj.StartAutnum, End: obj.EndAutnum, Type: obj.Type, Country: obj.Country}
+	}
+}
+
+// fillWHOIS copies a parsed port-43 record into r, so a fallback answer
+// has the same fields as an RDAP one.
+func (r *Result) fillWHOIS(rec *whois.Record) {
+	r.Handle = rec.Handle
+	r.Name = rec.Domain
+	if r.Name == "" {
+		r.Name = firstNonEmpty(rec.NetName, rec.ASName)
+	}
+	r.Status = append(r.Status, rec.Status...)
+	r.Registered, r.Expires, r.Updated = rec.Created, rec.Expires, rec.Updated
+	for _, e := range []struct {
+		action string
+		date   *time.Time
+	}{{"registration", rec.Created}, {"expiration", rec.Expires}, {"last changed", rec.Updated}} {
+		if e.date != nil {
+			r.Events = append(r.Events, Event{Action: e.action, Date: *e.date})
+		}
+	}
+	r.Nameservers = append(r.Nameservers, rec.Nameservers...)
+	r.DNSSEC = rec.DNSSEC
+
+	if rec.Registrar != "" {
+		r.addContact(Contact{Roles: []string{"registrar"}, Name: rec.Registrar, IANAID: rec.RegistrarIANAID})
+	}
+	if c := rec.Registrant; c != nil {
+		r.addContact(Contact{
+			Roles:        []string{"registrant"},
+			Name:         firstNonEmpty(c.Name, c.Organization),
+			Organization: c.Organization,
+			Email:        c.Email,
+			Phone:        c.Phone,
+			Address:      joinAddress(c.Address, c.Country),
+		})
+	}
+	if c := rec.Abuse; c != nil {
+		r.addContact(Contact{Roles: []string{"abuse"}, Name: firstNonEmpty(c.Name, c.Email), Email: c.Email, Phone: c.Phone})
+	}
+
+	switch r.Type {
+	case whois.TypeIP:
+		n := &Network{CIDRs: rec.CIDRs, Country: rec.Country, Parent: rec.Parent}
+		if len(rec.Status) > 0 {
+			n.Type = rec.Status[0]
+		}
+		if first, last, ok := strings.Cut(rec.Network, "-"); ok {
+			n.StartAddress, n.EndAddress = strings.TrimSpace(first), strings.TrimSpace(last)
+		} else if p, err := netip.ParsePrefix(rec.Network); err == nil {
+			n.StartAddress, n.EndAddress = p.Masked().Addr().String(), lastAddr(p.Masked()).String()
+		}
+		if a, err := netip.ParseAddr(n.StartAddress); err == nil {
+			n.Version = "v6"
+			if a.Is4() {
+				n.Version = "v4"
+			}
+		}
+		r.Network = n
+	case whois.TypeASN:
+		if asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(rec.ASN), "AS"), 10, 32); err == nil {
+			r.AutNum = &AutNum{Start: uint32(asn), End: uint32(asn), Country: rec.Country}
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func joinAddress(address, country string) string {
+	if address == "" || country == "" {
+		return address + country
+	}
+	return address + ", " + country
+}
+
+// addContact appends c and takes it as the registrar, registrant or
+// abuse contact when none is set yet.
+func (r *Result) addContact(c Contact) {
+	for _, have := range r.Contacts {
+		if have.Handle != "" && have.Handle == c.Handle && strings.Join(have.Roles, ",") == strings.Join(c.Roles, ",") {
+			return
+		}
+	}
+	r.Contacts = append(r.Contacts, c)
+	for _, role := range c.Roles {
+		ct := c
+		switch role {
+		case "registrar":
+			if r.Registrar == nil {
+				r.Registrar = &ct
+			}
+		case "registrant":
+			if r.Registrant == nil {
+				r.Registrant = &ct
+			}
+		case "abuse":
+			if r.Abuse == nil {
+				r.Abuse = &ct
+			}
+		}
+	}
+}
+
+// contacts flattens entities and the entities nested in them.
+func contacts(entities []object) []Contact {
+	var list []Contact
+	for _, e := range entities {
+		list = append(list, contact(e))
+		list = append(list, contacts(e.Entities)...)
+	}
+	return list
+}
+
+func contact(e object) Contact {
+	c := parseVCard(e.VCardArray)
+	c.Handle = e.Handle
+	c.Roles = append([]string{}, e.Roles...)
+	for _, id := range e.PublicIDs {
+		if strings.EqualFold(id.Type, "IANA Registrar ID") {
+			c.IANAID = id.Identifier
+		}
+	}
+	return c
+}
+
+// parseVCard reads the name, organization, email, phone and address
+// from a jCard (RFC 7095): ["vcard", [[name, params, type, value], ...]].
+func parseVCard(raw json.RawMessage) Contact {
+	var c Contact
+	var card []json.RawMessage
+	if json.Unmarshal(raw, &card) != nil || len(card) < 2 {
+		return c
+	}
+	var props [][]json.RawMessage
+	if json.Unmarshal(card[1], &props) != nil {
+		return c
+	}
+	for _, p := range props {
+		if len(p) < 4 {
+			continue
+		}
+		var name string
+		var params map[string]interface{}
+		json.Unmarshal(p[0], &name)
+		json.Unmarshal(p[1], &params)
+		value := vcardText(p[3])
+		switch strings.ToLower(name) {
+		case "fn":
+			setOnce(&c.Name, value)
+		case "org":
+			setOnce(&c.Organization, value)
+		case "email":
+			setOnce(&c.Email, value)
+		case "tel":
+			setOnce(&c.Phone, strings.TrimPrefix(value, "tel:"))
+		case "adr":
+			if label, ok := params["label"].(string); ok && strings.TrimSpace(label) != "" {
+				value = label
+			}
+			setOnce(&c.Address, strings.Join(strings.Fields(strings.ReplaceAll(value, "\n", ", ")), " "))
+		}
+	}
+	return c
+}
+
+// vcardText returns a jCard value as text, joining the non-empty
+// components of structured values such as adr.
+func vcardText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var out []string
+	for _, p := range parts {
+		if v := vcardText(p); v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+func setOnce(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func nameservers(obj *object) []string {
+	list := []string{}
+	for _, ns := range obj.Nameservers {
+		if ns.LDHName != "" {
+			list = append(list, strings.ToLower(strings.TrimSuffix(ns.LDHName, ".")))
+		}
+	}
+	return list
+}
+
+// relatedLink returns the RDAP URL a registry links for the registrar's
+// copy of a domain, if any.
+func relatedLink(obj *object) string {
+	for _, l := range obj.Links {
+		if l.Rel == "related" && strings.Contains(l.Type, "rdap+json") && strings.Contains(l.Href, "/domain/") {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// parseTime parses an RDAP event date, RFC 3339 or one of its common
+// truncations.
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// networkCIDRs returns the prefixes of a network, from the cidr0
+// extension when present, else computed from its address range.
+func networkCIDRs(obj *object) []string {
+	cidrs := []string{}
+	for _, c := range obj.CIDRs {
+		prefix := c.V4Prefix
+		if prefix == "" {
+			prefix = c.V6Prefix
+		}
+		if prefix != "" {
+			cidrs = append(cidrs, prefix+"/"+strconv.Itoa(c.Length))
+		}
+	}
+	if len(cidrs) > 0 {
+		return cidrs
+	}
+	cidrs = append(cidrs, whois.RangeCIDRs(obj.StartAddress, obj.EndAddress)...)
+	return cidrs
+}
+
+// lastAddr returns the highest address in p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As16()
+	offset := 128 - p.Addr().BitLen()
+	for i := offset + p.Bits(); i < 128; i++ {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+	a := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		return a.Unmap()
+	}
+	return a
+}
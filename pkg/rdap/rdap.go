@@ -0,0 +1,233 @@
+// Package rdap looks up registration data for domains, IP networks, AS
+// numbers and entities over the Registration Data Access Protocol
+// (RFC 7480-7484), falling back to port-43 WHOIS where RDAP is not
+// available.
+package rdap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+)
+
+// TypeEntity is the Result.Type of contact and organization handles; the
+// other types are those of package whois.
+const TypeEntity = "entity"
+
+// Where a Result's data came from.
+const (
+	SourceRDAP  = "rdap"
+	SourceWHOIS = "whois"
+)
+
+// DefaultTimeout bounds each RDAP request when Options.Timeout is zero.
+const DefaultTimeout = 15 * time.Second
+
+// ErrNotFound is returned, wrapped, when the registry has no such
+// object, e.g. for a domain that is not registered.
+var ErrNotFound = errors.New("not found")
+
+// Options configures a Lookup.
+type Options struct {
+	// Timeout bounds each RDAP request. Zero means DefaultTimeout.
+	Timeout time.Duration
+	// HTTPClient sends the requests; nil means a client with Timeout.
+	HTTPClient *http.Client
+	// Bootstrap finds the server for a target; nil means
+	// DefaultBootstrap.
+	Bootstrap *Bootstrap
+	// NoWHOIS disables the fallback to port-43 WHOIS.
+	NoWHOIS bool
+	// NoRDAP skips RDAP and queries port-43 WHOIS only.
+	NoRDAP bool
+	// WHOIS configures the fallback lookups.
+	WHOIS whois.Options
+}
+
+// Result is the registration data of a domain, IP network, AS number or
+// entity.
+type Result struct {
+	Target string `json:"target"`
+	// Type is whois.TypeDomain, whois.TypeIP, whois.TypeASN or
+	// TypeEntity.
+	Type string `json:"type"`
+	// Source is SourceRDAP, or SourceWHOIS when the data comes from the
+	// port-43 fallback in WHOIS.
+	Source string `json:"source"`
+	// Server is the RDAP base URL or WHOIS server that answered.
+	Server string `json:"server"`
+	// URL is the RDAP URL of the object.
+	URL         string     `json:"url,omitempty"`
+	Handle      string     `json:"handle"`
+	Name        string     `json:"name"`
+	Status      []string   `json:"status"`
+	Registered  *time.Time `json:"registered,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	Events      []Event    `json:"events"`
+	Nameservers []string   `json:"nameservers"`
+	// DNSSEC is set when the registry has a signed delegation.
+	DNSSEC bool `json:"dnssec"`
+	// Registrar, Registrant and Abuse are the first contacts with those
+	// roles; Contacts lists every contact, including these.
+	Registrar  *Contact  `json:"registrar,omitempty"`
+	Registrant *Contact  `json:"registrant,omitempty"`
+	Abuse      *Contact  `json:"abuse,omitempty"`
+	Contacts   []Contact `json:"contacts"`
+	Network    *Network  `json:"network,omitempty"`
+	AutNum     *AutNum   `json:"autnum,omitempty"`
+	// WHOIS holds the port-43 responses when Source is SourceWHOIS.
+	WHOIS  *whois.Result `json:"whois,omitempty"`
+	Errors []string      `json:"errors"`
+}
+
+// Event is a dated action on the object, such as "registration" or
+// "expiration".
+type Event struct {
+	Action string    `json:"action"`
+	Date   time.Time `json:"date"`
+	Actor  string    `json:"actor,omitempty"`
+}
+
+// Contact is an entity with its roles and vCard details.
+type Contact struct {
+	Handle       string   `json:"handle"`
+	Roles        []string `json:"roles"`
+	Name         string   `json:"name"`
+	Organization string   `json:"organization,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Phone        string   `json:"phone,omitempty"`
+	Address      string   `json:"address,omitempty"`
+	// IANAID is the IANA Registrar ID of a registrar.
+	IANAID string `json:"iana_id,omitempty"`
+}
+
+// Network is the IP network an address belongs to.
+type Network struct {
+	StartAddress string   `json:"start_address"`
+	EndAddress   string   `json:"end_address"`
+	CIDRs        []string `json:"cidrs"`
+	// Version is "v4" or "v6".
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Country string `json:"country"`
+	// Parent is the handle of the enclosing network.
+	Parent string `json:"parent"`
+}
+
+// AutNum is a range of AS numbers.
+type AutNum struct {
+	Start   uint32 `json:"start"`
+	End     uint32 `json:"end"`
+	Type    string `json:"type"`
+	Country string `json:"country"`
+}
+
+// Lookup returns the registration data for target: a domain name, an IP
+// address or network, an AS number such as AS15169, or an entity handle
+// tagged with its registry such as GOGL-ARIN. The RDAP server comes from
+// the bootstrap files; when there is none or it fails, the port-43
+// WHOIS lookup is used instead unless NoWHOIS is set.
+func Lookup(ctx context.Context, target string, opts Options) (*Result, error) {
+	b := opts.Bootstrap
+	if b == nil {
+		b = DefaultBootstrap()
+	}
+	r := &Result{Status: []string{}, Events: []Event{}, Nameservers: []string{}, Contacts: []Contact{}, Errors: []string{}}
+	var servers []string
+	var path string
+	if h := strings.TrimSpace(target); !strings.Contains(h, ".") && !strings.Contains(h, ":") && len(b.EntityServers(h)) > 0 {
+		r.Target, r.Type = strings.ToUpper(h), TypeEntity
+		servers, path = b.EntityServers(h), "entity/"+url.PathEscape(r.Target)
+	} else {
+		t, kind, err := whois.ParseTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		r.Target, r.Type = t, kind
+		switch kind {
+		case whois.TypeDomain:
+			servers, path = b.DomainServers(t), "domain/"+t
+		case whois.TypeIP:
+			prefix, err := netip.ParsePrefix(t)
+			if err != nil {
+				addr := netip.MustParseAddr(t)
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			servers, path = b.IPServers(prefix), "ip/"+t
+		case whois.TypeASN:
+			n := strings.TrimPrefix(t, "AS")
+			var asn uint32
+			fmt.Sscan(n, &asn)
+			servers, path = b.ASNServers(asn), "autnum/"+n
+		}
+	}
+
+	if !opts.NoRDAP {
+		c := newClient(opts)
+		if len(servers) == 0 {
+			r.Errors = append(r.Errors, fmt.Sprintf("no RDAP server for %s in the bootstrap files", r.Target))
+		}
+		for _, base := range servers {
+			u := strings.TrimSuffix(base, "/") + "/" + path
+			obj, err := c.get(ctx, u)
+			if err == nil {
+				r.Source, r.Server, r.URL = SourceRDAP, base, u
+				r.fill(obj)
+				if r.Type == whois.TypeDomain {
+					r.followRelated(ctx, c, obj)
+				}
+				return r, nil
+			}
+			if ctx.Err() != nil {
+				return r, ctx.Err()
+			}
+			if errors.Is(err, ErrNotFound) && r.Type == whois.TypeDomain {
+				// The registry is authoritative: the domain doesn't exist.
+				return r, fmt.Errorf("%s: %w", r.Target, ErrNotFound)
+			}
+			r.Errors = append(r.Errors, err.Error())
+		}
+	}
+
+	if opts.NoWHOIS || r.Type == TypeEntity {
+		return r, fmt.Errorf("RDAP lookup of %s failed: %s", r.Target, strings.Join(r.Errors, "; "))
+	}
+	w, err := whois.Lookup(ctx, r.Target, opts.WHOIS)
+	if err != nil {
+		return r, err
+	}
+	r.Source, r.Server, r.WHOIS = SourceWHOIS, w.Server, w
+	if w.Record != nil {
+		r.fillWHOIS(w.Record)
+	}
+	return r, nil
+}
+
+// followRelated completes a registry's domain record with the
+// registrar's, which thin registries such as .com link to and which holds
+// the registrant and abuse contacts.
+func (r *Result) followRelated(ctx context.Context, c *client, obj *object) {
+	href := relatedLink(obj)
+	if href == "" || strings.EqualFold(href, r.URL) {
+		return
+	}
+	rel, err := c.get(ctx, href)
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("registrar RDAP: %v", err))
+		return
+	}
+	for _, ct := range contacts(rel.Entities) {
+		r.addContact(ct)
+	}
+	if len(r.Nameservers) == 0 {
+		r.Nameservers = nameservers(rel)
+	}
+}
@@ -0,0 +1,408 @@
+package rdap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+	"github.com/tanvircs/afsa/pkg/whois/whoistest"
+)
+
+// rdapServer serves routes, keyed by host and path such as
+// "rdap.verisign.com/com/v1/domain/google.com", and returns a client that
+// sends the requests for every host to it. Other paths get a 404.
+func rdapServer(t *testing.T, routes map[string]http.HandlerFunc) (*http.Client, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Host + r.URL.Path
+		mu.Lock()
+		requests = append(requests, key)
+		mu.Unlock()
+		if h := routes[key]; h != nil {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorCode": 404, "title": "Not Found"}`))
+	}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+	// The test certificate is issued to example.com.
+	transport.TLSClientConfig.ServerName = "example.com"
+	client.Transport = transport
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+// arinHTTPS returns a bootstrap sending 8.0.0.0/8 to ARIN over HTTPS
+// only; the bundled one also lists ARIN's plain HTTP URL, which the test
+// server doesn't answer.
+func arinHTTPS(t *testing.T) *Bootstrap {
+	t.Helper()
+	dir := t.TempDir()
+	ipv4 := `{"version": "1.0", "publication": "2024-01-01T00:00:00Z", "services": [[["8.0.0.0/8"], ["https://rdap.arin.net/registry/"]]]}`
+	if err := os.WriteFile(filepath.Join(dir, FileIPv4), []byte(ipv4), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBootstrap(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// fixture serves the RDAP response saved in testdata/name.
+func fixture(t *testing.T, name string) http.HandlerFunc {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "" || !strings.Contains(r.Header.Get("Accept"), mediaType) {
+			http.Error(w, "missing Accept header", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(body)
+	}
+}
+
+func status(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}
+}
+
+const (
+	verisignGoogle    = "rdap.verisign.com/com/v1/domain/google.com"
+	markmonitorGoogle = "rdap.markmonitor.com/rdap/domain/GOOGLE.COM"
+	arinGoogleDNS     = "rdap.arin.net/registry/ip/8.8.8.8"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func findContact(r *Result, role string) *Contact {
+	for i, c := range r.Contacts {
+		for _, have := range c.Roles {
+			if have == role {
+				return &r.Contacts[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestLookupDomain(t *testing.T) {
+	client, requests := rdapServer(t, map[string]http.HandlerFunc{
+		verisignGoogle:    fixture(t, "verisign-google.com.json"),
+		markmonitorGoogle: fixture(t, "markmonitor-google.com.json"),
+	})
+	r, err := Lookup(context.Background(), "Google.COM", Options{HTTPClient: client, NoWHOIS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Source != SourceRDAP || r.Server != "https://rdap.verisign.com/com/v1/" || r.URL != "https://"+verisignGoogle || r.Name != "google.com" {
+		t.Errorf("source %s, server %s, URL %s, name %s", r.Source, r.Server, r.URL, r.Name)
+	}
+	if want := []string{verisignGoogle, markmonitorGoogle}; !reflect.DeepEqual(requests(), want) {
+		t.Errorf("requests %q, want %q", requests(), want)
+	}
+	if len(r.Errors) != 0 {
+		t.Errorf("Errors = %q", r.Errors)
+	}
+
+	// Dates come from the registry's events, the registrar's are not
+	// merged in.
+	if r.Registered == nil || !r.Registered.Equal(date("1997-09-15T04:00:00Z")) ||
+		r.Expires == nil || !r.Expires.Equal(date("2028-09-14T04:00:00Z")) ||
+		r.Updated == nil || !r.Updated.Equal(date("2019-09-09T15:39:04Z")) {
+		t.Errorf("registered %v, expires %v, updated %v", r.Registered, r.Expires, r.Updated)
+	}
+	if len(r.Events) != 4 || r.Events[3].Action != "last update of RDAP database" {
+		t.Errorf("Events = %+v", r.Events)
+	}
+	if len(r.Status) != 6 || r.DNSSEC {
+		t.Errorf("Status = %q, DNSSEC %v", r.Status, r.DNSSEC)
+	}
+	if want := []string{"ns1.google.com", "ns2.google.com", "ns3.google.com", "ns4.google.com"}; !reflect.DeepEqual(r.Nameservers, want) {
+		t.Errorf("Nameservers = %q, want %q", r.Nameservers, want)
+	}
+
+	if r.Registrar == nil || r.Registrar.Name != "MarkMonitor Inc." || r.Registrar.IANAID != "292" {
+		t.Errorf("Registrar = %+v", r.Registrar)
+	}
+	if r.Abuse == nil || r.Abuse.Email != "abusecomplaints@markmonitor.com" || r.Abuse.Phone != "+1.2086851750" {
+		t.Errorf("Abuse = %+v", r.Abuse)
+	}
+	// The registrant and technical contacts are only in the registrar's
+	// record.
+	if r.Registrant == nil || r.Registrant.Organization != "Google LLC" || r.Registrant.Address != "CA, US" || r.Registrant.Name != "" {
+		t.Errorf("Registrant = %+v", r.Registrant)
+	}
+	tech := findContact(r, "technical")
+	want := &Contact{
+		Handle:       "MMR-2383-TECH",
+		Roles:        []string{"technical"},
+		Name:         "Domain Administrator",
+		Organization: "Google LLC",
+		Email:        "dns-admin@google.com",
+		Phone:        "+1.6502530000",
+		Address:      "1600 Amphitheatre Parkway, Mountain View, CA 94043, US",
+	}
+	if !reflect.DeepEqual(tech, want) {
+		t.Errorf("technical contact = %+v, want %+v", tech, want)
+	}
+	// The registrar appears in both records but is listed once.
+	registrars := 0
+	for _, c := range r.Contacts {
+		if c.Handle == "292" {
+			registrars++
+		}
+	}
+	if registrars != 1 {
+		t.Errorf("registrar listed %d times in %+v", registrars, r.Contacts)
+	}
+}
+
+func TestLookupRegistrarFailure(t *testing.T) {
+	client, _ := rdapServer(t, map[string]http.HandlerFunc{
+		verisignGoogle:    fixture(t, "verisign-google.com.json"),
+		markmonitorGoogle: status(http.StatusServiceUnavailable, `{"errorCode": 503, "title": "Service Unavailable"}`),
+	})
+	r, err := Lookup(context.Background(), "google.com", Options{HTTPClient: client, NoWHOIS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The registry's record stands on its own.
+	if r.Source != SourceRDAP || r.Registrar == nil || r.Registrant != nil || len(r.Nameservers) != 4 {
+		t.Errorf("source %s, registrar %+v, registrant %+v, nameservers %q", r.Source, r.Registrar, r.Registrant, r.Nameservers)
+	}
+	if len(r.Errors) != 1 || !strings.HasPrefix(r.Errors[0], "registrar RDAP: ") || !strings.Contains(r.Errors[0], "503 Service Unavailable: Service Unavailable") {
+		t.Errorf("Errors = %q", r.Errors)
+	}
+}
+
+func TestLookupIPNetwork(t *testing.T) {
+	client, _ := rdapServer(t, map[string]http.HandlerFunc{arinGoogleDNS: fixture(t, "arin-8.8.8.8.json")})
+	r, err := Lookup(context.Background(), "8.8.8.8", Options{HTTPClient: client, Bootstrap: arinHTTPS(t), NoWHOIS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Network{
+		StartAddress: "8.8.8.0",
+		EndAddress:   "8.8.8.255",
+		CIDRs:        []string{"8.8.8.0/24"},
+		Version:      "v4",
+		Type:         "DIRECT ALLOCATION",
+		Parent:       "NET-8-0-0-0-0",
+	}
+	if r.Type != whois.TypeIP || r.Handle != "NET-8-8-8-0-2" || r.Name != "GOGL" || !reflect.DeepEqual(r.Network, want) {
+		t.Errorf("type %s, handle %s, name %s, network %+v", r.Type, r.Handle, r.Name, r.Network)
+	}
+	// Offsets are converted to UTC.
+	if r.Registered == nil || r.Registered.Format(time.RFC3339) != "2023-12-28T22:24:33Z" || r.Expires != nil {
+		t.Errorf("registered %v, expires %v", r.Registered, r.Expires)
+	}
+	if r.Registrant == nil || r.Registrant.Name != "Google LLC" || r.Registrant.Address != "1600 Amphitheatre Parkway, Mountain View, CA, 94043, United States" {
+		t.Errorf("Registrant = %+v", r.Registrant)
+	}
+	if r.Abuse == nil || r.Abuse.Handle != "ABUSE5250-ARIN" || r.Abuse.Email != "network-abuse@google.com" || r.Abuse.Phone != "+1-650-253-0000" {
+		t.Errorf("Abuse = %+v", r.Abuse)
+	}
+}
+
+func TestParseVCard(t *testing.T) {
+	tests := []struct {
+		name string
+		card string
+		want Contact
+	}{
+		{name: "empty", card: ``},
+		{name: "not a vcard", card: `{"fn": "x"}`},
+		{name: "short property", card: `["vcard", [["fn", {}, "text"]]]`},
+		{
+			name: "first value wins",
+			card: `["vcard", [["fn", {}, "text", " Jane Doe "], ["fn", {}, "text", "Other"], ["email", {"type": "work"}, "text", "jane@example.com"], ["email", {}, "text", "other@example.com"]]]`,
+			want: Contact{Name: "Jane Doe", Email: "jane@example.com"},
+		},
+		{
+			name: "structured address",
+			card: `["vcard", [["adr", {}, "text", ["", "Suite 5", ["1 Main St", "Building 2"], "Springfield", "", "12345", "US"]]]]`,
+			want: Contact{Address: "Suite 5, 1 Main St, Building 2, Springfield, 12345, US"},
+		},
+		{
+			name: "label and tel URI",
+			card: `["vcard", [["adr", {"label": "1 Main St\n  Springfield\n"}, "text", ["", "", "", "", "", "", ""]], ["tel", {}, "uri", "tel:+1-555-0100;ext=1"], ["ORG", {}, "text", "Example Inc."]]]`,
+			want: Contact{Address: "1 Main St, Springfield,", Phone: "+1-555-0100;ext=1", Organization: "Example Inc."},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseVCard([]byte(tt.card)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseVCard = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLookupFallback(t *testing.T) {
+	srv, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.Handle("whois.example", "google.com", "Domain Name: GOOGLE.COM\nRegistrar: MarkMonitor Inc.\nName Server: NS1.GOOGLE.COM\nRegistry Expiry Date: 2028-09-14T04:00:00Z\n")
+	srv.Handle("whois.example", "8.8.8.8", "NetRange: 8.8.8.0 - 8.8.8.255\nCIDR: 8.8.8.0/24\nNetName: GOGL\n")
+	whoisOpts := whois.Options{Server: "whois.example", Dial: srv.Dial, MaxReferrals: -1}
+
+	tests := []struct {
+		name    string
+		target  string
+		route   string
+		handler http.HandlerFunc
+		noWHOIS bool
+		source  string
+		err     error
+		errs    string
+	}{
+		{name: "domain not registered", target: "google.com", route: verisignGoogle, handler: status(http.StatusNotFound, ""), err: ErrNotFound},
+		{name: "registry error", target: "google.com", route: verisignGoogle, source: SourceWHOIS,
+			handler: status(http.StatusInternalServerError, `{"errorCode": 500, "title": "Internal Server Error", "description": ["Backend unavailable"]}`),
+			errs:    "https://" + verisignGoogle + ": HTTP 500 Internal Server Error: Internal Server Error Backend unavailable"},
+		{name: "registry error without WHOIS", target: "google.com", route: verisignGoogle, noWHOIS: true, handler: status(http.StatusBadGateway, "<html>bad gateway</html>"),
+			errs: "https://" + verisignGoogle + ": HTTP 502 Bad Gateway"},
+		{name: "network not found", target: "8.8.8.8", route: arinGoogleDNS, handler: status(http.StatusNotFound, ""), source: SourceWHOIS,
+			errs: "https://" + arinGoogleDNS + ": not found"},
+		{name: "invalid JSON", target: "8.8.8.8", route: arinGoogleDNS, handler: status(http.StatusOK, "{"), source: SourceWHOIS,
+			errs: "https://" + arinGoogleDNS + ": invalid RDAP response: unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		client, _ := rdapServer(t, map[string]http.HandlerFunc{tt.route: tt.handler})
+		before := len(srv.Queries())
+		r, err := Lookup(context.Background(), tt.target, Options{HTTPClient: client, Bootstrap: arinHTTPS(t), NoWHOIS: tt.noWHOIS, WHOIS: whoisOpts})
+		switch {
+		case tt.err != nil:
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			}
+		case tt.noWHOIS:
+			if err == nil || !strings.Contains(err.Error(), tt.errs) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.errs)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		asked := len(srv.Queries()) > before
+		if asked != (tt.source == SourceWHOIS) {
+			t.Errorf("%s: WHOIS asked %v", tt.name, asked)
+		}
+		if r.Source != tt.source {
+			t.Errorf("%s: source %q, want %q", tt.name, r.Source, tt.source)
+		}
+		if tt.errs != "" && (len(r.Errors) != 1 || r.Errors[0] != tt.errs) {
+			t.Errorf("%s: Errors = %q, want %q", tt.name, r.Errors, tt.errs)
+		}
+	}
+
+	// The fallback answer is filled like an RDAP one.
+	client, _ := rdapServer(t, nil)
+	r, err := Lookup(context.Background(), "8.8.8.8", Options{HTTPClient: client, Bootstrap: arinHTTPS(t), WHOIS: whoisOpts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Source != SourceWHOIS || r.Server != "whois.example" || r.Name != "GOGL" || r.Network == nil || r.Network.EndAddress != "8.8.8.255" || r.Network.Version != "v4" {
+		t.Errorf("source %s, server %s, name %s, network %+v", r.Source, r.Server, r.Name, r.Network)
+	}
+}
+
+func TestLookupRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter []string
+		requests   int
+		ok         bool
+	}{
+		{name: "retried after the delay", retryAfter: []string{"0"}, requests: 2, ok: true},
+		{name: "limited twice", retryAfter: []string{"0", "0"}, requests: 2},
+		{name: "delay too long", retryAfter: []string{"3600"}, requests: 1},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		served := 0
+		ok := fixture(t, "arin-8.8.8.8.json")
+		client, requests := rdapServer(t, map[string]http.HandlerFunc{arinGoogleDNS: func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			n := served
+			served++
+			mu.Unlock()
+			if n < len(tt.retryAfter) {
+				w.Header().Set("Retry-After", tt.retryAfter[n])
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			ok(w, r)
+		}})
+		r, err := Lookup(context.Background(), "8.8.8.8", Options{HTTPClient: client, Bootstrap: arinHTTPS(t), NoWHOIS: true})
+		if n := len(requests()); n != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, n, tt.requests)
+		}
+		if tt.ok {
+			if err != nil || r.Network == nil {
+				t.Errorf("%s: err = %v, network %+v", tt.name, err, r.Network)
+			}
+			continue
+		}
+		if err == nil || len(r.Errors) != 1 || !strings.HasSuffix(r.Errors[0], ErrRateLimited.Error()) {
+			t.Errorf("%s: err = %v, Errors = %q, want rate limited", tt.name, err, r.Errors)
+		}
+	}
+
+	// Cancelling stops the wait.
+	client, _ := rdapServer(t, map[string]http.HandlerFunc{arinGoogleDNS: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Lookup(ctx, "8.8.8.8", Options{HTTPClient: client, Bootstrap: arinHTTPS(t), NoWHOIS: true}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("cancelled lookup took %v", time.Since(start))
+	}
+}
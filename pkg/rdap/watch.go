@@ -0,0 +1,359 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+)
+
+// DefaultExpiryDays is how close to expiry a watched domain is reported
+// when WatchOptions.ExpiryDays is zero.
+const DefaultExpiryDays = 30
+
+// Kinds of Change.
+const (
+	ChangeRegistrar     = "registrar"
+	ChangeNameservers   = "nameservers"
+	ChangeStatusRemoved = "status_removed"
+	ChangeStatusAdded   = "status_added"
+	ChangeExpires       = "expires"
+	// ChangeUnregistered is a domain the registry no longer has.
+	ChangeUnregistered = "unregistered"
+)
+
+// WatchOptions configures a Watch.
+type WatchOptions struct {
+	// Lookup configures the lookup of each domain.
+	Lookup Options
+	// Dir holds the snapshots, one file per domain. Empty keeps none, so
+	// every domain is seen for the first time.
+	Dir string
+	// ExpiryDays reports domains expiring within that many days. Zero
+	// means DefaultExpiryDays.
+	ExpiryDays int
+	// OnDomain, if set, is called before each domain is looked up.
+	OnDomain func(domain string)
+}
+
+// WatchResult is the outcome of checking a list of domains against their
+// last snapshots.
+type WatchResult struct {
+	Domains    []WatchedDomain `json:"domains"`
+	ExpiryDays int             `json:"expiry_days"`
+	// Changed, Expiring and Failed count the domains with changes, close
+	// to expiry and whose lookup failed.
+	Changed    int   `json:"changed"`
+	Expiring   int   `json:"expiring"`
+	Failed     int   `json:"failed"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// WatchedDomain is the current registration of a domain and how it
+// differs from the last snapshot.
+type WatchedDomain struct {
+	Domain      string     `json:"domain"`
+	Registrar   string     `json:"registrar"`
+	Nameservers []string   `json:"nameservers"`
+	Status      []string   `json:"status"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	// DaysLeft is the number of whole days until Expires, negative once
+	// it has passed.
+	DaysLeft *int `json:"days_left,omitempty"`
+	Expiring bool `json:"expiring"`
+	// FirstSeen is set when there was no snapshot to compare with.
+	FirstSeen bool `json:"first_seen"`
+	// LastChecked is when the previous snapshot was taken.
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	Changes     []Change   `json:"changes"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Change is a difference from the last snapshot. Old and New are empty
+// where they don't apply, e.g. New for a removed status.
+type Change struct {
+	Kind string `json:"kind"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Snapshot is what a Watch stores of a domain between runs.
+type Snapshot struct {
+	Domain      string     `json:"domain"`
+	Registrar   string     `json:"registrar"`
+	Nameservers []string   `json:"nameservers"`
+	Status      []string   `json:"status"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	Source      string     `json:"source"`
+	CheckedAt   time.Time  `json:"checked_at"`
+}
+
+// DefaultWatchDir returns the afsa snapshot directory in the user's
+// cache directory.
+func DefaultWatchDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "afsa", "watch"), nil
+}
+
+// Watch looks up each domain and compares it with the snapshot saved by
+// the previous run: a registrar or nameserver change, a status added or
+// removed, a new expiry date or the domain no longer being registered.
+// Domains expiring within ExpiryDays are flagged too. The snapshots are
+// then replaced, so each change is reported once. A failed lookup leaves
+// its snapshot alone and is recorded in the domain's Error.
+func Watch(ctx context.Context, domains []string, opts WatchOptions) (*WatchResult, error) {
+	start := time.Now()
+	days := opts.ExpiryDays
+	if days <= 0 {
+		days = DefaultExpiryDays
+	}
+	res := &WatchResult{Domains: []WatchedDomain{}, ExpiryDays: days}
+	for _, d := range domains {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if opts.OnDomain != nil {
+			opts.OnDomain(d)
+		}
+		w := watchDomain(ctx, d, opts.Lookup, opts.Dir, days)
+		if w.Error != "" {
+			res.Failed++
+		}
+		if len(w.Changes) > 0 {
+			res.Changed++
+		}
+		if w.Expiring {
+			res.Expiring++
+		}
+		res.Domains = append(res.Domains, w)
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	return res, nil
+}
+
+func watchDomain(ctx context.Context, domain string, lookup Options, dir string, days int) WatchedDomain {
+	w := WatchedDomain{Domain: domain, Nameservers: []string{}, Status: []string{}, Changes: []Change{}}
+	name, kind, err := whois.ParseTarget(domain)
+	if err == nil && (kind != whois.TypeDomain || strings.ContainsAny(name, `/\`)) {
+		err = fmt.Errorf("%q is not a domain name", domain)
+	}
+	if err != nil {
+		w.Error = err.Error()
+		return w
+	}
+	w.Domain = name
+
+	prev, err := loadSnapshot(dir, name)
+	if err != nil {
+		w.Error = err.Error()
+		return w
+	}
+	if prev != nil {
+		checked := prev.CheckedAt
+		w.LastChecked = &checked
+	}
+
+	r, err := Lookup(ctx, name, lookup)
+	if errors.Is(err, ErrNotFound) && prev != nil {
+		w.Changes = append(w.Changes, Change{Kind: ChangeUnregistered, Old: prev.Registrar})
+		if err := os.Remove(snapshotPath(dir, name)); err != nil && !os.IsNotExist(err) {
+			w.Error = err.Error()
+		}
+		return w
+	}
+	if err != nil {
+		w.Error = err.Error()
+		return w
+	}
+
+	cur := snapshotOf(name, r)
+	if prev == nil {
+		w.FirstSeen = true
+	} else {
+		w.Changes = compareSnapshots(prev, &cur)
+		// Keep what this answer lacks, as a WHOIS fallback may, so the
+		// next run doesn't report it as changed back.
+		if cur.Registrar == "" {
+			cur.Registrar = prev.Registrar
+		}
+		if len(cur.Nameservers) == 0 {
+			cur.Nameservers = prev.Nameservers
+		}
+		if len(cur.Status) == 0 {
+			cur.Status = prev.Status
+		}
+		if cur.Expires == nil {
+			cur.Expires = prev.Expires
+		}
+	}
+	w.Registrar, w.Nameservers, w.Status, w.Expires = cur.Registrar, cur.Nameservers, cur.Status, cur.Expires
+	if w.Expires != nil {
+		left := int(math.Floor(time.Until(*w.Expires).Hours() / 24))
+		w.DaysLeft = &left
+		w.Expiring = left <= days
+	}
+	if dir != "" {
+		if err := saveSnapshot(dir, cur); err != nil {
+			w.Error = fmt.Sprintf("saving snapshot: %v", err)
+		}
+	}
+	return w
+}
+
+// snapshotOf takes the watched fields of a lookup result.
+func snapshotOf(domain string, r *Result) Snapshot {
+	s := Snapshot{Domain: domain, Nameservers: []string{}, Status: []string{}, Expires: r.Expires, Source: r.Source, CheckedAt: time.Now().UTC()}
+	if r.Registrar != nil {
+		s.Registrar = r.Registrar.Name
+		if s.Registrar == "" {
+			s.Registrar = r.Registrar.Organization
+		}
+	}
+	for _, ns := range r.Nameservers {
+		s.Nameservers = appendNew(s.Nameservers, strings.ToLower(ns))
+	}
+	sort.Strings(s.Nameservers)
+	for _, st := range r.Status {
+		s.Status = appendNew(s.Status, st)
+	}
+	return s
+}
+
+// compareSnapshots lists how cur differs from prev. A field cur lacks is
+// not a change: a WHOIS fallback may not have it.
+func compareSnapshots(prev, cur *Snapshot) []Change {
+	changes := []Change{}
+	if cur.Registrar != "" && prev.Registrar != "" && registrarKey(cur.Registrar) != registrarKey(prev.Registrar) {
+		changes = append(changes, Change{Kind: ChangeRegistrar, Old: prev.Registrar, New: cur.Registrar})
+	}
+	if len(cur.Nameservers) > 0 && len(prev.Nameservers) > 0 && !sameSet(prev.Nameservers, cur.Nameservers, strings.ToLower) {
+		changes = append(changes, Change{Kind: ChangeNameservers, Old: strings.Join(prev.Nameservers, ", "), New: strings.Join(cur.Nameservers, ", ")})
+	}
+	if len(cur.Status) > 0 {
+		for _, s := range prev.Status {
+			if !containsKey(cur.Status, s, statusKey) {
+				changes = append(changes, Change{Kind: ChangeStatusRemoved, Old: s})
+			}
+		}
+		if len(prev.Status) > 0 {
+			for _, s := range cur.Status {
+				if !containsKey(prev.Status, s, statusKey) {
+					changes = append(changes, Change{Kind: ChangeStatusAdded, New: s})
+				}
+			}
+		}
+	}
+	if cur.Expires != nil && prev.Expires != nil && !sameDay(*cur.Expires, *prev.Expires) {
+		changes = append(changes, Change{Kind: ChangeExpires, Old: prev.Expires.Format("2006-01-02"), New: cur.Expires.Format("2006-01-02")})
+	}
+	return changes
+}
+
+// statusKey compares status codes across sources: RDAP writes "client
+// transfer prohibited" where WHOIS writes "clientTransferProhibited".
+func statusKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+// registrarKey compares registrar names written differently, such as
+// "MarkMonitor Inc." and "MarkMonitor, Inc.".
+func registrarKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func sameSet(a, b []string, key func(string) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, s := range a {
+		if !containsKey(b, s, key) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsKey(list []string, s string, key func(string) string) bool {
+	for _, x := range list {
+		if key(x) == key(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendNew(list []string, s string) []string {
+	if containsKey(list, s, statusKey) {
+		return list
+	}
+	return append(list, s)
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
+
+func snapshotPath(dir, domain string) string {
+	return filepath.Join(dir, domain+".json")
+}
+
+// loadSnapshot returns the saved snapshot of domain, or nil if there is
+// none.
+func loadSnapshot(dir, domain string) (*Snapshot, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(snapshotPath(dir, domain))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", snapshotPath(dir, domain), err)
+	}
+	return s, nil
+}
+
+func saveSnapshot(dir string, s Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// Write and rename, so an interrupted run never leaves half a file.
+	tmp, err := os.CreateTemp(dir, s.Domain+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), snapshotPath(dir, s.Domain))
+}
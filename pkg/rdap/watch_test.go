@@ -0,0 +1,188 @@
+package rdap
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tanvircs/afsa/pkg/whois"
+	"github.com/tanvircs/afsa/pkg/whois/whoistest"
+)
+
+func TestCompareSnapshots(t *testing.T) {
+	expires := date("2028-09-14T04:00:00Z")
+	base := func() *Snapshot {
+		e := expires
+		return &Snapshot{
+			Domain:      "example.com",
+			Registrar:   "MarkMonitor Inc.",
+			Nameservers: []string{"ns1.example.com", "ns2.example.com"},
+			Status:      []string{"client transfer prohibited", "server delete prohibited"},
+			Expires:     &e,
+		}
+	}
+	later := date("2029-09-14T04:00:00Z")
+	sameDay := date("2028-09-14T23:00:00Z")
+
+	tests := []struct {
+		name   string
+		change func(s *Snapshot)
+		want   []Change
+	}{
+		{name: "unchanged", change: func(s *Snapshot) {}},
+		{name: "registrar renamed", change: func(s *Snapshot) { s.Registrar = "MARKMONITOR, INC" }},
+		{name: "registrar", change: func(s *Snapshot) { s.Registrar = "Example Registrar LLC" },
+			want: []Change{{Kind: ChangeRegistrar, Old: "MarkMonitor Inc.", New: "Example Registrar LLC"}}},
+		{name: "nameservers reordered", change: func(s *Snapshot) { s.Nameservers = []string{"NS2.example.com", "ns1.example.com"} }},
+		{name: "nameserver replaced", change: func(s *Snapshot) { s.Nameservers = []string{"ns1.example.com", "ns3.example.net"} },
+			want: []Change{{Kind: ChangeNameservers, Old: "ns1.example.com, ns2.example.com", New: "ns1.example.com, ns3.example.net"}}},
+		{name: "nameserver added", change: func(s *Snapshot) { s.Nameservers = append(s.Nameservers, "ns3.example.net") },
+			want: []Change{{Kind: ChangeNameservers, Old: "ns1.example.com, ns2.example.com", New: "ns1.example.com, ns2.example.com, ns3.example.net"}}},
+		{name: "status as WHOIS writes it", change: func(s *Snapshot) { s.Status = []string{"serverDeleteProhibited", "clientTransferProhibited"} }},
+		{name: "status", change: func(s *Snapshot) { s.Status = []string{"server delete prohibited", "pending delete"} },
+			want: []Change{{Kind: ChangeStatusRemoved, Old: "client transfer prohibited"}, {Kind: ChangeStatusAdded, New: "pending delete"}}},
+		{name: "renewed", change: func(s *Snapshot) { s.Expires = &later },
+			want: []Change{{Kind: ChangeExpires, Old: "2028-09-14", New: "2029-09-14"}}},
+		{name: "expiry time of day", change: func(s *Snapshot) { s.Expires = &sameDay }},
+		{name: "fallback without the fields", change: func(s *Snapshot) {
+			s.Registrar, s.Nameservers, s.Status, s.Expires = "", []string{}, []string{}, nil
+		}},
+		{name: "fallback with some fields", change: func(s *Snapshot) {
+			s.Nameservers, s.Status, s.Expires = []string{}, []string{}, &later
+		}, want: []Change{{Kind: ChangeExpires, Old: "2028-09-14", New: "2029-09-14"}}},
+	}
+	for _, tt := range tests {
+		cur := base()
+		tt.change(cur)
+		got := compareSnapshots(base(), cur)
+		if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: changes %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Nothing is reported as added next to a status list the previous
+	// answer lacked.
+	prev := base()
+	prev.Status = []string{}
+	if got := compareSnapshots(prev, base()); len(got) != 0 {
+		t.Errorf("changes %+v after a snapshot without status", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	registry, err := os.ReadFile(filepath.Join("testdata", "verisign-google.com.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same record after a renewal that moved a nameserver.
+	renewed := bytes.Replace(registry, []byte("NS4.GOOGLE.COM"), []byte("NS5.EXAMPLE.NET"), 1)
+	renewed = bytes.Replace(renewed, []byte(`"eventDate": "2028-09-14`), []byte(`"eventDate": "2029-09-14`), 1)
+	if bytes.Equal(renewed, registry) || bytes.Count(renewed, []byte("2029-09-14")) != 1 {
+		t.Fatal("the fixture changed; update the renewal")
+	}
+
+	srv, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	// The fallback has only the registrar, written its own way, and the
+	// expiry date.
+	srv.Handle("whois.example", "google.com", "Domain Name: GOOGLE.COM\nRegistrar: MarkMonitor, Inc.\nRegistry Expiry Date: 2028-09-14T04:00:00Z\n")
+
+	dir := t.TempDir()
+	watch := func(registry http.HandlerFunc, days int) WatchedDomain {
+		t.Helper()
+		client, _ := rdapServer(t, map[string]http.HandlerFunc{
+			verisignGoogle:    registry,
+			markmonitorGoogle: fixture(t, "markmonitor-google.com.json"),
+		})
+		var seen []string
+		res, err := Watch(context.Background(), []string{"Google.com", "not a domain"}, WatchOptions{
+			Lookup:     Options{HTTPClient: client, WHOIS: whois.Options{Server: "whois.example", Dial: srv.Dial, MaxReferrals: -1}},
+			Dir:        dir,
+			ExpiryDays: days,
+			OnDomain:   func(d string) { seen = append(seen, d) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Domains) != 2 || len(seen) != 2 || res.Failed != 1 || res.Domains[1].Error == "" {
+			t.Fatalf("domains %+v, seen %q, %d failed", res.Domains, seen, res.Failed)
+		}
+		w := res.Domains[0]
+		if w.Error != "" {
+			t.Fatalf("google.com: %s", w.Error)
+		}
+		changed, expiring := 0, 0
+		if len(w.Changes) > 0 {
+			changed = 1
+		}
+		if w.Expiring {
+			expiring = 1
+		}
+		if res.Changed != changed || res.Expiring != expiring {
+			t.Errorf("%d changed, %d expiring for %+v", res.Changed, res.Expiring, w)
+		}
+		return w
+	}
+	snapshot := func() *Snapshot {
+		t.Helper()
+		s, err := loadSnapshot(dir, "google.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	nameservers := []string{"ns1.google.com", "ns2.google.com", "ns3.google.com", "ns4.google.com"}
+
+	// The first run has nothing to compare with. The expiry is years
+	// away, so only a window that long flags it.
+	w := watch(fixture(t, "verisign-google.com.json"), 5000)
+	if w.Domain != "google.com" || !w.FirstSeen || w.LastChecked != nil || len(w.Changes) != 0 || !w.Expiring || w.DaysLeft == nil || *w.DaysLeft < 365 {
+		t.Errorf("first run: %+v", w)
+	}
+	if s := snapshot(); s == nil || s.Registrar != "MarkMonitor Inc." || !reflect.DeepEqual(s.Nameservers, nameservers) || len(s.Status) != 6 || s.Source != SourceRDAP {
+		t.Fatalf("first snapshot %+v", s)
+	}
+
+	// The WHOIS fallback lacks the nameservers and status: they are not
+	// reported as changed, and the snapshot keeps them.
+	w = watch(status(http.StatusServiceUnavailable, ""), 30)
+	if w.FirstSeen || w.LastChecked == nil || len(w.Changes) != 0 || w.Expiring || !reflect.DeepEqual(w.Nameservers, nameservers) {
+		t.Errorf("fallback run: %+v", w)
+	}
+	if s := snapshot(); s.Source != SourceWHOIS || s.Registrar != "MarkMonitor, Inc." || !reflect.DeepEqual(s.Nameservers, nameservers) || len(s.Status) != 6 {
+		t.Errorf("fallback snapshot %+v", s)
+	}
+
+	renewal := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(renewed)
+	}
+	w = watch(renewal, 30)
+	want := []Change{
+		{Kind: ChangeNameservers, Old: "ns1.google.com, ns2.google.com, ns3.google.com, ns4.google.com", New: "ns1.google.com, ns2.google.com, ns3.google.com, ns5.example.net"},
+		{Kind: ChangeExpires, Old: "2028-09-14", New: "2029-09-14"},
+	}
+	if !reflect.DeepEqual(w.Changes, want) {
+		t.Errorf("renewal changes %+v, want %+v", w.Changes, want)
+	}
+	// Each change is reported once.
+	if w = watch(renewal, 30); len(w.Changes) != 0 {
+		t.Errorf("renewal reported again: %+v", w.Changes)
+	}
+
+	// A domain the registry no longer has loses its snapshot.
+	w = watch(status(http.StatusNotFound, ""), 30)
+	if want := []Change{{Kind: ChangeUnregistered, Old: "MarkMonitor Inc."}}; !reflect.DeepEqual(w.Changes, want) {
+		t.Errorf("unregistered changes %+v, want %+v", w.Changes, want)
+	}
+	if s := snapshot(); s != nil {
+		t.Errorf("snapshot %+v kept after the domain was unregistered", s)
+	}
+}
@@ -0,0 +1,130 @@
+package scan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		tcp  []int
+		udp  []int
+	}{
+		{spec: "80", tcp: []int{80}, udp: []int{80}},
+		{spec: "22,80,443", tcp: []int{22, 80, 443}, udp: []int{22, 80, 443}},
+		{spec: "443, 22 ,80", tcp: []int{22, 80, 443}, udp: []int{22, 80, 443}},
+		{spec: "1000-1003", tcp: []int{1000, 1001, 1002, 1003}, udp: []int{1000, 1001, 1002, 1003}},
+		{spec: "80,80,79-81,81", tcp: []int{79, 80, 81}, udp: []int{79, 80, 81}},
+		{spec: "T:22,80", tcp: []int{22, 80}, udp: []int{}},
+		{spec: "U:53,161", tcp: []int{}, udp: []int{53, 161}},
+		{spec: "22,U:53,T:8080", tcp: []int{22, 8080}, udp: []int{22, 53}},
+		{spec: "t:22,u:53", tcp: []int{22}, udp: []int{53}},
+		{spec: "T:53,U:53", tcp: []int{53}, udp: []int{53}},
+		{spec: "65533-", tcp: []int{65533, 65534, 65535}, udp: []int{65533, 65534, 65535}},
+		{spec: "-3", tcp: []int{1, 2, 3}, udp: []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		ps, err := ParsePortSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePortSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(ps.TCP, tt.tcp) || !reflect.DeepEqual(ps.UDP, tt.udp) {
+			t.Errorf("ParsePortSpec(%q) = TCP %v UDP %v, want TCP %v UDP %v", tt.spec, ps.TCP, ps.UDP, tt.tcp, tt.udp)
+		}
+	}
+}
+
+func TestParsePortSpecRanges(t *testing.T) {
+	tests := []struct {
+		spec       string
+		tcp, udp   int
+		first, end int
+	}{
+		{spec: "22,80,443,1000-2000,U:53,T:8080", tcp: 3 + 1001 + 1, udp: 3 + 1001 + 1, first: 22, end: 8080},
+		{spec: "-", tcp: MaxPort, udp: MaxPort, first: 1, end: MaxPort},
+		{spec: "T:-", tcp: MaxPort, udp: 0, first: 1, end: MaxPort},
+	}
+	for _, tt := range tests {
+		ps, err := ParsePortSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePortSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if len(ps.TCP) != tt.tcp || len(ps.UDP) != tt.udp {
+			t.Errorf("ParsePortSpec(%q): %d TCP and %d UDP ports, want %d and %d", tt.spec, len(ps.TCP), len(ps.UDP), tt.tcp, tt.udp)
+			continue
+		}
+		if ps.TCP[0] != tt.first || ps.TCP[len(ps.TCP)-1] != tt.end {
+			t.Errorf("ParsePortSpec(%q): TCP ports %d..%d, want %d..%d", tt.spec, ps.TCP[0], ps.TCP[len(ps.TCP)-1], tt.first, tt.end)
+		}
+	}
+
+	ps, err := ParsePortSpec("22,80,443,1000-2000,U:53,T:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []int{53, 8080} {
+		if contains(ps.TCP, p) == contains(ps.UDP, p) {
+			t.Errorf("port %d: in TCP %v, in UDP %v, want exactly one", p, contains(ps.TCP, p), contains(ps.UDP, p))
+		}
+	}
+}
+
+func TestParsePortSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "", want: "empty port specification"},
+		{spec: "0", want: "out of range"},
+		{spec: "65536", want: "out of range"},
+		{spec: "10-5", want: "greater than"},
+		{spec: "T:", want: "empty entry"},
+		{spec: "80,", want: "empty entry"},
+		{spec: "abc", want: "not a port number"},
+		{spec: "1-2-3", want: "not a port number"},
+		{spec: "S:80", want: "SCTP"},
+		{spec: "X:80", want: "unknown protocol prefix"},
+	}
+	for _, tt := range tests {
+		_, err := ParsePortSpec(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParsePortSpec(%q) error = %v, want one containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func contains(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTopPorts(t *testing.T) {
+	ports, err := TopPorts(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, p := range ports {
+		if p < 1 || p > MaxPort || seen[p] {
+			t.Fatalf("TopPorts(1000) has invalid or repeated port %d", p)
+		}
+		seen[p] = true
+	}
+	if got := ports[:5]; !reflect.DeepEqual(got, []int{80, 23, 443, 21, 22}) {
+		t.Errorf("TopPorts(1000) starts with %v, want the most common first", got)
+	}
+	if _, err := TopPorts(1001); err == nil {
+		t.Error("TopPorts(1001) succeeded, want an error")
+	}
+	if _, err := TopPorts(0); err == nil {
+		t.Error("TopPorts(0) succeeded, want an error")
+	}
+}
@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces events evenly at a fixed rate shared by all callers.
+// A nil *rateLimiter never blocks.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller's slot comes up or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hostLimiter caps the number of simultaneous connections per host.
+// A nil *hostLimiter never blocks.
+type hostLimiter struct {
+	mu    sync.Mutex
+	max   int
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(max int) *hostLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &hostLimiter{max: max, slots: make(map[string]chan struct{})}
+}
+
+func (h *hostLimiter) acquire(ctx context.Context, host string) error {
+	if h == nil {
+		return ctx.Err()
+	}
+
+	h.mu.Lock()
+	sem, ok := h.slots[host]
+	if !ok {
+		sem = make(chan struct{}, h.max)
+		h.slots[host] = sem
+	}
+	h.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *hostLimiter) release(host string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	sem := h.slots[host]
+	h.mu.Unlock()
+	<-sem
+}
@@ -0,0 +1,107 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	if l := newRateLimiter(0); l != nil {
+		t.Errorf("newRateLimiter(0) = %+v, want nil", l)
+	}
+	// A nil limiter never blocks, but still reports a cancelled context.
+	var none *rateLimiter
+	if err := none.wait(context.Background()); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := none.wait(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("nil limiter after cancel: %v", err)
+	}
+
+	// Eleven callers at 100 per second share ten intervals between them.
+	l := newRateLimiter(100)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 11; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 95*time.Millisecond || elapsed > time.Second {
+		t.Errorf("11 events at 100/s took %v, want about 100ms", elapsed)
+	}
+
+	// An idle limiter doesn't bank slots for a burst later.
+	time.Sleep(50 * time.Millisecond)
+	start = time.Now()
+	l.wait(context.Background())
+	l.wait(context.Background())
+	if elapsed := time.Since(start); elapsed < 9*time.Millisecond {
+		t.Errorf("two events after a pause took %v, want one interval", elapsed)
+	}
+
+	// Cancelling stops the wait for a slot.
+	l = newRateLimiter(1)
+	l.wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("wait = %v after %v, want the deadline after about 20ms", err, time.Since(start))
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	if h := newHostLimiter(0); h != nil {
+		t.Errorf("newHostLimiter(0) = %+v, want nil", h)
+	}
+	var none *hostLimiter
+	if err := none.acquire(context.Background(), "192.0.2.1"); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+	none.release("192.0.2.1")
+
+	h := newHostLimiter(2)
+	for i := 0; i < 2; i++ {
+		if err := h.acquire(context.Background(), "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The host is full; another host is not.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.acquire(ctx, "192.0.2.1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("third acquire = %v, want the deadline", err)
+	}
+	if err := h.acquire(context.Background(), "192.0.2.2"); err != nil {
+		t.Errorf("other host: %v", err)
+	}
+
+	// A release lets a waiting caller in.
+	acquired := make(chan error)
+	go func() { acquired <- h.acquire(context.Background(), "192.0.2.1") }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquired a full host: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	h.release("192.0.2.1")
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("waiting caller not let in after a release")
+	}
+}
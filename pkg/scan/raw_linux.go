@@ -0,0 +1,21 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// openRawTCP opens a raw socket that receives every inbound TCP segment
+// for network "ip4:tcp" or "ip6:tcp". It needs root or CAP_NET_RAW.
+func openRawTCP(network string) (net.PacketConn, error) {
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("raw sockets need root or CAP_NET_RAW")
+		}
+		return nil, err
+	}
+	return conn, nil
+}
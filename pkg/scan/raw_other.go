@@ -0,0 +1,14 @@
+//go:build !linux
+
+package scan
+
+import (
+	"fmt"
+	"net"
+)
+
+// openRawTCP is only implemented on Linux: other kernels do not deliver
+// TCP segments to raw sockets.
+func openRawTCP(network string) (net.PacketConn, error) {
+	return nil, fmt.Errorf("SYN scanning is only supported on Linux")
+}
@@ -0,0 +1,659 @@
+// Package scan implements TCP port scanning with service identification.
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/fingerprint"
+)
+
+// DefaultTimeout is the per-port dial timeout used when Options.Timeout
+// is zero.
+const DefaultTimeout = 3 * time.Second
+
+// DefaultConcurrency is the number of workers used when
+// Options.Concurrency is zero.
+const DefaultConcurrency = 100
+
+// Options configures a Scan.
+type Options struct {
+	// Ports to probe on every target; nil means CommonPorts(), or
+	// CommonUDPPorts() for a UDP scan.
+	Ports []int
+	// UDP scans the ports over UDP instead of TCP.
+	UDP bool
+	// UDPPorts are probed over UDP in the same run as the TCP Ports, as
+	// a port specification mixing T: and U: entries asks for.
+	UDPPorts []int
+	// Method selects how TCP ports are probed: MethodAuto (the default
+	// when empty), MethodSYN or MethodConnect.
+	Method string
+	// Timeout is the per-port dial timeout, or for UDP how long to wait
+	// for a reply to each datagram.
+	Timeout time.Duration
+	// Retries is the number of retransmissions after a UDP request or a
+	// SYN probe gets no reply; zero means DefaultRetries, negative means
+	// none. Connect scans leave retransmission to the kernel.
+	Retries int
+	// Concurrency is the number of probes in flight at once.
+	Concurrency int
+	// Rate caps the probes started per second across all workers.
+	// Zero means unlimited.
+	Rate float64
+	// MaxPerHost caps simultaneous connections to a single address.
+	// Zero means only Concurrency applies.
+	MaxPerHost int
+	// ServiceDetection reads banners and sends protocol probes to every
+	// open port to identify the product and version behind it.
+	ServiceDetection bool
+	// Probes used for service detection; nil means the built-in set.
+	Probes []*fingerprint.Probe
+	// OnResult, if set, is called as each probe completes, in completion
+	// order. Calls are serialized.
+	OnResult func(Target, PortResult)
+	// Progress, if set, is called after each probe with the number of
+	// probes done so far and the total. Calls are serialized.
+	Progress func(done, total int)
+}
+
+// Result is the outcome of a scan, grouped per scanned address.
+type Result struct {
+	Method string `json:"method"`
+	// MethodNote explains why the requested method was not used.
+	MethodNote string `json:"method_note,omitempty"`
+	// Protocol is ProtocolTCP, ProtocolUDP or, when both were scanned,
+	// ProtocolMixed.
+	Protocol string `json:"protocol"`
+	// Retries is the number of UDP and SYN retransmissions.
+	Retries int `json:"retries,omitempty"`
+	// TimeoutSeconds is the per-port timeout rounded up to whole
+	// seconds; TimeoutMS has it exactly.
+	TimeoutSeconds int     `json:"timeout_seconds"`
+	TimeoutMS      int64   `json:"timeout_ms"`
+	Concurrency    int     `json:"concurrency"`
+	RatePerSecond  float64 `json:"rate_per_second,omitempty"`
+	MaxPerHost     int     `json:"max_per_host,omitempty"`
+	PortsPerHost   int     `json:"ports_per_host"`
+	// ServiceDetection records whether open ports were fingerprinted.
+	ServiceDetection bool         `json:"service_detection"`
+	Hosts            []HostResult `json:"hosts"`
+	// DualStack is filled for hostnames that resolved to both IPv4 and
+	// IPv6 addresses, one entry per port open on either family.
+	DualStack []PortReachability `json:"dual_stack,omitempty"`
+	Open      int                `json:"open"`
+	Closed    int                `json:"closed"`
+	Filtered  int                `json:"filtered"`
+	// Unreachable counts ports whose probe failed with a routing error.
+	Unreachable int `json:"unreachable"`
+	// OpenFiltered is only produced by UDP scans.
+	OpenFiltered int   `json:"open_filtered,omitempty"`
+	DurationMS   int64 `json:"duration_ms"`
+
+	Elapsed time.Duration `json:"-"`
+}
+
+// HostResult holds the ports found on one address. Only open ports are
+// listed, sorted by port; the remaining ports are counted by state.
+type HostResult struct {
+	Address      string       `json:"address"`
+	Family       string       `json:"family"`
+	Hostname     string       `json:"hostname,omitempty"`
+	OpenPorts    []PortResult `json:"open_ports"`
+	Open         int          `json:"open"`
+	Closed       int          `json:"closed"`
+	Filtered     int          `json:"filtered"`
+	Unreachable  int          `json:"unreachable"`
+	OpenFiltered int          `json:"open_filtered,omitempty"`
+}
+
+// PortResult is the outcome of probing a single port.
+type PortResult struct {
+	Port  int    `json:"port"`
+	State string `json:"state"`
+	// Protocol is the transport the port was probed over.
+	Protocol string `json:"protocol,omitempty"`
+	Service  string `json:"service,omitempty"`
+	// Family is the address family the port was probed over.
+	Family string `json:"family,omitempty"`
+	// Reason is the error behind a state other than open, e.g.
+	// "connection refused" or "no response".
+	Reason string `json:"reason,omitempty"`
+
+	// Filled by service detection. Confidence is between 0 and 1; a
+	// service named only from the port number scores PortGuessConfidence.
+	Product    string  `json:"product,omitempty"`
+	Version    string  `json:"version,omitempty"`
+	Info       string  `json:"info,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Banner     string  `json:"banner,omitempty"`
+}
+
+// PortGuessConfidence is reported when service detection found nothing
+// and the service name comes from the well-known port table.
+const PortGuessConfidence = 0.3
+
+// PortReachability compares, for a hostname with both A and AAAA
+// records, which address families a port was open on.
+type PortReachability struct {
+	Hostname string `json:"hostname"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+	IPv4     bool   `json:"ipv4"`
+	IPv6     bool   `json:"ipv6"`
+}
+
+// Port states.
+const (
+	StateOpen = "open"
+	// StateClosed means the host answered with a RST (TCP) or an ICMP
+	// port unreachable (UDP): nothing listens, but nothing filters.
+	StateClosed = "closed"
+	// StateFiltered means probes went unanswered, or were blocked
+	// locally, so a firewall most likely drops them.
+	StateFiltered = "filtered"
+	// StateUnreachable means an ICMP host or network unreachable came
+	// back: the problem is routing, not the port.
+	StateUnreachable = "unreachable"
+	// StateOpenFiltered means a UDP request got no answer at all: the
+	// service ignored it or a firewall dropped it.
+	StateOpenFiltered = "open|filtered"
+)
+
+// Protocols reported in Result.Protocol.
+const (
+	ProtocolTCP   = "tcp"
+	ProtocolUDP   = "udp"
+	ProtocolMixed = "tcp+udp"
+)
+
+type probeJob struct {
+	host int
+	port int
+	udp  bool
+}
+
+type probeResult struct {
+	host int
+	PortResult
+}
+
+// Scan probes every port on every target with a TCP SYN or connect, or
+// a UDP request for opts.UDPPorts and, when opts.UDP is set, opts.Ports,
+// using a bounded pool of workers. Hosts appear in the result in target
+// order.
+func Scan(ctx context.Context, targets []Target, opts Options) (*Result, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets to scan")
+	}
+
+	tcpPorts, udpPorts := opts.Ports, opts.UDPPorts
+	if opts.UDP {
+		tcpPorts, udpPorts = nil, mergePorts(opts.Ports, opts.UDPPorts)
+	}
+	if opts.Ports == nil && opts.UDPPorts == nil {
+		if opts.UDP {
+			udpPorts = CommonUDPPorts()
+		} else {
+			tcpPorts = CommonPorts()
+		}
+	}
+	if len(tcpPorts)+len(udpPorts) == 0 {
+		return nil, fmt.Errorf("no ports to scan")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	total := len(targets) * (len(tcpPorts) + len(udpPorts))
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	if workers > total {
+		workers = total
+	}
+
+	p := prober{timeout: timeout}
+	r := &Result{
+		Method:           "TCP Connect",
+		Protocol:         ProtocolTCP,
+		TimeoutSeconds:   int((timeout + time.Second - 1) / time.Second),
+		TimeoutMS:        timeout.Milliseconds(),
+		Concurrency:      workers,
+		RatePerSecond:    opts.Rate,
+		MaxPerHost:       opts.MaxPerHost,
+		PortsPerHost:     len(tcpPorts) + len(udpPorts),
+		ServiceDetection: opts.ServiceDetection,
+		Hosts:            make([]HostResult, len(targets)),
+	}
+	for i, t := range targets {
+		r.Hosts[i] = HostResult{Address: t.Addr.String(), Family: FamilyOf(t.Addr), Hostname: t.Hostname}
+	}
+
+	startTime := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	switch {
+	case len(tcpPorts) == 0:
+		r.Method = "UDP"
+		r.Protocol = ProtocolUDP
+	case opts.Method == "" || opts.Method == MethodAuto || opts.Method == MethodSYN:
+		syn, err := newSYNScanner(targets)
+		if err != nil {
+			if opts.Method == MethodSYN {
+				r.MethodNote = fmt.Sprintf("SYN scan unavailable (%v); used TCP connect instead", err)
+			}
+			break
+		}
+		defer syn.close()
+		p.syn = syn
+		r.Method = "TCP SYN"
+	case opts.Method != MethodConnect:
+		return nil, fmt.Errorf("unknown scan method %q (use %s, %s or %s)", opts.Method, MethodAuto, MethodSYN, MethodConnect)
+	}
+	if len(udpPorts) > 0 && len(tcpPorts) > 0 {
+		r.Method += " + UDP"
+		r.Protocol = ProtocolMixed
+	}
+	if len(udpPorts) > 0 || p.syn != nil {
+		p.retries = opts.Retries
+		if p.retries == 0 {
+			p.retries = DefaultRetries
+		} else if p.retries < 0 {
+			p.retries = 0
+		}
+		r.Retries = p.retries
+	}
+	if opts.ServiceDetection && len(tcpPorts) > 0 {
+		p.probes = opts.Probes
+		if p.probes == nil {
+			p.probes = fingerprint.DefaultProbes()
+		}
+	}
+
+	p.rate = newRateLimiter(opts.Rate)
+	p.perHost = newHostLimiter(opts.MaxPerHost)
+
+	jobs := make(chan probeJob)
+	results := make(chan probeResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res, err := p.probe(ctx, targets[job.host], job.port, job.udp)
+				if err != nil {
+					// Cancelled: stop without reporting a bogus state
+					return
+				}
+				select {
+				case results <- probeResult{host: job.host, PortResult: res}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Interleave hosts so per-host limits don't serialize the scan
+	go func() {
+		defer close(jobs)
+		for _, job := range portJobs(tcpPorts, udpPorts) {
+			for host := range targets {
+				job.host = host
+				select {
+				case jobs <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	done := 0
+	for res := range results {
+		done++
+		host := &r.Hosts[res.host]
+		switch res.State {
+		case StateOpen:
+			host.OpenPorts = append(host.OpenPorts, res.PortResult)
+			host.Open++
+			r.Open++
+		case StateFiltered:
+			host.Filtered++
+			r.Filtered++
+		case StateUnreachable:
+			host.Unreachable++
+			r.Unreachable++
+		case StateOpenFiltered:
+			host.OpenFiltered++
+			r.OpenFiltered++
+		default:
+			host.Closed++
+			r.Closed++
+		}
+		if opts.OnResult != nil {
+			opts.OnResult(targets[res.host], res.PortResult)
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range r.Hosts {
+		open := r.Hosts[i].OpenPorts
+		sort.Slice(open, func(a, b int) bool {
+			if open[a].Port != open[b].Port {
+				return open[a].Port < open[b].Port
+			}
+			return open[a].Protocol < open[b].Protocol
+		})
+	}
+
+	r.DualStack = dualStackReachability(r.Hosts)
+
+	r.Elapsed = time.Since(startTime)
+	r.DurationMS = r.Elapsed.Milliseconds()
+
+	return r, nil
+}
+
+// mergePorts returns the ports of a and b without duplicates, in order.
+func mergePorts(a, b []int) []int {
+	set := map[int]bool{}
+	for _, p := range append(append([]int(nil), a...), b...) {
+		set[p] = true
+	}
+	return sortedPorts(set)
+}
+
+// portJobs lists one job per port, the TCP ports first.
+func portJobs(tcpPorts, udpPorts []int) []probeJob {
+	jobs := make([]probeJob, 0, len(tcpPorts)+len(udpPorts))
+	for _, port := range tcpPorts {
+		jobs = append(jobs, probeJob{port: port})
+	}
+	for _, port := range udpPorts {
+		jobs = append(jobs, probeJob{port: port, udp: true})
+	}
+	return jobs
+}
+
+// dualStackReachability merges the open ports of hostnames that were
+// scanned over both address families.
+func dualStackReachability(hosts []HostResult) []PortReachability {
+	families := map[string]map[string]bool{}
+	for _, h := range hosts {
+		if h.Hostname == "" {
+			continue
+		}
+		if families[h.Hostname] == nil {
+			families[h.Hostname] = map[string]bool{}
+		}
+		families[h.Hostname][h.Family] = true
+	}
+
+	var result []PortReachability
+	index := map[string]int{}
+	for _, h := range hosts {
+		if !families[h.Hostname][FamilyIPv4] || !families[h.Hostname][FamilyIPv6] {
+			continue
+		}
+		for _, p := range h.OpenPorts {
+			key := h.Hostname + "/" + strconv.Itoa(p.Port) + "/" + p.Protocol
+			i, ok := index[key]
+			if !ok {
+				i = len(result)
+				index[key] = i
+				result = append(result, PortReachability{Hostname: h.Hostname, Port: p.Port, Protocol: p.Protocol})
+			}
+			if h.Family == FamilyIPv4 {
+				result[i].IPv4 = true
+			} else {
+				result[i].IPv6 = true
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Hostname != result[j].Hostname {
+			return result[i].Hostname < result[j].Hostname
+		}
+		if result[i].Port != result[j].Port {
+			return result[i].Port < result[j].Port
+		}
+		return result[i].Protocol < result[j].Protocol
+	})
+	return result
+}
+
+// prober holds the settings shared by every worker of a scan.
+type prober struct {
+	timeout time.Duration
+	// retries is the number of UDP and SYN retransmissions.
+	retries int
+	// syn is set when TCP ports are probed with raw SYN segments.
+	syn *synScanner
+	// probes enables service detection on open TCP ports.
+	probes  []*fingerprint.Probe
+	rate    *rateLimiter
+	perHost *hostLimiter
+}
+
+// probe checks one port and, when service detection is on, fingerprints
+// the service on it while still holding the host's connection slot.
+func (p *prober) probe(ctx context.Context, target Target, port int, udp bool) (PortResult, error) {
+	if err := p.rate.wait(ctx); err != nil {
+		return PortResult{}, err
+	}
+	host := target.Addr.String()
+	if err := p.perHost.acquire(ctx, host); err != nil {
+		return PortResult{}, err
+	}
+	defer p.perHost.release(host)
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	res := PortResult{Port: port, State: StateClosed, Protocol: ProtocolTCP, Family: FamilyOf(target.Addr)}
+
+	if udp {
+		res.Protocol = ProtocolUDP
+		state, err := ProbeUDP(ctx, "udp", address, UDPPayload(port), p.timeout, p.retries)
+		if err != nil {
+			if ctx.Err() != nil {
+				return PortResult{}, ctx.Err()
+			}
+			state, res.Reason = ClassifyDialError(err)
+		}
+		res.State = state
+		if state == StateOpen {
+			res.Service = UDPServiceName(port)
+		}
+		return res, nil
+	}
+
+	if p.syn != nil {
+		state, err := p.syn.probe(ctx, target.Addr, port, p.timeout, p.retries)
+		if err != nil {
+			if ctx.Err() != nil {
+				return PortResult{}, ctx.Err()
+			}
+			state, res.Reason = ClassifyDialError(err)
+		}
+		res.State = state
+		if state != StateOpen {
+			return res, nil
+		}
+	} else if _, err := ProbeTCP(ctx, "tcp", address, p.timeout); err != nil {
+		if ctx.Err() != nil {
+			return PortResult{}, ctx.Err()
+		}
+		res.State, res.Reason = ClassifyDialError(err)
+		return res, nil
+	}
+
+	res.State = StateOpen
+	res.Service = ServiceName(port)
+	if p.probes == nil {
+		return res, nil
+	}
+
+	fp, err := fingerprint.Detect(ctx, "tcp", address, fingerprint.Options{Probes: p.probes, Timeout: p.timeout})
+	if ctx.Err() != nil {
+		return PortResult{}, ctx.Err()
+	}
+	if err == nil && fp != nil {
+		res.Banner = fp.Banner
+	}
+	if err != nil || fp == nil || fp.Service == "" {
+		if _, known := services[port]; known {
+			res.Confidence = PortGuessConfidence
+		}
+		return res, nil
+	}
+	res.Service = fp.Service
+	res.Product = fp.Product
+	res.Version = fp.Version
+	res.Info = fp.Info
+	res.Confidence = fp.Confidence
+	return res, nil
+}
+
+// ProbeTCP reports whether a TCP connection to address can be opened
+// within timeout, returning the remote address it connected to. network
+// is "tcp", "tcp4" or "tcp6". The connection is closed immediately.
+func ProbeTCP(ctx context.Context, network, address string, timeout time.Duration) (net.Addr, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	remote := conn.RemoteAddr()
+	return remote, conn.Close()
+}
+
+// ClassifyDialError maps the error of a failed probe to a port state and
+// a short reason: a refused connection is closed, a timeout or a locally
+// blocked send is filtered, and a host or network unreachable is a
+// routing problem.
+func ClassifyDialError(err error) (state, reason string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return StateClosed, "connection reset"
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return StateUnreachable, "host unreachable"
+	case errors.Is(err, syscall.ENETUNREACH):
+		return StateUnreachable, "network unreachable"
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return StateFiltered, "blocked by local firewall"
+	case errors.Is(err, syscall.ETIMEDOUT), errors.As(err, &netErr) && netErr.Timeout():
+		return StateFiltered, "no response"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return StateUnreachable, "cannot resolve host"
+	}
+	return StateFiltered, err.Error()
+}
+
+// CommonPorts returns the ports scanned by default.
+func CommonPorts() []int {
+	return []int{
+		21, 22, 25, 53, 80, 110, 143, 443, 465, 587,
+		993, 995, 1433, 1521, 3306, 5432, 5000, 8000,
+		8080, 8443, 9000, 27017, 28017, 6379, 11211,
+	}
+}
+
+// PortRange returns the ports from start to end inclusive.
+func PortRange(start, end int) []int {
+	var ports []int
+	for i := start; i <= end; i++ {
+		ports = append(ports, i)
+	}
+	return ports
+}
+
+var services = map[int]string{
+	21:    "FTP",
+	22:    "SSH",
+	25:    "SMTP",
+	53:    "DNS",
+	80:    "HTTP",
+	110:   "POP3",
+	143:   "IMAP",
+	443:   "HTTPS",
+	465:   "SMTPS",
+	587:   "SMTP",
+	993:   "IMAPS",
+	995:   "POP3S",
+	1433:  "MSSQL",
+	1521:  "Oracle",
+	3306:  "MySQL",
+	5432:  "PostgreSQL",
+	5000:  "Flask",
+	8000:  "HTTP",
+	8080:  "HTTP",
+	8443:  "HTTPS",
+	9000:  "PHP-FPM",
+	27017: "MongoDB",
+	6379:  "Redis",
+	11211: "Memcached",
+}
+
+var udpServices = map[int]string{
+	53:   "DNS",
+	67:   "DHCP",
+	69:   "TFTP",
+	123:  "NTP",
+	137:  "NetBIOS-NS",
+	138:  "NetBIOS-DGM",
+	161:  "SNMP",
+	162:  "SNMP-Trap",
+	500:  "IKE",
+	514:  "Syslog",
+	520:  "RIP",
+	1900: "SSDP",
+	4500: "IPsec-NAT-T",
+	5353: "mDNS",
+}
+
+// UDPServiceName returns the well-known UDP service for port, or
+// "Unknown".
+func UDPServiceName(port int) string {
+	if service, ok := udpServices[port]; ok {
+		return service
+	}
+	return "Unknown"
+}
+
+// ServiceName returns the well-known service for port, or "Unknown".
+func ServiceName(port int) string {
+	if service, ok := services[port]; ok {
+		return service
+	}
+	return "Unknown"
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestScanMixedProtocols(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(buf[:n], addr)
+		}
+	}()
+	tcpPort := tcp.Addr().(*net.TCPAddr).Port
+	udpPort := udp.LocalAddr().(*net.UDPAddr).Port
+
+	targets := []Target{{Addr: netip.MustParseAddr("127.0.0.1")}}
+	r, err := Scan(context.Background(), targets, Options{
+		Ports:    []int{tcpPort},
+		UDPPorts: []int{udpPort, tcpPort},
+		Method:   MethodConnect,
+		Timeout:  time.Second,
+		Retries:  -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Protocol != ProtocolMixed || r.Method != "TCP Connect + UDP" || r.PortsPerHost != 3 {
+		t.Errorf("protocol %s, method %s, %d ports per host", r.Protocol, r.Method, r.PortsPerHost)
+	}
+	// Open ports are sorted by number, whichever protocol they are.
+	open := r.Hosts[0].OpenPorts
+	if udpPort < tcpPort && len(open) == 2 {
+		open = []PortResult{open[1], open[0]}
+	}
+	if len(open) != 2 || open[0].Protocol != ProtocolTCP || open[0].Port != tcpPort || open[1].Protocol != ProtocolUDP || open[1].Port != udpPort {
+		t.Errorf("open ports %+v, want %d/tcp and %d/udp", r.Hosts[0].OpenPorts, tcpPort, udpPort)
+	}
+	// Nothing listens on the TCP port over UDP: ICMP port unreachable.
+	if r.Open != 2 || r.Closed != 1 {
+		t.Errorf("%d open and %d closed, want 2 and 1", r.Open, r.Closed)
+	}
+
+	if r.TimeoutSeconds != 1 || r.TimeoutMS != 1000 {
+		t.Errorf("timeout %ds, %dms, want 1s", r.TimeoutSeconds, r.TimeoutMS)
+	}
+
+	r, err = Scan(context.Background(), targets, Options{Ports: []int{udpPort}, UDP: true, UDPPorts: []int{udpPort}, Timeout: 500 * time.Millisecond, Retries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Protocol != ProtocolUDP || r.PortsPerHost != 1 || r.Open != 1 {
+		t.Errorf("UDP scan: protocol %s, %d ports per host, %d open", r.Protocol, r.PortsPerHost, r.Open)
+	}
+	// A sub-second timeout isn't reported as none.
+	if r.TimeoutSeconds != 1 || r.TimeoutMS != 500 {
+		t.Errorf("timeout %ds, %dms, want 500ms rounded up to 1s", r.TimeoutSeconds, r.TimeoutMS)
+	}
+
+	if _, err := Scan(context.Background(), targets, Options{Ports: []int{}}); err == nil {
+		t.Error("scan of no ports succeeded")
+	}
+}
@@ -0,0 +1,260 @@
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Scan methods accepted by Options.Method.
+const (
+	// MethodAuto uses a SYN scan when raw sockets are available and a
+	// connect scan otherwise.
+	MethodAuto = "auto"
+	// MethodSYN requests a half-open SYN scan, falling back to a connect
+	// scan (with Result.MethodNote set) when raw sockets are unavailable.
+	MethodSYN = "syn"
+	// MethodConnect always completes the TCP handshake.
+	MethodConnect = "connect"
+)
+
+// TCP flags used by the SYN scanner.
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// synScanner sends hand-crafted SYN segments over raw IP sockets and
+// matches the replies by the acknowledged sequence number. The kernel,
+// which has no socket for the source port, answers a SYN-ACK with a RST,
+// so the handshake is never completed.
+type synScanner struct {
+	srcPort uint16
+	conns   map[string]net.PacketConn
+
+	mu      sync.Mutex
+	pending map[synKey]*synProbe
+	sources map[netip.Addr]netip.Addr
+}
+
+type synKey struct {
+	addr netip.Addr
+	port uint16
+}
+
+type synProbe struct {
+	seq   uint32
+	reply chan string
+}
+
+// newSYNScanner opens a raw TCP socket for each address family in
+// targets. It fails without root or CAP_NET_RAW, or off Linux.
+func newSYNScanner(targets []Target) (*synScanner, error) {
+	s := &synScanner{
+		// Outside the usual Linux ephemeral range, so no local socket
+		// is ever bound to it
+		srcPort: uint16(10000 + rand.Intn(20000)),
+		conns:   map[string]net.PacketConn{},
+		pending: map[synKey]*synProbe{},
+		sources: map[netip.Addr]netip.Addr{},
+	}
+	for _, t := range targets {
+		family := FamilyOf(t.Addr)
+		if s.conns[family] != nil {
+			continue
+		}
+		network := "ip4:tcp"
+		if family == FamilyIPv6 {
+			network = "ip6:tcp"
+		}
+		conn, err := openRawTCP(network)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.conns[family] = conn
+		go s.receive(conn)
+	}
+	return s, nil
+}
+
+func (s *synScanner) close() {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// probe sends a SYN to dst:port and waits up to timeout for the reply,
+// sending it again up to retries times when none comes. Retransmissions
+// reuse the sequence number, as a TCP stack's do, so a late reply to an
+// earlier one still counts.
+func (s *synScanner) probe(ctx context.Context, dst netip.Addr, port int, timeout time.Duration, retries int) (string, error) {
+	src, err := s.source(dst)
+	if err != nil {
+		return "", err
+	}
+
+	key := synKey{addr: dst, port: uint16(port)}
+	p := &synProbe{seq: rand.Uint32(), reply: make(chan string, 1)}
+	s.mu.Lock()
+	s.pending[key] = p
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	segment := synSegment(src, dst, s.srcPort, uint16(port), p.seq)
+	conn := s.conns[FamilyOf(dst)]
+	to := &net.IPAddr{IP: dst.AsSlice(), Zone: dst.Zone()}
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := conn.WriteTo(segment, to); err != nil {
+			return "", err
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case state := <-p.reply:
+			timer.Stop()
+			return state, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return StateFiltered, nil
+}
+
+// receive dispatches incoming TCP segments to the probes waiting for
+// them until the socket is closed. Raw reads on "ip4:tcp" have the IP
+// header stripped, and IPv6 raw sockets never include it.
+func (s *synScanner) receive(conn net.PacketConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if n < 20 {
+			continue
+		}
+		ipAddr, ok := from.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ipAddr.IP)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap().WithZone(ipAddr.Zone)
+
+		segment := buf[:n]
+		if binary.BigEndian.Uint16(segment[2:4]) != s.srcPort {
+			continue
+		}
+		key := synKey{addr: addr, port: binary.BigEndian.Uint16(segment[0:2])}
+		ack := binary.BigEndian.Uint32(segment[8:12])
+		flags := segment[13]
+
+		s.mu.Lock()
+		p := s.pending[key]
+		s.mu.Unlock()
+		if p == nil || ack != p.seq+1 {
+			continue
+		}
+
+		var state string
+		switch {
+		case flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK:
+			state = StateOpen
+		case flags&tcpFlagRST != 0:
+			state = StateClosed
+		default:
+			continue
+		}
+		select {
+		case p.reply <- state:
+		default:
+		}
+	}
+}
+
+// source returns the local address the kernel routes dst through, which
+// the TCP checksum covers. Connecting a UDP socket sends no packets.
+func (s *synScanner) source(dst netip.Addr) (netip.Addr, error) {
+	s.mu.Lock()
+	src, ok := s.sources[dst]
+	s.mu.Unlock()
+	if ok {
+		return src, nil
+	}
+
+	conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(dst, 9)))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("no route to %s: %w", dst, err)
+	}
+	src = conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap()
+	conn.Close()
+
+	s.mu.Lock()
+	s.sources[dst] = src
+	s.mu.Unlock()
+	return src, nil
+}
+
+// synSegment builds a TCP SYN with an MSS option, checksummed for the
+// src/dst pseudo-header. The kernel adds the IP header.
+func synSegment(src, dst netip.Addr, srcPort, dstPort uint16, seq uint32) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint16(b[0:2], srcPort)
+	binary.BigEndian.PutUint16(b[2:4], dstPort)
+	binary.BigEndian.PutUint32(b[4:8], seq)
+	b[12] = 6 << 4 // data offset: 6 words
+	b[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(b[14:16], 1024) // window
+	// MSS 1460
+	b[20], b[21], b[22], b[23] = 2, 4, 0x05, 0xb4
+
+	binary.BigEndian.PutUint16(b[16:18], tcpChecksum(src, dst, b))
+	return b
+}
+
+func tcpChecksum(src, dst netip.Addr, segment []byte) uint16 {
+	var pseudo []byte
+	if src.Is4() {
+		pseudo = append(pseudo, src.AsSlice()...)
+		pseudo = append(pseudo, dst.AsSlice()...)
+		pseudo = append(pseudo, 0, 6)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(segment)))
+	} else {
+		pseudo = append(pseudo, src.AsSlice()...)
+		pseudo = append(pseudo, dst.AsSlice()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+		pseudo = append(pseudo, 0, 0, 0, 6)
+	}
+
+	var sum uint32
+	for _, data := range [][]byte{pseudo, segment} {
+		for i := 0; i+1 < len(data); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+		}
+		if len(data)%2 == 1 {
+			sum += uint32(data[len(data)-1]) << 8
+		}
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSYNSegment(t *testing.T) {
+	// Checksums worked out independently for a SYN from port 40000 to
+	// 443 with sequence number 0x01020304.
+	tests := []struct {
+		src, dst string
+		want     string
+	}{
+		{src: "192.0.2.1", dst: "198.51.100.2", want: "9c4001bb01020304000000006002040005ee0000020405b4"},
+		{src: "2001:db8::1", dst: "2001:db8::2", want: "9c4001bb01020304000000006002040096b00000020405b4"},
+	}
+	for _, tt := range tests {
+		src, dst := netip.MustParseAddr(tt.src), netip.MustParseAddr(tt.dst)
+		b := synSegment(src, dst, 40000, 443, 0x01020304)
+		if got := hex.EncodeToString(b); got != tt.want {
+			t.Errorf("%s → %s: segment %s, want %s", src, dst, got, tt.want)
+		}
+		// Summed with its checksum in place the segment comes to zero.
+		if sum := tcpChecksum(src, dst, b); sum != 0 {
+			t.Errorf("%s → %s: checksum over the segment is %#04x, want 0", src, dst, sum)
+		}
+	}
+
+	// An odd byte at the end is padded with zero.
+	odd := []byte{1, 2, 3, 4, 5}
+	if sum := tcpChecksum(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), odd); sum != 0xe2eb {
+		t.Errorf("odd-length checksum %#04x, want 0xe2eb", sum)
+	}
+}
+
+// fakeRaw stands in for a raw socket: it records the segments written
+// and answers the replyTo-th, if any, with a segment carrying flags.
+type fakeRaw struct {
+	mu      sync.Mutex
+	written [][]byte
+	replyTo int
+	flags   byte
+	replies chan []byte
+	from    net.Addr
+	closed  chan struct{}
+}
+
+func newFakeRaw(replyTo int, flags byte) *fakeRaw {
+	return &fakeRaw{replyTo: replyTo, flags: flags, replies: make(chan []byte, 4), closed: make(chan struct{})}
+}
+
+func (f *fakeRaw) WriteTo(b []byte, addr net.Addr) (int, error) {
+	f.mu.Lock()
+	f.written = append(f.written, append([]byte(nil), b...))
+	n := len(f.written)
+	f.from = addr
+	f.mu.Unlock()
+	if n == f.replyTo {
+		reply := make([]byte, 20)
+		copy(reply[0:2], b[2:4]) // from the probed port
+		copy(reply[2:4], b[0:2]) // to ours
+		binary.BigEndian.PutUint32(reply[8:12], binary.BigEndian.Uint32(b[4:8])+1)
+		reply[12] = 5 << 4
+		reply[13] = f.flags
+		f.replies <- reply
+	}
+	return len(b), nil
+}
+
+func (f *fakeRaw) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case reply := <-f.replies:
+		f.mu.Lock()
+		from := f.from
+		f.mu.Unlock()
+		return copy(b, reply), from, nil
+	case <-f.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (f *fakeRaw) writes() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.written)
+}
+
+func (f *fakeRaw) Close() error                     { close(f.closed); return nil }
+func (f *fakeRaw) LocalAddr() net.Addr              { return &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)} }
+func (f *fakeRaw) SetDeadline(time.Time) error      { return nil }
+func (f *fakeRaw) SetReadDeadline(time.Time) error  { return nil }
+func (f *fakeRaw) SetWriteDeadline(time.Time) error { return nil }
+
+func TestSYNProbeRetransmits(t *testing.T) {
+	dst := netip.MustParseAddr("198.51.100.2")
+	tests := []struct {
+		name    string
+		replyTo int
+		flags   byte
+		retries int
+		state   string
+		writes  int
+	}{
+		{name: "open at once", replyTo: 1, flags: tcpFlagSYN | tcpFlagACK, retries: 2, state: StateOpen, writes: 1},
+		{name: "open on the retransmission", replyTo: 2, flags: tcpFlagSYN | tcpFlagACK, retries: 2, state: StateOpen, writes: 2},
+		{name: "closed on the last try", replyTo: 3, flags: tcpFlagRST | tcpFlagACK, retries: 2, state: StateClosed, writes: 3},
+		{name: "silent", retries: 2, state: StateFiltered, writes: 3},
+		{name: "no retries", replyTo: 2, flags: tcpFlagSYN | tcpFlagACK, state: StateFiltered, writes: 1},
+	}
+	for _, tt := range tests {
+		raw := newFakeRaw(tt.replyTo, tt.flags)
+		s := &synScanner{
+			srcPort: 40000,
+			conns:   map[string]net.PacketConn{FamilyIPv4: raw},
+			pending: map[synKey]*synProbe{},
+			sources: map[netip.Addr]netip.Addr{dst: netip.MustParseAddr("192.0.2.1")},
+		}
+		go s.receive(raw)
+		state, err := s.probe(context.Background(), dst, 443, 30*time.Millisecond, tt.retries)
+		s.close()
+		if err != nil || state != tt.state || raw.writes() != tt.writes {
+			t.Errorf("%s: %s, %v after %d SYNs, want %s after %d", tt.name, state, err, raw.writes(), tt.state, tt.writes)
+		}
+		raw.mu.Lock()
+		for i, b := range raw.written {
+			if binary.BigEndian.Uint32(b[4:8]) != binary.BigEndian.Uint32(raw.written[0][4:8]) {
+				t.Errorf("%s: SYN %d has another sequence number", tt.name, i+1)
+			}
+		}
+		raw.mu.Unlock()
+	}
+}
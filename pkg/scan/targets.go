@@ -0,0 +1,402 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// MaxTargetsPerSpec bounds how many addresses a single CIDR block or
+// range may expand to, so a typo such as /8 fails fast instead of
+// queueing millions of hosts.
+const MaxTargetsPerSpec = 1 << 16
+
+// Address families.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// FamilyOf returns FamilyIPv4 or FamilyIPv6 for addr.
+func FamilyOf(addr netip.Addr) string {
+	if addr.Unmap().Is4() {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// Target is a single address to scan.
+type Target struct {
+	Addr netip.Addr
+	// Hostname is the name Addr was resolved from, if any.
+	Hostname string
+}
+
+// ExpandOptions configures ExpandTargets.
+type ExpandOptions struct {
+	// Exclude lists addresses, CIDR blocks, ranges or hostnames to skip.
+	// Unlike targets, blocks and ranges may be of any size.
+	Exclude []string
+	// Family restricts targets to FamilyIPv4 or FamilyIPv6; empty keeps
+	// both, so hostnames are scanned dual-stack.
+	Family string
+	// Resolver resolves hostnames; nil means net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// ExpandTargets turns target specifications into the list of addresses
+// to scan. A specification may be an IP address, a CIDR block
+// (10.0.0.0/24), an octet range (10.0.0.1-50, 10.0.1-3.*), a full range
+// (10.0.0.1-10.0.0.50) or a hostname, which contributes every A and AAAA
+// address it resolves to. Duplicates are dropped, keeping the first
+// occurrence.
+func ExpandTargets(ctx context.Context, specs []string, opts ExpandOptions) ([]Target, error) {
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if opts.Family != "" && opts.Family != FamilyIPv4 && opts.Family != FamilyIPv6 {
+		return nil, fmt.Errorf("unknown address family %q", opts.Family)
+	}
+
+	var excluded exclusions
+	for _, spec := range opts.Exclude {
+		if err := excluded.add(ctx, resolver, spec); err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+	}
+
+	var result []Target
+	seen := map[netip.Addr]bool{}
+	for _, spec := range specs {
+		targets, err := expandSpec(ctx, resolver, spec, opts.Family)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range targets {
+			if seen[t.Addr] || excluded.contains(t.Addr) {
+				continue
+			}
+			if opts.Family != "" && FamilyOf(t.Addr) != opts.Family {
+				continue
+			}
+			seen[t.Addr] = true
+			result = append(result, t)
+		}
+	}
+	if len(result) == 0 && opts.Family != "" {
+		return nil, fmt.Errorf("no %s addresses among the targets", opts.Family)
+	}
+	return result, nil
+}
+
+// exclusions are the addresses ExpandTargets skips. Blocks and ranges
+// are kept as prefixes and octet bounds rather than expanded, so
+// excluding 10.0.0.0/8 costs no more than excluding one address.
+type exclusions struct {
+	prefixes []netip.Prefix
+	octets   [][4][2]int
+}
+
+// add parses an exclude specification, which takes the same forms as a
+// target. Only hostnames are resolved, to every address they have.
+func (e *exclusions) add(ctx context.Context, resolver *net.Resolver, spec string) error {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return fmt.Errorf("empty target")
+	}
+
+	if addr, err := netip.ParseAddr(spec); err == nil {
+		addr = addr.Unmap()
+		e.prefixes = append(e.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		return nil
+	}
+	if strings.Contains(spec, "/") {
+		prefix, err := netip.ParsePrefix(spec)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR block %q: %w", spec, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		e.prefixes = append(e.prefixes, prefix.Masked())
+		return nil
+	}
+	if lo, hi, ok := strings.Cut(spec, "-"); ok {
+		if start, err := netip.ParseAddr(lo); err == nil {
+			if end, err := netip.ParseAddr(hi); err == nil {
+				start, end = start.Unmap(), end.Unmap()
+				if start.Is4() != end.Is4() {
+					return fmt.Errorf("invalid range %q: mixed address families", spec)
+				}
+				if end.Less(start) {
+					return fmt.Errorf("invalid range %q: start is after end", spec)
+				}
+				e.prefixes = append(e.prefixes, rangePrefixes(start, end)...)
+				return nil
+			}
+		}
+	}
+	if looksLikeOctetRange(spec) {
+		var bounds [4][2]int
+		for i, part := range strings.Split(spec, ".") {
+			lo, hi, err := parseOctet(part)
+			if err != nil {
+				return fmt.Errorf("invalid range %q: %w", spec, err)
+			}
+			bounds[i] = [2]int{lo, hi}
+		}
+		e.octets = append(e.octets, bounds)
+		return nil
+	}
+
+	targets, err := expandSpec(ctx, resolver, spec, "")
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		e.prefixes = append(e.prefixes, netip.PrefixFrom(t.Addr, t.Addr.BitLen()))
+	}
+	return nil
+}
+
+func (e *exclusions) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range e.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	if !addr.Is4() {
+		return false
+	}
+	b := addr.As4()
+	for _, bounds := range e.octets {
+		in := true
+		for i, o := range b {
+			if int(o) < bounds[i][0] || int(o) > bounds[i][1] {
+				in = false
+				break
+			}
+		}
+		if in {
+			return true
+		}
+	}
+	return false
+}
+
+// rangePrefixes returns the fewest prefixes that exactly cover start
+// through end, which must be of one family with start not after end.
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		// The largest block starting at start that ends by end.
+		bits := start.BitLen()
+		for bits > 0 {
+			p := netip.PrefixFrom(start, bits-1)
+			if p.Masked().Addr() != start || end.Less(lastAddr(p)) {
+				break
+			}
+			bits--
+		}
+		p := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, p)
+		last := lastAddr(p)
+		if last == end || !last.Next().IsValid() {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr returns the highest address in p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+	for i := offset + p.Bits(); i < 128; i++ {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+	if p.Addr().Is4() {
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+	return netip.AddrFrom16(b)
+}
+
+// ReadTargets reads whitespace-separated target specifications from r,
+// ignoring blank lines and anything after a '#'.
+func ReadTargets(r io.Reader) ([]string, error) {
+	var specs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		specs = append(specs, strings.Fields(line)...)
+	}
+	return specs, scanner.Err()
+}
+
+// expandSpec expands one specification. family only narrows hostname
+// resolution; literal addresses are filtered by the caller.
+func expandSpec(ctx context.Context, resolver *net.Resolver, spec, family string) ([]Target, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty target")
+	}
+
+	if addr, err := netip.ParseAddr(spec); err == nil {
+		return []Target{{Addr: addr.Unmap()}}, nil
+	}
+	if strings.Contains(spec, "/") {
+		return expandCIDR(spec)
+	}
+	if lo, hi, ok := strings.Cut(spec, "-"); ok {
+		if start, err := netip.ParseAddr(lo); err == nil {
+			if end, err := netip.ParseAddr(hi); err == nil {
+				return expandAddrRange(spec, start.Unmap(), end.Unmap())
+			}
+		}
+	}
+	if looksLikeOctetRange(spec) {
+		return expandOctetRange(spec)
+	}
+
+	network := "ip"
+	switch family {
+	case FamilyIPv4:
+		network = "ip4"
+	case FamilyIPv6:
+		network = "ip6"
+	}
+	addrs, err := resolver.LookupNetIP(ctx, network, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", spec, err)
+	}
+	targets := make([]Target, 0, len(addrs))
+	for _, addr := range addrs {
+		targets = append(targets, Target{Addr: addr.Unmap(), Hostname: spec})
+	}
+	return targets, nil
+}
+
+func expandCIDR(spec string) ([]Target, error) {
+	prefix, err := netip.ParsePrefix(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR block %q: %w", spec, err)
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("CIDR block %s has more than %d addresses", spec, MaxTargetsPerSpec)
+	}
+
+	var targets []Target
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		targets = append(targets, Target{Addr: addr})
+	}
+	return targets, nil
+}
+
+func expandAddrRange(spec string, start, end netip.Addr) ([]Target, error) {
+	if start.Is4() != end.Is4() {
+		return nil, fmt.Errorf("invalid range %q: mixed address families", spec)
+	}
+	if end.Less(start) {
+		return nil, fmt.Errorf("invalid range %q: start is after end", spec)
+	}
+
+	var targets []Target
+	for addr := start; ; addr = addr.Next() {
+		if len(targets) == MaxTargetsPerSpec {
+			return nil, fmt.Errorf("range %s has more than %d addresses", spec, MaxTargetsPerSpec)
+		}
+		targets = append(targets, Target{Addr: addr})
+		if addr == end {
+			break
+		}
+	}
+	return targets, nil
+}
+
+// looksLikeOctetRange reports whether spec is a dotted quad in which
+// some octets are ranges, e.g. 10.0.0.1-50 or 192.168.*.1.
+func looksLikeOctetRange(spec string) bool {
+	parts := strings.Split(spec, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789-*") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func expandOctetRange(spec string) ([]Target, error) {
+	var bounds [4][2]int
+	total := 1
+	for i, part := range strings.Split(spec, ".") {
+		lo, hi, err := parseOctet(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", spec, err)
+		}
+		bounds[i] = [2]int{lo, hi}
+		total *= hi - lo + 1
+	}
+	if total > MaxTargetsPerSpec {
+		return nil, fmt.Errorf("range %s has more than %d addresses", spec, MaxTargetsPerSpec)
+	}
+
+	targets := make([]Target, 0, total)
+	for a := bounds[0][0]; a <= bounds[0][1]; a++ {
+		for b := bounds[1][0]; b <= bounds[1][1]; b++ {
+			for c := bounds[2][0]; c <= bounds[2][1]; c++ {
+				for d := bounds[3][0]; d <= bounds[3][1]; d++ {
+					addr := netip.AddrFrom4([4]byte{byte(a), byte(b), byte(c), byte(d)})
+					targets = append(targets, Target{Addr: addr})
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+func parseOctet(part string) (lo, hi int, err error) {
+	if part == "*" {
+		return 0, 255, nil
+	}
+	loStr, hiStr, isRange := strings.Cut(part, "-")
+	if lo, err = parseOctetValue(loStr); err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	if hi, err = parseOctetValue(hiStr); err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("octet range %s is reversed", part)
+	}
+	return lo, hi, nil
+}
+
+func parseOctetValue(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 || v > 255 {
+		return 0, fmt.Errorf("%q is not an octet", s)
+	}
+	return v, nil
+}
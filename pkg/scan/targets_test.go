@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func addrs(targets []Target) []string {
+	out := []string{}
+	for _, t := range targets {
+		out = append(out, t.Addr.String())
+	}
+	return out
+}
+
+func TestExpandTargets(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		exclude []string
+		want    []string
+	}{
+		{
+			specs: []string{"10.0.0.1", "10.0.0.0/30", "10.0.0.1-2"},
+			want:  []string{"10.0.0.1", "10.0.0.0", "10.0.0.2", "10.0.0.3"},
+		},
+		{
+			specs: []string{"192.168.1-2.7", "::ffff:10.0.0.9", "2001:db8::1-2001:db8::2"},
+			want:  []string{"192.168.1.7", "192.168.2.7", "10.0.0.9", "2001:db8::1", "2001:db8::2"},
+		},
+		{
+			specs:   []string{"10.1.2.0/30", "192.0.2.1"},
+			exclude: []string{"10.0.0.0/8"},
+			want:    []string{"192.0.2.1"},
+		},
+		{
+			specs:   []string{"192.0.2.0/29"},
+			exclude: []string{"192.0.2.2-192.0.2.5"},
+			want:    []string{"192.0.2.0", "192.0.2.1", "192.0.2.6", "192.0.2.7"},
+		},
+		{
+			specs:   []string{"192.0.2.0/29"},
+			exclude: []string{"192.0.2.0-3", "192.0.*.7"},
+			want:    []string{"192.0.2.4", "192.0.2.5", "192.0.2.6"},
+		},
+		{
+			specs:   []string{"192.0.2.1", "2001:db8::1", "2001:db8::2"},
+			exclude: []string{"::/0", "2001:db8::2"},
+			want:    []string{"192.0.2.1"},
+		},
+		{
+			specs:   []string{"192.0.2.1", "192.0.2.2"},
+			exclude: []string{"0.0.0.0-255.255.255.255"},
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		targets, err := ExpandTargets(context.Background(), tt.specs, ExpandOptions{Exclude: tt.exclude})
+		if err != nil {
+			t.Errorf("ExpandTargets(%q, exclude %q): %v", tt.specs, tt.exclude, err)
+			continue
+		}
+		if got := addrs(targets); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandTargets(%q, exclude %q) = %q, want %q", tt.specs, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTargetsErrors(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		exclude []string
+		want    string
+	}{
+		{specs: []string{"10.0.0.0/8"}, want: "more than"},
+		{specs: []string{"10.0.0.0-10.2.0.0"}, want: "more than"},
+		{specs: []string{"10.0.0.5-10.0.0.1"}, want: "start is after end"},
+		{specs: []string{"10.0.0.300"}, want: "not an octet"},
+		{specs: []string{"10.0.0.1"}, exclude: []string{"10.0.0.0/33"}, want: "invalid CIDR block"},
+		{specs: []string{"10.0.0.1"}, exclude: []string{"10.0.0.1-::1"}, want: "mixed address families"},
+		{specs: []string{"10.0.0.1"}, exclude: []string{""}, want: "empty target"},
+	}
+	for _, tt := range tests {
+		_, err := ExpandTargets(context.Background(), tt.specs, ExpandOptions{Exclude: tt.exclude})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExpandTargets(%q, exclude %q) error = %v, want one containing %q", tt.specs, tt.exclude, err, tt.want)
+		}
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+		{"2001:db8::", "2001:db8::1:0", []string{"2001:db8::/112", "2001:db8::1:0/128"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range rangePrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end)) {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rangePrefixes(%s, %s) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
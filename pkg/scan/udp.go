@@ -0,0 +1,136 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultRetries is the number of retransmissions used when
+// Options.Retries is zero. Neither UDP nor an unanswered SYN gives an
+// acknowledgement, so a lost request and a silently dropped one look
+// the same.
+const DefaultRetries = 1
+
+// DefaultUDPRetries is the former name of DefaultRetries.
+//
+// Deprecated: use DefaultRetries.
+const DefaultUDPRetries = DefaultRetries
+
+// CommonUDPPorts returns the UDP ports scanned by default.
+func CommonUDPPorts() []int {
+	return []int{53, 67, 69, 123, 137, 161, 500, 514, 520, 1900, 4500, 5353}
+}
+
+// udpPayloads are requests that make the service on a port answer, so
+// an open port can be told apart from one whose datagrams are dropped.
+// Ports without an entry get an empty datagram.
+var udpPayloads = map[int][]byte{
+	// DNS: standard query for the root NS set
+	53: {
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x02, 0x00, 0x01,
+	},
+	// NTP: version 4 client request
+	123: append([]byte{0xe3}, make([]byte, 47)...),
+	// NetBIOS: node status request for the wildcard name "*"
+	137: append(append([]byte{
+		0x80, 0xf0, 0x00, 0x10, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 'C', 'K',
+	}, repeatByte('A', 30)...), 0x00, 0x00, 0x21, 0x00, 0x01),
+	// SNMP: v1 get-request for sysDescr.0 with community "public"
+	161: {
+		0x30, 0x29, 0x02, 0x01, 0x00, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x1c, 0x02, 0x04, 0x41, 0x46, 0x53, 0x41, 0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00, 0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08,
+		0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+	},
+	// IKE: ISAKMP main mode with one 3DES/SHA1/PSK/MODP-1024 proposal
+	500: ikeMainMode,
+	// SSDP: discovery request for every device type
+	1900: []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: ssdp:all\r\n\r\n"),
+}
+
+var ikeMainMode = []byte{
+	// Header: initiator cookie, responder cookie, next payload SA,
+	// version 1.0, main mode, flags, message ID, length 84
+	0x41, 0x46, 0x53, 0x41, 0x73, 0x63, 0x61, 0x6e,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x10, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x54,
+	// SA payload: length 56, DOI IPsec, situation identity only
+	0x00, 0x00, 0x00, 0x38, 0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	// Proposal 1: length 44, ISAKMP, no SPI, one transform
+	0x00, 0x00, 0x00, 0x2c, 0x01, 0x01, 0x00, 0x01,
+	// Transform 1: length 36, KEY_IKE
+	0x00, 0x00, 0x00, 0x24, 0x01, 0x01, 0x00, 0x00,
+	0x80, 0x01, 0x00, 0x05, // encryption 3DES-CBC
+	0x80, 0x02, 0x00, 0x02, // hash SHA1
+	0x80, 0x03, 0x00, 0x01, // authentication pre-shared key
+	0x80, 0x04, 0x00, 0x02, // group MODP-1024
+	0x80, 0x0b, 0x00, 0x01, // life type seconds
+	0x00, 0x0c, 0x00, 0x04, 0x00, 0x00, 0x70, 0x80, // life 28800
+}
+
+func repeatByte(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
+
+// UDPPayload returns the request sent to port during a UDP scan.
+func UDPPayload(port int) []byte {
+	return udpPayloads[port]
+}
+
+// ProbeUDP sends payload to address and classifies the port from the
+// reply: any datagram means open, an ICMP port unreachable means closed,
+// a host or network unreachable means unreachable, a locally blocked
+// send means filtered and silence after every retransmission means
+// open|filtered. network is "udp", "udp4" or "udp6". ICMP errors are
+// reported by the kernel on connected sockets, so no privileges are
+// needed.
+func ProbeUDP(ctx context.Context, network, address string, payload []byte, timeout time.Duration, retries int) (string, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, 1500)
+	for attempt := 0; attempt <= retries; attempt++ {
+		_, err := conn.Write(payload)
+		if err == nil {
+			conn.SetReadDeadline(time.Now().Add(timeout))
+			_, err = conn.Read(buf)
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		if err == nil {
+			return StateOpen, nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			// Lost or dropped: retransmit
+			continue
+		}
+		// ICMP errors surface on the next read or write
+		state, _ := ClassifyDialError(err)
+		return state, nil
+	}
+	return StateOpenFiltered, nil
+}
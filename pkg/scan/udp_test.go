@@ -0,0 +1,170 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	dnswire "github.com/miekg/dns"
+)
+
+// udpListener answers the datagrams reply returns a response for and
+// reports every datagram it receives on the returned channel.
+func udpListener(t *testing.T, reply func(n int, req []byte) []byte) (string, <-chan []byte) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	received := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 1500)
+		for n := 0; ; n++ {
+			size, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte(nil), buf[:size]...)
+			received <- req
+			if resp := reply(n, req); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String(), received
+}
+
+func TestProbeUDP(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte("ping")
+
+	// Anything sent back means open.
+	addr, _ := udpListener(t, func(int, []byte) []byte { return []byte("pong") })
+	if state, err := ProbeUDP(ctx, "udp", addr, payload, time.Second, 0); err != nil || state != StateOpen {
+		t.Errorf("answering port: %s, %v, want %s", state, err, StateOpen)
+	}
+
+	// The first request is lost; the retransmission is answered.
+	addr, received := udpListener(t, func(n int, _ []byte) []byte {
+		if n == 0 {
+			return nil
+		}
+		return []byte("pong")
+	})
+	if state, err := ProbeUDP(ctx, "udp", addr, payload, 100*time.Millisecond, 1); err != nil || state != StateOpen {
+		t.Errorf("lossy port: %s, %v, want %s", state, err, StateOpen)
+	}
+	if n := len(received); n != 2 {
+		t.Errorf("lossy port got %d datagrams, want 2", n)
+	}
+
+	// Silence after every retransmission is open|filtered.
+	addr, received = udpListener(t, func(int, []byte) []byte { return nil })
+	start := time.Now()
+	if state, err := ProbeUDP(ctx, "udp", addr, payload, 50*time.Millisecond, 2); err != nil || state != StateOpenFiltered {
+		t.Errorf("silent port: %s, %v, want %s", state, err, StateOpenFiltered)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("silent port given up after %v, want three waits of 50ms", elapsed)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case req := <-received:
+			if !bytes.Equal(req, payload) {
+				t.Errorf("datagram %d = %q, want %q", i, req, payload)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("silent port got %d datagrams, want 3", i)
+		}
+	}
+
+	// Nothing listening: the ICMP port unreachable makes it closed.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := pc.LocalAddr().String()
+	pc.Close()
+	if state, err := ProbeUDP(ctx, "udp", closed, payload, time.Second, 2); err != nil || state != StateClosed {
+		t.Errorf("closed port: %s, %v, want %s", state, err, StateClosed)
+	}
+
+	// Cancelling stops the wait.
+	addr, _ = udpListener(t, func(int, []byte) []byte { return nil })
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := ProbeUDP(cctx, "udp", addr, payload, 5*time.Second, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled probe: %v, want the deadline", err)
+	}
+}
+
+// TestUDPPayloads checks that each service payload is a well-formed
+// request, and that a listener answering only that request finds the
+// port open.
+func TestUDPPayloads(t *testing.T) {
+	tests := []struct {
+		port  int
+		valid func(b []byte) bool
+	}{
+		{port: 53, valid: func(b []byte) bool {
+			m := new(dnswire.Msg)
+			return m.Unpack(b) == nil && !m.Response && len(m.Question) == 1 &&
+				m.Question[0].Name == "." && m.Question[0].Qtype == dnswire.TypeNS
+		}},
+		{port: 123, valid: func(b []byte) bool {
+			// Version 4 in client mode.
+			return len(b) == 48 && b[0]>>3&7 == 4 && b[0]&7 == 3
+		}},
+		{port: 137, valid: func(b []byte) bool {
+			m := new(dnswire.Msg)
+			// The first-level encoding of "*" padded with NULs, asking
+			// for NBSTAT.
+			name := "CK" + string(bytes.Repeat([]byte("A"), 30)) + "."
+			return len(b) == 50 && m.Unpack(b) == nil && len(m.Question) == 1 &&
+				m.Question[0].Name == name && m.Question[0].Qtype == 0x21
+		}},
+		{port: 161, valid: func(b []byte) bool {
+			// A BER sequence spanning the datagram, with the community
+			// and a GetRequest PDU spanning the rest.
+			return len(b) > 15 && b[0] == 0x30 && int(b[1]) == len(b)-2 &&
+				string(b[7:13]) == "public" && b[13] == 0xa0 && int(b[14]) == len(b)-15
+		}},
+		{port: 500, valid: func(b []byte) bool {
+			// The header length and the SA, proposal and transform
+			// payload lengths add up.
+			return len(b) == int(binary.BigEndian.Uint32(b[24:28])) &&
+				int(binary.BigEndian.Uint16(b[30:32])) == len(b)-28 &&
+				int(binary.BigEndian.Uint16(b[42:44])) == len(b)-40 &&
+				int(binary.BigEndian.Uint16(b[50:52])) == len(b)-48
+		}},
+		{port: 1900, valid: func(b []byte) bool {
+			return bytes.HasPrefix(b, []byte("M-SEARCH * HTTP/1.1\r\n")) && bytes.HasSuffix(b, []byte("\r\n\r\n"))
+		}},
+	}
+	for _, tt := range tests {
+		payload := UDPPayload(tt.port)
+		if len(payload) == 0 || !tt.valid(payload) {
+			t.Errorf("port %d: malformed payload % x", tt.port, payload)
+			continue
+		}
+		addr, _ := udpListener(t, func(_ int, req []byte) []byte {
+			if bytes.Equal(req, payload) {
+				return []byte("reply")
+			}
+			return nil
+		})
+		if state, err := ProbeUDP(context.Background(), "udp", addr, payload, time.Second, 0); err != nil || state != StateOpen {
+			t.Errorf("port %d: %s, %v, want %s", tt.port, state, err, StateOpen)
+		}
+	}
+
+	// Other ports get an empty datagram.
+	if p := UDPPayload(9); len(p) != 0 {
+		t.Errorf("port 9 payload % x, want none", p)
+	}
+}
@@ -0,0 +1,123 @@
+// Package waf fingerprints Web Application Firewalls from response
+// headers, cookies and blocking behaviour.
+package waf
+
+import (
+	"context"
+	"fmt"
+)
+
+// Result is the outcome of a WAF analysis.
+type Result struct {
+	Target           string      `json:"target"`
+	Signatures       []Signature `json:"signatures"`
+	HeaderTargets    []string    `json:"header_targets"`
+	DetectionMethods []string    `json:"detection_methods"`
+	Payloads         []Payload   `json:"payloads,omitempty"`
+}
+
+// Signature lists the indicators that identify one WAF product.
+type Signature struct {
+	Name       string   `json:"name"`
+	Indicators []string `json:"indicators"`
+}
+
+// Payload is a test request used to provoke a WAF block.
+type Payload struct {
+	Type    string `json:"type"`
+	Request string `json:"request"`
+}
+
+// Signatures are the indicators checked for each supported WAF.
+var Signatures = []Signature{
+	{Name: "Cloudflare", Indicators: []string{
+		"Server header: cloudflare",
+		"CF-Ray header present",
+		"cf_clearance cookie",
+		"__cfruid cookie (Bot Management)",
+		"Nameservers: *.ns.cloudflare.com",
+	}},
+	{Name: "AWS WAF", Indicators: []string{
+		"x-amzn-RequestId header",
+		"Server header patterns",
+		"AWS security headers",
+		"Response patterns",
+	}},
+	{Name: "ModSecurity", Indicators: []string{
+		"X-Mod-Security header",
+		"403/406 error patterns",
+		"Rule ID in response",
+	}},
+	{Name: "Akamai", Indicators: []string{
+		"AkamaiGHost in Server header",
+		"X-Akamai-* headers",
+		"Cookie patterns",
+	}},
+	{Name: "Imperva", Indicators: []string{
+		"X-Iinfo header",
+		"X-CDN: Imperva",
+		"Imperva response headers",
+	}},
+	{Name: "F5 BIG-IP", Indicators: []string{
+		"X-Forwarded-* headers",
+		"BigIP persistence cookies",
+		"Response header patterns",
+	}},
+	{Name: "Barracuda", Indicators: []string{
+		"Specific cookie patterns",
+		"Barracuda error pages",
+		"Header signatures",
+	}},
+	{Name: "Sucuri", Indicators: []string{
+		"X-Sucuri-Cache header",
+		"Sucuri error patterns",
+		"Blocking page indicators",
+	}},
+	{Name: "Wordfence", Indicators: []string{
+		"X-Wordfence-* headers",
+		"WordPress security",
+		"Plugin detection",
+	}},
+}
+
+// Options configures Analyze.
+type Options struct {
+	// TestXSS adds a reflected XSS probe to the payload list.
+	TestXSS bool
+	// TestSQLi adds a SQL injection probe to the payload list.
+	TestSQLi bool
+}
+
+// Analyze returns the WAF signatures, header targets and payloads used to
+// fingerprint the firewall in front of domain.
+func Analyze(ctx context.Context, domain string, opts Options) (*Result, error) {
+	if domain == "" {
+		return nil, fmt.Errorf("domain cannot be empty")
+	}
+
+	r := &Result{
+		Target:     domain,
+		Signatures: Signatures,
+		HeaderTargets: []string{
+			"Server", "X-Powered-By", "X-Frame-Options",
+			"X-Content-Type-Options", "Strict-Transport-Security",
+			"Content-Security-Policy", "X-XSS-Protection",
+		},
+		DetectionMethods: []string{
+			"Analyze HTTP response headers",
+			"Inspect Set-Cookie headers",
+			"Check error page content",
+			"Examine server behavior patterns",
+			"Test XSS/SQLi payload responses",
+		},
+	}
+
+	if opts.TestXSS {
+		r.Payloads = append(r.Payloads, Payload{Type: "xss", Request: "GET /?test=<script>alert('xss')</script>"})
+	}
+	if opts.TestSQLi {
+		r.Payloads = append(r.Payloads, Payload{Type: "sqli", Request: "GET /?id=1' OR '1'='1"})
+	}
+
+	return r, ctx.Err()
+}
@@ -0,0 +1,127 @@
+package whois
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached responses are used when
+// Cache.TTL is zero.
+const DefaultCacheTTL = time.Hour
+
+// Cache keeps raw WHOIS responses, so repeated lookups don't count
+// against the servers' rate limits. Responses are held in memory and,
+// when Dir is set, in one file per query. A nil *Cache caches nothing.
+type Cache struct {
+	// Dir holds the cached responses; empty keeps them in memory only.
+	Dir string
+	// TTL is how long a response is used. Zero means DefaultCacheTTL.
+	TTL time.Duration
+
+	mu  sync.Mutex
+	mem map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	raw     string
+	fetched time.Time
+}
+
+// NewCache returns a Cache storing responses under dir for ttl.
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{Dir: dir, TTL: ttl}
+}
+
+// DefaultCacheDir returns the afsa WHOIS cache directory in the user's
+// cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "afsa", "whois"), nil
+}
+
+// Get returns the response cached for query to server, if it is fresh.
+func (c *Cache) Get(server, query string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	key := cacheKey(server, query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.mem[key]; ok && c.fresh(e.fetched) {
+		return e.raw, true
+	}
+	if c.Dir == "" {
+		return "", false
+	}
+	path := filepath.Join(c.Dir, key)
+	info, err := os.Stat(path)
+	if err != nil || !c.fresh(info.ModTime()) {
+		return "", false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	c.remember(key, cacheEntry{raw: string(raw), fetched: info.ModTime()})
+	return string(raw), true
+}
+
+// Put stores the response to query from server.
+func (c *Cache) Put(server, query, raw string) error {
+	if c == nil {
+		return nil
+	}
+	key := cacheKey(server, query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remember(key, cacheEntry{raw: raw, fetched: time.Now()})
+	if c.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return err
+	}
+	// Write and rename, so a concurrent reader never sees half a file.
+	tmp, err := os.CreateTemp(c.Dir, key+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, key))
+}
+
+func (c *Cache) remember(key string, e cacheEntry) {
+	if c.mem == nil {
+		c.mem = map[string]cacheEntry{}
+	}
+	c.mem[key] = e
+}
+
+func (c *Cache) fresh(fetched time.Time) bool {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return time.Since(fetched) < ttl
+}
+
+// cacheKey names the cache entry for query to server.
+func cacheKey(server, query string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(withPort(server)) + "\x00" + query))
+	return hex.EncodeToString(sum[:16])
+}
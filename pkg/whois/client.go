@@ -0,0 +1,305 @@
+package whois
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultServer is where lookups start when Options.Server is empty.
+const DefaultServer = "whois.iana.org"
+
+// Port is the WHOIS port (RFC 3912).
+const Port = "43"
+
+// Defaults for the zero Options.
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultMaxReferrals     = 3
+	DefaultRateLimitRetries = 2
+	DefaultRateLimitDelay   = 5 * time.Second
+)
+
+// maxResponseSize bounds a single response; real ones are a few KiB.
+const maxResponseSize = 1 << 20
+
+// ErrRateLimited is returned, wrapped, when a server keeps refusing
+// queries because too many were sent.
+var ErrRateLimited = errors.New("rate limited")
+
+// Options configures WHOIS lookups.
+type Options struct {
+	// Server is where lookups start, as host or host:port. Empty means
+	// DefaultServer.
+	Server string
+	// Timeout bounds each query, from connecting to the end of the
+	// response. Zero means DefaultTimeout.
+	Timeout time.Duration
+	// MaxReferrals is the number of referrals followed after the first
+	// server; zero means DefaultMaxReferrals, negative means none.
+	MaxReferrals int
+	// RateLimitRetries is the number of times a rate-limited query is
+	// repeated; zero means DefaultRateLimitRetries, negative means none.
+	RateLimitRetries int
+	// RateLimitDelay is the wait before the first repeat, doubled for
+	// every further one. Zero means DefaultRateLimitDelay.
+	RateLimitDelay time.Duration
+	// Cache stores raw responses; nil disables caching.
+	Cache *Cache
+	// Dial overrides how connections are made, e.g. to send every query
+	// to a local fake server. It has the signature of
+	// net.Dialer.DialContext.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Client sends WHOIS queries.
+type Client struct {
+	server           string
+	timeout          time.Duration
+	maxReferrals     int
+	rateLimitRetries int
+	rateLimitDelay   time.Duration
+	cache            *Cache
+	dial             func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewClient returns a Client for opts, with zero fields defaulted.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		server:           opts.Server,
+		timeout:          opts.Timeout,
+		maxReferrals:     opts.MaxReferrals,
+		rateLimitRetries: opts.RateLimitRetries,
+		rateLimitDelay:   opts.RateLimitDelay,
+		cache:            opts.Cache,
+		dial:             opts.Dial,
+	}
+	if c.server == "" {
+		c.server = DefaultServer
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	switch {
+	case c.maxReferrals == 0:
+		c.maxReferrals = DefaultMaxReferrals
+	case c.maxReferrals < 0:
+		c.maxReferrals = 0
+	}
+	switch {
+	case c.rateLimitRetries == 0:
+		c.rateLimitRetries = DefaultRateLimitRetries
+	case c.rateLimitRetries < 0:
+		c.rateLimitRetries = 0
+	}
+	if c.rateLimitDelay <= 0 {
+		c.rateLimitDelay = DefaultRateLimitDelay
+	}
+	if c.dial == nil {
+		c.dial = (&net.Dialer{Timeout: c.timeout}).DialContext
+	}
+	return c
+}
+
+// Response is the answer of one WHOIS server.
+type Response struct {
+	Server string `json:"server"`
+	Query  string `json:"query"`
+	Raw    string `json:"raw"`
+	// Referral is the server this one points to for more specific
+	// records, if any.
+	Referral string `json:"referral,omitempty"`
+	// Cached is set when Raw came from the cache rather than the server.
+	Cached bool `json:"cached"`
+	// Retries counts the queries repeated after rate-limit replies.
+	Retries    int   `json:"retries"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Query sends query to server (host or host:port) and returns its
+// response, from the cache when it holds a fresh copy. Rate-limit replies
+// are retried with backoff; ErrRateLimited is returned when they persist.
+func (c *Client) Query(ctx context.Context, server, query string) (*Response, error) {
+	start := time.Now()
+	resp := &Response{Server: server, Query: query}
+	if raw, ok := c.cache.Get(server, query); ok {
+		resp.Raw, resp.Cached = raw, true
+		resp.Referral = referral(raw, server)
+		return resp, nil
+	}
+
+	delay := c.rateLimitDelay
+	for {
+		raw, err := c.exchange(ctx, server, query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", server, err)
+		}
+		if !rateLimited(raw) {
+			resp.Raw = raw
+			break
+		}
+		if resp.Retries >= c.rateLimitRetries {
+			return nil, fmt.Errorf("%s: %w: %s", server, ErrRateLimited, firstLine(raw))
+		}
+		resp.Retries++
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+
+	// A failed write only costs a later cache miss.
+	_ = c.cache.Put(server, query, resp.Raw)
+	resp.Referral = referral(resp.Raw, server)
+	resp.DurationMS = time.Since(start).Milliseconds()
+	return resp, nil
+}
+
+// exchange sends one query and reads the response until the server
+// closes the connection.
+func (c *Client) exchange(ctx context.Context, server, query string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := c.dial(ctx, "tcp", withPort(server))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	// Unblock reads when ctx is cancelled before the deadline.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", err
+	}
+	body, err := io.ReadAll(io.LimitReader(conn, maxResponseSize+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", err
+	}
+	if len(body) > maxResponseSize {
+		return "", fmt.Errorf("response larger than %d bytes", maxResponseSize)
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		return "", fmt.Errorf("empty response")
+	}
+	return decode(body), nil
+}
+
+// decode returns body as UTF-8. Most servers send UTF-8; the rest send
+// Latin-1, which maps byte for byte onto the first 256 code points.
+func decode(body []byte) string {
+	if utf8.Valid(body) {
+		return strings.ReplaceAll(string(body), "\r\n", "\n")
+	}
+	runes := make([]rune, len(body))
+	for i, b := range body {
+		runes[i] = rune(b)
+	}
+	return strings.ReplaceAll(string(runes), "\r\n", "\n")
+}
+
+// referralKeys are the fields registries use to name a more specific
+// server: IANA's refer and whois, the registrar server in gTLD registry
+// records, and ARIN's ReferralServer.
+var referralKeys = map[string]bool{
+	"refer":                  true,
+	"whois":                  true,
+	"whois server":           true,
+	"registrar whois server": true,
+	"referralserver":         true,
+}
+
+// referral returns the server raw refers to, or "" when it names none
+// or only itself. Referrals to other protocols, such as rwhois://
+// or web pages, are ignored.
+func referral(raw, from string) string {
+	sc := bufio.NewScanner(strings.NewReader(raw))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok || !referralKeys[strings.ToLower(strings.TrimSpace(key))] {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "whois://"), "/")
+		if value == "" || strings.Contains(value, "://") || strings.ContainsAny(value, " \t/") {
+			continue
+		}
+		if strings.EqualFold(hostOf(value), hostOf(from)) {
+			continue
+		}
+		return value
+	}
+	return ""
+}
+
+// rateLimitPhrases appear in the replies servers send instead of a
+// record once a client has queried too often.
+var rateLimitPhrases = []string{
+	"rate limit",
+	"limit exceeded",
+	"exceeded the maximum",
+	"too many",
+	"query rate",
+	"queries exceeded",
+	"try again later",
+	"please wait",
+	"temporarily denied",
+	"access denied",
+}
+
+// rateLimited reports whether raw is a rate-limit reply. Only short
+// replies are considered: full records quote terms of use that mention
+// limits too.
+func rateLimited(raw string) bool {
+	if len(raw) > 1024 {
+		return false
+	}
+	lower := strings.ToLower(raw)
+	for _, p := range rateLimitPhrases {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLine(raw string) string {
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "%#"))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// withPort adds the WHOIS port to server unless it has one.
+func withPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), Port)
+}
+
+// hostOf returns server without its port.
+func hostOf(server string) string {
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return server
+}
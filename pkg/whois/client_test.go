@@ -0,0 +1,193 @@
+package whois
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tanvircs/afsa/pkg/whois/whoistest"
+)
+
+func newServer(t *testing.T) *whoistest.Server {
+	t.Helper()
+	srv, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// servers returns the server of each response.
+func servers(r *Result) []string {
+	var s []string
+	for _, resp := range r.Responses {
+		s = append(s, resp.Server)
+	}
+	return s
+}
+
+func TestLookupReferralChain(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("whois.iana.org", "example.com", "domain: COM\nrefer: whois.verisign-grs.com\n")
+	srv.Handle("whois.verisign-grs.com", "domain example.com",
+		"Domain Name: EXAMPLE.COM\nRegistrar WHOIS Server: whois.markmonitor.com\nRegistrar: Registry View\n")
+	srv.Handle("whois.markmonitor.com", "example.com",
+		"Domain Name: example.com\nRegistrar: MarkMonitor, Inc.\nName Server: a.iana-servers.net\n")
+
+	r, err := Lookup(context.Background(), "Example.COM.", Options{Dial: srv.Dial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"whois.iana.org", "whois.verisign-grs.com", "whois.markmonitor.com"}
+	if got := servers(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("servers = %q, want %q", got, want)
+	}
+	if r.Server != "whois.markmonitor.com" || r.Type != TypeDomain || len(r.Errors) != 0 {
+		t.Errorf("Server = %q, Type = %q, Errors = %q", r.Server, r.Type, r.Errors)
+	}
+	if r.Record == nil || r.Record.Registrar != "MarkMonitor, Inc." {
+		t.Errorf("Record = %+v, want the registrar's", r.Record)
+	}
+	wantQueries := []whoistest.Query{
+		{Host: "whois.iana.org", Query: "example.com"},
+		{Host: "whois.verisign-grs.com", Query: "domain example.com"},
+		{Host: "whois.markmonitor.com", Query: "example.com"},
+	}
+	if got := srv.Queries(); !reflect.DeepEqual(got, wantQueries) {
+		t.Errorf("queries = %+v, want %+v", got, wantQueries)
+	}
+}
+
+func TestLookupReferralLoop(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("a.example", "192.0.2.1", "inetnum: 192.0.2.0 - 192.0.2.255\nReferralServer: whois://b.example\n")
+	srv.Handle("b.example", "192.0.2.1", "inetnum: 192.0.2.0 - 192.0.2.255\nReferralServer: whois://a.example\n")
+
+	r, err := Lookup(context.Background(), "192.0.2.1", Options{Server: "a.example", Dial: srv.Dial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := servers(r), []string{"a.example", "b.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("servers = %q, want %q", got, want)
+	}
+	if n := len(srv.Queries()); n != 2 {
+		t.Errorf("%d queries sent, want 2", n)
+	}
+}
+
+func TestLookupMaxReferrals(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("a.example", "AS64500", "refer: b.example\n")
+	srv.Handle("b.example", "AS64500", "refer: c.example\n")
+	srv.Handle("c.example", "AS64500", "aut-num: AS64500\n")
+
+	tests := []struct {
+		maxReferrals int
+		servers      []string
+		errors       int
+	}{
+		{maxReferrals: -1, servers: []string{"a.example"}, errors: 1},
+		{maxReferrals: 1, servers: []string{"a.example", "b.example"}, errors: 1},
+		{maxReferrals: 2, servers: []string{"a.example", "b.example", "c.example"}},
+	}
+	for _, tt := range tests {
+		r, err := Lookup(context.Background(), "as64500", Options{
+			Server:       "a.example",
+			MaxReferrals: tt.maxReferrals,
+			Dial:         srv.Dial,
+		})
+		if err != nil {
+			t.Fatalf("MaxReferrals %d: %v", tt.maxReferrals, err)
+		}
+		if got := servers(r); !reflect.DeepEqual(got, tt.servers) {
+			t.Errorf("MaxReferrals %d: servers = %q, want %q", tt.maxReferrals, got, tt.servers)
+		}
+		if len(r.Errors) != tt.errors {
+			t.Errorf("MaxReferrals %d: Errors = %q, want %d", tt.maxReferrals, r.Errors, tt.errors)
+		}
+		if tt.errors > 0 && !strings.Contains(r.Errors[0], "not followed") {
+			t.Errorf("MaxReferrals %d: Errors = %q, want a referral not followed", tt.maxReferrals, r.Errors)
+		}
+	}
+}
+
+func TestQueryRateLimitRetries(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("", "example.net", "Domain Name: EXAMPLE.NET\n")
+	c := NewClient(Options{Server: srv.Addr, RateLimitRetries: 2, RateLimitDelay: time.Millisecond})
+
+	srv.RateLimit("", 2)
+	resp, err := c.Query(context.Background(), srv.Addr, "example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Retries != 2 || !strings.Contains(resp.Raw, "EXAMPLE.NET") {
+		t.Errorf("Retries = %d, Raw = %q, want the record after 2 retries", resp.Retries, resp.Raw)
+	}
+
+	srv.RateLimit("", 3)
+	_, err = c.Query(context.Background(), srv.Addr, "example.net")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	if n := len(srv.Queries()); n != 3+3 {
+		t.Errorf("%d queries sent, want 6", n)
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("", "example.org", "Domain Name: EXAMPLE.ORG\n")
+	dir := t.TempDir()
+	c := NewClient(Options{Cache: NewCache(dir, time.Hour)})
+
+	first, err := c.Query(context.Background(), srv.Addr, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Query(context.Background(), srv.Addr, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || !second.Cached || second.Raw != first.Raw {
+		t.Errorf("Cached = %v then %v, want false then true with the same Raw", first.Cached, second.Cached)
+	}
+
+	// A fresh cache over the same directory reads the stored file.
+	c = NewClient(Options{Cache: NewCache(dir, time.Hour)})
+	third, err := c.Query(context.Background(), srv.Addr, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !third.Cached || third.Raw != first.Raw {
+		t.Errorf("Cached = %v, Raw = %q, want the stored response", third.Cached, third.Raw)
+	}
+	if n := len(srv.Queries()); n != 1 {
+		t.Errorf("%d queries sent, want 1", n)
+	}
+}
+
+func TestQueryLatin1(t *testing.T) {
+	srv := newServer(t)
+	srv.Handle("", "example.de", "owner: J\xfcrgen M\xfcller\r\nstreet: Stra\xdfe 1\r\n")
+	srv.Handle("", "example.fr", "owner: Jürgen Müller\r\n")
+	c := NewClient(Options{})
+
+	tests := map[string]string{
+		"example.de": "owner: Jürgen Müller\nstreet: Straße 1\n",
+		"example.fr": "owner: Jürgen Müller\n",
+	}
+	for query, want := range tests {
+		resp, err := c.Query(context.Background(), srv.Addr, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Raw != want {
+			t.Errorf("Query(%s).Raw = %q, want %q", query, resp.Raw, want)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package whois
+
+import (
+	"bufio"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Record is the normalized content of a WHOIS response. Domain records
+// come from registries and registrars in the ICANN format (Verisign,
+// PIR and the gTLD registrars) and from ccTLD formats such as DENIC's
+// and Nominet's. Network and AS number records come from the regional
+// internet registries: ARIN, RIPE, APNIC, LACNIC and AFRINIC.
+type Record struct {
+	Domain          string   `json:"domain,omitempty"`
+	Registrar       string   `json:"registrar,omitempty"`
+	RegistrarIANAID string   `json:"registrar_iana_id,omitempty"`
+	RegistrarURL    string   `json:"registrar_url,omitempty"`
+	WHOISServer     string   `json:"whois_server,omitempty"`
+	Nameservers     []string `json:"nameservers"`
+	DNSSEC          bool     `json:"dnssec"`
+
+	// Network is the address range as published, "first - last" or a
+	// CIDR block; CIDRs lists the blocks covering it.
+	Network string   `json:"network,omitempty"`
+	CIDRs   []string `json:"cidrs"`
+	NetName string   `json:"net_name,omitempty"`
+	// ASN is the AS number of an aut-num record, or the origin AS of a
+	// network.
+	ASN    string `json:"asn,omitempty"`
+	ASName string `json:"as_name,omitempty"`
+	// Parent is the enclosing network, as the registry names it.
+	Parent string `json:"parent,omitempty"`
+
+	Handle     string     `json:"handle,omitempty"`
+	Status     []string   `json:"status"`
+	Country    string     `json:"country,omitempty"`
+	Registrant *Contact   `json:"registrant,omitempty"`
+	Abuse      *Contact   `json:"abuse,omitempty"`
+	Created    *time.Time `json:"created,omitempty"`
+	Updated    *time.Time `json:"updated,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"`
+}
+
+// Contact is a person or organization named in a record.
+type Contact struct {
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Address      string `json:"address,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+// Parse reads a raw WHOIS response into a Record. Fields the response
+// doesn't have are left empty; Parse never fails.
+func Parse(raw string) *Record {
+	objs, comments := splitObjects(raw)
+	r := &Record{Nameservers: []string{}, CIDRs: []string{}, Status: []string{}}
+	var registrant, abuse Contact
+	if net := networkObject(objs); net != nil {
+		registrant, abuse = r.fillNetwork(net, objs)
+	} else if as := objectWith(objs, "aut-num", "asnumber"); as != nil && as.get("domain name", "domain") == "" {
+		registrant, abuse = r.fillAutNum(as, objs)
+	} else {
+		registrant, abuse = r.fillDomain(flatten(objs))
+	}
+	for _, c := range comments {
+		// RIPE and APNIC: % Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'
+		if m := abuseComment.FindStringSubmatch(c); m != nil && abuse.Email == "" {
+			abuse.Email = m[1]
+		}
+	}
+	if registrant != (Contact{}) {
+		r.Registrant = &registrant
+	}
+	if abuse != (Contact{}) {
+		r.Abuse = &abuse
+	}
+	return r
+}
+
+// parseResponses parses the responses along a referral chain into one
+// Record. The most specific server's answer wins; a registrar's record
+// is completed with the registry's, which alone may carry the expiry
+// date or DNSSEC. IANA's referral answer is only used when nothing else
+// answered.
+func parseResponses(responses []Response) *Record {
+	var rec *Record
+	for i := len(responses) - 1; i >= 0; i-- {
+		if i > 0 || len(responses) > 1 {
+			if strings.EqualFold(hostOf(responses[i].Server), DefaultServer) {
+				continue
+			}
+		}
+		r := Parse(responses[i].Raw)
+		if rec == nil {
+			rec = r
+		} else {
+			rec.merge(r)
+		}
+	}
+	return rec
+}
+
+// merge fills the fields of r that are empty from o.
+func (r *Record) merge(o *Record) {
+	fill(&r.Domain, o.Domain)
+	fill(&r.Registrar, o.Registrar)
+	fill(&r.RegistrarIANAID, o.RegistrarIANAID)
+	fill(&r.RegistrarURL, o.RegistrarURL)
+	fill(&r.WHOISServer, o.WHOISServer)
+	fill(&r.Network, o.Network)
+	fill(&r.NetName, o.NetName)
+	fill(&r.ASN, o.ASN)
+	fill(&r.ASName, o.ASName)
+	fill(&r.Parent, o.Parent)
+	fill(&r.Handle, o.Handle)
+	fill(&r.Country, o.Country)
+	if len(r.Nameservers) == 0 {
+		r.Nameservers = o.Nameservers
+	}
+	if len(r.CIDRs) == 0 {
+		r.CIDRs = o.CIDRs
+	}
+	if len(r.Status) == 0 {
+		r.Status = o.Status
+	}
+	r.DNSSEC = r.DNSSEC || o.DNSSEC
+	if r.Registrant == nil {
+		r.Registrant = o.Registrant
+	}
+	if r.Abuse == nil {
+		r.Abuse = o.Abuse
+	}
+	if r.Created == nil {
+		r.Created = o.Created
+	}
+	if r.Updated == nil {
+		r.Updated = o.Updated
+	}
+	if r.Expires == nil {
+		r.Expires = o.Expires
+	}
+}
+
+func fill(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+var abuseComment = regexp.MustCompile(`(?i)abuse contact for .* is '([^']+@[^']+)'`)
+
+func (r *Record) fillDomain(o object) (registrant, abuse Contact) {
+	r.Domain = strings.ToLower(strings.TrimSuffix(o.get("domain name", "domain"), "."))
+	r.Registrar = o.get("registrar", "sponsoring registrar", "registrar name")
+	if strings.HasPrefix(strings.ToLower(r.Registrar), "no registrar listed") {
+		r.Registrar = ""
+	}
+	r.RegistrarIANAID = o.get("registrar iana id")
+	r.RegistrarURL = o.get("registrar url", "url")
+	r.WHOISServer = o.get("registrar whois server", "whois server")
+	for _, scheme := range []string{"http://", "https://", "whois://"} {
+		r.WHOISServer = strings.TrimPrefix(r.WHOISServer, scheme)
+	}
+	r.Handle = o.get("registry domain id")
+	for _, ns := range o.all("name server", "nserver", "name servers", "nameservers", "nameserver") {
+		if f := strings.Fields(ns); len(f) > 0 {
+			r.Nameservers = appendUnique(r.Nameservers, strings.ToLower(strings.TrimSuffix(f[0], ".")))
+		}
+	}
+	for _, s := range o.all("domain status", "status", "registration status") {
+		// ICANN: clientTransferProhibited https://icann.org/epp#clientTransferProhibited,
+		// or with the URL in parentheses.
+		if i := strings.Index(s, "http"); i > 0 {
+			s = strings.TrimRight(s[:i], " (")
+		}
+		if s = strings.TrimSpace(s); s != "" && !strings.HasPrefix(strings.ToLower(s), "no registration status") {
+			r.Status = appendUnique(r.Status, s)
+		}
+	}
+	dnssec := strings.ToLower(o.get("dnssec"))
+	r.DNSSEC = (strings.Contains(dnssec, "signed") && !strings.Contains(dnssec, "unsigned")) || dnssec == "yes" || o.get("dnskey", "ds-rdata") != ""
+	r.Created = parseDate(o.get("creation date", "created", "registered on", "registered", "created on", "registration time"))
+	r.Updated = parseDate(o.get("updated date", "last updated", "changed", "last-modified", "last modified", "updated"))
+	r.Expires = parseDate(o.get("registry expiry date", "registrar registration expiration date", "expiry date", "expiration date", "expires", "expire date", "paid-till"))
+
+	disclosed := func(key ...string) string { return undisclosed(o.get(key...)) }
+	registrant = Contact{
+		Name:         disclosed("registrant name", "registrant"),
+		Organization: disclosed("registrant organization", "registrant organisation"),
+		Email:        disclosed("registrant email"),
+		Phone:        disclosed("registrant phone"),
+		Address:      joinNonEmpty(disclosed("registrant street"), disclosed("registrant city"), disclosed("registrant state/province"), disclosed("registrant postal code")),
+		Country:      disclosed("registrant country"),
+	}
+	r.Country = registrant.Country
+	abuse = Contact{Email: o.get("registrar abuse contact email"), Phone: o.get("registrar abuse contact phone")}
+	return registrant, abuse
+}
+
+// redactionPhrases mark contact values withheld under privacy rules.
+var redactionPhrases = []string{"redacted", "select request email form", "please query the rdds", "not disclosed", "data protected", "withheld", "privacy"}
+
+// undisclosed returns v, or "" when it is a redaction notice rather
+// than data.
+func undisclosed(v string) string {
+	lower := strings.ToLower(v)
+	for _, p := range redactionPhrases {
+		if strings.Contains(lower, p) {
+			return ""
+		}
+	}
+	return v
+}
+
+func (r *Record) fillNetwork(net object, objs []object) (registrant, abuse Contact) {
+	r.Network = net.get("inetnum", "inet6num", "netrange")
+	for _, c := range net.all("cidr") {
+		for _, p := range strings.Split(c, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				r.CIDRs = append(r.CIDRs, p)
+			}
+		}
+	}
+	if len(r.CIDRs) == 0 {
+		if prefix, err := netip.ParsePrefix(r.Network); err == nil {
+			r.CIDRs = append(r.CIDRs, prefix.String())
+		} else if first, last, ok := strings.Cut(r.Network, "-"); ok {
+			r.CIDRs = append(r.CIDRs, RangeCIDRs(strings.TrimSpace(first), strings.TrimSpace(last))...)
+		}
+	}
+	r.NetName = net.get("netname")
+	r.Handle = net.get("nethandle", "inetnum", "inet6num")
+	r.Parent = net.get("parent")
+	for _, s := range net.all("status", "nettype") {
+		r.Status = appendUnique(r.Status, s)
+	}
+	r.ASN = net.get("originas", "aut-num")
+	if route := objectWith(objs, "route", "route6"); route != nil && r.ASN == "" {
+		r.ASN = route.get("origin")
+	}
+	if strings.EqualFold(r.ASN, "N/A") {
+		r.ASN = ""
+	}
+	r.Created = parseDate(net.get("regdate", "created"))
+	r.Updated = parseDate(net.get("last-modified", "updated", "changed"))
+	return r.fillOwner(net, objs)
+}
+
+func (r *Record) fillAutNum(as object, objs []object) (registrant, abuse Contact) {
+	r.ASN = as.get("aut-num", "asnumber")
+	if !strings.HasPrefix(strings.ToUpper(r.ASN), "AS") {
+		r.ASN = "AS" + r.ASN
+	}
+	r.ASName = as.get("as-name", "asname")
+	r.Handle = as.get("ashandle", "aut-num")
+	for _, s := range as.all("status") {
+		r.Status = appendUnique(r.Status, s)
+	}
+	r.Created = parseDate(as.get("regdate", "created"))
+	r.Updated = parseDate(as.get("last-modified", "updated", "changed"))
+	return r.fillOwner(as, objs)
+}
+
+// fillOwner reads the registrant and abuse contacts of a network or AS
+// number: the organisation object RIPE, APNIC and AFRINIC link with org:
+// and ARIN lists after the network, or LACNIC's owner fields. The abuse
+// contact is ARIN's OrgAbuse fields or the object abuse-c names.
+func (r *Record) fillOwner(main object, objs []object) (registrant, abuse Contact) {
+	org := main
+	if o := objectWith(objs, "organisation", "orgname", "org-name"); o != nil {
+		org = o
+	}
+	registrant = Contact{
+		Organization: org.get("org-name", "orgname", "owner"),
+		Email:        org.get("e-mail"),
+		Phone:        org.get("phone"),
+		Address:      joinNonEmpty(append(org.all("address"), org.get("city"), org.get("stateprov"), org.get("postalcode"))...),
+		Country:      org.get("country"),
+	}
+	if registrant.Organization == "" {
+		registrant.Organization = main.get("descr")
+	}
+	registrant.Name = main.get("responsible")
+	r.Country = main.get("country")
+	if r.Country == "" {
+		r.Country = registrant.Country
+	}
+
+	all := flatten(objs)
+	abuse = Contact{
+		Name:  all.get("orgabusename"),
+		Email: all.get("orgabuseemail", "abuse-mailbox"),
+		Phone: all.get("orgabusephone"),
+	}
+	if h := main.get("abuse-c"); h != "" && abuse.Email == "" {
+		for _, o := range objs {
+			if strings.EqualFold(o.get("nic-hdl", "nic-hdl-br", "irt"), h) {
+				abuse.Name = o.get("person", "role", "irt")
+				abuse.Email = o.get("abuse-mailbox", "e-mail")
+				abuse.Phone = o.get("phone")
+			}
+		}
+	}
+	return registrant, abuse
+}
+
+// object is one block of "key: value" lines. Keys are lower case with
+// runs of spaces collapsed.
+type object []pair
+
+type pair struct {
+	key, value string
+}
+
+// get returns the first non-empty value of the first key present.
+func (o object) get(keys ...string) string {
+	for _, k := range keys {
+		for _, p := range o {
+			if p.key == k && p.value != "" {
+				return p.value
+			}
+		}
+	}
+	return ""
+}
+
+// all returns the non-empty values of keys, in order.
+func (o object) all(keys ...string) []string {
+	var values []string
+	for _, p := range o {
+		for _, k := range keys {
+			if p.key == k && p.value != "" {
+				values = append(values, p.value)
+			}
+		}
+	}
+	return values
+}
+
+func (o object) has(key string) bool {
+	for _, p := range o {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+var keyLine = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9 /()._-]{0,60}?):(?:\s+(.*))?$`)
+
+// splitObjects reads raw into blank-line separated objects and the
+// comment lines starting with % or #. A key with no value on its line,
+// as in Nominet's output, takes the indented lines below it as values.
+func splitObjects(raw string) ([]object, []string) {
+	var objs []object
+	var comments []string
+	var cur object
+	block, blockIndent := "", 0
+	flush := func() {
+		if len(cur) > 0 {
+			objs = append(objs, cur)
+		}
+		cur = nil
+	}
+	sc := bufio.NewScanner(strings.NewReader(raw))
+	sc.Buffer(make([]byte, 64*1024), maxResponseSize)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		switch {
+		case trimmed == "":
+			if block == "" {
+				flush()
+			}
+			continue
+		case strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "#"):
+			comments = append(comments, trimmed)
+			continue
+		case strings.HasPrefix(trimmed, ">>>") || strings.HasPrefix(trimmed, "--"):
+			block = ""
+			continue
+		}
+		if block != "" && indent <= blockIndent {
+			block = ""
+			flush()
+		}
+		if m := keyLine.FindStringSubmatch(trimmed); m != nil {
+			key := strings.ToLower(strings.Join(strings.Fields(m[1]), " "))
+			value := strings.TrimSpace(m[2])
+			cur = append(cur, pair{key: key, value: value})
+			if value == "" && block == "" {
+				block, blockIndent = key, indent
+			}
+			continue
+		}
+		switch {
+		case block != "":
+			cur = append(cur, pair{key: block, value: trimmed})
+		case len(cur) > 0 && indent > 0:
+			// An RPSL continuation line.
+			cur = append(cur, pair{key: cur[len(cur)-1].key, value: trimmed})
+		}
+	}
+	flush()
+	return objs, comments
+}
+
+func flatten(objs []object) object {
+	var all object
+	for _, o := range objs {
+		all = append(all, o...)
+	}
+	return all
+}
+
+// objectWith returns the first object with any of keys.
+func objectWith(objs []object, keys ...string) object {
+	for _, o := range objs {
+		for _, k := range keys {
+			if o.has(k) {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// networkObject returns the most specific network object: ARIN lists
+// every network covering the address, the largest first.
+func networkObject(objs []object) object {
+	var best object
+	bestSize := -1
+	for _, o := range objs {
+		rng := o.get("inetnum", "inet6num", "netrange")
+		if rng == "" {
+			continue
+		}
+		size := rangeBits(rng)
+		if best == nil || (size >= 0 && (bestSize < 0 || size < bestSize)) {
+			best, bestSize = o, size
+		}
+	}
+	return best
+}
+
+// rangeBits returns the number of host bits of a range, roughly, or -1
+// when it can't be read.
+func rangeBits(rng string) int {
+	if prefix, err := netip.ParsePrefix(strings.TrimSpace(rng)); err == nil {
+		return prefix.Addr().BitLen() - prefix.Bits()
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return -1
+	}
+	cidrs := RangeCIDRs(strings.TrimSpace(first), strings.TrimSpace(last))
+	if len(cidrs) == 0 {
+		return -1
+	}
+	p := netip.MustParsePrefix(cidrs[0])
+	return p.Addr().BitLen() - p.Bits()
+}
+
+// RangeCIDRs returns the fewest CIDR blocks covering the addresses from
+// first to last, or nil if they don't form a range.
+func RangeCIDRs(first, last string) []string {
+	start, err1 := netip.ParseAddr(first)
+	end, err2 := netip.ParseAddr(last)
+	if err1 != nil || err2 != nil || start.BitLen() != end.BitLen() || start.Compare(end) > 0 {
+		return nil
+	}
+	var cidrs []string
+	for start.IsValid() && start.Compare(end) <= 0 && len(cidrs) < 128 {
+		bits := start.BitLen()
+		for bits > 0 {
+			p := netip.PrefixFrom(start, bits-1)
+			if p.Masked().Addr() != start || lastAddr(p).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		p := netip.PrefixFrom(start, bits)
+		cidrs = append(cidrs, p.String())
+		start = lastAddr(p).Next()
+	}
+	return cidrs
+}
+
+// lastAddr returns the highest address in p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As16()
+	offset := 128 - p.Addr().BitLen()
+	for i := offset + p.Bits(); i < 128; i++ {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+	a := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		return a.Unmap()
+	}
+	return a
+}
+
+// dateLayouts are the date formats registries use, most common first.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02-Jan-2006",
+	"02-Jan-2006 15:04:05",
+	"2 January 2006",
+	"2006/01/02",
+	"2006.01.02",
+	"02.01.2006",
+	"20060102",
+}
+
+// parseDate parses a WHOIS date in UTC, or returns nil. A date followed
+// by a comment, as in "2024-01-01 (YYYY-MM-DD)", is read too.
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	candidates := []string{s}
+	if f := strings.Fields(s); len(f) > 1 {
+		candidates = append(candidates, f[0])
+	}
+	for _, c := range candidates {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, c); err == nil {
+				t = t.UTC()
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+func joinNonEmpty(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return list
+		}
+	}
+	return append(list, v)
+}
@@ -0,0 +1,164 @@
+package whois
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		file        string
+		registrar   string
+		nameservers []string
+		status      []string
+		cidrs       []string
+		asn         string
+		created     string
+		updated     string
+		expires     string
+	}{
+		{
+			file:   "afrinic-196.216.2.1.txt",
+			status: []string{"ASSIGNED PI"},
+			cidrs:  []string{"196.216.2.0/23"},
+			asn:    "AS37177",
+		},
+		{
+			file:    "apnic-1.1.1.1.txt",
+			status:  []string{"ASSIGNED PORTABLE"},
+			cidrs:   []string{"1.1.1.0/24"},
+			asn:     "AS13335",
+			updated: "2023-04-26T22:57:58Z",
+		},
+		{
+			file:    "arin-8.8.8.8.txt",
+			status:  []string{"Direct Allocation"},
+			cidrs:   []string{"8.8.8.0/24"},
+			asn:     "AS15169",
+			created: "2023-12-28T00:00:00Z",
+			updated: "2023-12-28T00:00:00Z",
+		},
+		{
+			file:    "arin-AS15169.txt",
+			asn:     "AS15169",
+			created: "2000-03-30T00:00:00Z",
+			updated: "2012-02-24T00:00:00Z",
+		},
+		{
+			file:        "denic-denic.de.txt",
+			nameservers: []string{"ns1.denic.de", "ns2.denic.net", "ns3.denic.de", "ns4.denic.net"},
+			status:      []string{"connect"},
+			updated:     "2018-03-12T20:44:25Z",
+		},
+		{
+			file:    "lacnic-200.160.2.3.txt",
+			cidrs:   []string{"200.160.0.0/20"},
+			asn:     "AS22548",
+			created: "2000-03-03T00:00:00Z",
+			updated: "2023-11-07T00:00:00Z",
+		},
+		{
+			file:        "nominet-nominet.uk.txt",
+			nameservers: []string{"dns1.nic.uk", "dns2.nic.uk", "dns3.nic.uk", "dns4.nic.uk"},
+			status:      []string{"Registered until expiry date."},
+			created:     "2014-06-10T00:00:00Z",
+			updated:     "2024-05-09T00:00:00Z",
+			expires:     "2026-06-10T00:00:00Z",
+		},
+		{
+			file:        "pir-wikipedia.org.txt",
+			registrar:   "MarkMonitor Inc.",
+			nameservers: []string{"ns0.wikimedia.org", "ns1.wikimedia.org", "ns2.wikimedia.org"},
+			status: []string{
+				"clientDeleteProhibited", "clientTransferProhibited", "clientUpdateProhibited",
+				"serverDeleteProhibited", "serverTransferProhibited", "serverUpdateProhibited",
+			},
+			created: "2001-01-13T00:12:14Z",
+			updated: "2024-01-08T19:35:34Z",
+			expires: "2025-01-13T00:12:14Z",
+		},
+		{
+			file:        "registrar-markmonitor-google.com.txt",
+			registrar:   "MarkMonitor, Inc.",
+			nameservers: []string{"ns1.google.com", "ns4.google.com", "ns3.google.com", "ns2.google.com"},
+			status: []string{
+				"clientUpdateProhibited", "clientTransferProhibited", "clientDeleteProhibited",
+				"serverUpdateProhibited", "serverTransferProhibited", "serverDeleteProhibited",
+			},
+			created: "1997-09-15T07:00:00Z",
+			updated: "2024-08-02T02:17:33Z",
+			expires: "2028-09-13T07:00:00Z",
+		},
+		{
+			file:    "ripe-193.0.6.139.txt",
+			status:  []string{"ASSIGNED PA"},
+			cidrs:   []string{"193.0.0.0/21"},
+			asn:     "AS3333",
+			created: "2003-03-17T12:15:57Z",
+			updated: "2017-12-04T14:42:31Z",
+		},
+		{
+			file:    "ripe-AS3333.txt",
+			status:  []string{"ASSIGNED"},
+			asn:     "AS3333",
+			created: "2002-08-06T12:34:46Z",
+			updated: "2022-12-01T17:21:49Z",
+		},
+		{
+			file:        "verisign-example.com.txt",
+			registrar:   "RESERVED-Internet Assigned Numbers Authority",
+			nameservers: []string{"a.iana-servers.net", "b.iana-servers.net"},
+			status:      []string{"clientDeleteProhibited", "clientTransferProhibited", "clientUpdateProhibited"},
+			created:     "1995-08-14T04:00:00Z",
+			updated:     "2024-08-14T07:01:34Z",
+			expires:     "2025-08-13T04:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := Parse(string(raw))
+			if r.Registrar != tt.registrar {
+				t.Errorf("Registrar = %q, want %q", r.Registrar, tt.registrar)
+			}
+			checkList(t, "Nameservers", r.Nameservers, tt.nameservers)
+			checkList(t, "Status", r.Status, tt.status)
+			checkList(t, "CIDRs", r.CIDRs, tt.cidrs)
+			if r.ASN != tt.asn {
+				t.Errorf("ASN = %q, want %q", r.ASN, tt.asn)
+			}
+			checkDate(t, "Created", r.Created, tt.created)
+			checkDate(t, "Updated", r.Updated, tt.updated)
+			checkDate(t, "Expires", r.Expires, tt.expires)
+		})
+	}
+}
+
+func checkList(t *testing.T, field string, got, want []string) {
+	t.Helper()
+	if want == nil {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %q, want %q", field, got, want)
+	}
+}
+
+func checkDate(t *testing.T, field string, got *time.Time, want string) {
+	t.Helper()
+	switch {
+	case got == nil && want == "":
+	case got == nil:
+		t.Errorf("%s = nil, want %s", field, want)
+	case want == "":
+		t.Errorf("%s = %s, want nil", field, got.UTC().Format(time.RFC3339))
+	case got.UTC().Format(time.RFC3339) != want:
+		t.Errorf("%s = %s, want %s", field, got.UTC().Format(time.RFC3339), want)
+	}
+}
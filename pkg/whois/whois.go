@@ -0,0 +1,136 @@
+// Package whois looks up registration and ownership details for domains,
+// IP addresses and autonomous systems over the WHOIS protocol (RFC 3912).
+package whois
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Target types.
+const (
+	TypeDomain = "domain"
+	TypeIP     = "ip"
+	TypeASN    = "asn"
+)
+
+// Result is the outcome of a WHOIS lookup for a domain, IP address or
+// AS number.
+type Result struct {
+	Target string `json:"target"`
+	// Type is TypeDomain, TypeIP or TypeASN.
+	Type string `json:"type"`
+	// Server is the most specific server that answered: the registrar
+	// for a domain, the RIR for an address.
+	Server string `json:"server"`
+	// Responses are the answers of each server along the referral
+	// chain, starting with the first one asked.
+	Responses []Response `json:"responses"`
+	// Record is the parsed content of the responses, the most specific
+	// server's first; nil when none could be read.
+	Record *Record  `json:"record,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// Raw returns the response of the most specific server that answered.
+func (r *Result) Raw() string {
+	if len(r.Responses) == 0 {
+		return ""
+	}
+	return r.Responses[len(r.Responses)-1].Raw
+}
+
+// Lookup returns the WHOIS records for target, which may be a domain
+// name, an IP address or an AS number such as AS15169. It asks
+// opts.Server (whois.iana.org by default) and follows the referrals to
+// the registry and then the registrar. A server failing further down the
+// chain is recorded in Errors; Lookup only fails when no server answered.
+func Lookup(ctx context.Context, target string, opts Options) (*Result, error) {
+	return NewClient(opts).Lookup(ctx, target)
+}
+
+// Lookup is the package-level Lookup using c's settings.
+func (c *Client) Lookup(ctx context.Context, target string) (*Result, error) {
+	target, kind, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Target: target, Type: kind, Responses: []Response{}, Errors: []string{}}
+
+	server := c.server
+	visited := map[string]bool{}
+	for hop := 0; server != "" && !visited[strings.ToLower(server)]; hop++ {
+		if hop > c.maxReferrals {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: not followed, more than %d referrals", server, c.maxReferrals))
+			break
+		}
+		visited[strings.ToLower(server)] = true
+		resp, err := c.Query(ctx, server, queryFor(server, target, kind))
+		if err != nil {
+			if ctx.Err() != nil {
+				return r, ctx.Err()
+			}
+			r.Errors = append(r.Errors, err.Error())
+			break
+		}
+		r.Responses = append(r.Responses, *resp)
+		r.Server = resp.Server
+		server = resp.Referral
+	}
+	if len(r.Responses) == 0 {
+		return r, fmt.Errorf("no WHOIS server answered for %s: %s", target, strings.Join(r.Errors, "; "))
+	}
+	r.Record = parseResponses(r.Responses)
+	return r, nil
+}
+
+// ParseTarget normalizes target and tells which kind of object it names.
+func ParseTarget(target string) (string, string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", "", fmt.Errorf("target cannot be empty")
+	}
+	if strings.ContainsAny(target, " \t\r\n") {
+		return "", "", fmt.Errorf("invalid target %q", target)
+	}
+	if addr, err := netip.ParseAddr(target); err == nil {
+		return addr.String(), TypeIP, nil
+	}
+	if prefix, err := netip.ParsePrefix(target); err == nil {
+		return prefix.Masked().String(), TypeIP, nil
+	}
+	if len(target) > 2 && strings.EqualFold(target[:2], "as") {
+		if n, err := strconv.ParseUint(target[2:], 10, 32); err == nil {
+			return "AS" + strconv.FormatUint(n, 10), TypeASN, nil
+		}
+	}
+	domain := strings.ToLower(strings.TrimSuffix(target, "."))
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 {
+			return "", "", fmt.Errorf("invalid domain %q", target)
+		}
+	}
+	return domain, TypeDomain, nil
+}
+
+// queryFor returns the query to send server for target. Most servers
+// take the bare target; a few need flags to return the object itself
+// rather than a list of matches or an abbreviated record.
+func queryFor(server, target, kind string) string {
+	switch host := strings.ToLower(hostOf(server)); {
+	case host == "whois.verisign-grs.com" && kind == TypeDomain:
+		// Without the keyword, names of nameserver hosts match too.
+		return "domain " + target
+	case host == "whois.denic.de" && kind == TypeDomain:
+		return "-T dn,ace " + target
+	case host == "whois.arin.net" && kind == TypeIP:
+		// n + returns the network with its organization and contacts.
+		return "n + " + target
+	case host == "whois.arin.net" && kind == TypeASN:
+		return "a + " + strings.TrimPrefix(target, "AS")
+	}
+	return target
+}
@@ -0,0 +1,148 @@
+// Package whoistest runs fake WHOIS servers, in the manner of
+// net/http/httptest, so lookups can be exercised without the network.
+package whoistest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitReply is what a rate-limited host answers.
+const RateLimitReply = "% Query rate limit exceeded. Please wait before querying again.\n"
+
+// Server answers WHOIS queries for any number of hosts from canned
+// responses. Point a lookup at Addr to ask it as a single server, or use
+// Dial as whois.Options.Dial to have it stand in for every host, so
+// referrals stay local.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	ln        net.Listener
+	mu        sync.Mutex
+	responses map[string]map[string]string
+	limited   map[string]int
+	queries   []Query
+	wg        sync.WaitGroup
+}
+
+// Query is a query the server received.
+type Query struct {
+	Host  string
+	Query string
+}
+
+// NewServer starts a Server on a loopback port. Close it when done.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:      ln.Addr().String(),
+		ln:        ln,
+		responses: map[string]map[string]string{},
+		limited:   map[string]int{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Handle sets the response host gives to query. Queries to Addr are
+// answered as host "". Queries without a response get a "No match"
+// reply.
+func (s *Server) Handle(host, query, response string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host = strings.ToLower(host)
+	if s.responses[host] == nil {
+		s.responses[host] = map[string]string{}
+	}
+	s.responses[host][query] = response
+}
+
+// RateLimit makes host answer its next n queries with RateLimitReply.
+func (s *Server) RateLimit(host string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limited[strings.ToLower(host)] = n
+}
+
+// Queries returns the queries received so far, in order.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// Dial connects to the fake host named by address, whatever it is. It
+// has the signature of net.Dialer.DialContext.
+func (s *Server) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	client, server := net.Pipe()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.handle(server, host)
+	}()
+	return client, nil
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn, "")
+		}()
+	}
+}
+
+// handle answers one query on conn as host, then closes it.
+func (s *Server) handle(conn net.Conn, host string) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	query := strings.TrimRight(line, "\r\n")
+	_, _ = conn.Write([]byte(s.reply(strings.ToLower(host), query)))
+}
+
+func (s *Server) reply(host, query string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, Query{Host: host, Query: query})
+	if s.limited[host] > 0 {
+		s.limited[host]--
+		return RateLimitReply
+	}
+	if resp, ok := s.responses[host][query]; ok {
+		return resp
+	}
+	return "No match for \"" + query + "\".\n"
+}